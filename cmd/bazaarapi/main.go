@@ -16,10 +16,12 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
 	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/cf-platform-eng/kibosh/pkg/repository"
 	"github.com/sirupsen/logrus"
@@ -35,9 +37,46 @@ func main() {
 		bazaarLogger.Fatal("Loading config file", err)
 	}
 
-	repo := repository.NewRepository(conf.HelmChartDir, conf.RegistryConfig.Server, bazaarLogger)
-	bazaarAPI := bazaar.NewAPI(repo, conf.KiboshConfig, bazaarLogger)
+	retainedVersions := conf.RetainedVersions
+	if retainedVersions == 0 {
+		// CHART_VERSIONS_RETAINED=0 keeps every version
+		retainedVersions = -1
+	}
+	repo := repository.NewRepository(
+		conf.HelmChartDir, repository.Options{Registries: conf.RegistryConfig, RetainedVersions: retainedVersions}, bazaarLogger,
+	)
+	var verifier *bazaar.ProvenanceVerifier
+	if conf.ProvenanceKeyring != "" {
+		verifier, err = bazaar.NewProvenanceVerifier(conf.ProvenanceKeyring, conf.ProvenanceRequired)
+		if err != nil {
+			bazaarLogger.Fatal("Loading provenance keyring", err)
+		}
+	}
+	kiboshClient, err := conf.KiboshConfig.HTTPClient()
+	if err != nil {
+		bazaarLogger.Fatal("Loading kibosh client tls config", err)
+	}
+	imageChecker, err := docker.NewConfiguredImageChecker(conf.RegistryConfig)
+	if err != nil {
+		bazaarLogger.Fatal("Unable to check images", err)
+	}
+	bazaarAPI := bazaar.NewAPI(repo, conf.KiboshConfig, bazaar.APIOptions{
+		KiboshClient: kiboshClient,
+		Uploads:      conf.UploadConfig,
+		Verifier:     verifier,
+		ImageChecker: imageChecker,
+		Registries:   conf.RegistryConfig,
+	}, bazaarLogger)
 	authFilter := httphelpers.NewAuthFilter(conf.AdminUsername, conf.AdminPassword)
+	if conf.TokenConfig.Enabled() {
+		verifier, err := conf.TokenConfig.Verifier()
+		if err != nil {
+			bazaarLogger.Fatal("Loading token verification key", err)
+		}
+		authFilter = httphelpers.NewTokenAuthFilter(
+			conf.AdminUsername, conf.AdminPassword, verifier, conf.TokenConfig.RoleScopes(),
+		)
+	}
 
 	// When registering *only* the trailing slash, for the non-trailing slash url,
 	// ServeMux returns a 301 (not 307), so client flips to GET
@@ -47,8 +86,33 @@ func main() {
 	http.Handle("/charts/", authFilter.Filter(
 		bazaarAPI.Charts(),
 	))
+	http.Handle("/quarantined_charts", authFilter.Filter(
+		bazaarAPI.QuarantinedCharts(),
+	))
+	http.Handle("/uploads", authFilter.Filter(
+		bazaarAPI.Uploads(),
+	))
+	http.Handle("/uploads/", authFilter.Filter(
+		bazaarAPI.Uploads(),
+	))
+	http.Handle("/instances", authFilter.Filter(
+		bazaarAPI.Instances(),
+	))
+	http.Handle("/instances/", authFilter.Filter(
+		bazaarAPI.Instances(),
+	))
+
+	var tlsConfig *tls.Config
+	if conf.ServerTLSConfig.HasServerTLS() {
+		tlsConfig, err = httphelpers.ServerTLSConfig(
+			conf.ServerTLSConfig.CertFile, conf.ServerTLSConfig.KeyFile, conf.ServerTLSConfig.ClientCAFile, true,
+		)
+		if err != nil {
+			bazaarLogger.Fatal("Loading tls config", err)
+		}
+	}
 
 	bazaarLogger.Info(fmt.Sprintf("Listening on %v", conf.Port))
-	err = http.ListenAndServe(fmt.Sprintf(":%v", conf.Port), nil)
+	err = httphelpers.ListenAndServe(fmt.Sprintf(":%v", conf.Port), tlsConfig)
 	bazaarLogger.Fatal("http-listen", err)
 }
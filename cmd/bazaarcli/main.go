@@ -25,7 +25,7 @@ import (
 func main() {
 	cmd := newRootCmd(os.Args[1:])
 	if err := cmd.Execute(); err != nil {
-		os.Exit(1)
+		os.Exit(cli.ExitCode(err))
 	}
 }
 
@@ -41,8 +41,16 @@ func newRootCmd(args []string) *cobra.Command {
 
 	cmd.AddCommand(
 		cli.NewChartsListCmd(out),
+		cli.NewChartsShowCmd(out),
+		cli.NewChartsPullCmd(out),
 		cli.NewChartsSaveCmd(out),
 		cli.NewChartsDeleteCmd(out),
+		cli.NewChartsActivateCmd(out),
+		cli.NewChartsValidateCmd(out),
+		cli.NewChartsPromoteCmd(out),
+		cli.NewPlansCmd(out),
+		cli.NewInstancesCmd(out),
+		cli.NewTargetCmd(out),
 	)
 
 	flags.Parse(args)
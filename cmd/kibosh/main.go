@@ -16,6 +16,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
@@ -26,6 +27,7 @@ import (
 	"code.cloudfoundry.org/lager"
 	"github.com/cf-platform-eng/kibosh/pkg/broker"
 	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/cf-platform-eng/kibosh/pkg/k8s"
@@ -44,13 +46,72 @@ func main() {
 		kiboshLogger.Fatal("Loading config file", err)
 	}
 
-	repo := repository.NewRepository(conf.HelmChartDir, conf.RegistryConfig.Server, kiboshLogger)
+	var repo repository.Repository
+	if conf.HelmRepoConfig.HasHelmRepoConfig() {
+		helmRepo, err := repository.NewHelmIndexRepository(
+			conf.HelmRepoConfig, conf.HelmChartDir, conf.RegistryConfig, kiboshLogger,
+		)
+		if err != nil {
+			kiboshLogger.Fatal("Unable to configure helm repository", err)
+		}
+		err = helmRepo.Sync()
+		if err != nil {
+			kiboshLogger.WithError(err).Error("Unable to sync with helm repository, serving cached charts")
+		}
+		repo = helmRepo
+	} else if conf.OCIChartConfig.HasOCIChartConfig() {
+		ociRepo, err := repository.NewOCIRepository(
+			conf.RegistryConfig, conf.OCIChartConfig, conf.HelmChartDir, kiboshLogger,
+		)
+		if err != nil {
+			kiboshLogger.Fatal("Unable to configure OCI charts", err)
+		}
+		err = ociRepo.Sync()
+		if err != nil {
+			kiboshLogger.WithError(err).Error("Unable to sync with OCI registry, serving cached charts")
+		}
+		repo = ociRepo
+	} else if conf.GitRepoConfig.HasGitRepoConfig() {
+		gitRepo, err := repository.NewGitRepository(
+			conf.GitRepoConfig, conf.HelmChartDir, conf.RegistryConfig, kiboshLogger,
+		)
+		if err != nil {
+			kiboshLogger.Fatal("Unable to configure git repository", err)
+		}
+		err = gitRepo.Sync()
+		if err != nil {
+			kiboshLogger.WithError(err).Error("Unable to sync with git repository, serving checked out charts")
+		}
+		repo = gitRepo
+	} else {
+		repo = repository.NewRepository(
+			conf.HelmChartDir, repository.Options{Registries: conf.RegistryConfig}, kiboshLogger,
+		)
+	}
 	charts, err := repo.GetCharts()
 	if err != nil {
 		kiboshLogger.Fatal("Unable to load charts", err)
 	}
 	kiboshLogger.Info(fmt.Sprintf("Brokering charts %s", charts))
 
+	if conf.WatchChartDir {
+		watcher := repository.NewWatcher(repo, conf.HelmChartDir, conf.WatchDebounce, kiboshLogger)
+		err = watcher.Start()
+		if err != nil {
+			kiboshLogger.Fatal("Unable to watch chart directory", err)
+		}
+		defer watcher.Stop()
+	}
+
+	if conf.GitRepoConfig.HasGitRepoConfig() && conf.GitRepoConfig.SyncInterval > 0 {
+		poller := repository.NewSyncPoller(repo, conf.GitRepoConfig.SyncInterval, kiboshLogger)
+		err = poller.Start()
+		if err != nil {
+			kiboshLogger.Fatal("Unable to poll git repository", err)
+		}
+		defer poller.Stop()
+	}
+
 	var credStore credstore.CredStore
 	if conf.CredStoreConfig.HasCredHubConfig() {
 		credStore, err = credstore.NewCredhubStore(
@@ -61,6 +122,17 @@ func main() {
 		if err != nil {
 			kiboshLogger.Fatal("Unable to create credhub client", err)
 		}
+
+		if conf.CredStoreConfig.HasAuditConfig() {
+			auditSink, err := credstore.NewAuditSink(
+				conf.CredStoreConfig.AuditSink, conf.CredStoreConfig.AuditFile,
+				conf.CredStoreConfig.AuditWebhookURL, kiboshLogger,
+			)
+			if err != nil {
+				kiboshLogger.Fatal("Unable to create credential audit sink", err)
+			}
+			credStore = credstore.NewAuditingCredStore(credStore, auditSink, kiboshLogger)
+		}
 	}
 
 	var cfAPIClient cf.Client
@@ -76,7 +148,9 @@ func main() {
 		}
 	}
 
-	operatorRepo := repository.NewRepository(conf.OperatorDir, conf.RegistryConfig.Server, kiboshLogger)
+	operatorRepo := repository.NewRepository(
+		conf.OperatorDir, repository.Options{Registries: conf.RegistryConfig}, kiboshLogger,
+	)
 	operatorCharts, err := operatorRepo.GetCharts()
 	if err != nil {
 		if !os.IsNotExist(err) {
@@ -95,10 +169,19 @@ func main() {
 		kiboshLogger.Fatal("Unable to prepare default cluster", err)
 	}
 
-	serviceBroker := broker.NewPksServiceBroker(
-		conf, clusterFactory, helmClientFactory, serviceAccountInstallerFactory, helm.InstallerFactoryDefault,
-		repo, credStore, operatorCharts, kiboshLogger,
-	)
+	imageChecker, err := docker.NewConfiguredImageChecker(conf.RegistryConfig)
+	if err != nil {
+		kiboshLogger.Fatal("Unable to check images", err)
+	}
+	serviceBroker := broker.NewPksServiceBroker(conf, repo, broker.Options{
+		ClusterFactory:                 clusterFactory,
+		HelmClientFactory:              helmClientFactory,
+		ServiceAccountInstallerFactory: serviceAccountInstallerFactory,
+		HelmInstallerFactory:           helm.InstallerFactoryDefault,
+		CredStore:                      credStore,
+		Operators:                      operatorCharts,
+		ImageChecker:                   imageChecker,
+	}, kiboshLogger)
 	brokerCredentials := brokerapi.BrokerCredentials{
 		Username: conf.AdminUsername,
 		Password: conf.AdminPassword,
@@ -112,11 +195,43 @@ func main() {
 
 	repositoryAPI := repository.NewAPI(repo, cfAPIClient, conf, kiboshLogger)
 	authFilter := httphelpers.NewAuthFilter(conf.AdminUsername, conf.AdminPassword)
-	http.Handle("/reload_charts", authFilter.Filter(
+	// Cloud Controller calls the broker api without a client certificate, only Bazaar's routes need one
+	adminFilter := func(handler http.Handler) http.Handler {
+		handler = authFilter.Filter(handler)
+		if conf.ServerTLSConfig.ClientCAFile != "" {
+			handler = httphelpers.RequireClientCert(handler)
+		}
+		return handler
+	}
+	http.Handle("/reload_charts", adminFilter(
 		repositoryAPI.ReloadCharts(),
 	))
+	http.Handle("/quarantined_charts", adminFilter(
+		repositoryAPI.QuarantinedCharts(),
+	))
+	http.Handle("/chart_revision", adminFilter(
+		repositoryAPI.ChartRevision(),
+	))
+
+	instancesAPI := broker.NewInstancesAPI(serviceBroker, kiboshLogger)
+	http.Handle("/instances", adminFilter(
+		instancesAPI.Instances(),
+	))
+	http.Handle("/instances/", adminFilter(
+		instancesAPI.Instances(),
+	))
+
+	var tlsConfig *tls.Config
+	if conf.ServerTLSConfig.HasServerTLS() {
+		tlsConfig, err = httphelpers.ServerTLSConfig(
+			conf.ServerTLSConfig.CertFile, conf.ServerTLSConfig.KeyFile, conf.ServerTLSConfig.ClientCAFile, false,
+		)
+		if err != nil {
+			kiboshLogger.Fatal("Loading tls config", err)
+		}
+	}
 
 	kiboshLogger.Info(fmt.Sprintf("Listening on %v", conf.Port))
-	err = http.ListenAndServe(fmt.Sprintf(":%v", conf.Port), nil)
+	err = httphelpers.ListenAndServe(fmt.Sprintf(":%v", conf.Port), tlsConfig)
 	kiboshLogger.Fatal("http-listen", err)
 }
@@ -18,13 +18,15 @@ package main
 import (
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"path"
-	"strings"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/cf-platform-eng/kibosh/pkg/moreio"
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
@@ -56,25 +58,21 @@ func run() error {
 		return errors.New(fmt.Sprintf("Error parsing values file %s", err.Error()))
 	}
 
-	files, err := ioutil.ReadDir(imagesPath)
+	registryConf := &config.RegistryConfig{}
+	err = envconfig.Process("", registryConf)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error reading files in images subpath: %s", err.Error()))
+		return err
 	}
-
-	for _, file := range files {
-		if strings.HasPrefix(file.Name(), ".") {
-			continue
-		}
-		err := docker.LoadImage(path.Join(imagesPath, file.Name()))
-		if err != nil {
-			return err
-		}
+	registryConf.Server = registry
+	httpClient, err := httphelpers.NewHTTPClient(registryConf.CACertFile, "", "", false)
+	if err != nil {
+		return err
 	}
 
-	err = docker.TagAndPush(registry)
+	loader, err := docker.NewLoader(registryConf, httpClient, logrus.New())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return loader.LoadChart(chartPath)
 }
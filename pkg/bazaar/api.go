@@ -20,13 +20,20 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
-
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/moreio"
 	"github.com/cf-platform-eng/kibosh/pkg/repository"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -34,29 +41,118 @@ import (
 
 type API interface {
 	Charts() http.Handler
+	QuarantinedCharts() http.Handler
+	Instances() http.Handler
+	Uploads() http.Handler
 	ListCharts(w http.ResponseWriter, r *http.Request) error
 	SaveChart(w http.ResponseWriter, r *http.Request) error
 	DeleteChart(w http.ResponseWriter, r *http.Request) error
+	ActivateChartVersion(w http.ResponseWriter, r *http.Request) error
+	ValidateChart(w http.ResponseWriter, r *http.Request) error
+	ShowChart(w http.ResponseWriter, r *http.Request) error
+	ArchiveChart(w http.ResponseWriter, r *http.Request) error
+	ChartProvenance(w http.ResponseWriter, r *http.Request) error
+	ListPlans(w http.ResponseWriter, r *http.Request) error
+	AddPlan(w http.ResponseWriter, r *http.Request) error
+	UpdatePlan(w http.ResponseWriter, r *http.Request) error
+	DeletePlan(w http.ResponseWriter, r *http.Request) error
 }
 
 type api struct {
 	repo         repository.Repository
 	kiboshConfig *KiboshConfig
+	kiboshClient *http.Client
+	uploadConfig *UploadConfig
+	verifier     *ProvenanceVerifier
+	imageChecker docker.ImageChecker
+	registries   *config.RegistryConfig
 	logger       *logrus.Logger
+	sessions     *uploadSessions
+}
+
+// APIOptions are the optional settings of the API, the zero value of each is its default
+type APIOptions struct {
+	// KiboshClient calls Kibosh, as built by KiboshConfig.HTTPClient(), http.DefaultClient when nil
+	KiboshClient *http.Client
+	// Uploads sets how uploaded charts are staged, DefaultUploadConfig() when nil
+	Uploads *UploadConfig
+	// Verifier verifies uploaded charts before saving them, verification is disabled when nil
+	Verifier *ProvenanceVerifier
+	// ImageChecker checks that the images of every plan of uploaded charts, as rewritten for
+	// Registries, are in the registries before saving them. The check is disabled when nil.
+	ImageChecker docker.ImageChecker
+	Registries   *config.RegistryConfig
 }
 
-func NewAPI(repo repository.Repository, kiboshConfig *KiboshConfig, logger *logrus.Logger) API {
+func NewAPI(repo repository.Repository, kiboshConfig *KiboshConfig, options APIOptions, logger *logrus.Logger) API {
+	if options.Uploads == nil {
+		options.Uploads = DefaultUploadConfig()
+	}
+	if options.Registries == nil {
+		options.Registries = &config.RegistryConfig{}
+	}
+	if options.KiboshClient == nil {
+		options.KiboshClient = http.DefaultClient
+	}
 	return &api{
 		repo:         repo,
 		kiboshConfig: kiboshConfig,
+		kiboshClient: options.KiboshClient,
+		uploadConfig: options.Uploads,
+		verifier:     options.Verifier,
+		imageChecker: options.ImageChecker,
+		registries:   options.Registries,
 		logger:       logger,
+		sessions:     newUploadSessions(options.Uploads, logger),
 	}
 }
 
 type DisplayChart struct {
-	Name    string   `json:"name"`
-	Plans   []string `json:"plans"`
-	Version string   `json:"version"`
+	Name     string   `json:"name"`
+	Plans    []string `json:"plans"`
+	Version  string   `json:"version"`
+	Versions []string `json:"versions"`
+}
+
+type DisplayChartDetail struct {
+	Name         string              `json:"name"`
+	Version      string              `json:"version"`
+	Versions     []string            `json:"versions"`
+	AppVersion   string              `json:"appVersion,omitempty"`
+	Description  string              `json:"description,omitempty"`
+	Icon         string              `json:"icon,omitempty"`
+	Home         string              `json:"home,omitempty"`
+	Keywords     []string            `json:"keywords,omitempty"`
+	Sources      []string            `json:"sources,omitempty"`
+	Plans        []DisplayPlanDetail `json:"plans"`
+	BindTemplate string              `json:"bindTemplate,omitempty"`
+	Values       string              `json:"values"`
+}
+
+// DisplayPlanDetail leaves out plan credentials, reporting only whether the plan targets its own cluster
+type DisplayPlanDetail struct {
+	Name          string   `json:"name"`
+	Description   string   `json:"description"`
+	Bullets       []string `json:"bullets"`
+	File          string   `json:"file"`
+	Free          bool     `json:"free"`
+	Bindable      bool     `json:"bindable"`
+	CustomCluster bool     `json:"customCluster"`
+}
+
+// PlanRequest carries the contents of the plan's values file, and of its credentials file when
+// the plan targets its own cluster. Updates keep the plan's values and credentials when they're
+// left out, ClearCredentials moves the plan back to the default cluster.
+type PlanRequest struct {
+	Name             string   `json:"name"`
+	Description      string   `json:"description"`
+	Bullets          []string `json:"bullets"`
+	File             string   `json:"file,omitempty"`
+	Free             *bool    `json:"free,omitempty"`
+	Bindable         *bool    `json:"bindable,omitempty"`
+	Values           *string  `json:"values,omitempty"`
+	Credentials      string   `json:"credentials,omitempty"`
+	ClearCredentials bool     `json:"clearCredentials,omitempty"`
 }
 
 type DisplayResponse struct {
@@ -65,22 +161,161 @@ type DisplayResponse struct {
 
 func (api *api) Charts() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(w, r, requiredRole(r)) {
+			return
+		}
+
 		var err error
+		resource, _ := getUrlPart(2, r)
 		switch r.Method {
 		case "GET":
-			err = api.ListCharts(w, r)
+			if countUrlParts(r) == 3 && resource == "plans" {
+				err = api.ListPlans(w, r)
+			} else if countUrlParts(r) == 3 && resource == "archive" {
+				err = api.ArchiveChart(w, r)
+			} else if countUrlParts(r) == 3 && resource == "provenance" {
+				err = api.ChartProvenance(w, r)
+			} else if countUrlParts(r) > 1 {
+				err = api.ShowChart(w, r)
+			} else {
+				err = api.ListCharts(w, r)
+			}
 			break
 		case "POST":
-			err = api.SaveChart(w, r)
+			action, _ := getUrlPart(1, r)
+			if countUrlParts(r) == 2 && action == "validate" {
+				err = api.ValidateChart(w, r)
+			} else if countUrlParts(r) == 3 && resource == "plans" {
+				err = api.AddPlan(w, r)
+			} else if countUrlParts(r) > 1 {
+				err = api.ActivateChartVersion(w, r)
+			} else {
+				err = api.SaveChart(w, r)
+			}
+			break
+		case "PUT":
+			if countUrlParts(r) == 4 && resource == "plans" {
+				err = api.UpdatePlan(w, r)
+			} else {
+				w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+				w.WriteHeader(405)
+			}
 			break
 		case "DELETE":
-			err = api.DeleteChart(w, r)
+			if countUrlParts(r) == 4 && resource == "plans" {
+				err = api.DeletePlan(w, r)
+			} else {
+				err = api.DeleteChart(w, r)
+			}
 			break
 		default:
+			w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+			w.WriteHeader(405)
+		}
+
+		if err != nil {
+			api.logger.WithError(err).Error("Error writing response")
+		}
+	})
+}
+
+func (api *api) QuarantinedCharts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(w, r, httphelpers.RoleReader) {
+			return
+		}
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(405)
+			return
+		}
+
+		quarantine, err := api.repo.GetQuarantinedCharts()
+		if err != nil {
+			api.logger.WithError(err).Error("Unable to load charts")
+			api.ServerError(500, errors.Wrap(err, "Unable to load charts").Error(), w)
+			return
+		}
+
+		err = api.WriteJSONResponse(w, quarantine)
+		if err != nil {
+			api.logger.WithError(err).Error("Error writing response")
+		}
+	})
+}
+
+// requiredRole lets readers look, publishers upload, download and change charts, and only admins
+// delete charts or download plan credentials (or the signed archives holding them)
+func requiredRole(r *http.Request) httphelpers.Role {
+	switch r.Method {
+	case "POST", "PUT":
+		return httphelpers.RolePublisher
+	case "DELETE":
+		return httphelpers.RoleAdmin
+	default:
+		resource, _ := getUrlPart(2, r)
+		if countUrlParts(r) == 3 && (resource == "archive" || resource == "provenance") {
+			if includeCredentials(r) {
+				return httphelpers.RoleAdmin
+			}
+			return httphelpers.RolePublisher
+		}
+		return httphelpers.RoleReader
+	}
+}
+
+// includeCredentials is set when an archive or provenance request asks for the plan cluster credentials
+func includeCredentials(r *http.Request) bool {
+	return r.URL.Query().Get("credentials") == "true"
+}
+
+// authorized checks the role granted by the auth filter. Filters that don't grant roles, like the
+// admin basic auth filter, authorize everything they let through.
+func (api *api) authorized(w http.ResponseWriter, r *http.Request, required httphelpers.Role) bool {
+	role, ok := httphelpers.RoleFromRequest(r)
+	if !ok || role.Allows(required) {
+		return true
+	}
+	api.ServerError(403, fmt.Sprintf("%s requires the %s role, caller has the %s role", r.Method, required, role), w)
+	return false
+}
+
+// Instances passes instance listings through from Kibosh, which has access to the clusters
+func (api *api) Instances() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(w, r, httphelpers.RoleReader) {
+			return
+		}
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
 			w.WriteHeader(405)
-			w.Header().Set("Allow", "GET, POST, DELETE")
+			return
+		}
+
+		kiboshURL := fmt.Sprintf("%v%v", api.kiboshConfig.Server, r.URL.Path)
+		if r.URL.RawQuery != "" {
+			kiboshURL = kiboshURL + "?" + r.URL.RawQuery
+		}
+		req, err := http.NewRequest("GET", kiboshURL, nil)
+		if err != nil {
+			api.ServerError(500, errors.Wrap(err, "Unable to load instances").Error(), w)
+			return
 		}
+		httphelpers.AddBasicAuthHeader(req, api.kiboshConfig.User, api.kiboshConfig.Pass)
 
+		res, err := api.kiboshClient.Do(req)
+		if err != nil {
+			api.logger.WithError(err).Error("Couldn't call kibosh for instances")
+			api.ServerError(502, errors.Wrap(err, "Unable to load instances from Kibosh").Error(), w)
+			return
+		}
+		defer res.Body.Close()
+
+		if contentType := res.Header.Get("Content-Type"); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.WriteHeader(res.StatusCode)
+		_, err = io.Copy(w, res.Body)
 		if err != nil {
 			api.logger.WithError(err).Error("Error writing response")
 		}
@@ -92,37 +327,396 @@ func (api *api) ListCharts(w http.ResponseWriter, r *http.Request) error {
 	if err != nil {
 		api.logger.WithError(err).Error("Unable to load charts")
 		api.ServerError(500, errors.Wrap(err, "Unable to load charts").Error(), w)
-	} else {
-		var displayCharts []DisplayChart
-		for _, chart := range charts {
-			var plans []string
-			for _, plan := range chart.Plans {
-				plans = append(plans, plan.Name)
+		return nil
+	}
+
+	chartVersions, err := api.repo.GetChartVersions()
+	if err != nil {
+		api.logger.WithError(err).Error("Unable to load chart versions")
+		api.ServerError(500, errors.Wrap(err, "Unable to load chart versions").Error(), w)
+		return nil
+	}
+	versionsByName := map[string][]string{}
+	for _, chartVersion := range chartVersions {
+		versionsByName[chartVersion.Name] = chartVersion.Versions
+	}
+
+	var displayCharts []DisplayChart
+	for _, chart := range charts {
+		var plans []string
+		for _, plan := range chart.Plans {
+			plans = append(plans, plan.Name)
+		}
+		versions, ok := versionsByName[chart.Metadata.Name]
+		if !ok {
+			versions = []string{chart.Metadata.Version}
+		}
+		displayCharts = append(displayCharts, DisplayChart{
+			Name:     chart.Metadata.Name,
+			Version:  chart.Metadata.Version,
+			Versions: versions,
+			Plans:    plans,
+		})
+	}
+	return api.WriteJSONResponse(w, displayCharts)
+}
+
+func (api *api) ShowChart(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	if countUrlParts(r) != 2 {
+		api.ServerError(404, fmt.Sprintf("Unknown path [%s]", r.URL.Path), w)
+		return nil
+	}
+
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+
+	chartVersions, err := api.repo.GetChartVersions()
+	if err != nil {
+		api.logger.WithError(err).Error("Unable to load chart versions")
+		api.ServerError(500, errors.Wrap(err, "Unable to load chart versions").Error(), w)
+		return nil
+	}
+	versions := []string{found.Metadata.Version}
+	for _, chartVersion := range chartVersions {
+		if chartVersion.Name == chartName {
+			versions = chartVersion.Versions
+		}
+	}
+
+	return api.WriteJSONResponse(w, newDisplayChartDetail(found, versions))
+}
+
+// ArchiveChart serves the archive the active version of a stored chart was uploaded as, when it was
+// signed and can be served as it is, so that its provenance file still matches. Otherwise it packages
+// the chart, as it is on disk, into a .tgz.
+func (api *api) ArchiveChart(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+
+	filename := fmt.Sprintf("%s-%s.tgz", found.Metadata.Name, found.Metadata.Version)
+	archivePath, _, err := api.signedArchive(found, r)
+	if err != nil {
+		api.ServerError(500, err.Error(), w)
+		return nil
+	}
+	if archivePath != "" {
+		return api.serveFile(archivePath, "application/gzip", filename, w)
+	}
+
+	if !moreio.DirExistsAndIsReadable(found.ChartPath) {
+		api.ServerError(500, fmt.Sprintf("Chart [%s] isn't stored as a directory", chartName), w)
+		return nil
+	}
+
+	skip := map[string]bool{}
+	if !includeCredentials(r) {
+		for _, plan := range found.Plans {
+			if plan.CredentialsPath != "" {
+				skip[path.Join("plans", plan.CredentialsPath)] = true
 			}
-			displayCharts = append(displayCharts, DisplayChart{
-				Name:    chart.Metadata.Name,
-				Version: chart.Metadata.Version,
-				Plans:   plans,
-			})
 		}
-		return api.WriteJSONResponse(w, displayCharts)
 	}
+
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	return moreio.TarZipUnderSkipping(found.ChartPath, found.Metadata.Name, skip, w)
+}
+
+// ChartProvenance serves the provenance file of the archive ArchiveChart serves, when it's signed
+func (api *api) ChartProvenance(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+
+	_, provenancePath, err := api.signedArchive(found, r)
+	if err != nil {
+		api.ServerError(500, err.Error(), w)
+		return nil
+	}
+	if provenancePath == "" {
+		api.ServerError(404, fmt.Sprintf("Chart [%s] has no provenance file", chartName), w)
+		return nil
+	}
+
+	filename := fmt.Sprintf("%s-%s.tgz%s", found.Metadata.Name, found.Metadata.Version, ProvenanceExtension)
+	return api.serveFile(provenancePath, "text/plain", filename, w)
+}
+
+// signedArchive returns the archive the active version of the chart was uploaded as, and its
+// provenance file, or "" when it wasn't signed. The archive holds the plan credentials, so when
+// the chart has any it's only served to requests that include them.
+func (api *api) signedArchive(found *helm.MyChart, r *http.Request) (string, string, error) {
+	if !includeCredentials(r) {
+		for _, plan := range found.Plans {
+			if plan.CredentialsPath != "" {
+				return "", "", nil
+			}
+		}
+	}
+	return api.repo.SignedChartArchive(found.Metadata.Name)
+}
+
+func (api *api) serveFile(filePath string, contentType string, filename string, w http.ResponseWriter) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		api.ServerError(500, err.Error(), w)
+		return nil
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (api *api) ListPlans(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+
+	return api.WriteJSONResponse(w, newDisplayChartDetail(found, nil).Plans)
+}
+
+func (api *api) AddPlan(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+
+	planRequest := PlanRequest{}
+	err := json.NewDecoder(r.Body).Decode(&planRequest)
+	if err != nil {
+		api.ServerError(400, errors.Wrap(err, "Unable to parse plan").Error(), w)
+		return nil
+	}
+	if _, ok := found.Plans[planRequest.Name]; ok {
+		api.ServerError(400, fmt.Sprintf("Plan [%s] already exists in chart [%s]", planRequest.Name, chartName), w)
+		return nil
+	}
+
+	return api.savePlan(chartName, planRequest, "added", w)
+}
+
+func (api *api) UpdatePlan(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	planName, _ := getUrlPart(3, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+	if _, ok := found.Plans[planName]; !ok {
+		api.ServerError(404, fmt.Sprintf("Plan [%s] not found in chart [%s]", planName, chartName), w)
+		return nil
+	}
+
+	planRequest := PlanRequest{}
+	err := json.NewDecoder(r.Body).Decode(&planRequest)
+	if err != nil {
+		api.ServerError(400, errors.Wrap(err, "Unable to parse plan").Error(), w)
+		return nil
+	}
+	if planRequest.Name == "" {
+		planRequest.Name = planName
+	}
+	if planRequest.Name != planName {
+		api.ServerError(400, fmt.Sprintf("Plan name [%s] doesn't match [%s], plans can't be renamed", planRequest.Name, planName), w)
+		return nil
+	}
+
+	return api.savePlan(chartName, planRequest, "updated", w)
+}
+
+func (api *api) DeletePlan(w http.ResponseWriter, r *http.Request) error {
+	chartName, _ := getUrlPart(1, r)
+	planName, _ := getUrlPart(3, r)
+	found := api.findChart(chartName, w)
+	if found == nil {
+		return nil
+	}
+	if _, ok := found.Plans[planName]; !ok {
+		api.ServerError(404, fmt.Sprintf("Plan [%s] not found in chart [%s]", planName, chartName), w)
+		return nil
+	}
+	if len(found.Plans) == 1 {
+		api.ServerError(400, "Cannot remove last plan (this would result in a service without plans)", w)
+		return nil
+	}
+
+	return api.changePlans(func(dir string) (string, error) {
+		return api.repo.PackageChartWithoutPlan(chartName, planName, dir)
+	}, fmt.Sprintf("Plan [%v] deleted from chart [%v]", planName, chartName), w)
+}
+
+func (api *api) savePlan(chartName string, planRequest PlanRequest, action string, w http.ResponseWriter) error {
+	plan := helm.Plan{
+		Name:        planRequest.Name,
+		Description: planRequest.Description,
+		Bullets:     planRequest.Bullets,
+		File:        planRequest.File,
+		Free:        planRequest.Free,
+		Bindable:    planRequest.Bindable,
+	}
+	if planRequest.Values != nil {
+		plan.Values = []byte(*planRequest.Values)
+	}
+	return api.changePlans(func(dir string) (string, error) {
+		return api.repo.PackageChartWithPlan(chartName, plan, []byte(planRequest.Credentials), planRequest.ClearCredentials, dir)
+	}, fmt.Sprintf("Plan [%v] %s in chart [%v]", plan.Name, action, chartName), w)
+}
+
+// changePlans saves the chart packaged with the changed plans the same way as an upload: verified,
+// staged, and rolled back when Kibosh fails to reload it
+func (api *api) changePlans(packageChart func(dir string) (string, error), message string, w http.ResponseWriter) error {
+	if api.verifier != nil && api.verifier.Required() {
+		// the chart packaged with the changed plans can't carry a signature
+		api.ServerError(400, "Plans can't be changed while signatures are required (CHART_PROVENANCE_REQUIRED), sign and upload the chart with the changed plans instead", w)
+		return nil
+	}
+
+	dir, err := ioutil.TempDir(api.uploadConfig.Dir, "plans-")
+	if err != nil {
+		api.ServerError(500, errors.Wrap(err, "Unable to change plans").Error(), w)
+		return nil
+	}
+	defer os.RemoveAll(dir)
+
+	chartFile, err := packageChart(dir)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*helm.ChartValidationError); ok {
+			api.ServerError(400, errors.Wrap(err, "Invalid plan").Error(), w)
+			return nil
+		}
+		api.ServerError(500, errors.Wrap(err, "Unable to change plans").Error(), w)
+		return nil
+	}
+
+	change, err := api.stageChart(chartFile, "")
+	if err != nil {
+		switch errors.Cause(err).(type) {
+		case *ProvenanceError, *docker.MissingImagesError:
+			api.ServerError(400, errors.Wrap(err, "Unable to change plans").Error(), w)
+			return nil
+		case *repository.ChartBusyError:
+			api.ServerError(409, errors.Wrap(err, "Unable to change plans").Error(), w)
+			return nil
+		}
+		api.ServerError(500, errors.Wrap(err, "Unable to change plans").Error(), w)
+		return nil
+	}
+
+	err = api.triggerKiboshReload()
+	if err != nil {
+		api.rollback([]repository.StagedChange{change})
+		api.ServerError(500, errors.Wrap(err, "Kibosh reload failed, plan changes were rolled back").Error(), w)
+		return nil
+	}
+	api.commit([]repository.StagedChange{change})
+	return api.WriteJSONResponse(w, DisplayResponse{Message: message})
+}
+
+// findChart writes the error response itself and returns nil when the chart can't be found
+func (api *api) findChart(chartName string, w http.ResponseWriter) *helm.MyChart {
+	charts, err := api.repo.GetCharts()
+	if err != nil {
+		api.logger.WithError(err).Error("Unable to load charts")
+		api.ServerError(500, errors.Wrap(err, "Unable to load charts").Error(), w)
+		return nil
+	}
+
+	for _, chart := range charts {
+		if chart.Metadata.Name == chartName {
+			return chart
+		}
+	}
+	api.ServerError(404, fmt.Sprintf("Chart [%s] not found", chartName), w)
 	return nil
 }
 
+func newDisplayChartDetail(chart *helm.MyChart, versions []string) DisplayChartDetail {
+	plans := []DisplayPlanDetail{}
+	for _, plan := range chart.Plans {
+		plans = append(plans, DisplayPlanDetail{
+			Name:          plan.Name,
+			Description:   plan.Description,
+			Bullets:       plan.Bullets,
+			File:          plan.File,
+			Free:          plan.Free == nil || *plan.Free,
+			Bindable:      plan.Bindable == nil || *plan.Bindable,
+			CustomCluster: plan.ClusterConfig != nil,
+		})
+	}
+	sort.Slice(plans, func(i, j int) bool {
+		return plans[i].Name < plans[j].Name
+	})
+
+	return DisplayChartDetail{
+		Name:         chart.Metadata.Name,
+		Version:      chart.Metadata.Version,
+		Versions:     versions,
+		AppVersion:   chart.Metadata.AppVersion,
+		Description:  chart.Metadata.Description,
+		Icon:         chart.Metadata.Icon,
+		Home:         chart.Metadata.Home,
+		Keywords:     chart.Metadata.Keywords,
+		Sources:      chart.Metadata.Sources,
+		Plans:        plans,
+		BindTemplate: chart.BindTemplate,
+		Values:       string(chart.TransformedValues),
+	}
+}
+
+// SaveChart saves charts uploaded as multipart/form-data, or fetches the chart of a
+// ChartURLRequest sent as json
 func (api *api) SaveChart(w http.ResponseWriter, r *http.Request) error {
-	err := api.saveChartToRepository(r)
+	receive := api.receiveUpload
+	if isJSONRequest(r) {
+		receive = api.receiveChartURL
+	}
+	upload, err := receive(r)
+	if err != nil {
+		api.uploadFailed(errors.Wrap(err, "Unable to save charts"), w)
+		return nil
+	}
+	defer upload.cleanup()
+
+	return api.saveUpload(upload, w)
+}
+
+// saveUpload saves every chart of the upload, or none of them, and has Kibosh reload them
+func (api *api) saveUpload(upload *upload, w http.ResponseWriter) error {
+	changes, err := api.saveChartToRepository(upload)
 	if err != nil {
+		switch errors.Cause(err).(type) {
+		case *ProvenanceError, *docker.MissingImagesError:
+			api.ServerError(400, errors.Wrap(err, "Unable to save charts").Error(), w)
+			return nil
+		case *repository.ChartBusyError:
+			api.ServerError(409, errors.Wrap(err, "Unable to save charts").Error(), w)
+			return nil
+		}
 		api.ServerError(500, errors.Wrap(err, "Unable to save charts").Error(), w)
 		return nil
 	}
 
 	err = api.triggerKiboshReload()
 	if err != nil {
-		//todo: retry? rollback? what's on disk now doesn't match Kibosh
-		api.ServerError(500, errors.Wrap(err, "Chart persisted, but Kibosh reload failed").Error(), w)
+		api.rollback(changes)
+		api.ServerError(500, errors.Wrap(err, "Kibosh reload failed, chart changes were rolled back").Error(), w)
 		return nil
 	}
+	api.commit(changes)
 	return api.WriteJSONResponse(w, DisplayResponse{Message: "Chart saved"})
 }
 
@@ -145,23 +739,96 @@ func (api *api) DeleteChart(w http.ResponseWriter, r *http.Request) error {
 		}
 	}
 
-	err = api.repo.DeleteChart(chartName)
+	change, err := api.repo.StageDeleteChart(chartName)
 	if err != nil {
+		if _, ok := errors.Cause(err).(*repository.ChartBusyError); ok {
+			api.ServerError(409, errors.Wrap(err, "Unable to delete chart").Error(), w)
+			return nil
+		}
 		api.ServerError(500, errors.Wrap(err, "Unable to delete chart").Error(), w)
 		return nil
 	}
 
 	err = api.triggerKiboshReload()
 	if err != nil {
-		//todo: retry? rollback? what's on disk now doesn't match Kibosh
-		api.ServerError(500, errors.Wrap(err, "Chart deleted, but Kibosh reload failed").Error(), w)
+		api.rollback([]repository.StagedChange{change})
+		api.ServerError(500, errors.Wrap(err, "Kibosh reload failed, chart deletion was rolled back").Error(), w)
 		return nil
 	}
+	api.commit([]repository.StagedChange{change})
 	return api.WriteJSONResponse(w, DisplayResponse{
 		Message: fmt.Sprintf("Chart [%v] deleted", chartName),
 	})
 }
 
+func (api *api) ActivateChartVersion(w http.ResponseWriter, r *http.Request) error {
+	chartName, err := getUrlPart(1, r)
+	if err != nil {
+		api.ServerError(400, errors.Wrap(err, "Unable to parse url path parameters").Error(), w)
+		return nil
+	}
+	versionsPart, _ := getUrlPart(2, r)
+	version, _ := getUrlPart(3, r)
+	action, _ := getUrlPart(4, r)
+	if versionsPart != "versions" || version == "" || action != "activate" {
+		api.ServerError(404, fmt.Sprintf("Unknown path [%s]", r.URL.Path), w)
+		return nil
+	}
+
+	change, err := api.repo.StageActivateChartVersion(chartName, version)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*repository.ChartBusyError); ok {
+			api.ServerError(409, errors.Wrap(err, "Unable to activate chart version").Error(), w)
+			return nil
+		}
+		api.ServerError(400, errors.Wrap(err, "Unable to activate chart version").Error(), w)
+		return nil
+	}
+
+	err = api.triggerKiboshReload()
+	if err != nil {
+		api.rollback([]repository.StagedChange{change})
+		api.ServerError(500, errors.Wrap(err, "Kibosh reload failed, chart version activation was rolled back").Error(), w)
+		return nil
+	}
+	api.commit([]repository.StagedChange{change})
+	return api.WriteJSONResponse(w, DisplayResponse{
+		Message: fmt.Sprintf("Chart [%v] version [%v] activated", chartName, version),
+	})
+}
+
+// ValidateChart reports whether Kibosh would accept the uploaded charts, without saving them
+func (api *api) ValidateChart(w http.ResponseWriter, r *http.Request) error {
+	upload, err := api.receiveUpload(r)
+	if err != nil {
+		api.uploadFailed(errors.Wrap(err, "Unable to validate chart"), w)
+		return nil
+	}
+	defer upload.cleanup()
+
+	reports := []*helm.ValidationReport{}
+	for _, chartFile := range upload.charts {
+		reports = append(reports, helm.ValidateChart(chartFile, api.registries))
+	}
+	return api.WriteJSONResponse(w, reports)
+}
+
+// uploadFailed writes 413 for uploads over the maximum size, 400 for malformed ones and 502 for
+// charts that couldn't be fetched
+func (api *api) uploadFailed(err error, w http.ResponseWriter) {
+	switch errors.Cause(err).(type) {
+	case *uploadTooLargeError:
+		api.ServerError(http.StatusRequestEntityTooLarge, err.Error(), w)
+	case *badUploadError:
+		api.ServerError(400, err.Error(), w)
+	case *fetchError:
+		api.ServerError(http.StatusBadGateway, err.Error(), w)
+	default:
+		api.logger.WithError(err).Error("Upload failed")
+		api.ServerError(500, err.Error(), w)
+	}
+}
+
 func (api *api) WriteJSONResponse(w http.ResponseWriter, body interface{}) error {
 	serialized, err := json.Marshal(body)
 	if err != nil {
@@ -189,61 +856,135 @@ func getUrlPart(position int, r *http.Request) (string, error) {
 	return parts[position], nil
 }
 
-func (api *api) saveChartToRepository(r *http.Request) error {
-	err := r.ParseMultipartForm(1000000)
-	if err != nil {
-		api.logger.WithError(err).Error("SaveChart: Couldn't parse the multipart form request")
-		return err
+func countUrlParts(r *http.Request) int {
+	count := 0
+	for _, part := range strings.Split(r.URL.Path, "/") {
+		if part != "" {
+			count++
+		}
 	}
+	return count
+}
 
-	formdata := r.MultipartForm
+// saveChartToRepository stages every uploaded chart, or none of them when any fails to save
+func (api *api) saveChartToRepository(upload *upload) ([]repository.StagedChange, error) {
+	changes := []repository.StagedChange{}
+	for _, chartFile := range upload.charts {
+		change, err := api.stageChart(chartFile, upload.provenanceFor(chartFile))
+		if err != nil {
+			api.rollback(changes)
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
 
-	files := formdata.File["chart"]
-	//file, handler, err := r.FormFile("chart")
-	for i := range files {
-		file, err := files[i].Open()
+func (api *api) stageChart(chartFile string, provenancePath string) (repository.StagedChange, error) {
+	name := filepath.Base(chartFile)
+	if api.verifier != nil {
+		signer, err := api.verifier.Verify(chartFile, provenancePath)
 		if err != nil {
-			api.logger.WithError(err).Error("SaveChart: Couldn't read request POST form data")
-			return err
+			api.logger.WithError(err).Error("SaveChart: Chart failed provenance verification")
+			return nil, err
+		}
+		if signer != "" {
+			api.logger.Info(fmt.Sprintf("SaveChart: [%s] signed by [%s]", name, signer))
 		}
+	} else if provenancePath != "" {
+		api.logger.Info(fmt.Sprintf("SaveChart: No provenance keyring configured, not verifying [%s]", name))
+	}
 
-		chartPath, err := ioutil.TempDir("", "chart-")
-		f, err := os.OpenFile(filepath.Join(chartPath, files[i].Filename), os.O_WRONLY|os.O_CREATE, 0666)
+	err := api.checkImages(chartFile)
+	if err != nil {
+		api.logger.WithError(err).Error("SaveChart: Chart images failed the registry check")
+		return nil, err
+	}
+
+	change, err := api.repo.StageSaveChart(chartFile, provenancePath)
+	if err != nil {
+		api.logger.WithError(err).Error("SaveChart: Couldn't save the chart")
+		return nil, err
+	}
+	return change, nil
+}
 
+func (api *api) checkImages(chartFile string) error {
+	if api.imageChecker == nil {
+		return nil
+	}
+	myChart, err := helm.NewChart(chartFile, api.registries, api.logger)
+	if err != nil {
+		return err
+	}
+
+	images := []string{}
+	for planName := range myChart.Plans {
+		planImages, err := myChart.ImageReferences(planName)
 		if err != nil {
-			api.logger.WithError(err).Error("SaveChart: Couldn't write on disk ")
-			return err
+			return errors.Wrapf(err, "Unable to list images of plan [%s]", planName)
 		}
+		images = append(images, planImages...)
+	}
+	return api.imageChecker.CheckImages(images)
+}
 
-		buffer := make([]byte, 1000000)
-		_, err = io.CopyBuffer(f, file, buffer)
+// commit discards what staged changes replaced, once Kibosh has picked them up
+func (api *api) commit(changes []repository.StagedChange) {
+	for _, change := range changes {
+		err := change.Commit()
 		if err != nil {
-			api.logger.WithError(err).Error("SaveChart: Couldn't copy file to buffer")
-			return err
+			api.logger.WithError(err).Error("Unable to clean up staged chart")
 		}
+	}
+}
 
-		err = api.repo.SaveChart(filepath.Join(chartPath, files[i].Filename))
+// rollback restores what staged changes replaced, newest first, then asks Kibosh to reload again so
+// that it matches the restored charts
+func (api *api) rollback(changes []repository.StagedChange) {
+	if len(changes) == 0 {
+		return
+	}
+	for i := len(changes) - 1; i >= 0; i-- {
+		err := changes[i].Rollback()
 		if err != nil {
-			api.logger.WithError(err).Error("SaveChart: Couldn't save the chart")
-			return err
+			api.logger.WithError(err).Error("Unable to roll back chart change")
 		}
+	}
+	err := api.reloadKibosh()
+	if err != nil {
+		api.logger.WithError(err).Error("Charts rolled back, but Kibosh reload failed")
+	}
+}
 
-		err = file.Close()
-		if err != nil {
-			api.logger.WithError(err).Error("error closing source file")
-			return err
+// triggerKiboshReload retries failed reloads, doubling the wait between attempts. Requests Kibosh
+// rejected (4xx) aren't retried.
+func (api *api) triggerKiboshReload() error {
+	attempts := api.kiboshConfig.ReloadAttempts
+	backoff := api.kiboshConfig.ReloadBackoff
+	for attempt := 1; ; attempt++ {
+		err := api.reloadKibosh()
+		if err == nil {
+			return nil
 		}
-		err = f.Close()
-		if err != nil {
-			api.logger.WithError(err).Error("error closing target file")
+		if _, rejected := err.(*kiboshRejectedError); rejected || attempt >= attempts {
 			return err
 		}
+		api.logger.WithError(err).Warn(fmt.Sprintf("Kibosh reload failed, retrying in %v", backoff))
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	return nil
 }
 
-func (api *api) triggerKiboshReload() error {
-	client := &http.Client{}
+type kiboshRejectedError struct {
+	statusCode int
+}
+
+func (e *kiboshRejectedError) Error() string {
+	return fmt.Sprintf("kibosh return non 200 status code [%v]", e.statusCode)
+}
+
+func (api *api) reloadKibosh() error {
 	kiboshURL := fmt.Sprintf("%v/reload_charts", api.kiboshConfig.Server)
 	req, err := http.NewRequest("GET", kiboshURL, nil)
 	if err != nil {
@@ -252,11 +993,17 @@ func (api *api) triggerKiboshReload() error {
 	}
 
 	httphelpers.AddBasicAuthHeader(req, api.kiboshConfig.User, api.kiboshConfig.Pass)
-	res, err := client.Do(req)
+	res, err := api.kiboshClient.Do(req)
 	if err != nil {
 		api.logger.WithError(err).Error("Couldn't call kibosh to update")
 		return err
 	}
+	res.Body.Close()
+	if res.StatusCode >= 400 && res.StatusCode < 500 {
+		err = &kiboshRejectedError{statusCode: res.StatusCode}
+		api.logger.WithError(err).Error("Error triggering Kibosh reload")
+		return err
+	}
 	if res.StatusCode != 200 {
 		err = errors.Errorf("kibosh return non 200 status code [%v]", res.StatusCode)
 		api.logger.WithError(err).Error("Error triggering Kibosh reload")
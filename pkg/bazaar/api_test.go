@@ -16,20 +16,32 @@
 package bazaar_test
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/cf-platform-eng/kibosh/pkg/docker/dockerfakes"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
 	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
 	"github.com/sirupsen/logrus"
+	k8sAPI "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/helm/pkg/chartutil"
 	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
 )
 
@@ -37,6 +49,7 @@ var _ = Describe("Api", func() {
 	const spacebearsServiceGUID = "37b7acb6-6755-56fe-a17f-2307657023ef"
 
 	var repo repositoryfakes.FakeRepository
+	var stagedChange *repositoryfakes.FakeStagedChange
 	var logger *logrus.Logger
 	var api bazaar.API
 	var kiboshConfig *bazaar.KiboshConfig
@@ -45,19 +58,24 @@ var _ = Describe("Api", func() {
 	var kiboshAPITestServer *httptest.Server
 
 	BeforeEach(func() {
+		kiboshAPIRequest = nil
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			kiboshAPIRequest = r
 		})
 		kiboshAPITestServer = httptest.NewServer(handler)
 
 		repo = repositoryfakes.FakeRepository{}
+		stagedChange = &repositoryfakes.FakeStagedChange{}
+		repo.StageSaveChartReturns(stagedChange, nil)
+		repo.StageDeleteChartReturns(stagedChange, nil)
+		repo.StageActivateChartVersionReturns(stagedChange, nil)
 		logger = logrus.New()
 		kiboshConfig = &bazaar.KiboshConfig{
 			Server: kiboshAPITestServer.URL,
 			User:   "bob",
 			Pass:   "monkey123",
 		}
-		api = bazaar.NewAPI(&repo, kiboshConfig, logger)
+		api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{}, logger)
 	})
 
 	AfterEach(func() {
@@ -73,7 +91,7 @@ var _ = Describe("Api", func() {
 		apiHandler := api.Charts()
 		apiHandler.ServeHTTP(recorder, req)
 		Expect(recorder.Code).To(Equal(405))
-		Expect(recorder.Header().Get("Allow")).To(Equal("GET, POST, DELETE"))
+		Expect(recorder.Header().Get("Allow")).To(Equal("GET, POST, PUT, DELETE"))
 	})
 
 	Context("List charts", func() {
@@ -115,6 +133,36 @@ var _ = Describe("Api", func() {
 			Expect(body[0]["name"]).To(Equal("spacebears"))
 		})
 
+		It("includes all stored versions", func() {
+			spacebearsChart := &helm.MyChart{
+				Chart: hapi_chart.Chart{
+					Metadata: &hapi_chart.Metadata{
+						Name:    "spacebears",
+						Version: "0.0.2",
+					},
+				},
+			}
+			repo.GetChartsReturns([]*helm.MyChart{spacebearsChart}, nil)
+			repo.GetChartVersionsReturns([]repository.ChartVersions{
+				{Name: "spacebears", Active: "0.0.2", Versions: []string{"0.0.3", "0.0.2", "0.0.1"}},
+			}, nil)
+
+			req, err := http.NewRequest("GET", "/charts/", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.Charts()
+			apiHandler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			body := []bazaar.DisplayChart{}
+			err = json.Unmarshal(recorder.Body.Bytes(), &body)
+			Expect(err).To(BeNil())
+			Expect(body[0].Version).To(Equal("0.0.2"))
+			Expect(body[0].Versions).To(Equal([]string{"0.0.3", "0.0.2", "0.0.1"}))
+		})
+
 		It("500s on failure", func() {
 			repo.GetChartsReturns(nil, errors.New("something went south"))
 			req, err := http.NewRequest("GET", "/charts/", nil)
@@ -128,8 +176,392 @@ var _ = Describe("Api", func() {
 		})
 	})
 
+	Context("Show chart", func() {
+		var spacebearsChart *helm.MyChart
+
+		BeforeEach(func() {
+			free := false
+			bindable := true
+			spacebearsChart = &helm.MyChart{
+				Plans: map[string]helm.Plan{
+					"small": {
+						Name:        "small",
+						Description: "small plan",
+						Bullets:     []string{"1 node"},
+						File:        "small.yaml",
+						Free:        &free,
+						Bindable:    &bindable,
+					},
+					"big": {
+						Name:            "big",
+						File:            "big.yaml",
+						Free:            &free,
+						Bindable:        &bindable,
+						CredentialsPath: "big-creds.yaml",
+						ClusterConfig:   &k8sAPI.Config{},
+					},
+				},
+				BindTemplate:      "{}",
+				TransformedValues: []byte("image: my-image\n"),
+				Chart: hapi_chart.Chart{
+					Metadata: &hapi_chart.Metadata{
+						Name:        "spacebears",
+						Version:     "0.0.2",
+						Description: "spacebears service and spacebears broker helm chart",
+						Icon:        "https://example.com/icon.png",
+					},
+				},
+			}
+			repo.GetChartsReturns([]*helm.MyChart{spacebearsChart}, nil)
+			repo.GetChartVersionsReturns([]repository.ChartVersions{
+				{Name: "spacebears", Active: "0.0.2", Versions: []string{"0.0.2", "0.0.1"}},
+			}, nil)
+		})
+
+		It("returns chart detail", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			body := bazaar.DisplayChartDetail{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(BeNil())
+			Expect(body.Name).To(Equal("spacebears"))
+			Expect(body.Icon).To(Equal("https://example.com/icon.png"))
+			Expect(body.Versions).To(Equal([]string{"0.0.2", "0.0.1"}))
+			Expect(body.BindTemplate).To(Equal("{}"))
+			Expect(body.Values).To(Equal("image: my-image\n"))
+			Expect(body.Plans).To(Equal([]bazaar.DisplayPlanDetail{
+				{Name: "big", File: "big.yaml", Free: false, Bindable: true, CustomCluster: true},
+				{Name: "small", Description: "small plan", Bullets: []string{"1 node"}, File: "small.yaml", Free: false, Bindable: true},
+			}))
+		})
+
+		It("never includes plan credentials", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Body.String()).NotTo(ContainSubstring("big-creds.yaml"))
+			Expect(recorder.Body.String()).NotTo(ContainSubstring("clusterConfig"))
+		})
+
+		It("404s on unknown chart", func() {
+			req, err := http.NewRequest("GET", "/charts/mysql", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+		})
+
+		It("500s on failure", func() {
+			repo.GetChartsReturns(nil, errors.New("nope"))
+			req, err := http.NewRequest("GET", "/charts/spacebears", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(500))
+		})
+	})
+
+	Context("Archive chart", func() {
+		var chartDir string
+
+		BeforeEach(func() {
+			var err error
+			chartDir, err = ioutil.TempDir("", "archive-")
+			Expect(err).To(BeNil())
+			Expect(test.DefaultChart().WriteChart(chartDir)).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(chartDir, "bind.yaml"), []byte("template: '{}'"), 0666)).To(BeNil())
+
+			spacebearsChart, err := helm.NewChart(chartDir, nil, logger)
+			Expect(err).To(BeNil())
+			repo.GetChartsReturns([]*helm.MyChart{spacebearsChart}, nil)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(chartDir)
+		})
+
+		It("packages chart with plans and bind template", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/archive", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Header().Get("Content-Disposition")).To(ContainSubstring("spacebears-0.0.1.tgz"))
+
+			archived, err := chartutil.LoadArchive(recorder.Body)
+			Expect(err).To(BeNil())
+			Expect(archived.Metadata.Name).To(Equal("spacebears"))
+			files := []string{}
+			for _, file := range archived.Files {
+				files = append(files, file.TypeUrl)
+			}
+			Expect(files).To(ContainElement("plans.yaml"))
+			Expect(files).To(ContainElement("plans/small.yaml"))
+			Expect(files).To(ContainElement("bind.yaml"))
+			Expect(files).NotTo(ContainElement("plans/medium-creds.yaml"))
+		})
+
+		It("includes plan credentials for admins that ask for them", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/archive?credentials=true", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleAdmin))
+
+			Expect(recorder.Code).To(Equal(200))
+			archived, err := chartutil.LoadArchive(recorder.Body)
+			Expect(err).To(BeNil())
+			files := []string{}
+			for _, file := range archived.Files {
+				files = append(files, file.TypeUrl)
+			}
+			Expect(files).To(ContainElement("plans/medium-creds.yaml"))
+		})
+
+		It("doesn't let readers download charts", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/archive", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleReader))
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(recorder.Body.String()).To(ContainSubstring("publisher"))
+		})
+
+		It("doesn't let publishers download plan credentials", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/archive?credentials=true", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RolePublisher))
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(recorder.Body.String()).To(ContainSubstring("admin"))
+		})
+
+		Context("signed", func() {
+			var archivePath string
+			var provenancePath string
+
+			BeforeEach(func() {
+				archivePath = filepath.Join(chartDir, "spacebears-0.0.1.tgz")
+				Expect(ioutil.WriteFile(archivePath, []byte("uploaded archive"), 0600)).To(BeNil())
+				provenancePath = archivePath + bazaar.ProvenanceExtension
+				Expect(ioutil.WriteFile(provenancePath, []byte("signature"), 0600)).To(BeNil())
+				repo.SignedChartArchiveReturns(archivePath, provenancePath, nil)
+			})
+
+			It("serves the archive the chart was uploaded as and its provenance file", func() {
+				req, err := http.NewRequest("GET", "/charts/spacebears/archive?credentials=true", nil)
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleAdmin))
+
+				Expect(recorder.Code).To(Equal(200))
+				Expect(recorder.Header().Get("Content-Disposition")).To(ContainSubstring("spacebears-0.0.1.tgz"))
+				Expect(recorder.Body.String()).To(Equal("uploaded archive"))
+				Expect(repo.SignedChartArchiveArgsForCall(0)).To(Equal("spacebears"))
+
+				req, err = http.NewRequest("GET", "/charts/spacebears/provenance?credentials=true", nil)
+				Expect(err).To(BeNil())
+				recorder = httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleAdmin))
+
+				Expect(recorder.Code).To(Equal(200))
+				Expect(recorder.Header().Get("Content-Disposition")).To(ContainSubstring("spacebears-0.0.1.tgz.prov"))
+				Expect(recorder.Body.String()).To(Equal("signature"))
+			})
+
+			It("packages the chart instead when the uploaded archive holds plan credentials", func() {
+				req, err := http.NewRequest("GET", "/charts/spacebears/archive", nil)
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(200))
+				archived, err := chartutil.LoadArchive(recorder.Body)
+				Expect(err).To(BeNil())
+				Expect(archived.Metadata.Name).To(Equal("spacebears"))
+				Expect(repo.SignedChartArchiveCallCount()).To(BeZero())
+
+				req, err = http.NewRequest("GET", "/charts/spacebears/provenance", nil)
+				Expect(err).To(BeNil())
+				recorder = httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(404))
+			})
+
+			It("doesn't let publishers download the provenance of archives with plan credentials", func() {
+				req, err := http.NewRequest("GET", "/charts/spacebears/provenance?credentials=true", nil)
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RolePublisher))
+
+				Expect(recorder.Code).To(Equal(403))
+			})
+		})
+
+		It("404s on provenance of a chart that wasn't signed", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/provenance?credentials=true", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+			Expect(recorder.Body.String()).To(ContainSubstring("no provenance file"))
+		})
+
+		It("404s on unknown chart", func() {
+			req, err := http.NewRequest("GET", "/charts/mysql/archive", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+		})
+	})
+
+	Context("Quarantined charts", func() {
+		It("lists quarantined charts", func() {
+			repo.GetQuarantinedChartsReturns([]repository.QuarantinedChart{
+				{Name: "mysql", Path: "/charts/mysql", Reason: "values.yaml is requires"},
+			}, nil)
+			req, err := http.NewRequest("GET", "/quarantined_charts", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.QuarantinedCharts()
+			apiHandler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			body := []map[string]interface{}{}
+			err = json.Unmarshal(recorder.Body.Bytes(), &body)
+			Expect(err).To(BeNil())
+			Expect(body).To(HaveLen(1))
+			Expect(body[0]["name"]).To(Equal("mysql"))
+			Expect(body[0]["reason"]).To(Equal("values.yaml is requires"))
+		})
+
+		It("500s on failure", func() {
+			repo.GetQuarantinedChartsReturns(nil, errors.New("something went south"))
+			req, err := http.NewRequest("GET", "/quarantined_charts", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.QuarantinedCharts()
+			apiHandler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(500))
+		})
+
+		It("only allows GET", func() {
+			req, err := http.NewRequest("POST", "/quarantined_charts", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.QuarantinedCharts()
+			apiHandler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(405))
+		})
+	})
+
+	Context("Activate chart version", func() {
+		It("activates version in repository and reloads kibosh", func() {
+			req, err := http.NewRequest("POST", "/charts/spacebears/versions/0.0.1/activate", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.Charts()
+			apiHandler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.StageActivateChartVersionCallCount()).To(Equal(1))
+			name, version := repo.StageActivateChartVersionArgsForCall(0)
+			Expect(name).To(Equal("spacebears"))
+			Expect(version).To(Equal("0.0.1"))
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/reload_charts"))
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			Expect(stagedChange.CommitCallCount()).To(Equal(1))
+		})
+
+		It("rolls back the activation when kibosh fails to reload", func() {
+			kiboshAPITestServer.Close()
+			kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(401)
+			}))
+			kiboshConfig.Server = kiboshAPITestServer.URL
+			req, err := http.NewRequest("POST", "/charts/spacebears/versions/0.0.1/activate", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(500))
+			Expect(recorder.Body.String()).To(ContainSubstring("rolled back"))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+			Expect(stagedChange.CommitCallCount()).To(BeZero())
+		})
+
+		It("400s when version can't be activated", func() {
+			repo.StageActivateChartVersionReturns(nil, errors.New("version not found"))
+			req, err := http.NewRequest("POST", "/charts/spacebears/versions/9.9.9/activate", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.Charts()
+			apiHandler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("version not found"))
+		})
+
+		It("404s on unknown path", func() {
+			req, err := http.NewRequest("POST", "/charts/spacebears/bogus", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.Charts()
+			apiHandler.ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+			Expect(repo.StageActivateChartVersionCallCount()).To(BeZero())
+		})
+	})
+
 	Context("Save chart", func() {
 		It("passes file to repository", func() {
+			var saved []byte
+			repo.StageSaveChartStub = func(path string, provenancePath string) (repository.StagedChange, error) {
+				saved, _ = ioutil.ReadFile(path)
+				return stagedChange, nil
+			}
 			req, err := createRequestWithFile()
 			Expect(err).To(BeNil())
 			recorder := httptest.NewRecorder()
@@ -137,12 +569,182 @@ var _ = Describe("Api", func() {
 			apiHandler := api.Charts()
 			apiHandler.ServeHTTP(recorder, req)
 
-			path := repo.SaveChartArgsForCall(0)
-			saved, err := ioutil.ReadFile(path)
-			Expect(err).To(BeNil())
 			Expect(string(saved)).To(Equal("hello upload"))
 		})
 
+		Context("uploads", func() {
+			var uploadDir string
+
+			BeforeEach(func() {
+				var err error
+				uploadDir, err = ioutil.TempDir("", "uploads-")
+				Expect(err).To(BeNil())
+				api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{Uploads: &bazaar.UploadConfig{Dir: uploadDir, MaxSize: 1 << 20}}, logger)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(uploadDir)
+			})
+
+			It("stages the upload in the upload dir and cleans it up", func() {
+				var stagedPath string
+				repo.StageSaveChartStub = func(path string, provenancePath string) (repository.StagedChange, error) {
+					stagedPath = path
+					return stagedChange, nil
+				}
+				req, err := createRequestWithFile()
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(200))
+				Expect(stagedPath).To(HavePrefix(uploadDir))
+				Expect(stagedPath).NotTo(BeAnExistingFile())
+				Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+			})
+
+			It("cleans up when the chart fails to save", func() {
+				repo.StageSaveChartReturns(nil, errors.New("failed to save charts"))
+				req, err := createRequestWithFile()
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(500))
+				Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+			})
+
+			It("413s uploads over the maximum size", func() {
+				api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{Uploads: &bazaar.UploadConfig{Dir: uploadDir, MaxSize: 100}}, logger)
+				payload, err := ioutil.TempFile("", "")
+				Expect(err).To(BeNil())
+				payload.Write(bytes.Repeat([]byte("a"), 200))
+				payload.Close()
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{payload.Name()})
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(413))
+				Expect(recorder.Body.String()).To(ContainSubstring("maximum of [100] bytes"))
+				Expect(repo.StageSaveChartCallCount()).To(BeZero())
+				Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+			})
+
+			It("413s uploads over the maximum size without a content length", func() {
+				api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{Uploads: &bazaar.UploadConfig{Dir: uploadDir, MaxSize: 100}}, logger)
+				payload, err := ioutil.TempFile("", "")
+				Expect(err).To(BeNil())
+				payload.Write(bytes.Repeat([]byte("a"), 200))
+				payload.Close()
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{payload.Name()})
+				Expect(err).To(BeNil())
+				req.ContentLength = -1
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(413))
+				Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+			})
+
+			It("400s requests that aren't multipart", func() {
+				req, err := http.NewRequest("POST", "/charts", strings.NewReader("{}"))
+				Expect(err).To(BeNil())
+				req.Header.Set("Content-Type", "application/json")
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(400))
+				Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			})
+
+			It("400s when no chart uploaded", func() {
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{})
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(400))
+				Expect(recorder.Body.String()).To(ContainSubstring("No chart uploaded"))
+			})
+		})
+
+		Context("image check", func() {
+			var workDir string
+			var chartPath string
+			var fakeImageChecker *dockerfakes.FakeImageChecker
+
+			BeforeEach(func() {
+				var err error
+				workDir, err = ioutil.TempDir("", "image-check-")
+				Expect(err).To(BeNil())
+
+				chartDir := filepath.Join(workDir, "spacebears")
+				Expect(os.Mkdir(chartDir, 0700)).To(BeNil())
+				testChart := test.DefaultChart()
+				testChart.ValuesYaml = []byte("image: spacebears\nimageTag: 1.0.0\n")
+				testChart.PlanContents["medium"] = []byte("imageTag: 1.1.0\n")
+				Expect(testChart.WriteChart(chartDir)).To(BeNil())
+				chart, err := helm.NewChart(chartDir, nil, logger)
+				Expect(err).To(BeNil())
+				chartPath, err = chartutil.Save(&chart.Chart, workDir)
+				Expect(err).To(BeNil())
+
+				fakeImageChecker = &dockerfakes.FakeImageChecker{}
+				api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{ImageChecker: fakeImageChecker, Registries: &config.RegistryConfig{Server: "registry.example.com"}}, logger)
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(workDir)
+			})
+
+			It("checks the images of every plan", func() {
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{chartPath})
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(200))
+				Expect(fakeImageChecker.CheckImagesCallCount()).To(Equal(1))
+				Expect(fakeImageChecker.CheckImagesArgsForCall(0)).To(ConsistOf(
+					"registry.example.com/spacebears:1.0.0", "registry.example.com/spacebears:1.1.0",
+				))
+				Expect(repo.StageSaveChartCallCount()).To(Equal(1))
+			})
+
+			It("400s when images are missing", func() {
+				fakeImageChecker.CheckImagesReturns(&docker.MissingImagesError{Images: []string{"registry.example.com/spacebears:1.1.0"}})
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{chartPath})
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(400))
+				Expect(recorder.Body.String()).To(ContainSubstring("registry.example.com/spacebears:1.1.0"))
+				Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			})
+
+			It("500s when the registry can't be checked", func() {
+				fakeImageChecker.CheckImagesReturns(errors.New("registry down"))
+				req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{chartPath})
+				Expect(err).To(BeNil())
+				recorder := httptest.NewRecorder()
+
+				api.Charts().ServeHTTP(recorder, req)
+
+				Expect(recorder.Code).To(Equal(500))
+				Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			})
+		})
+
 		It("calls kibosh reload charts", func() {
 			req, err := createRequestWithFile()
 			Expect(err).To(BeNil())
@@ -168,7 +770,7 @@ var _ = Describe("Api", func() {
 				Pass:   "monkey123",
 			}
 
-			api = bazaar.NewAPI(&repo, kiboshConfig, logger)
+			api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{}, logger)
 
 			req, err := createRequestWithFile()
 			Expect(err).To(BeNil())
@@ -177,10 +779,90 @@ var _ = Describe("Api", func() {
 			apiHandler := api.Charts()
 			apiHandler.ServeHTTP(recorder, req)
 			Expect(recorder.Code).To(Equal(500))
+			Expect(recorder.Body.String()).To(ContainSubstring("rolled back"))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+			Expect(stagedChange.CommitCallCount()).To(BeZero())
+		})
+
+		It("commits the change once kibosh reloads", func() {
+			req, err := createRequestWithFile()
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(stagedChange.CommitCallCount()).To(Equal(1))
+			Expect(stagedChange.RollbackCallCount()).To(BeZero())
+		})
+
+		It("retries kibosh reload with backoff", func() {
+			kiboshAPITestServer.Close()
+			reloads := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reloads++
+				if reloads < 3 {
+					w.WriteHeader(500)
+				}
+			})
+			kiboshAPITestServer = httptest.NewServer(handler)
+			kiboshConfig.Server = kiboshAPITestServer.URL
+			kiboshConfig.ReloadAttempts = 3
+			kiboshConfig.ReloadBackoff = time.Millisecond
+
+			req, err := createRequestWithFile()
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(reloads).To(Equal(3))
+			Expect(stagedChange.CommitCallCount()).To(Equal(1))
+		})
+
+		It("rolls back and reloads kibosh again once retries run out", func() {
+			kiboshAPITestServer.Close()
+			reloads := 0
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				reloads++
+				w.WriteHeader(500)
+			})
+			kiboshAPITestServer = httptest.NewServer(handler)
+			kiboshConfig.Server = kiboshAPITestServer.URL
+			kiboshConfig.ReloadAttempts = 2
+			kiboshConfig.ReloadBackoff = time.Millisecond
+
+			req, err := createRequestWithFile()
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(500))
+			Expect(reloads).To(Equal(3))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+		})
+
+		It("rolls back every chart when one of several fails to save", func() {
+			repo.StageSaveChartReturnsOnCall(1, nil, errors.New("failed to save charts"))
+			first, err := ioutil.TempFile("", "")
+			Expect(err).To(BeNil())
+			second, err := ioutil.TempFile("", "")
+			Expect(err).To(BeNil())
+			req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{first.Name(), second.Name()})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(500))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/reload_charts"))
 		})
 
 		It("writes error when save to repo fails", func() {
-			repo.SaveChartReturns(errors.New("failed to save charts"))
+			repo.StageSaveChartReturns(nil, errors.New("failed to save charts"))
 			req, err := createRequestWithFile()
 			Expect(err).To(BeNil())
 			recorder := httptest.NewRecorder()
@@ -189,7 +871,383 @@ var _ = Describe("Api", func() {
 			apiHandler.ServeHTTP(recorder, req)
 
 			Expect(recorder.Code).To(Equal(500))
-			Expect(repo.SaveChartCallCount()).To(Equal(1))
+			Expect(repo.StageSaveChartCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("Validate chart", func() {
+		It("reports problems without saving", func() {
+			payload, err := ioutil.TempFile("", "")
+			Expect(err).To(BeNil())
+			payload.Write([]byte("not a chart"))
+			payload.Close()
+			req, err := httphelpers.CreateFormRequest("/charts/validate", "chart", []string{payload.Name()})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			reports := []helm.ValidationReport{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &reports)).To(BeNil())
+			Expect(reports).To(HaveLen(1))
+			Expect(reports[0].Valid).To(BeFalse())
+			Expect(reports[0].Problems[0].Check).To(Equal("chart"))
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			Expect(kiboshAPIRequest).To(BeNil())
+		})
+
+		It("400s when no chart uploaded", func() {
+			req, err := httphelpers.CreateFormRequest("/charts/validate", "chart", []string{})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+		})
+	})
+
+	Context("Plans", func() {
+		BeforeEach(func() {
+			spacebearsChart := &helm.MyChart{
+				Plans: map[string]helm.Plan{
+					"small":  {Name: "small", Description: "small plan"},
+					"medium": {Name: "medium"},
+				},
+				Chart: hapi_chart.Chart{
+					Metadata: &hapi_chart.Metadata{Name: "spacebears"},
+				},
+			}
+			repo.GetChartsReturns([]*helm.MyChart{spacebearsChart}, nil)
+		})
+
+		It("lists plans", func() {
+			req, err := http.NewRequest("GET", "/charts/spacebears/plans", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			plans := []bazaar.DisplayPlanDetail{}
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &plans)).To(BeNil())
+			Expect(plans).To(HaveLen(2))
+			Expect(plans[1].Description).To(Equal("small plan"))
+		})
+
+		It("adds plan and saves the changed chart", func() {
+			repo.PackageChartWithPlanReturns("/tmp/plans/spacebears-0.0.1.tgz", nil)
+			values := "persistence:\n  size: 64Gi\n"
+			body, _ := json.Marshal(bazaar.PlanRequest{
+				Name:        "large",
+				Description: "large plan",
+				Values:      &values,
+				Credentials: "apiVersion: v1",
+			})
+			req, err := http.NewRequest("POST", "/charts/spacebears/plans", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.PackageChartWithPlanCallCount()).To(Equal(1))
+			chartName, plan, credentials, _, _ := repo.PackageChartWithPlanArgsForCall(0)
+			Expect(chartName).To(Equal("spacebears"))
+			Expect(plan.Name).To(Equal("large"))
+			Expect(string(plan.Values)).To(ContainSubstring("64Gi"))
+			Expect(string(credentials)).To(Equal("apiVersion: v1"))
+			Expect(repo.StageSaveChartCallCount()).To(Equal(1))
+			savedPath, provenancePath := repo.StageSaveChartArgsForCall(0)
+			Expect(savedPath).To(Equal("/tmp/plans/spacebears-0.0.1.tgz"))
+			Expect(provenancePath).To(BeEmpty())
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/reload_charts"))
+			Expect(stagedChange.CommitCallCount()).To(Equal(1))
+		})
+
+		It("rolls back the plan change when kibosh fails to reload", func() {
+			kiboshAPITestServer.Close()
+			kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(401)
+			}))
+			kiboshConfig.Server = kiboshAPITestServer.URL
+			body, _ := json.Marshal(bazaar.PlanRequest{Name: "large"})
+			req, err := http.NewRequest("POST", "/charts/spacebears/plans", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(500))
+			Expect(recorder.Body.String()).To(ContainSubstring("rolled back"))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+			Expect(stagedChange.CommitCallCount()).To(BeZero())
+		})
+
+		It("400s adding plan that exists", func() {
+			body, _ := json.Marshal(bazaar.PlanRequest{Name: "small"})
+			req, err := http.NewRequest("POST", "/charts/spacebears/plans", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(repo.PackageChartWithPlanCallCount()).To(BeZero())
+		})
+
+		It("400s on plan that fails validation", func() {
+			repo.PackageChartWithPlanReturns("", helm.NewChartValidationError(errors.New("Name [Large] contains invalid characters")))
+			body, _ := json.Marshal(bazaar.PlanRequest{Name: "Large"})
+			req, err := http.NewRequest("POST", "/charts/spacebears/plans", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("invalid characters"))
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+			Expect(kiboshAPIRequest).To(BeNil())
+		})
+
+		It("updates plan", func() {
+			body, _ := json.Marshal(bazaar.PlanRequest{Description: "bigger small plan"})
+			req, err := http.NewRequest("PUT", "/charts/spacebears/plans/small", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			_, plan, credentials, clearCredentials, _ := repo.PackageChartWithPlanArgsForCall(0)
+			Expect(plan.Name).To(Equal("small"))
+			Expect(plan.Description).To(Equal("bigger small plan"))
+			Expect(plan.Values).To(BeNil())
+			Expect(credentials).To(BeEmpty())
+			Expect(clearCredentials).To(BeFalse())
+			Expect(repo.StageSaveChartCallCount()).To(Equal(1))
+		})
+
+		It("clears plan credentials", func() {
+			body, _ := json.Marshal(bazaar.PlanRequest{ClearCredentials: true})
+			req, err := http.NewRequest("PUT", "/charts/spacebears/plans/small", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			_, _, _, clearCredentials, _ := repo.PackageChartWithPlanArgsForCall(0)
+			Expect(clearCredentials).To(BeTrue())
+		})
+
+		It("404s updating unknown plan", func() {
+			body, _ := json.Marshal(bazaar.PlanRequest{})
+			req, err := http.NewRequest("PUT", "/charts/spacebears/plans/large", bytes.NewReader(body))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+			Expect(repo.PackageChartWithPlanCallCount()).To(BeZero())
+		})
+
+		It("deletes plan", func() {
+			repo.PackageChartWithoutPlanReturns("/tmp/plans/spacebears-0.0.1.tgz", nil)
+			req, err := http.NewRequest("DELETE", "/charts/spacebears/plans/medium", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			chartName, planName, _ := repo.PackageChartWithoutPlanArgsForCall(0)
+			Expect(chartName).To(Equal("spacebears"))
+			Expect(planName).To(Equal("medium"))
+			savedPath, provenancePath := repo.StageSaveChartArgsForCall(0)
+			Expect(savedPath).To(Equal("/tmp/plans/spacebears-0.0.1.tgz"))
+			Expect(provenancePath).To(BeEmpty())
+			Expect(repo.StageDeleteChartCallCount()).To(BeZero())
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/reload_charts"))
+		})
+
+		It("rejects deleting the last plan", func() {
+			repo.GetChartsReturns([]*helm.MyChart{{
+				Plans: map[string]helm.Plan{"small": {Name: "small"}},
+				Chart: hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "spacebears"}},
+			}}, nil)
+			req, err := http.NewRequest("DELETE", "/charts/spacebears/plans/small", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(repo.PackageChartWithoutPlanCallCount()).To(BeZero())
+		})
+	})
+
+	It("reloads kibosh over tls", func() {
+		certDir, err := ioutil.TempDir("", "bazaar-tls-")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(certDir)
+		certs, err := test.WriteCertificates(certDir)
+		Expect(err).To(BeNil())
+
+		kiboshAPITestServer.Close()
+		kiboshAPITestServer = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kiboshAPIRequest = r
+		}))
+		kiboshAPITestServer.TLS, err = httphelpers.ServerTLSConfig(certs.ServerCertFile, certs.ServerKeyFile, certs.CACertFile, true)
+		Expect(err).To(BeNil())
+		kiboshAPITestServer.StartTLS()
+
+		kiboshConfig.Server = kiboshAPITestServer.URL
+		kiboshConfig.CACertFile = certs.CACertFile
+		kiboshConfig.ClientCertFile = certs.ClientCertFile
+		kiboshConfig.ClientKeyFile = certs.ClientKeyFile
+		kiboshClient, err := kiboshConfig.HTTPClient()
+		Expect(err).To(BeNil())
+		api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{KiboshClient: kiboshClient}, logger)
+
+		req, err := http.NewRequest("POST", "/charts/mysql/versions/1.0.0/activate", nil)
+		Expect(err).To(BeNil())
+		recorder := httptest.NewRecorder()
+
+		api.Charts().ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(kiboshAPIRequest).NotTo(BeNil())
+		Expect(kiboshAPIRequest.TLS.PeerCertificates[0].Subject.CommonName).To(Equal("bazaar"))
+	})
+
+	Context("Instances", func() {
+		var kiboshResponse string
+		var kiboshStatus int
+
+		BeforeEach(func() {
+			kiboshResponse = `[{"instanceID":"mysql-1","chart":"mysql","releaseStatus":"DEPLOYED","ready":true}]`
+			kiboshStatus = 200
+			kiboshAPITestServer.Close()
+			kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				kiboshAPIRequest = r
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(kiboshStatus)
+				w.Write([]byte(kiboshResponse))
+			}))
+			kiboshConfig.Server = kiboshAPITestServer.URL
+		})
+
+		It("passes instance listings through from kibosh", func() {
+			req, err := http.NewRequest("GET", "/instances?chart=mysql", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(recorder.Body.String()).To(Equal(kiboshResponse))
+			Expect(recorder.Header().Get("Content-Type")).To(Equal("application/json"))
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/instances"))
+			Expect(kiboshAPIRequest.URL.Query().Get("chart")).To(Equal("mysql"))
+			Expect(kiboshAPIRequest.Header.Get("Authorization")).To(Equal(httphelpers.BasicAuthHeaderVal("bob", "monkey123")))
+		})
+
+		It("passes kibosh's errors through", func() {
+			kiboshStatus = 404
+			kiboshResponse = "Service instance not found"
+			req, err := http.NewRequest("GET", "/instances/nope", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+			Expect(kiboshAPIRequest.URL.Path).To(Equal("/instances/nope"))
+		})
+
+		It("fails when kibosh is unreachable", func() {
+			kiboshAPITestServer.Close()
+			req, err := http.NewRequest("GET", "/instances", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(502))
+		})
+
+		It("requires the reader role", func() {
+			req, err := http.NewRequest("GET", "/instances", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleNone))
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(kiboshAPIRequest).To(BeNil())
+		})
+	})
+
+	Context("Roles", func() {
+		serve := func(method string, url string, role httphelpers.Role) *httptest.ResponseRecorder {
+			req, err := http.NewRequest(method, url, nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, httphelpers.WithRole(req, role))
+			return recorder
+		}
+
+		It("lets readers list charts", func() {
+			recorder := serve("GET", "/charts/", httphelpers.RoleReader)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.GetChartsCallCount()).To(Equal(1))
+		})
+
+		It("doesn't let readers change charts", func() {
+			recorder := serve("POST", "/charts/mysql/versions/1.0.0/activate", httphelpers.RoleReader)
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(recorder.Body.String()).To(ContainSubstring("publisher"))
+			Expect(repo.StageActivateChartVersionCallCount()).To(Equal(0))
+		})
+
+		It("lets publishers change charts", func() {
+			recorder := serve("POST", "/charts/mysql/versions/1.0.0/activate", httphelpers.RolePublisher)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.StageActivateChartVersionCallCount()).To(Equal(1))
+		})
+
+		It("doesn't let publishers delete charts", func() {
+			recorder := serve("DELETE", "/charts/mysql", httphelpers.RolePublisher)
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(recorder.Body.String()).To(ContainSubstring("admin"))
+			Expect(repo.StageDeleteChartCallCount()).To(Equal(0))
+		})
+
+		It("lets admins delete charts", func() {
+			recorder := serve("DELETE", "/charts/mysql", httphelpers.RoleAdmin)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.StageDeleteChartCallCount()).To(Equal(1))
+		})
+
+		It("requires the reader role for quarantined charts", func() {
+			req, err := http.NewRequest("GET", "/quarantined_charts", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.QuarantinedCharts().ServeHTTP(recorder, httphelpers.WithRole(req, httphelpers.RoleNone))
+
+			Expect(recorder.Code).To(Equal(403))
+			Expect(repo.GetQuarantinedChartsCallCount()).To(Equal(0))
 		})
 	})
 
@@ -205,7 +1263,7 @@ var _ = Describe("Api", func() {
 			Expect(recorder.Code).To(Equal(500))
 		})
 		It("delete chart fails in repo", func() {
-			repo.DeleteChartReturns(errors.New("Nope. Keeping the chart."))
+			repo.StageDeleteChartReturns(nil, errors.New("Nope. Keeping the chart."))
 
 			req, err := http.NewRequest("DELETE", "/charts/mysql", nil)
 			Expect(err).To(BeNil())
@@ -218,6 +1276,20 @@ var _ = Describe("Api", func() {
 			Expect(recorder.Body).To(ContainSubstring("delete"))
 		})
 
+		It("409s when the chart is being changed by another request", func() {
+			repo.StageDeleteChartReturns(nil, &repository.ChartBusyError{Name: "mysql"})
+
+			req, err := http.NewRequest("DELETE", "/charts/mysql", nil)
+			Expect(err).To(BeNil())
+
+			recorder := httptest.NewRecorder()
+
+			apiHandler := api.Charts()
+			apiHandler.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(409))
+			Expect(recorder.Body).To(ContainSubstring("another request"))
+		})
+
 		It("delete chart fails in updating kibosh", func() {
 
 			kiboshAPITestServer.Close()
@@ -226,7 +1298,7 @@ var _ = Describe("Api", func() {
 			})
 			kiboshAPITestServer = httptest.NewServer(handler)
 
-			api = bazaar.NewAPI(&repo, kiboshConfig, logger)
+			api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{}, logger)
 
 			req, err := http.NewRequest("DELETE", "/charts/mysql", nil)
 			Expect(err).To(BeNil())
@@ -237,6 +1309,7 @@ var _ = Describe("Api", func() {
 			apiHandler.ServeHTTP(recorder, req)
 			Expect(recorder.Code).To(Equal(500))
 			Expect(recorder.Body).To(ContainSubstring("Kibosh"))
+			Expect(stagedChange.RollbackCallCount()).To(Equal(1))
 		})
 
 		It("successfully deleted chart", func() {
@@ -249,7 +1322,7 @@ var _ = Describe("Api", func() {
 			apiHandler := api.Charts()
 			apiHandler.ServeHTTP(recorder, req)
 
-			chartDeleted := repo.DeleteChartArgsForCall(0)
+			chartDeleted := repo.StageDeleteChartArgsForCall(0)
 			Expect(chartDeleted).To(Equal("mysql"))
 
 			Expect(recorder.Code).To(Equal(200))
@@ -282,7 +1355,7 @@ var _ = Describe("Api", func() {
 			apiHandler.ServeHTTP(recorder, req)
 
 			Expect(recorder.Code).To(Equal(400))
-			Expect(repo.DeleteChartCallCount()).To(BeZero())
+			Expect(repo.StageDeleteChartCallCount()).To(BeZero())
 
 		})
 	})
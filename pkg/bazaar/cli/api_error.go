@@ -0,0 +1,77 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// Exit codes of bazaarcli, so scripts can tell why a command failed
+const (
+	ExitOK           = 0
+	ExitError        = 1
+	ExitBadRequest   = 2
+	ExitUnauthorized = 3
+	ExitNotFound     = 4
+	ExitServerError  = 5
+)
+
+// APIError is returned by commands when the bazaar API responds with a non-OK status
+type APIError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Non-OK response code from API [%v]\nMessage from server: %v\n", e.Status, e.Message)
+}
+
+func newAPIError(res *http.Response) error {
+	body, _ := ioutil.ReadAll(res.Body)
+	return &APIError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Message:    string(body),
+	}
+}
+
+// ExitCode maps the error returned by a command to the exit code of bazaarcli
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	apiErr, ok := errors.Cause(err).(*APIError)
+	if !ok {
+		return ExitError
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusNotFound:
+		return ExitNotFound
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden:
+		return ExitUnauthorized
+	case apiErr.StatusCode >= 500:
+		return ExitServerError
+	case apiErr.StatusCode >= 400:
+		return ExitBadRequest
+	}
+	return ExitError
+}
@@ -0,0 +1,59 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	pkgerrors "github.com/pkg/errors"
+)
+
+var _ = Describe("Exit codes", func() {
+	It("exits zero without an error", func() {
+		Expect(cli.ExitCode(nil)).To(Equal(cli.ExitOK))
+	})
+
+	It("exits one for errors that aren't from the api", func() {
+		Expect(cli.ExitCode(errors.New("missing chart name"))).To(Equal(cli.ExitError))
+	})
+
+	It("maps api errors by status code", func() {
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusBadRequest})).To(Equal(cli.ExitBadRequest))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusConflict})).To(Equal(cli.ExitBadRequest))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusUnauthorized})).To(Equal(cli.ExitUnauthorized))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusForbidden})).To(Equal(cli.ExitUnauthorized))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusNotFound})).To(Equal(cli.ExitNotFound))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusInternalServerError})).To(Equal(cli.ExitServerError))
+		Expect(cli.ExitCode(&cli.APIError{StatusCode: http.StatusBadGateway})).To(Equal(cli.ExitServerError))
+	})
+
+	It("maps wrapped api errors", func() {
+		err := pkgerrors.Wrap(&cli.APIError{StatusCode: http.StatusNotFound}, "pulling chart")
+
+		Expect(cli.ExitCode(err)).To(Equal(cli.ExitNotFound))
+	})
+
+	It("keeps the server's message in the error", func() {
+		err := &cli.APIError{StatusCode: 404, Status: "404 Not Found", Message: "Chart not found"}
+
+		Expect(err.Error()).To(Equal("Non-OK response code from API [404 Not Found]\nMessage from server: Chart not found\n"))
+	})
+})
@@ -16,19 +16,44 @@
 package cli
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
 type baseBazaarCmd struct {
-	target string
-	user   string
-	pass   string
-	out    io.Writer
+	target         string
+	user           string
+	pass           string
+	token          string
+	clientID       string
+	clientSecret   string
+	tokenURL       string
+	caCert         string
+	cert           string
+	key            string
+	skipSSL        bool
+	output         string
+	chunkSize      int64
+	resumeUploadID string
+	out            io.Writer
 }
 
+const (
+	OutputTable = "table"
+	OutputJSON  = "json"
+	OutputYAML  = "yaml"
+)
+
 func (b *baseBazaarCmd) preRun(cmd *cobra.Command, args []string) {
 	if strings.HasSuffix(b.target, "/") {
 		b.target = b.target[:len(b.target)-1]
@@ -36,12 +61,161 @@ func (b *baseBazaarCmd) preRun(cmd *cobra.Command, args []string) {
 }
 
 func (b *baseBazaarCmd) addCommonFlags(cmd *cobra.Command) {
-	//todo: -k flag for ignore ssl
-
-	cmd.Flags().StringVarP(&b.target, "target", "t", "", "bazaar API url (required)")
-	cobra.MarkFlagRequired(cmd.Flags(), "target")
-	cmd.Flags().StringVarP(&b.user, "user", "u", "", "bazaar API user (required)")
-	cobra.MarkFlagRequired(cmd.Flags(), "user")
-	cmd.Flags().StringVarP(&b.pass, "password", "p", "", "bazaar API password (required)")
-	cobra.MarkFlagRequired(cmd.Flags(), "password")
+	cmd.Flags().StringVarP(&b.target, "target", "t", "", "bazaar API url, or name of a target added with 'target add' (defaults to the current target)")
+	cmd.Flags().StringVarP(&b.user, "user", "u", "", "bazaar API user")
+	cmd.Flags().StringVarP(&b.pass, "password", "p", "", "bazaar API password")
+	cmd.Flags().StringVar(&b.token, "token", "", "bearer token for the bazaar API, instead of user and password")
+	cmd.Flags().StringVar(&b.clientID, "client-id", "", "client to fetch a bearer token for, instead of user and password")
+	cmd.Flags().StringVar(&b.clientSecret, "client-secret", "", "secret of the client fetching a bearer token")
+	cmd.Flags().StringVar(&b.tokenURL, "token-url", "", "token endpoint to fetch a bearer token from, such as https://uaa.example.com/oauth/token")
+	cmd.Flags().StringVar(&b.caCert, "ca-cert", "", "CA bundle to trust instead of the system's")
+	cmd.Flags().StringVar(&b.cert, "cert", "", "client certificate, for a bazaar API that verifies them")
+	cmd.Flags().StringVar(&b.key, "key", "", "client certificate key")
+	cmd.Flags().BoolVarP(&b.skipSSL, "skip-ssl-validation", "k", false, "don't verify the server's certificate, for dev environments only")
+	b.addOutputFlags(cmd)
+}
+
+func (b *baseBazaarCmd) addOutputFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&b.output, "output", "o", OutputTable, "output format, one of table, json or yaml")
+}
+
+// setup checks the output format and resolves the target before a command calls the API
+func (b *baseBazaarCmd) setup() error {
+	_, err := b.outputFormat()
+	if err != nil {
+		return err
+	}
+	return b.resolveTarget()
+}
+
+// resolveTarget looks up --target in the config file unless it's a url, falling back to the
+// current target, and fills in any credentials not given as flags from the target
+func (b *baseBazaarCmd) resolveTarget() error {
+	if strings.Contains(b.target, "://") {
+		b.target = strings.TrimSuffix(b.target, "/")
+		return nil
+	}
+
+	config, err := loadTargetConfig()
+	if err != nil {
+		return err
+	}
+	name := b.target
+	if name == "" {
+		name = config.Current
+	}
+	if name == "" {
+		return errors.New("no target, use --target or add one with 'target add'")
+	}
+	t := config.find(name)
+	if t == nil {
+		return errors.Errorf("unknown target [%s], add it with 'target add'", name)
+	}
+
+	b.useTarget(t)
+	return nil
+}
+
+func (b *baseBazaarCmd) useTarget(t *target) {
+	b.target = strings.TrimSuffix(t.URL, "/")
+	if b.user == "" {
+		b.user = t.User
+	}
+	if b.pass == "" {
+		b.pass = t.password()
+	}
+	if b.clientID == "" {
+		b.clientID = t.ClientID
+	}
+	if b.clientSecret == "" {
+		b.clientSecret = t.clientSecret()
+	}
+	if b.tokenURL == "" {
+		b.tokenURL = t.TokenURL
+	}
+	if b.caCert == "" {
+		b.caCert = t.CACert
+	}
+	if b.cert == "" {
+		b.cert = t.Cert
+	}
+	if b.key == "" {
+		b.key = t.Key
+	}
+	b.skipSSL = b.skipSSL || t.SkipSSLValidation
+}
+
+func (b *baseBazaarCmd) outputFormat() (string, error) {
+	switch b.output {
+	case "", OutputTable:
+		return OutputTable, nil
+	case OutputJSON, OutputYAML:
+		return b.output, nil
+	}
+	return "", errors.Errorf("unknown output format [%s], expected one of table, json or yaml", b.output)
+}
+
+// print writes value as json or yaml, using the same field names as the bazaar API, or calls
+// printTable for the default human readable output
+func (b *baseBazaarCmd) print(value interface{}, printTable func()) error {
+	format, err := b.outputFormat()
+	if err != nil {
+		return err
+	}
+
+	var serialized []byte
+	switch format {
+	case OutputJSON:
+		serialized, err = json.MarshalIndent(value, "", "  ")
+	case OutputYAML:
+		serialized, err = yaml.Marshal(value)
+	default:
+		printTable()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = b.out.Write(append(bytes.TrimRight(serialized, "\n"), '\n'))
+	return err
+}
+
+// printMessage prints a message from the server as {"message": ...} for json and yaml output
+func (b *baseBazaarCmd) printMessage(message string) error {
+	return b.print(bazaar.DisplayResponse{Message: message}, func() {
+		b.out.Write([]byte(fmt.Sprintf("Message from server: %s\n", message)))
+	})
+}
+
+func (b *baseBazaarCmd) httpClient() (*http.Client, error) {
+	return httphelpers.NewHTTPClient(b.caCert, b.cert, b.key, b.skipSSL)
+}
+
+// addAuthHeader authenticates with a token when given one or client credentials to fetch one,
+// and with user and password otherwise
+func (b *baseBazaarCmd) addAuthHeader(req *http.Request) error {
+	if b.token == "" && b.clientID != "" {
+		if b.tokenURL == "" {
+			return errors.New("fetching a token with --client-id requires --token-url")
+		}
+		client, err := b.httpClient()
+		if err != nil {
+			return err
+		}
+		token, err := httphelpers.FetchClientCredentialsToken(client, b.tokenURL, b.clientID, b.clientSecret)
+		if err != nil {
+			return err
+		}
+		b.token = token
+	}
+
+	if b.token != "" {
+		httphelpers.AddBearerAuthHeader(req, b.token)
+		return nil
+	}
+	if b.user == "" || b.pass == "" {
+		return errors.New("either --token, --client-id or --user and --password are required")
+	}
+	httphelpers.AddBasicAuthHeader(req, b.user, b.pass)
+	return nil
 }
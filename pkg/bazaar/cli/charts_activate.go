@@ -0,0 +1,101 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/spf13/cobra"
+)
+
+type chartsActivateCmd struct {
+	baseBazaarCmd
+	name    string
+	version string
+}
+
+func NewChartsActivateCmd(out io.Writer) *cobra.Command {
+	ca := &chartsActivateCmd{}
+	ca.out = out
+
+	cmd := &cobra.Command{
+		Use:   "activate CHART-NAME VERSION",
+		Short: "make a stored version of a chart the one used by the catalog",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			ca.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("missing chart name or version")
+			}
+			ca.name = args[0]
+			ca.version = args[1]
+			return ca.run()
+		},
+	}
+
+	ca.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (ca *chartsActivateCmd) run() error {
+	if err := ca.setup(); err != nil {
+		return err
+	}
+
+	client, err := ca.httpClient()
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/charts/%s/versions/%s/activate", ca.target, ca.name, ca.version)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return err
+	}
+	err = ca.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	responseJSON := bazaar.DisplayResponse{}
+	err = json.Unmarshal(responseBody, &responseJSON)
+	if err != nil {
+		return err
+	}
+
+	return ca.printMessage(responseJSON.Message)
+}
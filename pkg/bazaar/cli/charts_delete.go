@@ -24,7 +24,6 @@ import (
 	"net/http"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
-	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/spf13/cobra"
 )
 
@@ -58,13 +57,23 @@ func NewChartsDeleteCmd(out io.Writer) *cobra.Command {
 }
 
 func (cd *chartsDeleteCmd) run() error {
-	client := &http.Client{}
+	if err := cd.setup(); err != nil {
+		return err
+	}
+
+	client, err := cd.httpClient()
+	if err != nil {
+		return err
+	}
 	url := fmt.Sprintf("%s/charts/%s", cd.target, cd.name)
 	req, err := http.NewRequest("DELETE", url, nil)
 	if err != nil {
 		return err
 	}
-	httphelpers.AddBasicAuthHeader(req, cd.user, cd.pass)
+	err = cd.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -72,8 +81,7 @@ func (cd *chartsDeleteCmd) run() error {
 	}
 
 	if res.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(res.Body)
-		return errors.New(fmt.Sprintf("Non-OK response code from API [%v]\nMessage from server: %v\n", res.Status, string(body)))
+		return newAPIError(res)
 	}
 
 	responseBody, err := ioutil.ReadAll(res.Body)
@@ -87,7 +95,5 @@ func (cd *chartsDeleteCmd) run() error {
 		return err
 	}
 
-	cd.out.Write([]byte(fmt.Sprintf("Message from server: %s\n", responseJSON.Message)))
-
-	return nil
+	return cd.printMessage(responseJSON.Message)
 }
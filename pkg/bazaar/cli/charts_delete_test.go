@@ -78,6 +78,42 @@ var _ = Describe("Delete charts", func() {
 
 	})
 
+	It("prints the server's message as json", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"message":"Yay"}`))
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{
+			"casandra",
+		})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(MatchJSON(`{"message":"Yay"}`))
+	})
+
+	It("returns an error with the not found exit code for unknown charts", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+			w.Write([]byte(`{"message":"Chart not found"}`))
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err := c.RunE(c, []string{
+			"casandra",
+		})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Chart not found"))
+		Expect(cli.ExitCode(err)).To(Equal(cli.ExitNotFound))
+	})
+
 	It("correctly auths request", func() {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("{}"))
@@ -23,9 +23,7 @@ import (
 	"net/http"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
-	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/gosuri/uitable"
-	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 )
 
@@ -54,13 +52,23 @@ func NewChartsListCmd(out io.Writer) *cobra.Command {
 }
 
 func (cl *chartsListCmd) run() error {
-	client := &http.Client{}
+	if err := cl.setup(); err != nil {
+		return err
+	}
+
+	client, err := cl.httpClient()
+	if err != nil {
+		return err
+	}
 	url := fmt.Sprintf("%s/charts", cl.target)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return err
 	}
-	httphelpers.AddBasicAuthHeader(req, cl.user, cl.pass)
+	err = cl.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
 
 	res, err := client.Do(req)
 	if err != nil {
@@ -68,8 +76,7 @@ func (cl *chartsListCmd) run() error {
 	}
 
 	if res.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(res.Body)
-		return errors.New(fmt.Sprintf("Non-OK response code from API [%v]\nMessage from server: %v\n", res.Status, string(body)))
+		return newAPIError(res)
 	}
 
 	body, err := ioutil.ReadAll(res.Body)
@@ -77,20 +84,20 @@ func (cl *chartsListCmd) run() error {
 		return err
 	}
 
-	var charts []bazaar.DisplayChart
-	json.Unmarshal(body, &charts)
+	charts := []bazaar.DisplayChart{}
+	err = json.Unmarshal(body, &charts)
 	if err != nil {
 		return err
 	}
 
-	table := uitable.New()
-	table.AddRow("NAME", "VERSION", "PLANS")
-	for _, c := range charts {
-		table.AddRow(c.Name, c.Version, fmt.Sprintf("%+v", c.Plans))
-	}
-
-	cl.out.Write(table.Bytes())
-	cl.out.Write([]byte("\n"))
+	return cl.print(charts, func() {
+		table := uitable.New()
+		table.AddRow("NAME", "VERSION", "PLANS", "VERSIONS")
+		for _, c := range charts {
+			table.AddRow(c.Name, c.Version, fmt.Sprintf("%+v", c.Plans), fmt.Sprintf("%+v", c.Versions))
+		}
 
-	return nil
+		cl.out.Write(table.Bytes())
+		cl.out.Write([]byte("\n"))
+	})
 }
@@ -19,8 +19,12 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -101,6 +105,82 @@ var _ = Describe("List charts", func() {
 		)
 	})
 
+	It("auths request with a token", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+			bazaarAPIRequest = r
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+		c.Flags().Set("token", "my-token")
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.Header.Get("Authorization")).To(Equal("Bearer my-token"))
+	})
+
+	It("auths request with a token fetched with client credentials", func() {
+		var tokenRequest *http.Request
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/oauth/token" {
+				tokenRequest = r
+				w.Write([]byte(`{"access_token":"fetched-token","token_type":"bearer"}`))
+				return
+			}
+			w.Write([]byte("[]"))
+			bazaarAPIRequest = r
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+		c.Flags().Set("client-id", "bazaar-ci")
+		c.Flags().Set("client-secret", "secret")
+		c.Flags().Set("token-url", bazaarAPITestServer.URL+"/oauth/token")
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		user, pass, _ := tokenRequest.BasicAuth()
+		Expect(user).To(Equal("bazaar-ci"))
+		Expect(pass).To(Equal("secret"))
+		Expect(bazaarAPIRequest.Header.Get("Authorization")).To(Equal("Bearer fetched-token"))
+	})
+
+	It("connects over tls", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("[]"))
+			bazaarAPIRequest = r
+		})
+		bazaarAPITestServer = httptest.NewTLSServer(handler)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err := c.RunE(c, []string{})
+		Expect(err).NotTo(BeNil())
+
+		c.Flags().Set("skip-ssl-validation", "true")
+
+		err = c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.TLS).NotTo(BeNil())
+	})
+
+	It("requires some credentials", func() {
+		bazaarAPITestServer = httptest.NewServer(http.NotFoundHandler())
+		c = cli.NewChartsListCmd(out)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err := c.RunE(c, []string{})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("--token"))
+	})
+
 	It("auth failure list charts", func() {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(401)
@@ -114,5 +194,144 @@ var _ = Describe("List charts", func() {
 
 		Expect(err).NotTo(BeNil())
 		Expect(err.Error()).To(ContainSubstring("401"))
+		Expect(cli.ExitCode(err)).To(Equal(cli.ExitUnauthorized))
+	})
+
+	Context("targets", func() {
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("[]"))
+				bazaarAPIRequest = r
+			})
+			bazaarAPITestServer = httptest.NewServer(handler)
+
+			c = cli.NewChartsListCmd(out)
+			config := fmt.Sprintf(`
+current: dev
+targets:
+- name: dev
+  url: %s
+  user: dev-admin
+  password: dev-password
+- name: prod
+  url: %s
+  user: prod-admin
+  passwordEnv: BAZAAR_TEST_PROD_PASSWORD
+`, bazaarAPITestServer.URL, bazaarAPITestServer.URL+"/")
+			err := ioutil.WriteFile(filepath.Join(bazaarHome, "config.yaml"), []byte(config), 0600)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			os.Unsetenv("BAZAAR_TEST_PROD_PASSWORD")
+		})
+
+		It("uses the current target without --target", func() {
+			err := c.RunE(c, []string{})
+
+			Expect(err).To(BeNil())
+			Expect(bazaarAPIRequest.Header.Get("Authorization")).To(
+				Equal(httphelpers.BasicAuthHeaderVal("dev-admin", "dev-password")),
+			)
+		})
+
+		It("uses a named target, reading its password from the environment", func() {
+			os.Setenv("BAZAAR_TEST_PROD_PASSWORD", "prod-password")
+			c.Flags().Set("target", "prod")
+
+			err := c.RunE(c, []string{})
+
+			Expect(err).To(BeNil())
+			Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts"))
+			Expect(bazaarAPIRequest.Header.Get("Authorization")).To(
+				Equal(httphelpers.BasicAuthHeaderVal("prod-admin", "prod-password")),
+			)
+		})
+
+		It("prefers credentials given as flags", func() {
+			c.Flags().Set("token", "my-token")
+
+			err := c.RunE(c, []string{})
+
+			Expect(err).To(BeNil())
+			Expect(bazaarAPIRequest.Header.Get("Authorization")).To(Equal("Bearer my-token"))
+		})
+
+		It("returns an error for unknown targets", func() {
+			c.Flags().Set("target", "staging")
+
+			err := c.RunE(c, []string{})
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unknown target [staging]"))
+		})
+
+		It("requires a target", func() {
+			Expect(os.Remove(filepath.Join(bazaarHome, "config.yaml"))).To(Succeed())
+
+			err := c.RunE(c, []string{})
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("no target"))
+		})
+	})
+
+	Context("output", func() {
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`[{"name":"mysql","version":"0.1","plans":["small"],"versions":["0.1"]}]`))
+				bazaarAPIRequest = r
+			})
+			bazaarAPITestServer = httptest.NewServer(handler)
+			c.Flags().Set("target", bazaarAPITestServer.URL)
+		})
+
+		It("prints json", func() {
+			c.Flags().Set("output", "json")
+
+			err := c.RunE(c, []string{})
+			out.Flush()
+
+			Expect(err).To(BeNil())
+			Expect(b.String()).To(MatchJSON(`[{"name":"mysql","version":"0.1","plans":["small"],"versions":["0.1"]}]`))
+		})
+
+		It("prints yaml", func() {
+			c.Flags().Set("output", "yaml")
+
+			err := c.RunE(c, []string{})
+			out.Flush()
+
+			Expect(err).To(BeNil())
+			Expect(b.String()).To(MatchYAML(`- name: mysql
+  version: "0.1"
+  plans: [small]
+  versions: ["0.1"]
+`))
+		})
+
+		It("prints an empty list as json", func() {
+			bazaarAPITestServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("[]"))
+			})
+			c.Flags().Set("output", "json")
+
+			err := c.RunE(c, []string{})
+			out.Flush()
+
+			Expect(err).To(BeNil())
+			Expect(b.String()).To(Equal("[]\n"))
+		})
+
+		It("rejects unknown formats before calling the api", func() {
+			bazaarAPIRequest = nil
+			c.Flags().Set("output", "xml")
+
+			err := c.RunE(c, []string{})
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unknown output format [xml]"))
+			Expect(bazaarAPIRequest).To(BeNil())
+		})
 	})
 })
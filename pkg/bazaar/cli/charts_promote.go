@@ -0,0 +1,141 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type promotedChart struct {
+	Name    string `json:"name"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+	Message string `json:"message"`
+}
+
+type chartsPromoteCmd struct {
+	baseBazaarCmd
+	name               string
+	from               string
+	to                 string
+	includeCredentials bool
+}
+
+func NewChartsPromoteCmd(out io.Writer) *cobra.Command {
+	cp := &chartsPromoteCmd{}
+	cp.out = out
+
+	cmd := &cobra.Command{
+		Use:   "promote CHART-NAME --from TARGET --to TARGET",
+		Short: "copy the active version of a chart from one target to another",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing chart name")
+			}
+			cp.name = args[0]
+			return cp.run()
+		},
+	}
+
+	cmd.Flags().StringVar(&cp.from, "from", "", "target to pull the chart from (required)")
+	cmd.Flags().StringVar(&cp.to, "to", "", "target to save the chart to (required)")
+	cmd.Flags().BoolVar(&cp.includeCredentials, "include-credentials", false, "copy the plans' cluster credentials too (requires the admin role on the --from target)")
+	cobra.MarkFlagRequired(cmd.Flags(), "from")
+	cobra.MarkFlagRequired(cmd.Flags(), "to")
+	cp.baseBazaarCmd.addOutputFlags(cmd)
+
+	return cmd
+}
+
+func (cp *chartsPromoteCmd) run() error {
+	if _, err := cp.outputFormat(); err != nil {
+		return err
+	}
+	if cp.from == "" || cp.to == "" {
+		return errors.New("both --from and --to targets are required")
+	}
+
+	// each target brings its own credentials from the config file
+	from := &baseBazaarCmd{target: cp.from}
+	err := from.resolveTarget()
+	if err != nil {
+		return err
+	}
+	to := &baseBazaarCmd{target: cp.to, chunkSize: defaultChunkSize}
+	err = to.resolveTarget()
+	if err != nil {
+		return err
+	}
+
+	if !cp.includeCredentials {
+		err = cp.checkNoCustomClusters(from)
+		if err != nil {
+			return err
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "bazaar-promote")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	chartPath, err := from.downloadChart(cp.name, dir, cp.includeCredentials)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to pull chart [%s] from [%s]", cp.name, cp.from)
+	}
+	provenancePath, err := from.downloadProvenance(cp.name, chartPath, cp.includeCredentials)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to pull the provenance file of chart [%s] from [%s]", cp.name, cp.from)
+	}
+	message, err := to.uploadChart([]string{chartPath}, provenancePath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to save chart [%s] to [%s]", cp.name, cp.to)
+	}
+
+	promoted := promotedChart{Name: cp.name, From: cp.from, To: cp.to, Message: message}
+	return cp.print(promoted, func() {
+		cp.out.Write([]byte(fmt.Sprintf("Chart [%s] promoted from [%s] to [%s]\n", cp.name, cp.from, cp.to)))
+		cp.out.Write([]byte(fmt.Sprintf("Message from server: %s\n", message)))
+	})
+}
+
+// checkNoCustomClusters fails when a plan of the chart deploys to its own cluster, as the archive
+// pulled without credentials leaves out the credentials files plans.yaml still refers to
+func (cp *chartsPromoteCmd) checkNoCustomClusters(from *baseBazaarCmd) error {
+	chart, err := from.getChartDetail(cp.name)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to pull chart [%s] from [%s]", cp.name, cp.from)
+	}
+	customClusters := []string{}
+	for _, plan := range chart.Plans {
+		if plan.CustomCluster {
+			customClusters = append(customClusters, plan.Name)
+		}
+	}
+	if len(customClusters) > 0 {
+		return errors.Errorf("Plans [%s] of chart [%s] deploy to their own clusters, use --include-credentials to promote their credentials too",
+			strings.Join(customClusters, ", "), cp.name)
+	}
+	return nil
+}
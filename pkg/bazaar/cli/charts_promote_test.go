@@ -0,0 +1,214 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Promote chart", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var devRequest *http.Request
+	var detailRequest *http.Request
+	var provenanceRequest *http.Request
+	var prodRequest *http.Request
+	var provenance string
+	var plans string
+	var savedChart []byte
+	var savedProvenance []byte
+	var devServer *httptest.Server
+	var prodServer *httptest.Server
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewChartsPromoteCmd(out)
+
+		provenance = ""
+		plans = `[{"name":"small","customCluster":false}]`
+		savedProvenance = nil
+		devServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/charts/spacebears" {
+				detailRequest = r
+				w.Write([]byte(`{"name":"spacebears","version":"0.0.1","plans":` + plans + `}`))
+				return
+			}
+			if r.URL.Path == "/charts/spacebears/provenance" {
+				provenanceRequest = r
+				if provenance == "" {
+					w.WriteHeader(404)
+					return
+				}
+				w.Write([]byte(provenance))
+				return
+			}
+			devRequest = r
+			w.Header().Set("Content-Disposition", `attachment; filename="spacebears-0.0.1.tgz"`)
+			w.Write([]byte("chart archive"))
+		}))
+		prodServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			prodRequest = r
+			file, _, err := r.FormFile("chart")
+			Expect(err).To(BeNil())
+			savedChart, _ = ioutil.ReadAll(file)
+			provenanceFile, _, err := r.FormFile("provenance")
+			if err == nil {
+				savedProvenance, _ = ioutil.ReadAll(provenanceFile)
+			}
+			w.Write([]byte(`{"message":"Chart saved"}`))
+		}))
+
+		config := fmt.Sprintf(`
+current: dev
+targets:
+- name: dev
+  url: %s
+  user: dev-admin
+  password: dev-password
+- name: prod
+  url: %s
+  user: prod-admin
+  password: prod-password
+`, devServer.URL, prodServer.URL)
+		err := ioutil.WriteFile(filepath.Join(bazaarHome, "config.yaml"), []byte(config), 0600)
+		Expect(err).To(BeNil())
+
+		c.Flags().Set("from", "dev")
+		c.Flags().Set("to", "prod")
+	})
+
+	AfterEach(func() {
+		devServer.Close()
+		prodServer.Close()
+	})
+
+	It("pulls the chart from one target and saves it to the other", func() {
+		err := c.RunE(c, []string{"spacebears"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(devRequest.URL.Path).To(Equal("/charts/spacebears/archive"))
+		Expect(devRequest.Header.Get("Authorization")).To(
+			Equal(httphelpers.BasicAuthHeaderVal("dev-admin", "dev-password")),
+		)
+		Expect(prodRequest.Method).To(Equal("POST"))
+		Expect(prodRequest.URL.Path).To(Equal("/charts"))
+		Expect(prodRequest.Header.Get("Authorization")).To(
+			Equal(httphelpers.BasicAuthHeaderVal("prod-admin", "prod-password")),
+		)
+		Expect(string(savedChart)).To(Equal("chart archive"))
+		Expect(savedProvenance).To(BeNil())
+		Expect(b.String()).To(ContainSubstring("Chart [spacebears] promoted from [dev] to [prod]"))
+	})
+
+	It("forwards the chart's provenance file", func() {
+		provenance = "signature"
+
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).To(BeNil())
+		Expect(provenanceRequest.Header.Get("Authorization")).To(
+			Equal(httphelpers.BasicAuthHeaderVal("dev-admin", "dev-password")),
+		)
+		Expect(string(savedChart)).To(Equal("chart archive"))
+		Expect(string(savedProvenance)).To(Equal("signature"))
+	})
+
+	It("leaves plan credentials out", func() {
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).To(BeNil())
+		Expect(devRequest.URL.Query().Get("credentials")).To(BeEmpty())
+		Expect(provenanceRequest.URL.Query().Get("credentials")).To(BeEmpty())
+	})
+
+	It("refuses to promote plans with their own cluster without their credentials", func() {
+		prodRequest = nil
+		devRequest = nil
+		plans = `[{"name":"small","customCluster":false},{"name":"dedicated","customCluster":true}]`
+
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("[dedicated]"))
+		Expect(err.Error()).To(ContainSubstring("--include-credentials"))
+		Expect(detailRequest.Header.Get("Authorization")).To(
+			Equal(httphelpers.BasicAuthHeaderVal("dev-admin", "dev-password")),
+		)
+		Expect(devRequest).To(BeNil())
+		Expect(prodRequest).To(BeNil())
+	})
+
+	It("copies plan credentials when asked to", func() {
+		plans = `[{"name":"dedicated","customCluster":true}]`
+		c.Flags().Set("include-credentials", "true")
+
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).To(BeNil())
+		Expect(devRequest.URL.Query().Get("credentials")).To(Equal("true"))
+		Expect(provenanceRequest.URL.Query().Get("credentials")).To(Equal("true"))
+	})
+
+	It("prints the promotion as json", func() {
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{"spacebears"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(MatchJSON(`{"name":"spacebears","from":"dev","to":"prod","message":"Chart saved"}`))
+	})
+
+	It("doesn't save anything when the chart can't be pulled", func() {
+		prodRequest = nil
+		devServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		})
+
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Unable to pull chart [spacebears] from [dev]"))
+		Expect(cli.ExitCode(err)).To(Equal(cli.ExitNotFound))
+		Expect(prodRequest).To(BeNil())
+	})
+
+	It("returns an error for unknown targets", func() {
+		c.Flags().Set("to", "staging")
+
+		err := c.RunE(c, []string{"spacebears"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("unknown target [staging]"))
+	})
+})
@@ -0,0 +1,164 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type pulledChart struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+type chartsPullCmd struct {
+	baseBazaarCmd
+	name        string
+	destination string
+}
+
+func NewChartsPullCmd(out io.Writer) *cobra.Command {
+	cp := &chartsPullCmd{}
+	cp.out = out
+
+	cmd := &cobra.Command{
+		Use:   "pull NAME",
+		Short: "download a chart from repository as a .tgz",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			cp.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing chart name")
+			}
+			cp.name = args[0]
+			return cp.run()
+		},
+	}
+
+	cp.baseBazaarCmd.addCommonFlags(cmd)
+	cmd.Flags().StringVarP(&cp.destination, "destination", "d", ".", "directory to write the chart to")
+
+	return cmd
+}
+
+func (cp *chartsPullCmd) run() error {
+	if err := cp.setup(); err != nil {
+		return err
+	}
+
+	chartPath, err := cp.downloadChart(cp.name, cp.destination, false)
+	if err != nil {
+		return err
+	}
+
+	return cp.print(pulledChart{Name: cp.name, Path: chartPath}, func() {
+		cp.out.Write([]byte(fmt.Sprintf("Chart [%s] saved to [%s]\n", cp.name, chartPath)))
+	})
+}
+
+// downloadChart writes the active version of the chart to destination, returning its path. Plan
+// cluster credentials are left out unless includeCredentials is set.
+func (b *baseBazaarCmd) downloadChart(name string, destination string, includeCredentials bool) (string, error) {
+	res, err := b.getChart(name, "archive", includeCredentials)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return "", newAPIError(res)
+	}
+
+	filename := name + ".tgz"
+	_, params, err := mime.ParseMediaType(res.Header.Get("Content-Disposition"))
+	if err == nil && params["filename"] != "" {
+		filename = filepath.Base(params["filename"])
+	}
+	chartPath := filepath.Join(destination, filename)
+
+	err = writeDownload(chartPath, res.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to download chart")
+	}
+	return chartPath, nil
+}
+
+// downloadProvenance writes the provenance file of the chart downloaded to chartPath next to it,
+// returning its path, or "" when the chart isn't signed
+func (b *baseBazaarCmd) downloadProvenance(name string, chartPath string, includeCredentials bool) (string, error) {
+	res, err := b.getChart(name, "provenance", includeCredentials)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == 404 {
+		return "", nil
+	}
+	if res.StatusCode != 200 {
+		return "", newAPIError(res)
+	}
+
+	provenancePath := chartPath + bazaar.ProvenanceExtension
+	err = writeDownload(provenancePath, res.Body)
+	if err != nil {
+		return "", errors.Wrap(err, "Unable to download provenance file")
+	}
+	return provenancePath, nil
+}
+
+func (b *baseBazaarCmd) getChart(name string, resource string, includeCredentials bool) (*http.Response, error) {
+	client, err := b.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/charts/%s/%s", b.target, name, resource)
+	if includeCredentials {
+		url += "?credentials=true"
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = b.addAuthHeader(req)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+func writeDownload(path string, body io.Reader) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, body)
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
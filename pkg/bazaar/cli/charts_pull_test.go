@@ -0,0 +1,95 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Pull chart", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPITestServer *httptest.Server
+	var destination string
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewChartsPullCmd(out)
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+
+		var err error
+		destination, err = ioutil.TempDir("", "pull-")
+		Expect(err).To(BeNil())
+		c.Flags().Set("destination", destination)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+		os.RemoveAll(destination)
+	})
+
+	It("writes archive named by server", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bazaarAPIRequest = r
+			w.Header().Set("Content-Disposition", `attachment; filename="spacebears-0.0.1.tgz"`)
+			w.Write([]byte("chart archive"))
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err := c.RunE(c, []string{"spacebears"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts/spacebears/archive"))
+		contents, err := ioutil.ReadFile(filepath.Join(destination, "spacebears-0.0.1.tgz"))
+		Expect(err).To(BeNil())
+		Expect(string(contents)).To(Equal("chart archive"))
+		Expect(string(b.Bytes())).To(ContainSubstring("spacebears-0.0.1.tgz"))
+	})
+
+	It("returns error on unknown chart", func() {
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(404)
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err := c.RunE(c, []string{"mysql"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("404"))
+		files, _ := ioutil.ReadDir(destination)
+		Expect(files).To(BeEmpty())
+	})
+})
@@ -16,12 +16,13 @@
 package cli
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
 	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
@@ -30,7 +31,9 @@ import (
 
 type chartsSaveCmd struct {
 	baseBazaarCmd
-	paths []string
+	paths      []string
+	provenance string
+	chartURL   bazaar.ChartURLRequest
 }
 
 func NewChartsSaveCmd(out io.Writer) *cobra.Command {
@@ -38,12 +41,18 @@ func NewChartsSaveCmd(out io.Writer) *cobra.Command {
 	cs.out = out
 
 	cmd := &cobra.Command{
-		Use:   "save PATH-TO-CHART.tgz",
+		Use:   "save PATH-TO-CHART.tgz | --url CHART-URL",
 		Short: "save chart to repository",
 		PreRun: func(cmd *cobra.Command, args []string) {
 			cs.preRun(cmd, args)
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if cs.chartURL.URL != "" {
+				if len(args) != 0 {
+					return errors.New("either a tar file or --url, not both")
+				}
+				return cs.runURL()
+			}
 			if len(args) != 1 {
 				return errors.New("missing tar file")
 			}
@@ -53,44 +62,132 @@ func NewChartsSaveCmd(out io.Writer) *cobra.Command {
 	}
 
 	cs.baseBazaarCmd.addCommonFlags(cmd)
+	cmd.Flags().StringVar(&cs.provenance, "provenance", "", "provenance file for the chart (defaults to PATH-TO-CHART.tgz.prov when present)")
+	cmd.Flags().Int64Var(&cs.chunkSize, "chunk-size", defaultChunkSize, "upload charts larger than this many bytes in chunks of this size, 0 to never chunk")
+	cmd.Flags().StringVar(&cs.resumeUploadID, "resume", "", "resume the chunked upload with this id")
+	cmd.Flags().StringVar(&cs.chartURL.URL, "url", "", "url for bazaar to fetch the chart from, instead of uploading a tar file")
+	cmd.Flags().StringVar(&cs.chartURL.SHA256, "sha256", "", "sha256 the chart fetched from --url must match")
+	cmd.Flags().StringVar(&cs.chartURL.ProvenanceURL, "provenance-url", "", "url for bazaar to fetch the chart's provenance file from")
 
 	return cmd
 }
 
 func (cs *chartsSaveCmd) run() error {
-	url := cs.target + "/charts"
-	req, err := httphelpers.CreateFormRequest(url, "chart", cs.paths)
+	if err := cs.setup(); err != nil {
+		return err
+	}
+
+	provenance := cs.provenance
+	if provenance == "" {
+		_, err := os.Stat(cs.paths[0] + bazaar.ProvenanceExtension)
+		if err == nil {
+			provenance = cs.paths[0] + bazaar.ProvenanceExtension
+		}
+	}
+
+	message, err := cs.uploadChart(cs.paths, provenance)
 	if err != nil {
 		return err
 	}
-	httphelpers.AddBasicAuthHeader(req, cs.user, cs.pass)
 
-	client := &http.Client{}
+	return cs.printMessage(message)
+}
+
+// runURL has bazaar fetch and save the chart, which is faster than uploading it when the chart is
+// in an artifact store close to bazaar
+func (cs *chartsSaveCmd) runURL() error {
+	if err := cs.setup(); err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(cs.chartURL)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("POST", cs.target+"/charts", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	err = cs.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	client, err := cs.httpClient()
+	if err != nil {
+		return err
+	}
 	res, err := client.Do(req)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != 200 {
-		body, _ := ioutil.ReadAll(res.Body)
-		return errors.New(fmt.Sprintf("Non-OK response code from API [%v]\nMessage from server: %v\n", res.Status, string(body)))
+		return newAPIError(res)
 	}
 
-	responseBody, err := ioutil.ReadAll(res.Body)
+	responseJSON := bazaar.DisplayResponse{}
+	err = json.NewDecoder(res.Body).Decode(&responseJSON)
 	if err != nil {
 		return err
 	}
 
-	responseJSON := bazaar.DisplayResponse{}
-	err = json.Unmarshal(responseBody, &responseJSON)
+	return cs.printMessage(responseJSON.Message)
+}
+
+// uploadChart saves the chart, with its provenance file when given one, returning the server's
+// message. A single chart larger than b.chunkSize is uploaded in chunks.
+func (b *baseBazaarCmd) uploadChart(paths []string, provenance string) (string, error) {
+	if len(paths) == 1 {
+		info, err := os.Stat(paths[0])
+		if err != nil {
+			return "", err
+		}
+		if b.resumeUploadID != "" || (b.chunkSize > 0 && info.Size() > b.chunkSize) {
+			return b.uploadChartInChunks(paths[0], provenance)
+		}
+	}
+
+	url := b.target + "/charts"
+	formFiles := []httphelpers.FormFiles{{FieldName: "chart", Paths: paths}}
+	if provenance != "" {
+		formFiles = append(formFiles, httphelpers.FormFiles{FieldName: "provenance", Paths: []string{provenance}})
+	}
+
+	req, err := httphelpers.CreateFormRequestFiles(url, formFiles, []httphelpers.FlagValues{})
 	if err != nil {
-		return err
+		return "", err
+	}
+	err = b.addAuthHeader(req)
+	if err != nil {
+		return "", err
 	}
 
-	_, err = cs.out.Write([]byte(fmt.Sprintf("Message from server: %s\n", responseJSON.Message)))
+	client, err := b.httpClient()
 	if err != nil {
-		return err
+		return "", err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	if res.StatusCode != 200 {
+		return "", newAPIError(res)
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+
+	responseJSON := bazaar.DisplayResponse{}
+	err = json.Unmarshal(responseBody, &responseJSON)
+	if err != nil {
+		return "", err
 	}
 
-	return nil
+	return responseJSON.Message, nil
 }
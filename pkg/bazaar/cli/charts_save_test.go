@@ -98,6 +98,33 @@ var _ = Describe("Save charts", func() {
 		Expect(fileContents).To(Equal([]byte("I am really a tgz of a chart")))
 	})
 
+	It("sends provenance file found next to the chart", func() {
+		err := ioutil.WriteFile(file.Name()+".prov", []byte("I am a signature"), 0600)
+		Expect(err).To(BeNil())
+		defer os.Remove(file.Name() + ".prov")
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+			r.ParseMultipartForm(4096)
+			bazaarAPIRequest = r
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+
+		err = c.RunE(c, []string{
+			file.Name(),
+		})
+		out.Flush()
+		Expect(err).To(BeNil())
+
+		formFile, _, err := bazaarAPIRequest.FormFile("provenance")
+		Expect(err).To(BeNil())
+		fileContents, err := ioutil.ReadAll(formFile)
+		Expect(err).To(BeNil())
+		Expect(fileContents).To(Equal([]byte("I am a signature")))
+	})
+
 	It("correctly auths request", func() {
 		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.Write([]byte("{}"))
@@ -143,4 +170,139 @@ var _ = Describe("Save charts", func() {
 		Expect(err).NotTo(BeNil())
 		Expect(err.Error()).To(ContainSubstring("401"))
 	})
+	Context("from a url", func() {
+		var chartURLRequest bazaar.ChartURLRequest
+
+		BeforeEach(func() {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				bazaarAPIRequest = r
+				json.NewDecoder(r.Body).Decode(&chartURLRequest)
+				w.Write([]byte(`{"message":"Chart saved"}`))
+			})
+			bazaarAPITestServer = httptest.NewServer(handler)
+			c.Flags().Set("target", bazaarAPITestServer.URL)
+		})
+
+		It("has bazaar fetch the chart", func() {
+			c.Flags().Set("url", "https://artifacts.example.com/spacebears-0.0.1.tgz")
+			c.Flags().Set("sha256", "abc123")
+			c.Flags().Set("provenance-url", "https://artifacts.example.com/spacebears-0.0.1.tgz.prov")
+
+			err := c.RunE(c, []string{})
+			out.Flush()
+
+			Expect(err).To(BeNil())
+			Expect(bazaarAPIRequest.Method).To(Equal("POST"))
+			Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts"))
+			Expect(bazaarAPIRequest.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(bazaarAPIRequest.Header.Get("Authorization")).To(
+				Equal(httphelpers.BasicAuthHeaderVal("bob", "monkey123")),
+			)
+			Expect(chartURLRequest).To(Equal(bazaar.ChartURLRequest{
+				URL:           "https://artifacts.example.com/spacebears-0.0.1.tgz",
+				SHA256:        "abc123",
+				ProvenanceURL: "https://artifacts.example.com/spacebears-0.0.1.tgz.prov",
+			}))
+			Expect(b.String()).To(ContainSubstring("Chart saved"))
+		})
+
+		It("doesn't take a tar file as well", func() {
+			c.Flags().Set("url", "https://artifacts.example.com/spacebears-0.0.1.tgz")
+
+			err := c.RunE(c, []string{file.Name()})
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("not both"))
+		})
+	})
+
+	Context("chunked", func() {
+		var received bytes.Buffer
+		var offsets []string
+		var failNextChunk bool
+		var saveRequest bazaar.SaveUploadRequest
+		var requests []string
+
+		BeforeEach(func() {
+			received = bytes.Buffer{}
+			offsets = []string{}
+			requests = []string{}
+			failNextChunk = false
+			saveRequest = bazaar.SaveUploadRequest{}
+
+			const id = "0123456789abcdef0123456789abcdef"
+			upload := func() bazaar.DisplayUpload {
+				return bazaar.DisplayUpload{ID: id, Filename: "chart.tgz", Size: 28, Offset: int64(received.Len())}
+			}
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests = append(requests, r.Method+" "+r.URL.Path)
+				switch {
+				case r.Method == "POST" && r.URL.Path == "/uploads":
+					json.NewEncoder(w).Encode(upload())
+				case r.Method == "GET" && r.URL.Path == "/uploads/"+id:
+					json.NewEncoder(w).Encode(upload())
+				case r.Method == "PUT" && r.URL.Path == "/uploads/"+id:
+					if failNextChunk {
+						failNextChunk = false
+						w.WriteHeader(502)
+						return
+					}
+					offsets = append(offsets, r.Header.Get(bazaar.UploadOffsetHeader))
+					chunk, _ := ioutil.ReadAll(r.Body)
+					received.Write(chunk)
+					json.NewEncoder(w).Encode(upload())
+				case r.Method == "POST" && r.URL.Path == "/uploads/"+id+"/save":
+					json.NewDecoder(r.Body).Decode(&saveRequest)
+					w.Write([]byte(`{"message":"Chart saved"}`))
+				default:
+					w.WriteHeader(404)
+				}
+			})
+			bazaarAPITestServer = httptest.NewServer(handler)
+			c.Flags().Set("target", bazaarAPITestServer.URL)
+			c.Flags().Set("chunk-size", "10")
+		})
+
+		It("uploads charts larger than the chunk size in chunks", func() {
+			err := c.RunE(c, []string{file.Name()})
+			out.Flush()
+
+			Expect(err).To(BeNil())
+			Expect(received.String()).To(Equal("I am really a tgz of a chart"))
+			Expect(offsets).To(Equal([]string{"0", "10", "20"}))
+			Expect(b.String()).To(ContainSubstring("Chart saved"))
+		})
+
+		It("sends the provenance file when saving the upload", func() {
+			provenance := file.Name() + ".prov"
+			Expect(ioutil.WriteFile(provenance, []byte("signature"), 0600)).To(Succeed())
+			defer os.Remove(provenance)
+
+			err := c.RunE(c, []string{file.Name()})
+
+			Expect(err).To(BeNil())
+			Expect(saveRequest.Provenance).To(Equal("signature"))
+		})
+
+		It("retries a failed chunk from the server's offset", func() {
+			received.WriteString("I am reall")
+			failNextChunk = true
+			c.Flags().Set("resume", "0123456789abcdef0123456789abcdef")
+
+			err := c.RunE(c, []string{file.Name()})
+
+			Expect(err).To(BeNil())
+			Expect(received.String()).To(Equal("I am really a tgz of a chart"))
+			Expect(offsets).To(Equal([]string{"10", "20"}))
+			Expect(requests).NotTo(ContainElement("POST /uploads"))
+		})
+
+		It("doesn't chunk charts smaller than the chunk size", func() {
+			c.Flags().Set("chunk-size", "100")
+
+			c.RunE(c, []string{file.Name()})
+
+			Expect(requests).To(Equal([]string{"POST /charts"}))
+		})
+	})
 })
@@ -0,0 +1,136 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type chartsShowCmd struct {
+	baseBazaarCmd
+	name string
+}
+
+func NewChartsShowCmd(out io.Writer) *cobra.Command {
+	cs := &chartsShowCmd{}
+	cs.out = out
+
+	cmd := &cobra.Command{
+		Use:   "show NAME",
+		Short: "show detail of a chart in repository",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			cs.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing chart name")
+			}
+			cs.name = args[0]
+			return cs.run()
+		},
+	}
+
+	cs.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (cs *chartsShowCmd) run() error {
+	if err := cs.setup(); err != nil {
+		return err
+	}
+
+	chart, err := cs.getChartDetail(cs.name)
+	if err != nil {
+		return err
+	}
+
+	return cs.print(chart, func() {
+		summary := uitable.New()
+		summary.AddRow("NAME:", chart.Name)
+		summary.AddRow("VERSION:", chart.Version)
+		summary.AddRow("VERSIONS:", fmt.Sprintf("%+v", chart.Versions))
+		summary.AddRow("APP VERSION:", chart.AppVersion)
+		summary.AddRow("DESCRIPTION:", chart.Description)
+		summary.AddRow("ICON:", chart.Icon)
+		cs.out.Write(summary.Bytes())
+		cs.out.Write([]byte("\n\n"))
+
+		plans := uitable.New()
+		plans.AddRow("PLAN", "DESCRIPTION", "FILE", "FREE", "BINDABLE", "CUSTOM CLUSTER", "BULLETS")
+		for _, p := range chart.Plans {
+			plans.AddRow(p.Name, p.Description, p.File, p.Free, p.Bindable, p.CustomCluster, fmt.Sprintf("%+v", p.Bullets))
+		}
+		cs.out.Write(plans.Bytes())
+		cs.out.Write([]byte("\n"))
+
+		if chart.BindTemplate != "" {
+			cs.out.Write([]byte("\nBIND TEMPLATE:\n"))
+			cs.out.Write([]byte(strings.TrimRight(chart.BindTemplate, "\n") + "\n"))
+		}
+
+		cs.out.Write([]byte("\nVALUES:\n"))
+		cs.out.Write([]byte(strings.TrimRight(chart.Values, "\n") + "\n"))
+	})
+}
+
+func (b *baseBazaarCmd) getChartDetail(name string) (*bazaar.DisplayChartDetail, error) {
+	client, err := b.httpClient()
+	if err != nil {
+		return nil, err
+	}
+	url := fmt.Sprintf("%s/charts/%s", b.target, name)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = b.addAuthHeader(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, newAPIError(res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	chart := &bazaar.DisplayChartDetail{}
+	err = json.Unmarshal(body, chart)
+	if err != nil {
+		return nil, err
+	}
+	return chart, nil
+}
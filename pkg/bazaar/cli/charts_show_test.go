@@ -0,0 +1,101 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Show chart", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPITestServer *httptest.Server
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewChartsShowCmd(out)
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+
+		chart := bazaar.DisplayChartDetail{
+			Name:         "spacebears",
+			Version:      "0.0.2",
+			Versions:     []string{"0.0.2", "0.0.1"},
+			Description:  "spacebears service",
+			Plans:        []bazaar.DisplayPlanDetail{{Name: "small", Description: "small plan", Free: true, Bindable: true}},
+			BindTemplate: "{hostname: $.services[0].spec.clusterIP}",
+			Values:       "image: my-image\n",
+		}
+		responseBody, _ := json.Marshal(chart)
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(responseBody)
+			bazaarAPIRequest = r
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+	})
+
+	It("prints chart detail", func() {
+		err := c.RunE(c, []string{"spacebears"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts/spacebears"))
+		output := string(b.Bytes())
+		Expect(output).To(ContainSubstring("spacebears service"))
+		Expect(output).To(ContainSubstring("small plan"))
+		Expect(output).To(ContainSubstring("$.services[0].spec.clusterIP"))
+		Expect(output).To(ContainSubstring("image: my-image"))
+	})
+
+	It("prints chart detail as json", func() {
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{"spacebears"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		chart := bazaar.DisplayChartDetail{}
+		Expect(json.Unmarshal(b.Bytes(), &chart)).To(BeNil())
+		Expect(chart.Name).To(Equal("spacebears"))
+		Expect(chart.Plans[0].Name).To(Equal("small"))
+	})
+
+	It("error when name not supplied", func() {
+		err := c.RunE(c, []string{})
+		Expect(err).NotTo(BeNil())
+	})
+})
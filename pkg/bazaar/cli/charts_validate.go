@@ -0,0 +1,128 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+type chartsValidateCmd struct {
+	baseBazaarCmd
+	paths []string
+}
+
+func NewChartsValidateCmd(out io.Writer) *cobra.Command {
+	cv := &chartsValidateCmd{}
+	cv.out = out
+
+	cmd := &cobra.Command{
+		Use:   "validate PATH-TO-CHART.tgz",
+		Short: "check whether Kibosh would accept a chart, without saving it",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			cv.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing tar file")
+			}
+			cv.paths = args
+			return cv.run()
+		},
+	}
+
+	cv.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (cv *chartsValidateCmd) run() error {
+	if err := cv.setup(); err != nil {
+		return err
+	}
+
+	url := cv.target + "/charts/validate"
+	req, err := httphelpers.CreateFormRequest(url, "chart", cv.paths)
+	if err != nil {
+		return err
+	}
+	err = cv.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	client, err := cv.httpClient()
+	if err != nil {
+		return err
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	reports := []helm.ValidationReport{}
+	err = json.Unmarshal(body, &reports)
+	if err != nil {
+		return err
+	}
+
+	err = cv.print(reports, func() {
+		for _, report := range reports {
+			status := "valid"
+			if !report.Valid {
+				status = "invalid"
+			}
+			cv.out.Write([]byte(fmt.Sprintf("Chart [%s] version [%s] is %s\n", report.Chart, report.Version, status)))
+
+			if len(report.Problems) > 0 {
+				table := uitable.New()
+				table.AddRow("SEVERITY", "CHECK", "FILE", "MESSAGE")
+				for _, problem := range report.Problems {
+					table.AddRow(problem.Severity, problem.Check, problem.File, problem.Message)
+				}
+				cv.out.Write(table.Bytes())
+				cv.out.Write([]byte("\n"))
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		if !report.Valid {
+			return errors.New("chart failed validation")
+		}
+	}
+	return nil
+}
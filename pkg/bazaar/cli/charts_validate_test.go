@@ -0,0 +1,110 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Validate chart", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPITestServer *httptest.Server
+	var reports []helm.ValidationReport
+	var chartFile *os.File
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewChartsValidateCmd(out)
+
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+
+		var err error
+		chartFile, err = ioutil.TempFile("", "chart-*.tgz")
+		Expect(err).To(BeNil())
+		chartFile.Write([]byte("chart contents"))
+		chartFile.Close()
+
+		reports = []helm.ValidationReport{{
+			Chart:    "spacebears",
+			Version:  "0.0.1",
+			Valid:    true,
+			Problems: []helm.ValidationProblem{},
+		}}
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bazaarAPIRequest = r
+			responseBody, _ := json.Marshal(reports)
+			w.Write(responseBody)
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+		os.Remove(chartFile.Name())
+	})
+
+	It("posts chart to validate endpoint", func() {
+		err := c.RunE(c, []string{chartFile.Name()})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.Method).To(Equal("POST"))
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts/validate"))
+		Expect(string(b.Bytes())).To(ContainSubstring("Chart [spacebears] version [0.0.1] is valid"))
+	})
+
+	It("prints problems and errors on invalid chart", func() {
+		reports[0].Valid = false
+		reports[0].Problems = []helm.ValidationProblem{
+			{Check: "plans", File: "plans.yaml", Severity: helm.SeverityError, Message: "Name [Small] contains invalid characters"},
+		}
+
+		err := c.RunE(c, []string{chartFile.Name()})
+		out.Flush()
+
+		Expect(err).NotTo(BeNil())
+		output := string(b.Bytes())
+		Expect(output).To(ContainSubstring("is invalid"))
+		Expect(output).To(ContainSubstring("SEVERITY"))
+		Expect(output).To(ContainSubstring("Name [Small] contains invalid characters"))
+	})
+
+	It("error when chart not supplied", func() {
+		err := c.RunE(c, []string{})
+		Expect(err).NotTo(BeNil())
+	})
+})
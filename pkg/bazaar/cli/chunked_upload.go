@@ -0,0 +1,161 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultChunkSize = 100 << 20
+	chunkAttempts    = 3
+)
+
+// uploadChartInChunks sends a chart through the bazaar API's chunked uploads, so a failed chunk is
+// retried from what the server received rather than from the start. With b.resumeUploadID set, it
+// continues that upload instead of starting another.
+func (b *baseBazaarCmd) uploadChartInChunks(path string, provenance string) (string, error) {
+	client, err := b.httpClient()
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	upload := bazaar.DisplayUpload{}
+	if b.resumeUploadID == "" {
+		request, err := json.Marshal(bazaar.UploadRequest{Filename: filepath.Base(path), Size: info.Size()})
+		if err != nil {
+			return "", err
+		}
+		err = b.callUploads(client, "POST", "/uploads", bytes.NewReader(request), nil, &upload)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		err = b.callUploads(client, "GET", "/uploads/"+b.resumeUploadID, nil, nil, &upload)
+		if err != nil {
+			return "", err
+		}
+		if upload.Size != info.Size() {
+			return "", errors.Errorf("Upload [%s] is of a %d byte file, but [%s] is %d bytes", upload.ID, upload.Size, path, info.Size())
+		}
+	}
+
+	failures := 0
+	for upload.Offset < upload.Size {
+		err = b.sendChunk(client, file, &upload)
+		if err == nil {
+			failures = 0
+			continue
+		}
+		failures++
+		if failures >= chunkAttempts || !retryableChunkError(err) {
+			return "", errors.Wrapf(err, "Upload [%s] failed, resume it with --resume %s", upload.ID, upload.ID)
+		}
+		// continue from whatever the server received of the failed chunk
+		err = b.callUploads(client, "GET", "/uploads/"+upload.ID, nil, nil, &upload)
+		if err != nil {
+			return "", errors.Wrapf(err, "Upload [%s] failed, resume it with --resume %s", upload.ID, upload.ID)
+		}
+	}
+
+	saveRequest := bazaar.SaveUploadRequest{}
+	if provenance != "" {
+		contents, err := ioutil.ReadFile(provenance)
+		if err != nil {
+			return "", err
+		}
+		saveRequest.Provenance = string(contents)
+	}
+	request, err := json.Marshal(saveRequest)
+	if err != nil {
+		return "", err
+	}
+	response := bazaar.DisplayResponse{}
+	err = b.callUploads(client, "POST", "/uploads/"+upload.ID+"/save", bytes.NewReader(request), nil, &response)
+	if err != nil {
+		return "", err
+	}
+	return response.Message, nil
+}
+
+func (b *baseBazaarCmd) sendChunk(client *http.Client, file *os.File, upload *bazaar.DisplayUpload) error {
+	_, err := file.Seek(upload.Offset, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	length := upload.Size - upload.Offset
+	if length > b.chunkSize {
+		length = b.chunkSize
+	}
+
+	headers := map[string]string{bazaar.UploadOffsetHeader: strconv.FormatInt(upload.Offset, 10)}
+	return b.callUploads(client, "PUT", "/uploads/"+upload.ID, io.LimitReader(file, length), headers, upload)
+}
+
+func (b *baseBazaarCmd) callUploads(client *http.Client, method string, path string, body io.Reader, headers map[string]string, result interface{}) error {
+	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", b.target, path), body)
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	err = b.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// retryableChunkError is true for network errors, server errors and chunks the server didn't
+// expect, which resuming from the server's offset fixes
+func retryableChunkError(err error) bool {
+	apiErr, ok := errors.Cause(err).(*APIError)
+	if !ok {
+		return true
+	}
+	return apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode >= 500
+}
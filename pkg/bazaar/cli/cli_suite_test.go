@@ -16,6 +16,9 @@
 package cli_test
 
 import (
+	"io/ioutil"
+	"os"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -26,3 +29,17 @@ func TestCli(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Cli Suite")
 }
+
+var bazaarHome string
+
+var _ = BeforeEach(func() {
+	var err error
+	bazaarHome, err = ioutil.TempDir("", "bazaar-home")
+	Expect(err).To(BeNil())
+	os.Setenv("BAZAAR_HOME", bazaarHome)
+})
+
+var _ = AfterEach(func() {
+	os.Unsetenv("BAZAAR_HOME")
+	os.RemoveAll(bazaarHome)
+})
@@ -0,0 +1,129 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/cf-platform-eng/kibosh/pkg/broker"
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+func NewInstancesCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "instances",
+		Short: "inspect provisioned service instances",
+	}
+
+	cmd.AddCommand(
+		NewInstancesListCmd(out),
+		NewInstancesShowCmd(out),
+	)
+
+	return cmd
+}
+
+type instancesListCmd struct {
+	baseBazaarCmd
+	chart string
+}
+
+func NewInstancesListCmd(out io.Writer) *cobra.Command {
+	il := &instancesListCmd{}
+	il.out = out
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list service instances with their status",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			il.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return il.run()
+		},
+	}
+
+	il.baseBazaarCmd.addCommonFlags(cmd)
+	cmd.Flags().StringVar(&il.chart, "chart", "", "only list instances of this chart")
+
+	return cmd
+}
+
+func (il *instancesListCmd) run() error {
+	if err := il.setup(); err != nil {
+		return err
+	}
+
+	client, err := il.httpClient()
+	if err != nil {
+		return err
+	}
+	instancesURL := fmt.Sprintf("%s/instances", il.target)
+	if il.chart != "" {
+		instancesURL = instancesURL + "?chart=" + url.QueryEscape(il.chart)
+	}
+	req, err := http.NewRequest("GET", instancesURL, nil)
+	if err != nil {
+		return err
+	}
+	err = il.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	instances := []broker.Instance{}
+	err = json.Unmarshal(body, &instances)
+	if err != nil {
+		return err
+	}
+
+	return il.print(instances, func() {
+		table := uitable.New()
+		table.AddRow("INSTANCE", "CHART", "PLAN", "ORG", "SPACE", "RELEASE STATUS", "READY", "MESSAGE")
+		for _, i := range instances {
+			table.AddRow(i.InstanceID, i.Chart, i.Plan, i.OrganizationGUID, i.SpaceGUID, i.ReleaseStatus, i.Ready, instanceMessage(i))
+		}
+		il.out.Write(table.Bytes())
+		il.out.Write([]byte("\n"))
+	})
+}
+
+func instanceMessage(instance broker.Instance) string {
+	if instance.Error != "" {
+		return instance.Error
+	}
+	return instance.ReadinessMessage
+}
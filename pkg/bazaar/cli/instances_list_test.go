@@ -0,0 +1,114 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/cf-platform-eng/kibosh/pkg/broker"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("List instances", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPITestServer *httptest.Server
+	var responseBody []byte
+	var responseStatus int
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		responseStatus = 200
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bazaarAPIRequest = r
+			w.WriteHeader(responseStatus)
+			w.Write(responseBody)
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+	})
+
+	newCmd := func(c *cobra.Command) *cobra.Command {
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+		return c
+	}
+
+	BeforeEach(func() {
+		responseBody, _ = json.Marshal([]broker.Instance{
+			{
+				InstanceID: "mysql-1", Chart: "mysql", Plan: "small", OrganizationGUID: "my-org",
+				SpaceGUID: "my-space", ReleaseStatus: "DEPLOYED", Ready: true,
+			},
+			{
+				InstanceID: "mysql-2", Chart: "mysql", Plan: "large", ReleaseStatus: "DEPLOYED",
+				ReadinessMessage: "Deployment is not ready: kibosh-mysql-2/mysql",
+			},
+		})
+	})
+
+	It("lists instances", func() {
+		c := newCmd(cli.NewInstancesListCmd(out))
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/instances"))
+		Expect(b.String()).To(ContainSubstring("mysql-1"))
+		Expect(b.String()).To(ContainSubstring("DEPLOYED"))
+		Expect(b.String()).To(ContainSubstring("Deployment is not ready"))
+	})
+
+	It("filters by chart", func() {
+		c := newCmd(cli.NewInstancesListCmd(out))
+		c.Flags().Set("chart", "mysql")
+
+		err := c.RunE(c, []string{})
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.URL.Query().Get("chart")).To(Equal("mysql"))
+	})
+
+	It("prints json", func() {
+		c := newCmd(cli.NewInstancesListCmd(out))
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		var instances []broker.Instance
+		Expect(json.Unmarshal(b.Bytes(), &instances)).To(Succeed())
+		Expect(instances).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,122 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/cf-platform-eng/kibosh/pkg/broker"
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type instancesShowCmd struct {
+	baseBazaarCmd
+	instanceID string
+}
+
+func NewInstancesShowCmd(out io.Writer) *cobra.Command {
+	is := &instancesShowCmd{}
+	is.out = out
+
+	cmd := &cobra.Command{
+		Use:   "show INSTANCE-ID",
+		Short: "show a service instance and its status",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			is.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing instance id")
+			}
+			is.instanceID = args[0]
+			return is.run()
+		},
+	}
+
+	is.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (is *instancesShowCmd) run() error {
+	if err := is.setup(); err != nil {
+		return err
+	}
+
+	client, err := is.httpClient()
+	if err != nil {
+		return err
+	}
+	instanceURL := fmt.Sprintf("%s/instances/%s", is.target, url.PathEscape(is.instanceID))
+	req, err := http.NewRequest("GET", instanceURL, nil)
+	if err != nil {
+		return err
+	}
+	err = is.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	var instance broker.Instance
+	err = json.Unmarshal(body, &instance)
+	if err != nil {
+		return err
+	}
+
+	return is.print(instance, func() {
+		table := uitable.New()
+		table.AddRow("INSTANCE:", instance.InstanceID)
+		table.AddRow("CHART:", instance.Chart)
+		table.AddRow("PLAN:", instance.Plan)
+		table.AddRow("SERVICE ID:", instance.ServiceID)
+		table.AddRow("PLAN ID:", instance.PlanID)
+		table.AddRow("ORG:", instance.OrganizationGUID)
+		table.AddRow("SPACE:", instance.SpaceGUID)
+		table.AddRow("NAMESPACE:", instance.Namespace)
+		table.AddRow("RELEASE:", instance.Release)
+		table.AddRow("RELEASE STATUS:", instance.ReleaseStatus)
+		table.AddRow("READY:", instance.Ready)
+		if instance.ReadinessMessage != "" {
+			table.AddRow("READINESS:", instance.ReadinessMessage)
+		}
+		if instance.Error != "" {
+			table.AddRow("ERROR:", instance.Error)
+		}
+		is.out.Write(table.Bytes())
+		is.out.Write([]byte("\n"))
+	})
+}
@@ -0,0 +1,100 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/cf-platform-eng/kibosh/pkg/broker"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Show instance", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPITestServer *httptest.Server
+	var responseBody []byte
+	var responseStatus int
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		responseStatus = 200
+
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bazaarAPIRequest = r
+			w.WriteHeader(responseStatus)
+			w.Write(responseBody)
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+	})
+
+	newCmd := func(c *cobra.Command) *cobra.Command {
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+		return c
+	}
+
+	It("shows an instance", func() {
+		responseBody, _ = json.Marshal(broker.Instance{
+			InstanceID: "mysql-1", Chart: "mysql", Plan: "small", Namespace: "kibosh-mysql-1",
+			ReleaseStatus: "DEPLOYED", Ready: true,
+		})
+		c := newCmd(cli.NewInstancesShowCmd(out))
+
+		err := c.RunE(c, []string{"mysql-1"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/instances/mysql-1"))
+		Expect(b.String()).To(ContainSubstring("kibosh-mysql-1"))
+		Expect(b.String()).To(ContainSubstring("DEPLOYED"))
+	})
+
+	It("requires an instance id", func() {
+		c := newCmd(cli.NewInstancesShowCmd(out))
+
+		err := c.RunE(c, []string{})
+
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("returns the server's message for unknown instances", func() {
+		responseStatus = 404
+		responseBody = []byte("Service instance not found")
+		c := newCmd(cli.NewInstancesShowCmd(out))
+
+		err := c.RunE(c, []string{"nope"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Service instance not found"))
+	})
+})
@@ -0,0 +1,100 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type plansDeleteCmd struct {
+	baseBazaarCmd
+	chart string
+	plan  string
+}
+
+func NewPlansDeleteCmd(out io.Writer) *cobra.Command {
+	pd := &plansDeleteCmd{}
+	pd.out = out
+
+	cmd := &cobra.Command{
+		Use:   "delete CHART-NAME PLAN-NAME",
+		Short: "delete a plan from a chart",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			pd.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("missing chart name or plan name")
+			}
+			pd.chart = args[0]
+			pd.plan = args[1]
+			return pd.run()
+		},
+	}
+
+	pd.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (pd *plansDeleteCmd) run() error {
+	if err := pd.setup(); err != nil {
+		return err
+	}
+
+	client, err := pd.httpClient()
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/charts/%s/plans/%s", pd.target, pd.chart, pd.plan)
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		return err
+	}
+	err = pd.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	responseJSON := bazaar.DisplayResponse{}
+	err = json.Unmarshal(responseBody, &responseJSON)
+	if err != nil {
+		return err
+	}
+
+	return pd.printMessage(responseJSON.Message)
+}
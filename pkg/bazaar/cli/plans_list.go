@@ -0,0 +1,124 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/gosuri/uitable"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func NewPlansCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "plans",
+		Short: "manage the plans of a chart in repository",
+	}
+
+	cmd.AddCommand(
+		NewPlansListCmd(out),
+		NewPlansAddCmd(out),
+		NewPlansUpdateCmd(out),
+		NewPlansDeleteCmd(out),
+	)
+
+	return cmd
+}
+
+type plansListCmd struct {
+	baseBazaarCmd
+	chart string
+}
+
+func NewPlansListCmd(out io.Writer) *cobra.Command {
+	pl := &plansListCmd{}
+	pl.out = out
+
+	cmd := &cobra.Command{
+		Use:   "list CHART-NAME",
+		Short: "list plans of a chart",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			pl.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing chart name")
+			}
+			pl.chart = args[0]
+			return pl.run()
+		},
+	}
+
+	pl.baseBazaarCmd.addCommonFlags(cmd)
+
+	return cmd
+}
+
+func (pl *plansListCmd) run() error {
+	if err := pl.setup(); err != nil {
+		return err
+	}
+
+	client, err := pl.httpClient()
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("%s/charts/%s/plans", pl.target, pl.chart)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	err = pl.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	plans := []bazaar.DisplayPlanDetail{}
+	err = json.Unmarshal(body, &plans)
+	if err != nil {
+		return err
+	}
+
+	return pl.print(plans, func() {
+		table := uitable.New()
+		table.AddRow("NAME", "DESCRIPTION", "FILE", "FREE", "BINDABLE", "CUSTOM CLUSTER")
+		for _, p := range plans {
+			table.AddRow(p.Name, p.Description, p.File, p.Free, p.Bindable, p.CustomCluster)
+		}
+
+		pl.out.Write(table.Bytes())
+		pl.out.Write([]byte("\n"))
+	})
+}
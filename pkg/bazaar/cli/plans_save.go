@@ -0,0 +1,164 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type plansSaveCmd struct {
+	baseBazaarCmd
+	update          bool
+	chart           string
+	plan            bazaar.PlanRequest
+	free            bool
+	bindable        bool
+	valuesPath      string
+	credentialsPath string
+}
+
+func NewPlansAddCmd(out io.Writer) *cobra.Command {
+	return newPlansSaveCmd(out, false)
+}
+
+// NewPlansUpdateCmd replaces the plan, so options that aren't given are reset to their defaults,
+// except for its values and credentials, which are kept unless given or cleared
+func NewPlansUpdateCmd(out io.Writer) *cobra.Command {
+	return newPlansSaveCmd(out, true)
+}
+
+func newPlansSaveCmd(out io.Writer, update bool) *cobra.Command {
+	ps := &plansSaveCmd{update: update}
+	ps.out = out
+
+	cmd := &cobra.Command{
+		Use:   "add CHART-NAME PLAN-NAME",
+		Short: "add a plan to a chart",
+		PreRun: func(cmd *cobra.Command, args []string) {
+			ps.preRun(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("missing chart name or plan name")
+			}
+			ps.chart = args[0]
+			ps.plan.Name = args[1]
+			if cmd.Flags().Changed("free") {
+				ps.plan.Free = &ps.free
+			}
+			if cmd.Flags().Changed("bindable") {
+				ps.plan.Bindable = &ps.bindable
+			}
+			return ps.run()
+		},
+	}
+	if update {
+		cmd.Use = "update CHART-NAME PLAN-NAME"
+		cmd.Short = "replace a plan of a chart"
+	}
+
+	ps.baseBazaarCmd.addCommonFlags(cmd)
+	cmd.Flags().StringVar(&ps.plan.Description, "description", "", "plan description")
+	cmd.Flags().StringSliceVar(&ps.plan.Bullets, "bullet", []string{}, "plan bullet point, can be repeated")
+	cmd.Flags().StringVar(&ps.plan.File, "file", "", "name of the plan's values file in plans/ (defaults to PLAN-NAME.yaml)")
+	cmd.Flags().StringVar(&ps.valuesPath, "values", "", "path to the plan's values file")
+	cmd.Flags().StringVar(&ps.credentialsPath, "credentials", "", "path to a kubeconfig for the cluster the plan targets")
+	if update {
+		cmd.Flags().BoolVar(&ps.plan.ClearCredentials, "clear-credentials", false, "remove the plan's credentials, targeting the default cluster")
+	}
+	cmd.Flags().BoolVar(&ps.free, "free", true, "whether the plan is free")
+	cmd.Flags().BoolVar(&ps.bindable, "bindable", true, "whether the plan is bindable")
+
+	return cmd
+}
+
+func (ps *plansSaveCmd) run() error {
+	if err := ps.setup(); err != nil {
+		return err
+	}
+
+	if ps.valuesPath != "" {
+		values, err := ioutil.ReadFile(ps.valuesPath)
+		if err != nil {
+			return err
+		}
+		valuesString := string(values)
+		ps.plan.Values = &valuesString
+	}
+	if ps.credentialsPath != "" {
+		credentials, err := ioutil.ReadFile(ps.credentialsPath)
+		if err != nil {
+			return err
+		}
+		ps.plan.Credentials = string(credentials)
+	}
+
+	body, err := json.Marshal(ps.plan)
+	if err != nil {
+		return err
+	}
+
+	method := "POST"
+	url := fmt.Sprintf("%s/charts/%s/plans", ps.target, ps.chart)
+	if ps.update {
+		method = "PUT"
+		url = fmt.Sprintf("%s/charts/%s/plans/%s", ps.target, ps.chart, ps.plan.Name)
+	}
+
+	client, err := ps.httpClient()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	err = ps.addAuthHeader(req)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode != 200 {
+		return newAPIError(res)
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	responseJSON := bazaar.DisplayResponse{}
+	err = json.Unmarshal(responseBody, &responseJSON)
+	if err != nil {
+		return err
+	}
+
+	return ps.printMessage(responseJSON.Message)
+}
@@ -0,0 +1,128 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Save plan", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+
+	var bazaarAPIRequest *http.Request
+	var bazaarAPIRequestBody bazaar.PlanRequest
+	var bazaarAPITestServer *httptest.Server
+	var valuesFile *os.File
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+
+		var err error
+		valuesFile, err = ioutil.TempFile("", "values-")
+		Expect(err).To(BeNil())
+		valuesFile.Write([]byte("persistence:\n  size: 64Gi\n"))
+		valuesFile.Close()
+
+		responseBody, _ := json.Marshal(bazaar.DisplayResponse{Message: "Yay"})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			bazaarAPIRequest = r
+			bazaarAPIRequestBody = bazaar.PlanRequest{}
+			json.NewDecoder(r.Body).Decode(&bazaarAPIRequestBody)
+			w.Write(responseBody)
+		})
+		bazaarAPITestServer = httptest.NewServer(handler)
+	})
+
+	AfterEach(func() {
+		bazaarAPITestServer.Close()
+		os.Remove(valuesFile.Name())
+	})
+
+	setFlags := func(c *cobra.Command) {
+		c.Flags().Set("user", "bob")
+		c.Flags().Set("password", "monkey123")
+		c.Flags().Set("target", bazaarAPITestServer.URL)
+	}
+
+	It("adds plan", func() {
+		c := cli.NewPlansAddCmd(out)
+		setFlags(c)
+		c.Flags().Set("description", "large plan")
+		c.Flags().Set("bullet", "64Gi disk")
+		c.Flags().Set("values", valuesFile.Name())
+		c.Flags().Set("free", "false")
+
+		err := c.RunE(c, []string{"spacebears", "large"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(string(b.Bytes())).To(ContainSubstring("Yay"))
+		Expect(bazaarAPIRequest.Method).To(Equal("POST"))
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts/spacebears/plans"))
+		Expect(bazaarAPIRequestBody.Name).To(Equal("large"))
+		Expect(bazaarAPIRequestBody.Description).To(Equal("large plan"))
+		Expect(bazaarAPIRequestBody.Bullets).To(Equal([]string{"64Gi disk"}))
+		Expect(*bazaarAPIRequestBody.Values).To(ContainSubstring("64Gi"))
+		Expect(*bazaarAPIRequestBody.Free).To(BeFalse())
+		Expect(bazaarAPIRequestBody.Bindable).To(BeNil())
+	})
+
+	It("updates plan", func() {
+		c := cli.NewPlansUpdateCmd(out)
+		setFlags(c)
+
+		err := c.RunE(c, []string{"spacebears", "small"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequest.Method).To(Equal("PUT"))
+		Expect(bazaarAPIRequest.URL.Path).To(Equal("/charts/spacebears/plans/small"))
+		Expect(bazaarAPIRequestBody.Values).To(BeNil())
+		Expect(bazaarAPIRequestBody.ClearCredentials).To(BeFalse())
+	})
+
+	It("clears plan credentials", func() {
+		c := cli.NewPlansUpdateCmd(out)
+		setFlags(c)
+		c.Flags().Set("clear-credentials", "true")
+
+		err := c.RunE(c, []string{"spacebears", "medium"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(bazaarAPIRequestBody.ClearCredentials).To(BeTrue())
+	})
+
+	It("error when plan name not supplied", func() {
+		c := cli.NewPlansAddCmd(out)
+		err := c.RunE(c, []string{"spacebears"})
+		Expect(err).NotTo(BeNil())
+	})
+})
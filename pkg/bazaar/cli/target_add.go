@@ -0,0 +1,94 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type targetAddCmd struct {
+	baseBazaarCmd
+	entry target
+}
+
+func NewTargetAddCmd(out io.Writer) *cobra.Command {
+	ta := &targetAddCmd{}
+	ta.out = out
+
+	cmd := &cobra.Command{
+		Use:   "add NAME URL",
+		Short: "add a target, or replace the target with the same name",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 2 {
+				return errors.New("missing target name and url")
+			}
+			ta.entry.Name = args[0]
+			ta.entry.URL = args[1]
+			return ta.run()
+		},
+	}
+
+	cmd.Flags().StringVarP(&ta.entry.User, "user", "u", "", "bazaar API user")
+	cmd.Flags().StringVarP(&ta.entry.Password, "password", "p", "", "bazaar API password, stored in the config file")
+	cmd.Flags().StringVar(&ta.entry.PasswordEnv, "password-env", "", "environment variable to read the password from, instead of storing it")
+	cmd.Flags().StringVar(&ta.entry.ClientID, "client-id", "", "client to fetch a bearer token for, instead of user and password")
+	cmd.Flags().StringVar(&ta.entry.ClientSecret, "client-secret", "", "secret of the client, stored in the config file")
+	cmd.Flags().StringVar(&ta.entry.ClientSecretEnv, "client-secret-env", "", "environment variable to read the client secret from, instead of storing it")
+	cmd.Flags().StringVar(&ta.entry.TokenURL, "token-url", "", "token endpoint to fetch a bearer token from")
+	cmd.Flags().StringVar(&ta.entry.CACert, "ca-cert", "", "CA bundle to trust instead of the system's")
+	cmd.Flags().StringVar(&ta.entry.Cert, "cert", "", "client certificate, for a bazaar API that verifies them")
+	cmd.Flags().StringVar(&ta.entry.Key, "key", "", "client certificate key")
+	cmd.Flags().BoolVarP(&ta.entry.SkipSSLValidation, "skip-ssl-validation", "k", false, "don't verify the server's certificate, for dev environments only")
+
+	return cmd
+}
+
+func (ta *targetAddCmd) run() error {
+	if strings.Contains(ta.entry.Name, "://") {
+		return errors.Errorf("target name [%s] can't be a url", ta.entry.Name)
+	}
+	if !strings.Contains(ta.entry.URL, "://") {
+		return errors.Errorf("target url [%s] needs a scheme, such as https://", ta.entry.URL)
+	}
+	if ta.entry.Password != "" && ta.entry.PasswordEnv != "" {
+		return errors.New("only one of --password and --password-env can be given")
+	}
+	if ta.entry.ClientSecret != "" && ta.entry.ClientSecretEnv != "" {
+		return errors.New("only one of --client-secret and --client-secret-env can be given")
+	}
+	ta.entry.URL = strings.TrimSuffix(ta.entry.URL, "/")
+
+	config, err := loadTargetConfig()
+	if err != nil {
+		return err
+	}
+	config.put(&ta.entry)
+	if config.Current == "" {
+		config.Current = ta.entry.Name
+	}
+	err = config.save()
+	if err != nil {
+		return err
+	}
+
+	ta.out.Write([]byte(fmt.Sprintf("Target [%s] added\n", ta.entry.Name)))
+	return nil
+}
@@ -0,0 +1,130 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Add target", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewTargetAddCmd(out)
+	})
+
+	readConfig := func() string {
+		contents, err := ioutil.ReadFile(filepath.Join(bazaarHome, "config.yaml"))
+		Expect(err).To(BeNil())
+		return string(contents)
+	}
+
+	It("stores the target readable only by the user", func() {
+		c.Flags().Set("user", "admin")
+		c.Flags().Set("password", "monkey123")
+
+		err := c.RunE(c, []string{"dev", "https://bazaar.dev.example.com/"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(ContainSubstring("Target [dev] added"))
+		Expect(readConfig()).To(MatchYAML(`
+current: dev
+targets:
+- name: dev
+  url: https://bazaar.dev.example.com
+  user: admin
+  password: monkey123
+`))
+		info, err := os.Stat(filepath.Join(bazaarHome, "config.yaml"))
+		Expect(err).To(BeNil())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+	})
+
+	It("keeps the current target when adding another", func() {
+		err := c.RunE(c, []string{"dev", "https://bazaar.dev.example.com"})
+		Expect(err).To(BeNil())
+
+		c = cli.NewTargetAddCmd(out)
+		c.Flags().Set("password-env", "BAZAAR_PROD_PASSWORD")
+		err = c.RunE(c, []string{"prod", "https://bazaar.example.com"})
+		Expect(err).To(BeNil())
+
+		Expect(readConfig()).To(MatchYAML(`
+current: dev
+targets:
+- name: dev
+  url: https://bazaar.dev.example.com
+- name: prod
+  url: https://bazaar.example.com
+  passwordEnv: BAZAAR_PROD_PASSWORD
+`))
+	})
+
+	It("replaces a target with the same name", func() {
+		err := c.RunE(c, []string{"dev", "https://old.example.com"})
+		Expect(err).To(BeNil())
+
+		c = cli.NewTargetAddCmd(out)
+		err = c.RunE(c, []string{"dev", "https://new.example.com"})
+		Expect(err).To(BeNil())
+
+		Expect(readConfig()).To(MatchYAML(`
+current: dev
+targets:
+- name: dev
+  url: https://new.example.com
+`))
+	})
+
+	It("doesn't take a password and a password env", func() {
+		c.Flags().Set("password", "monkey123")
+		c.Flags().Set("password-env", "BAZAAR_PASSWORD")
+
+		err := c.RunE(c, []string{"dev", "https://bazaar.dev.example.com"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("--password-env"))
+	})
+
+	It("requires a url", func() {
+		err := c.RunE(c, []string{"dev", "bazaar.dev.example.com"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("scheme"))
+	})
+
+	It("requires a name and url", func() {
+		err := c.RunE(c, []string{"dev"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("missing target name and url"))
+	})
+})
@@ -0,0 +1,103 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io"
+
+	"github.com/gosuri/uitable"
+	"github.com/spf13/cobra"
+)
+
+func NewTargetCmd(out io.Writer) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "target",
+		Short: "manage the bazaar APIs stored in ~/.bazaar/config.yaml",
+	}
+
+	cmd.AddCommand(
+		NewTargetListCmd(out),
+		NewTargetAddCmd(out),
+		NewTargetUseCmd(out),
+	)
+
+	return cmd
+}
+
+// displayTarget leaves out the target's secrets
+type displayTarget struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	User    string `json:"user,omitempty"`
+	Current bool   `json:"current"`
+}
+
+type targetListCmd struct {
+	baseBazaarCmd
+}
+
+func NewTargetListCmd(out io.Writer) *cobra.Command {
+	tl := &targetListCmd{}
+	tl.out = out
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "list targets",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return tl.run()
+		},
+	}
+
+	tl.baseBazaarCmd.addOutputFlags(cmd)
+
+	return cmd
+}
+
+func (tl *targetListCmd) run() error {
+	if _, err := tl.outputFormat(); err != nil {
+		return err
+	}
+
+	config, err := loadTargetConfig()
+	if err != nil {
+		return err
+	}
+
+	targets := []displayTarget{}
+	for _, t := range config.Targets {
+		targets = append(targets, displayTarget{
+			Name:    t.Name,
+			URL:     t.URL,
+			User:    t.User,
+			Current: t.Name == config.Current,
+		})
+	}
+
+	return tl.print(targets, func() {
+		table := uitable.New()
+		table.AddRow("CURRENT", "NAME", "URL", "USER")
+		for _, t := range targets {
+			current := ""
+			if t.Current {
+				current = "*"
+			}
+			table.AddRow(current, t.Name, t.URL, t.User)
+		}
+
+		tl.out.Write(table.Bytes())
+		tl.out.Write([]byte("\n"))
+	})
+}
@@ -0,0 +1,90 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("List targets", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewTargetListCmd(out)
+
+		err := ioutil.WriteFile(filepath.Join(bazaarHome, "config.yaml"), []byte(`
+current: prod
+targets:
+- name: dev
+  url: https://bazaar.dev.example.com
+  user: admin
+  password: monkey123
+- name: prod
+  url: https://bazaar.example.com
+  clientID: bazaar-ci
+  clientSecret: secret
+`), 0600)
+		Expect(err).To(BeNil())
+	})
+
+	It("lists targets", func() {
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(ContainSubstring("https://bazaar.dev.example.com"))
+		Expect(b.String()).To(MatchRegexp(`\*\s+prod`))
+		Expect(b.String()).NotTo(ContainSubstring("monkey123"))
+	})
+
+	It("lists targets as json without their secrets", func() {
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(MatchJSON(`[
+			{"name": "dev", "url": "https://bazaar.dev.example.com", "user": "admin", "current": false},
+			{"name": "prod", "url": "https://bazaar.example.com", "current": true}
+		]`))
+	})
+
+	It("lists nothing without a config file", func() {
+		Expect(os.Remove(filepath.Join(bazaarHome, "config.yaml"))).To(Succeed())
+		c.Flags().Set("output", "json")
+
+		err := c.RunE(c, []string{})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(Equal("[]\n"))
+	})
+})
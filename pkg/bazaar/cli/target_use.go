@@ -0,0 +1,67 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type targetUseCmd struct {
+	baseBazaarCmd
+	name string
+}
+
+func NewTargetUseCmd(out io.Writer) *cobra.Command {
+	tu := &targetUseCmd{}
+	tu.out = out
+
+	cmd := &cobra.Command{
+		Use:   "use NAME",
+		Short: "make a target the current target, used when --target isn't given",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) != 1 {
+				return errors.New("missing target name")
+			}
+			tu.name = args[0]
+			return tu.run()
+		},
+	}
+
+	return cmd
+}
+
+func (tu *targetUseCmd) run() error {
+	config, err := loadTargetConfig()
+	if err != nil {
+		return err
+	}
+	if config.find(tu.name) == nil {
+		return errors.Errorf("unknown target [%s], add it with 'target add'", tu.name)
+	}
+
+	config.Current = tu.name
+	err = config.save()
+	if err != nil {
+		return err
+	}
+
+	tu.out.Write([]byte(fmt.Sprintf("Using target [%s]\n", tu.name)))
+	return nil
+}
@@ -0,0 +1,69 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli_test
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar/cli"
+	"github.com/spf13/cobra"
+)
+
+var _ = Describe("Use target", func() {
+	var b bytes.Buffer
+	var out *bufio.Writer
+	var c *cobra.Command
+
+	BeforeEach(func() {
+		b = bytes.Buffer{}
+		out = bufio.NewWriter(&b)
+		c = cli.NewTargetUseCmd(out)
+
+		err := ioutil.WriteFile(filepath.Join(bazaarHome, "config.yaml"), []byte(`
+current: dev
+targets:
+- name: dev
+  url: https://bazaar.dev.example.com
+- name: prod
+  url: https://bazaar.example.com
+`), 0600)
+		Expect(err).To(BeNil())
+	})
+
+	It("changes the current target", func() {
+		err := c.RunE(c, []string{"prod"})
+		out.Flush()
+
+		Expect(err).To(BeNil())
+		Expect(b.String()).To(ContainSubstring("Using target [prod]"))
+		contents, err := ioutil.ReadFile(filepath.Join(bazaarHome, "config.yaml"))
+		Expect(err).To(BeNil())
+		Expect(string(contents)).To(ContainSubstring("current: prod"))
+	})
+
+	It("returns an error for unknown targets", func() {
+		err := c.RunE(c, []string{"staging"})
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("unknown target [staging]"))
+	})
+})
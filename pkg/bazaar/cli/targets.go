@@ -0,0 +1,141 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const bazaarHomeEnv = "BAZAAR_HOME"
+
+// target is a named bazaar API and the credentials to use with it, stored in the config file
+type target struct {
+	Name              string `json:"name"`
+	URL               string `json:"url"`
+	User              string `json:"user,omitempty"`
+	Password          string `json:"password,omitempty"`
+	PasswordEnv       string `json:"passwordEnv,omitempty"`
+	ClientID          string `json:"clientID,omitempty"`
+	ClientSecret      string `json:"clientSecret,omitempty"`
+	ClientSecretEnv   string `json:"clientSecretEnv,omitempty"`
+	TokenURL          string `json:"tokenURL,omitempty"`
+	CACert            string `json:"caCert,omitempty"`
+	Cert              string `json:"cert,omitempty"`
+	Key               string `json:"key,omitempty"`
+	SkipSSLValidation bool   `json:"skipSSLValidation,omitempty"`
+}
+
+type targetConfig struct {
+	Current string    `json:"current,omitempty"`
+	Targets []*target `json:"targets"`
+}
+
+// targetConfigPath is ~/.bazaar/config.yaml, or config.yaml in $BAZAAR_HOME when set
+func targetConfigPath() (string, error) {
+	dir := os.Getenv(bazaarHomeEnv)
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", errors.Wrap(err, "Unable to find home directory for bazaar config")
+		}
+		dir = filepath.Join(home, ".bazaar")
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+func loadTargetConfig() (*targetConfig, error) {
+	config := &targetConfig{}
+	path, err := targetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read bazaar config [%s]", path)
+	}
+
+	err = yaml.Unmarshal(contents, config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse bazaar config [%s]", path)
+	}
+	return config, nil
+}
+
+// save writes the config readable only by the user, as it can hold passwords and secrets
+func (c *targetConfig) save() error {
+	path, err := targetConfigPath()
+	if err != nil {
+		return err
+	}
+
+	contents, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to create bazaar config directory [%s]", filepath.Dir(path))
+	}
+	err = ioutil.WriteFile(path, contents, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write bazaar config [%s]", path)
+	}
+	// WriteFile keeps the mode of an existing file
+	return os.Chmod(path, 0600)
+}
+
+func (c *targetConfig) find(name string) *target {
+	for _, t := range c.Targets {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// put adds the target, replacing any target with the same name
+func (c *targetConfig) put(t *target) {
+	for i, existing := range c.Targets {
+		if existing.Name == t.Name {
+			c.Targets[i] = t
+			return
+		}
+	}
+	c.Targets = append(c.Targets, t)
+}
+
+func (t *target) password() string {
+	if t.Password == "" && t.PasswordEnv != "" {
+		return os.Getenv(t.PasswordEnv)
+	}
+	return t.Password
+}
+
+func (t *target) clientSecret() string {
+	if t.ClientSecret == "" && t.ClientSecretEnv != "" {
+		return os.Getenv(t.ClientSecretEnv)
+	}
+	return t.ClientSecret
+}
@@ -16,7 +16,12 @@
 package bazaar
 
 import (
+	"errors"
+	"net/http"
+	"time"
+
 	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 	"github.com/kelseyhightower/envconfig"
 )
 
@@ -24,17 +29,75 @@ type bazaarConfig struct {
 	AdminUsername string `envconfig:"SECURITY_USER_NAME" required:"true"`
 	AdminPassword string `envconfig:"SECURITY_USER_PASSWORD" required:"true"`
 
-	Port         int    `envconfig:"PORT" default:"8081"`
-	HelmChartDir string `envconfig:"HELM_CHART_DIR" default:"charts"`
+	Port             int    `envconfig:"PORT" default:"8081"`
+	HelmChartDir     string `envconfig:"HELM_CHART_DIR" default:"charts"`
+	RetainedVersions int    `envconfig:"CHART_VERSIONS_RETAINED" default:"5"`
+
+	ProvenanceKeyring  string `envconfig:"CHART_PROVENANCE_KEYRING"`
+	ProvenanceRequired bool   `envconfig:"CHART_PROVENANCE_REQUIRED"`
 
-	RegistryConfig *config.RegistryConfig
-	KiboshConfig   *KiboshConfig
+	RegistryConfig  *config.RegistryConfig
+	KiboshConfig    *KiboshConfig
+	UploadConfig    *UploadConfig
+	TokenConfig     *TokenConfig
+	ServerTLSConfig *config.ServerTLSConfig
 }
 
 type KiboshConfig struct {
 	Server string `envconfig:"KIBOSH_SERVER" required:"true"`
 	User   string `envconfig:"KIBOSH_USER_NAME" required:"true"`
 	Pass   string `envconfig:"KIBOSH_USER_PASSWORD" required:"true"`
+
+	ReloadAttempts int           `envconfig:"KIBOSH_RELOAD_ATTEMPTS" default:"3"`
+	ReloadBackoff  time.Duration `envconfig:"KIBOSH_RELOAD_BACKOFF" default:"1s"`
+
+	CACertFile        string `envconfig:"KIBOSH_CA_CERT_FILE"`
+	ClientCertFile    string `envconfig:"KIBOSH_CLIENT_CERT_FILE"`
+	ClientKeyFile     string `envconfig:"KIBOSH_CLIENT_KEY_FILE"`
+	SkipSSLValidation bool   `envconfig:"KIBOSH_SKIP_SSL_VALIDATION"`
+}
+
+func (k *KiboshConfig) HTTPClient() (*http.Client, error) {
+	return httphelpers.NewHTTPClient(k.CACertFile, k.ClientCertFile, k.ClientKeyFile, k.SkipSSLValidation)
+}
+
+// TokenConfig enables bearer token auth when either a JWKS url or a verification key is set
+type TokenConfig struct {
+	JWKSURL         string `envconfig:"TOKEN_JWKS_URL"`
+	VerificationKey string `envconfig:"TOKEN_VERIFICATION_KEY"`
+	Issuer          string `envconfig:"TOKEN_ISSUER"`
+	Audience        string `envconfig:"TOKEN_AUDIENCE"`
+
+	CACertFile        string `envconfig:"TOKEN_CA_CERT_FILE"`
+	SkipSSLValidation bool   `envconfig:"TOKEN_SKIP_SSL_VALIDATION"`
+
+	ReaderScope    string `envconfig:"TOKEN_READER_SCOPE" default:"bazaar.read"`
+	PublisherScope string `envconfig:"TOKEN_PUBLISHER_SCOPE" default:"bazaar.write"`
+	AdminScope     string `envconfig:"TOKEN_ADMIN_SCOPE" default:"bazaar.admin"`
+}
+
+func (t *TokenConfig) Enabled() bool {
+	return t.JWKSURL != "" || t.VerificationKey != ""
+}
+
+func (t *TokenConfig) Verifier() (httphelpers.TokenVerifier, error) {
+	if t.JWKSURL != "" {
+		client, err := httphelpers.NewHTTPClient(t.CACertFile, "", "", t.SkipSSLValidation)
+		if err != nil {
+			return nil, err
+		}
+		client.Timeout = 10 * time.Second
+		return httphelpers.NewJWKSVerifier(client, t.JWKSURL, t.Issuer, t.Audience), nil
+	}
+	return httphelpers.NewKeyVerifier(t.VerificationKey, t.Issuer, t.Audience)
+}
+
+func (t *TokenConfig) RoleScopes() httphelpers.RoleScopes {
+	return httphelpers.RoleScopes{
+		Reader:    t.ReaderScope,
+		Publisher: t.PublisherScope,
+		Admin:     t.AdminScope,
+	}
 }
 
 func ParseConfig() (*bazaarConfig, error) {
@@ -43,5 +106,19 @@ func ParseConfig() (*bazaarConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	if c.ProvenanceRequired && c.ProvenanceKeyring == "" {
+		return nil, errors.New("requiring chart provenance requires a keyring (CHART_PROVENANCE_KEYRING)")
+	}
+	if c.TokenConfig.JWKSURL != "" && c.TokenConfig.VerificationKey != "" {
+		return nil, errors.New("token keys come from either TOKEN_JWKS_URL or TOKEN_VERIFICATION_KEY, not both")
+	}
+	err = c.ServerTLSConfig.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = c.RegistryConfig.Validate()
+	if err != nil {
+		return nil, err
+	}
 	return c, nil
 }
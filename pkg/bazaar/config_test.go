@@ -17,8 +17,10 @@ package bazaar_test
 
 import (
 	"os"
+	"time"
 
 	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -48,6 +50,111 @@ var _ = Describe("Config", func() {
 		Expect(c.KiboshConfig.Server).To(Equal("mykibosh.com"))
 		Expect(c.KiboshConfig.User).To(Equal("kevin"))
 		Expect(c.KiboshConfig.Pass).To(Equal("monkey123"))
+		Expect(c.RetainedVersions).To(Equal(5))
+	})
+
+	It("parses retained chart versions", func() {
+		os.Setenv("CHART_VERSIONS_RETAINED", "2")
+
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.RetainedVersions).To(Equal(2))
+	})
+
+	It("parses kibosh reload retry config", func() {
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.KiboshConfig.ReloadAttempts).To(Equal(3))
+		Expect(c.KiboshConfig.ReloadBackoff).To(Equal(time.Second))
+
+		os.Setenv("KIBOSH_RELOAD_ATTEMPTS", "5")
+		os.Setenv("KIBOSH_RELOAD_BACKOFF", "250ms")
+
+		c, err = bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.KiboshConfig.ReloadAttempts).To(Equal(5))
+		Expect(c.KiboshConfig.ReloadBackoff).To(Equal(250 * time.Millisecond))
+	})
+
+	It("parses upload config", func() {
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.UploadConfig).To(Equal(bazaar.DefaultUploadConfig()))
+
+		os.Setenv("UPLOAD_DIR", "/var/vcap/data/bazaar/uploads")
+		os.Setenv("UPLOAD_MAX_SIZE", "1048576")
+		os.Setenv("UPLOAD_EXPIRY", "1h")
+
+		c, err = bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.UploadConfig.Dir).To(Equal("/var/vcap/data/bazaar/uploads"))
+		Expect(c.UploadConfig.MaxSize).To(Equal(int64(1048576)))
+		Expect(c.UploadConfig.Expiry).To(Equal(time.Hour))
+	})
+
+	It("parses token config", func() {
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.TokenConfig.Enabled()).To(BeFalse())
+		Expect(c.TokenConfig.RoleScopes()).To(Equal(httphelpers.RoleScopes{
+			Reader: "bazaar.read", Publisher: "bazaar.write", Admin: "bazaar.admin",
+		}))
+
+		os.Setenv("TOKEN_JWKS_URL", "https://uaa.example.com/token_keys")
+		os.Setenv("TOKEN_ISSUER", "https://uaa.example.com/oauth/token")
+		os.Setenv("TOKEN_ADMIN_SCOPE", "pks.bazaar.admin")
+
+		c, err = bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.TokenConfig.Enabled()).To(BeTrue())
+		Expect(c.TokenConfig.JWKSURL).To(Equal("https://uaa.example.com/token_keys"))
+		Expect(c.TokenConfig.Issuer).To(Equal("https://uaa.example.com/oauth/token"))
+		Expect(c.TokenConfig.RoleScopes().Admin).To(Equal("pks.bazaar.admin"))
+	})
+
+	It("requires a single source of token keys", func() {
+		os.Setenv("TOKEN_JWKS_URL", "https://uaa.example.com/token_keys")
+		os.Setenv("TOKEN_VERIFICATION_KEY", "-----BEGIN PUBLIC KEY-----")
+
+		_, err := bazaar.ParseConfig()
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("parses tls config", func() {
+		os.Setenv("TLS_CERT_FILE", "/var/vcap/jobs/bazaar/config/tls.crt")
+		os.Setenv("TLS_KEY_FILE", "/var/vcap/jobs/bazaar/config/tls.key")
+		os.Setenv("KIBOSH_CA_CERT_FILE", "/var/vcap/jobs/bazaar/config/kibosh_ca.crt")
+		os.Setenv("KIBOSH_SKIP_SSL_VALIDATION", "true")
+
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.ServerTLSConfig.HasServerTLS()).To(BeTrue())
+		Expect(c.KiboshConfig.CACertFile).To(Equal("/var/vcap/jobs/bazaar/config/kibosh_ca.crt"))
+		Expect(c.KiboshConfig.SkipSSLValidation).To(BeTrue())
+	})
+
+	It("requires a key with the tls cert", func() {
+		os.Setenv("TLS_CERT_FILE", "/var/vcap/jobs/bazaar/config/tls.crt")
+
+		_, err := bazaar.ParseConfig()
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("parses provenance config", func() {
+		os.Setenv("CHART_PROVENANCE_KEYRING", "/var/vcap/jobs/bazaar/config/keyring.asc")
+		os.Setenv("CHART_PROVENANCE_REQUIRED", "true")
+
+		c, err := bazaar.ParseConfig()
+		Expect(err).To(BeNil())
+		Expect(c.ProvenanceKeyring).To(Equal("/var/vcap/jobs/bazaar/config/keyring.asc"))
+		Expect(c.ProvenanceRequired).To(BeTrue())
+	})
+
+	It("requires a keyring when provenance is required", func() {
+		os.Setenv("CHART_PROVENANCE_REQUIRED", "true")
+
+		_, err := bazaar.ParseConfig()
+		Expect(err).NotTo(BeNil())
 	})
 
 })
@@ -0,0 +1,239 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/pkg/errors"
+)
+
+// ChartURLRequest saves a chart that Bazaar fetches from URL, such as an artifact store, instead of
+// one uploaded with the request. When SHA256 is given, the fetched chart must match it.
+type ChartURLRequest struct {
+	URL           string `json:"url"`
+	SHA256        string `json:"sha256,omitempty"`
+	ProvenanceURL string `json:"provenanceURL,omitempty"`
+}
+
+// fetchError is returned when the chart's URL can't be fetched
+type fetchError struct {
+	url string
+	err error
+}
+
+func (e *fetchError) Error() string {
+	return fmt.Sprintf("Unable to fetch [%s]: %v", e.url, e.err)
+}
+
+func isJSONRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// receiveChartURL fetches the chart of a ChartURLRequest into a staging directory, the same way
+// receiveUpload stages uploaded charts
+func (api *api) receiveChartURL(r *http.Request) (*upload, error) {
+	request := ChartURLRequest{}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		return nil, &badUploadError{reason: errors.Wrap(err, "Unable to parse chart url request").Error()}
+	}
+	chartURL, err := api.uploadConfig.parseFetchURL(request.URL)
+	if err != nil {
+		return nil, err
+	}
+	var provenanceURL *url.URL
+	if request.ProvenanceURL != "" {
+		provenanceURL, err = api.uploadConfig.parseFetchURL(request.ProvenanceURL)
+		if err != nil {
+			return nil, err
+		}
+	}
+	checksum := strings.ToLower(strings.TrimPrefix(request.SHA256, "sha256:"))
+
+	client, err := api.uploadConfig.fetchClient()
+	if err != nil {
+		return nil, err
+	}
+	defer client.CloseIdleConnections()
+	dir, err := ioutil.TempDir(api.uploadConfig.dir(), "chart-")
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create upload staging directory")
+	}
+	u := &upload{dir: dir, provenance: map[string]string{}}
+
+	chartFile := filepath.Join(dir, fetchedFileName(chartURL, "chart.tgz"))
+	sum, err := api.fetch(client, chartURL, chartFile)
+	if err != nil {
+		u.cleanup()
+		return nil, err
+	}
+	if checksum != "" && checksum != sum {
+		u.cleanup()
+		return nil, &badUploadError{reason: fmt.Sprintf("sha256 of [%s] is [%s], expected [%s]", redactURL(chartURL), sum, checksum)}
+	}
+	u.charts = []string{chartFile}
+
+	if provenanceURL != nil {
+		provenanceFile := chartFile + ProvenanceExtension
+		_, err = api.fetch(client, provenanceURL, provenanceFile)
+		if err != nil {
+			u.cleanup()
+			return nil, err
+		}
+		u.provenance[filepath.Base(provenanceFile)] = provenanceFile
+	}
+
+	api.logger.Info(fmt.Sprintf("SaveChart: fetched [%s], sha256 [%s]", redactURL(chartURL), sum))
+	return u, nil
+}
+
+func (u *UploadConfig) parseFetchURL(rawURL string) (*url.URL, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, &badUploadError{reason: fmt.Sprintf("Chart url [%s] must be an http or https url", rawURL)}
+	}
+	if !u.fetchHostAllowed(parsed.Hostname()) {
+		return nil, &badUploadError{reason: fmt.Sprintf("Chart url [%s] isn't on an allowed host", redactURL(parsed))}
+	}
+	return parsed, nil
+}
+
+func (u *UploadConfig) fetchHostAllowed(host string) bool {
+	if len(u.FetchAllowedHosts) == 0 {
+		return true
+	}
+	for _, allowed := range u.FetchAllowedHosts {
+		if strings.EqualFold(host, allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactURL leaves out credentials in the url, for messages and logs
+func redactURL(fetchURL *url.URL) string {
+	redacted := *fetchURL
+	redacted.User = nil
+	return redacted.String()
+}
+
+func fetchedFileName(fetchURL *url.URL, fallback string) string {
+	name := path.Base(fetchURL.Path)
+	if name == "." || name == "/" {
+		return fallback
+	}
+	return name
+}
+
+// fetch writes the body at fetchURL to path, within the maximum upload size, returning its sha256
+func (api *api) fetch(client *http.Client, fetchURL *url.URL, path string) (string, error) {
+	res, err := client.Get(fetchURL.String())
+	if err != nil {
+		return "", &fetchError{url: redactURL(fetchURL), err: err}
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return "", &fetchError{url: redactURL(fetchURL), err: errors.Errorf("status [%s]", res.Status)}
+	}
+
+	maxSize := api.uploadConfig.MaxSize
+	if maxSize > 0 && res.ContentLength > maxSize {
+		return "", &uploadTooLargeError{maxSize: maxSize}
+	}
+	body := &uploadLimitReader{reader: res.Body, maxSize: maxSize, remaining: maxSize}
+	hash := sha256.New()
+	_, err = writeUploadedFile(path, io.TeeReader(body, hash), os.O_WRONLY|os.O_CREATE|os.O_EXCL, api.logger)
+	if err != nil {
+		if body.exceeded() {
+			return "", &uploadTooLargeError{maxSize: maxSize}
+		}
+		if _, ok := errors.Cause(err).(*os.PathError); ok {
+			return "", err
+		}
+		return "", &fetchError{url: redactURL(fetchURL), err: err}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fetchClient fetches urls publishers give, so it gives up after FetchTimeout, follows only a few
+// redirects to http or https urls on allowed hosts, and refuses loopback and link-local addresses
+// (eg cloud metadata services) unless their host is allowed explicitly
+func (u *UploadConfig) fetchClient() (*http.Client, error) {
+	client, err := httphelpers.NewHTTPClient(u.FetchCACertFile, "", "", u.FetchSkipSSLValidation)
+	if err != nil {
+		return nil, err
+	}
+	client.Timeout = u.FetchTimeout
+	if client.Timeout == 0 {
+		client.Timeout = defaultFetchTimeout
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return errors.Errorf("stopped after %d redirects", maxFetchRedirects)
+		}
+		_, err := u.parseFetchURL(req.URL.String())
+		return err
+	}
+	client.Transport.(*http.Transport).DialContext = u.dialFetch(&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	})
+	return client, nil
+}
+
+func (u *UploadConfig) dialFetch(dialer *net.Dialer) func(ctx context.Context, network string, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network string, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if len(u.FetchAllowedHosts) > 0 && u.fetchHostAllowed(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		// checked once the host is resolved, so that a name resolving to a refused address is refused too
+		checked := *dialer
+		checked.Control = func(network string, address string, _ syscall.RawConn) error {
+			ipHost, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(ipHost)
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+				return errors.Errorf("fetching from [%s] isn't allowed", host)
+			}
+			return nil
+		}
+		return checked.DialContext(ctx, network, addr)
+	}
+}
@@ -0,0 +1,255 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Save chart from url", func() {
+	const chart = "I am really a tgz of a chart"
+
+	var repo repositoryfakes.FakeRepository
+	var stagedChange *repositoryfakes.FakeStagedChange
+	var api bazaar.API
+	var uploadDir string
+	var savedChart string
+	var savedProvenance string
+	var artifactRequest *http.Request
+	var uploadConfig *bazaar.UploadConfig
+
+	var kiboshAPITestServer *httptest.Server
+	var artifactServer *httptest.Server
+
+	chartSHA256 := func() string {
+		sum := sha256.Sum256([]byte(chart))
+		return hex.EncodeToString(sum[:])
+	}
+
+	save := func(body string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("POST", "/charts", strings.NewReader(body))
+		Expect(err).To(BeNil())
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		api.Charts().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	BeforeEach(func() {
+		kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		artifactServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			artifactRequest = r
+			switch r.URL.Path {
+			case "/charts/spacebears-0.0.1.tgz":
+				w.Write([]byte(chart))
+			case "/charts/spacebears-0.0.1.tgz.prov":
+				w.Write([]byte("signature"))
+			default:
+				w.WriteHeader(404)
+			}
+		}))
+
+		var err error
+		uploadDir, err = ioutil.TempDir("", "uploads-")
+		Expect(err).To(BeNil())
+
+		savedChart = ""
+		savedProvenance = ""
+		repo = repositoryfakes.FakeRepository{}
+		stagedChange = &repositoryfakes.FakeStagedChange{}
+		repo.StageSaveChartStub = func(path string, provenancePath string) (repository.StagedChange, error) {
+			contents, _ := ioutil.ReadFile(path)
+			savedChart = string(contents)
+			provenance, _ := ioutil.ReadFile(path + bazaar.ProvenanceExtension)
+			savedProvenance = string(provenance)
+			return stagedChange, nil
+		}
+
+		kiboshConfig := &bazaar.KiboshConfig{
+			Server: kiboshAPITestServer.URL,
+			User:   "bob",
+			Pass:   "monkey123",
+		}
+		uploadConfig = &bazaar.UploadConfig{Dir: uploadDir, MaxSize: 100, FetchAllowedHosts: []string{"127.0.0.1"}}
+		api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{Uploads: uploadConfig}, logrus.New())
+	})
+
+	AfterEach(func() {
+		kiboshAPITestServer.Close()
+		artifactServer.Close()
+		os.RemoveAll(uploadDir)
+	})
+
+	It("fetches and saves the chart", func() {
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(savedChart).To(Equal(chart))
+		Expect(stagedChange.CommitCallCount()).To(Equal(1))
+		Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+	})
+
+	It("verifies the chart's sha256", func() {
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz", "sha256": "` + chartSHA256() + `"}`)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(savedChart).To(Equal(chart))
+	})
+
+	It("400s charts that don't match the sha256", func() {
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz", "sha256": "` + strings.Repeat("0", 64) + `"}`)
+
+		Expect(recorder.Code).To(Equal(400))
+		Expect(recorder.Body.String()).To(ContainSubstring(chartSHA256()))
+		Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+	})
+
+	It("fetches the provenance file", func() {
+		recorder := save(`{
+			"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz",
+			"provenanceURL": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz.prov"
+		}`)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(savedProvenance).To(Equal("signature"))
+	})
+
+	It("passes credentials in the url to the artifact store without echoing them", func() {
+		withCredentials := strings.Replace(artifactServer.URL, "http://", "http://ci:secret@", 1)
+
+		recorder := save(`{"url": "` + withCredentials + `/charts/missing.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(502))
+		user, pass, _ := artifactRequest.BasicAuth()
+		Expect(user).To(Equal("ci"))
+		Expect(pass).To(Equal("secret"))
+		Expect(recorder.Body.String()).To(ContainSubstring("404"))
+		Expect(recorder.Body.String()).NotTo(ContainSubstring("secret"))
+	})
+
+	It("413s charts over the maximum size", func() {
+		artifactServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(strings.Repeat("a", 200)))
+		})
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(413))
+		Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+	})
+
+	It("400s urls that aren't http", func() {
+		recorder := save(`{"url": "file:///etc/passwd"}`)
+
+		Expect(recorder.Code).To(Equal(400))
+		Expect(repo.StageSaveChartCallCount()).To(BeZero())
+	})
+
+	It("gives up on urls that don't respond", func() {
+		uploadConfig.FetchTimeout = 100 * time.Millisecond
+		hanging := make(chan struct{})
+		defer close(hanging)
+		artifactServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			<-hanging
+		})
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(502))
+		Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		Expect(ioutil.ReadDir(uploadDir)).To(BeEmpty())
+	})
+
+	It("stops following a redirect loop", func() {
+		redirects := 0
+		artifactServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			redirects++
+			http.Redirect(w, r, "/charts/spacebears-0.0.1.tgz", 302)
+		})
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(502))
+		Expect(recorder.Body.String()).To(ContainSubstring("redirects"))
+		Expect(redirects).To(Equal(5))
+	})
+
+	It("doesn't follow redirects to hosts that aren't allowed", func() {
+		artifactServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", 302)
+		})
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(502))
+		Expect(recorder.Body.String()).To(ContainSubstring("isn't on an allowed host"))
+	})
+
+	It("400s urls on hosts that aren't allowed", func() {
+		recorder := save(`{"url": "https://artifacts.example.com/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(400))
+		Expect(repo.StageSaveChartCallCount()).To(BeZero())
+	})
+
+	It("refuses loopback addresses unless their host is allowed", func() {
+		uploadConfig.FetchAllowedHosts = nil
+		artifactRequest = nil
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(502))
+		Expect(recorder.Body.String()).To(ContainSubstring("isn't allowed"))
+		Expect(artifactRequest).To(BeNil())
+	})
+
+	It("rolls back when kibosh fails to reload", func() {
+		kiboshAPITestServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(401)
+		})
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(500))
+		Expect(stagedChange.RollbackCallCount()).To(Equal(1))
+	})
+
+	It("500s when the repository fails to save", func() {
+		repo.StageSaveChartStub = nil
+		repo.StageSaveChartReturns(nil, errors.New("failed to save charts"))
+
+		recorder := save(`{"url": "` + artifactServer.URL + `/charts/spacebears-0.0.1.tgz"}`)
+
+		Expect(recorder.Code).To(Equal(500))
+	})
+})
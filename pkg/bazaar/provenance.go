@@ -0,0 +1,97 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+	"k8s.io/helm/pkg/provenance"
+)
+
+const ProvenanceExtension = ".prov"
+
+// ProvenanceError is returned when an uploaded chart fails provenance verification
+type ProvenanceError struct {
+	Chart  string
+	Reason string
+}
+
+func (e *ProvenanceError) Error() string {
+	return fmt.Sprintf("Provenance verification of [%s] failed: %s", e.Chart, e.Reason)
+}
+
+type ProvenanceVerifier struct {
+	signatory *provenance.Signatory
+	required  bool
+}
+
+// NewProvenanceVerifier verifies charts against the public keys in keyringPath, which can be
+// either a binary (gpg --export) or an armored (gpg --export --armor) keyring.
+func NewProvenanceVerifier(keyringPath string, required bool) (*ProvenanceVerifier, error) {
+	keyringBytes, err := ioutil.ReadFile(keyringPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read provenance keyring")
+	}
+
+	var keyring openpgp.EntityList
+	if bytes.HasPrefix(bytes.TrimSpace(keyringBytes), []byte("-----BEGIN PGP")) {
+		keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(keyringBytes))
+	} else {
+		keyring, err = openpgp.ReadKeyRing(bytes.NewReader(keyringBytes))
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse provenance keyring")
+	}
+
+	return &ProvenanceVerifier{
+		signatory: &provenance.Signatory{KeyRing: keyring},
+		required:  required,
+	}, nil
+}
+
+// Required is set when unsigned charts are rejected
+func (p *ProvenanceVerifier) Required() bool {
+	return p.required
+}
+
+// Verify checks chartPath against the provenance file at provPath. An empty provPath means
+// the chart wasn't signed, which is only an error when signatures are required.
+func (p *ProvenanceVerifier) Verify(chartPath string, provPath string) (string, error) {
+	chartName := filepath.Base(chartPath)
+	if provPath == "" {
+		if p.required {
+			return "", &ProvenanceError{Chart: chartName, Reason: "chart is unsigned and signatures are required"}
+		}
+		return "", nil
+	}
+
+	verification, err := p.signatory.Verify(chartPath, provPath)
+	if err != nil {
+		return "", &ProvenanceError{Chart: chartName, Reason: err.Error()}
+	}
+
+	signer := ""
+	for name := range verification.SignedBy.Identities {
+		signer = name
+		break
+	}
+	return signer, nil
+}
@@ -0,0 +1,265 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"k8s.io/helm/pkg/chartutil"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/provenance"
+)
+
+var _ = Describe("Provenance", func() {
+	var workDir string
+	var keyringPath string
+	var chartPath string
+	var provPath string
+	var logger *logrus.Logger
+
+	writeKey := func(entity *openpgp.Entity, path string, armored bool) {
+		file, err := os.Create(path)
+		Expect(err).To(BeNil())
+		defer file.Close()
+		if !armored {
+			Expect(entity.Serialize(file)).To(BeNil())
+			return
+		}
+		writer, err := armor.Encode(file, openpgp.PublicKeyType, nil)
+		Expect(err).To(BeNil())
+		Expect(entity.Serialize(writer)).To(BeNil())
+		Expect(writer.Close()).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		logger = logrus.New()
+
+		var err error
+		workDir, err = ioutil.TempDir("", "provenance-")
+		Expect(err).To(BeNil())
+
+		chartDir := filepath.Join(workDir, "spacebears")
+		Expect(os.Mkdir(chartDir, 0700)).To(BeNil())
+		Expect(test.DefaultChart().WriteChart(chartDir)).To(BeNil())
+		chart, err := helm.NewChart(chartDir, nil, logger)
+		Expect(err).To(BeNil())
+		chartPath, err = chartutil.Save(&chart.Chart, workDir)
+		Expect(err).To(BeNil())
+
+		entity, err := openpgp.NewEntity("Chart Publisher", "", "charts@example.com", nil)
+		Expect(err).To(BeNil())
+		secretPath := filepath.Join(workDir, "secret.gpg")
+		secretFile, err := os.Create(secretPath)
+		Expect(err).To(BeNil())
+		Expect(entity.SerializePrivate(secretFile, nil)).To(BeNil())
+		secretFile.Close()
+
+		keyringPath = filepath.Join(workDir, "pubring.gpg")
+		writeKey(entity, keyringPath, false)
+
+		signatory, err := provenance.NewFromFiles(secretPath, keyringPath)
+		Expect(err).To(BeNil())
+		signature, err := signatory.ClearSign(chartPath)
+		Expect(err).To(BeNil())
+		provPath = chartPath + bazaar.ProvenanceExtension
+		Expect(ioutil.WriteFile(provPath, []byte(signature), 0600)).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(workDir)
+	})
+
+	Context("verifier", func() {
+		It("verifies signed chart", func() {
+			verifier, err := bazaar.NewProvenanceVerifier(keyringPath, false)
+			Expect(err).To(BeNil())
+
+			signer, err := verifier.Verify(chartPath, provPath)
+			Expect(err).To(BeNil())
+			Expect(signer).To(ContainSubstring("charts@example.com"))
+		})
+
+		It("reads armored keyring", func() {
+			entityList, err := openpgp.ReadKeyRing(mustOpen(keyringPath))
+			Expect(err).To(BeNil())
+			armoredPath := filepath.Join(workDir, "pubring.asc")
+			writeKey(entityList[0], armoredPath, true)
+
+			verifier, err := bazaar.NewProvenanceVerifier(armoredPath, false)
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(chartPath, provPath)
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects tampered chart", func() {
+			Expect(ioutil.WriteFile(chartPath, []byte("not the chart that was signed"), 0600)).To(BeNil())
+
+			verifier, err := bazaar.NewProvenanceVerifier(keyringPath, false)
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(chartPath, provPath)
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("sha256 sum does not match"))
+		})
+
+		It("rejects chart signed by an unknown key", func() {
+			other, err := openpgp.NewEntity("Someone Else", "", "else@example.com", nil)
+			Expect(err).To(BeNil())
+			otherKeyring := filepath.Join(workDir, "other.gpg")
+			writeKey(other, otherKeyring, false)
+
+			verifier, err := bazaar.NewProvenanceVerifier(otherKeyring, false)
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(chartPath, provPath)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("allows unsigned charts unless required", func() {
+			verifier, err := bazaar.NewProvenanceVerifier(keyringPath, false)
+			Expect(err).To(BeNil())
+			_, err = verifier.Verify(chartPath, "")
+			Expect(err).To(BeNil())
+
+			verifier, err = bazaar.NewProvenanceVerifier(keyringPath, true)
+			Expect(err).To(BeNil())
+			_, err = verifier.Verify(chartPath, "")
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unsigned"))
+		})
+	})
+
+	Context("save chart", func() {
+		var repo *repositoryfakes.FakeRepository
+		var kiboshAPITestServer *httptest.Server
+		var api bazaar.API
+
+		BeforeEach(func() {
+			kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			repo = &repositoryfakes.FakeRepository{}
+			repo.StageSaveChartReturns(&repositoryfakes.FakeStagedChange{}, nil)
+
+			verifier, err := bazaar.NewProvenanceVerifier(keyringPath, true)
+			Expect(err).To(BeNil())
+			api = bazaar.NewAPI(repo, &bazaar.KiboshConfig{
+				Server: kiboshAPITestServer.URL,
+			}, bazaar.APIOptions{Verifier: verifier}, logger)
+		})
+
+		AfterEach(func() {
+			kiboshAPITestServer.Close()
+		})
+
+		It("saves signed chart", func() {
+			req, err := httphelpers.CreateFormRequestFiles("/charts", []httphelpers.FormFiles{
+				{FieldName: "chart", Paths: []string{chartPath}},
+				{FieldName: "provenance", Paths: []string{provPath}},
+			}, []httphelpers.FlagValues{})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			Expect(repo.StageSaveChartCallCount()).To(Equal(1))
+		})
+
+		It("400s on unsigned chart when signatures are required", func() {
+			req, err := httphelpers.CreateFormRequest("/charts", "chart", []string{chartPath})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("unsigned"))
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		})
+
+		It("400s on tampered chart", func() {
+			Expect(ioutil.WriteFile(chartPath, []byte("not the chart that was signed"), 0600)).To(BeNil())
+			req, err := httphelpers.CreateFormRequestFiles("/charts", []httphelpers.FormFiles{
+				{FieldName: "chart", Paths: []string{chartPath}},
+				{FieldName: "provenance", Paths: []string{provPath}},
+			}, []httphelpers.FlagValues{})
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("sha256 sum does not match"))
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		})
+
+		It("400s on plan changes when signatures are required", func() {
+			repo.GetChartsReturns([]*helm.MyChart{{
+				Plans: map[string]helm.Plan{"small": {Name: "small"}},
+				Chart: hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "spacebears"}},
+			}}, nil)
+			req, err := http.NewRequest("POST", "/charts/spacebears/plans", strings.NewReader(`{"name": "large"}`))
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("signatures are required"))
+			Expect(repo.PackageChartWithPlanCallCount()).To(BeZero())
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		})
+
+		It("400s on plan deletes when signatures are required", func() {
+			repo.GetChartsReturns([]*helm.MyChart{{
+				Plans: map[string]helm.Plan{"small": {Name: "small"}, "large": {Name: "large"}},
+				Chart: hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "spacebears"}},
+			}}, nil)
+			req, err := http.NewRequest("DELETE", "/charts/spacebears/plans/large", nil)
+			Expect(err).To(BeNil())
+			recorder := httptest.NewRecorder()
+
+			api.Charts().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(400))
+			Expect(recorder.Body.String()).To(ContainSubstring("CHART_PROVENANCE_REQUIRED"))
+			Expect(repo.PackageChartWithoutPlanCallCount()).To(BeZero())
+			Expect(repo.StageSaveChartCallCount()).To(BeZero())
+		})
+	})
+})
+
+func mustOpen(path string) *os.File {
+	file, err := os.Open(path)
+	Expect(err).To(BeNil())
+	return file
+}
@@ -0,0 +1,263 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultMaxUploadSize = 10 << 30
+	uploadProgressStep   = 100 << 20
+	defaultFetchTimeout  = 30 * time.Minute
+	maxFetchRedirects    = 5
+)
+
+// UploadConfig sets where uploaded charts are staged before they're saved, and how large an upload
+// may be. FetchAllowedHosts, when set, are the only hosts charts are fetched from by url, and they
+// may be loopback or link-local addresses, which are refused otherwise.
+type UploadConfig struct {
+	Dir     string        `envconfig:"UPLOAD_DIR"`
+	MaxSize int64         `envconfig:"UPLOAD_MAX_SIZE" default:"10737418240"`
+	Expiry  time.Duration `envconfig:"UPLOAD_EXPIRY" default:"24h"`
+
+	FetchCACertFile        string        `envconfig:"UPLOAD_FETCH_CA_CERT_FILE"`
+	FetchSkipSSLValidation bool          `envconfig:"UPLOAD_FETCH_SKIP_SSL_VALIDATION"`
+	FetchTimeout           time.Duration `envconfig:"UPLOAD_FETCH_TIMEOUT" default:"30m"`
+	FetchAllowedHosts      []string      `envconfig:"UPLOAD_FETCH_ALLOWED_HOSTS"`
+}
+
+func DefaultUploadConfig() *UploadConfig {
+	return &UploadConfig{
+		MaxSize:      defaultMaxUploadSize,
+		Expiry:       24 * time.Hour,
+		FetchTimeout: defaultFetchTimeout,
+	}
+}
+
+func (u *UploadConfig) dir() string {
+	if u.Dir == "" {
+		return os.TempDir()
+	}
+	return u.Dir
+}
+
+type uploadTooLargeError struct {
+	maxSize int64
+}
+
+func (e *uploadTooLargeError) Error() string {
+	return fmt.Sprintf("upload is larger than the maximum of [%d] bytes", e.maxSize)
+}
+
+type badUploadError struct {
+	reason string
+}
+
+func (e *badUploadError) Error() string {
+	return e.reason
+}
+
+// upload is a set of charts, and their provenance files, received into their own staging directory
+type upload struct {
+	dir        string
+	charts     []string
+	provenance map[string]string
+}
+
+// cleanup removes the upload's staging directory, which callers defer as soon as they have an upload
+func (u *upload) cleanup() {
+	os.RemoveAll(u.dir)
+}
+
+// provenanceFor finds the provenance file uploaded for a chart, matching on file name, or the only
+// provenance file when a single chart was uploaded
+func (u *upload) provenanceFor(chartFile string) string {
+	path, ok := u.provenance[filepath.Base(chartFile)+ProvenanceExtension]
+	if ok {
+		return path
+	}
+	if len(u.charts) == 1 && len(u.provenance) == 1 {
+		for _, path := range u.provenance {
+			return path
+		}
+	}
+	return ""
+}
+
+// receiveUpload streams the "chart" and "provenance" files of a multipart request into a staging
+// directory, without buffering them in memory, failing once the request grows past the maximum size
+func (api *api) receiveUpload(r *http.Request) (*upload, error) {
+	maxSize := api.uploadConfig.MaxSize
+	if maxSize > 0 && r.ContentLength > maxSize {
+		return nil, &uploadTooLargeError{maxSize: maxSize}
+	}
+
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return nil, &badUploadError{reason: "Expected a multipart/form-data request"}
+	}
+
+	dir, err := ioutil.TempDir(api.uploadConfig.dir(), "chart-")
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create upload staging directory")
+	}
+	u := &upload{dir: dir, provenance: map[string]string{}}
+
+	body := &uploadLimitReader{reader: r.Body, maxSize: maxSize, remaining: maxSize}
+	reader := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			u.cleanup()
+			return nil, body.readError(err)
+		}
+
+		field := part.FormName()
+		if part.FileName() == "" || (field != "chart" && field != "provenance") {
+			part.Close()
+			continue
+		}
+		name := filepath.Base(part.FileName())
+		if name == "." || name == string(filepath.Separator) {
+			u.cleanup()
+			return nil, &badUploadError{reason: fmt.Sprintf("Invalid file name [%s]", part.FileName())}
+		}
+
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			u.cleanup()
+			return nil, &badUploadError{reason: fmt.Sprintf("File [%s] was uploaded more than once", name)}
+		}
+		_, err = writeUploadedFile(path, part, os.O_WRONLY|os.O_CREATE|os.O_EXCL, api.logger)
+		part.Close()
+		if err != nil {
+			u.cleanup()
+			return nil, body.readError(err)
+		}
+
+		if field == "chart" {
+			u.charts = append(u.charts, path)
+		} else {
+			u.provenance[name] = path
+		}
+	}
+
+	if len(u.charts) == 0 {
+		u.cleanup()
+		return nil, &badUploadError{reason: "No chart uploaded"}
+	}
+	return u, nil
+}
+
+// writeUploadedFile copies the uploaded data into path, opened with flag, logging progress for
+// large files
+func writeUploadedFile(path string, data io.Reader, flag int, logger *logrus.Logger) (int64, error) {
+	file, err := os.OpenFile(path, flag, 0600)
+	if err != nil {
+		logger.WithError(err).Error("Upload: Couldn't write on disk")
+		return 0, err
+	}
+
+	progress := &uploadProgress{name: filepath.Base(path), logger: logger, next: uploadProgressStep}
+	written, err := io.Copy(io.MultiWriter(file, progress), data)
+	if err != nil {
+		file.Close()
+		logger.WithError(err).Error(fmt.Sprintf("Upload: Couldn't receive [%s]", filepath.Base(path)))
+		return written, err
+	}
+	err = file.Close()
+	if err != nil {
+		logger.WithError(err).Error("Upload: error closing target file")
+		return written, err
+	}
+	if written >= uploadProgressStep {
+		logger.Info(fmt.Sprintf("Upload: received [%s], %d MiB", filepath.Base(path), written>>20))
+	}
+	return written, nil
+}
+
+type uploadProgress struct {
+	name    string
+	logger  *logrus.Logger
+	written int64
+	next    int64
+}
+
+func (p *uploadProgress) Write(b []byte) (int, error) {
+	p.written += int64(len(b))
+	if p.written >= p.next {
+		p.logger.Info(fmt.Sprintf("Upload: receiving [%s], %d MiB so far", p.name, p.written>>20))
+		p.next = p.written + uploadProgressStep
+	}
+	return len(b), nil
+}
+
+// uploadLimitReader fails reads past maxSize bytes with an uploadTooLargeError. A maxSize of zero
+// or less doesn't limit reads.
+type uploadLimitReader struct {
+	reader    io.Reader
+	maxSize   int64
+	remaining int64
+}
+
+func (l *uploadLimitReader) exceeded() bool {
+	return l.maxSize > 0 && l.remaining < 0
+}
+
+// readError reports an upload that's too large as such, even when mime/multipart has wrapped the
+// error, keeps errors writing to disk, and treats any other error as a bad upload
+func (l *uploadLimitReader) readError(err error) error {
+	if l.exceeded() {
+		return &uploadTooLargeError{maxSize: l.maxSize}
+	}
+	if _, ok := errors.Cause(err).(*os.PathError); ok {
+		return err
+	}
+	return &badUploadError{reason: errors.Wrap(err, "Unable to read upload").Error()}
+}
+
+func (l *uploadLimitReader) Read(p []byte) (int, error) {
+	if l.maxSize <= 0 {
+		return l.reader.Read(p)
+	}
+	if l.remaining < 0 {
+		return 0, &uploadTooLargeError{maxSize: l.maxSize}
+	}
+	// read one byte past the limit to tell a request of exactly maxSize from a larger one
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, &uploadTooLargeError{maxSize: l.maxSize}
+	}
+	return n, err
+}
@@ -0,0 +1,330 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// UploadOffsetHeader carries the offset in the chart that a chunk of a chunked upload starts at
+const UploadOffsetHeader = "Upload-Offset"
+
+const uploadMetadataFile = "upload.json"
+
+// UploadRequest starts a chunked upload of a chart of Size bytes
+type UploadRequest struct {
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+}
+
+// SaveUploadRequest saves a completed chunked upload, with the chart's provenance file when the chart
+// is signed
+type SaveUploadRequest struct {
+	Provenance string `json:"provenance,omitempty"`
+}
+
+// DisplayUpload is the state of a chunked upload. Offset is how much of the chart has been received.
+type DisplayUpload struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename"`
+	Size     int64  `json:"size"`
+	Offset   int64  `json:"offset"`
+}
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+var errUploadNotFound = errors.New("Upload not found")
+
+// uploadOffsetError is returned when a chunk doesn't continue from what has been received, or when
+// saving an upload that isn't complete
+type uploadOffsetError struct {
+	upload *DisplayUpload
+	reason string
+}
+
+func (e *uploadOffsetError) Error() string {
+	return fmt.Sprintf("%s, [%d] of [%d] bytes received", e.reason, e.upload.Offset, e.upload.Size)
+}
+
+type uploadBusyError struct {
+	id string
+}
+
+func (e *uploadBusyError) Error() string {
+	return fmt.Sprintf("Upload [%s] is being written by another request", e.id)
+}
+
+// uploadSessions keeps chunked uploads on disk, one directory each, so they survive restarts and
+// can be resumed. Uploads with no activity for longer than the configured expiry are removed.
+type uploadSessions struct {
+	config *UploadConfig
+	logger *logrus.Logger
+
+	lock sync.Mutex
+	busy map[string]bool
+}
+
+func newUploadSessions(config *UploadConfig, logger *logrus.Logger) *uploadSessions {
+	return &uploadSessions{
+		config: config,
+		logger: logger,
+		busy:   map[string]bool{},
+	}
+}
+
+func (s *uploadSessions) root() string {
+	return filepath.Join(s.config.dir(), "bazaar-uploads")
+}
+
+func (s *uploadSessions) create(request UploadRequest) (*DisplayUpload, error) {
+	filename := filepath.Base(request.Filename)
+	if request.Filename == "" || filename != request.Filename || filename == "." || filename == ".." {
+		return nil, &badUploadError{reason: fmt.Sprintf("Invalid file name [%s]", request.Filename)}
+	}
+	// the upload is kept next to its metadata, which it can't replace
+	if filename == uploadMetadataFile {
+		return nil, &badUploadError{reason: fmt.Sprintf("File name [%s] is reserved", request.Filename)}
+	}
+	if request.Size <= 0 {
+		return nil, &badUploadError{reason: "Upload size is required"}
+	}
+	if s.config.MaxSize > 0 && request.Size > s.config.MaxSize {
+		return nil, &uploadTooLargeError{maxSize: s.config.MaxSize}
+	}
+
+	s.sweep()
+
+	id, err := newUploadID()
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(s.root(), id)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to create upload directory")
+	}
+
+	upload := &DisplayUpload{ID: id, Filename: filename, Size: request.Size}
+	metadata, err := json.Marshal(upload)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, uploadMetadataFile), metadata, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, filename), []byte{}, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("Upload: started [%s] for [%s], %d bytes", id, filename, request.Size))
+	return upload, nil
+}
+
+func (s *uploadSessions) get(id string) (*DisplayUpload, error) {
+	if !uploadIDPattern.MatchString(id) {
+		return nil, errUploadNotFound
+	}
+
+	dir := filepath.Join(s.root(), id)
+	metadata, err := ioutil.ReadFile(filepath.Join(dir, uploadMetadataFile))
+	if os.IsNotExist(err) {
+		return nil, errUploadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	upload := &DisplayUpload{}
+	err = json.Unmarshal(metadata, upload)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(filepath.Join(dir, upload.Filename))
+	if os.IsNotExist(err) {
+		return nil, errUploadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	upload.ID = id
+	upload.Offset = info.Size()
+	return upload, nil
+}
+
+// acquire keeps concurrent requests from writing the same upload
+func (s *uploadSessions) acquire(id string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.busy[id] {
+		return &uploadBusyError{id: id}
+	}
+	s.busy[id] = true
+	return nil
+}
+
+func (s *uploadSessions) release(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.busy, id)
+}
+
+// append writes a chunk that starts at offset. A chunk interrupted part way keeps what was received,
+// so the client can continue from the new offset, but a chunk past the upload's size is discarded.
+func (s *uploadSessions) append(id string, offset int64, chunk io.Reader) (*DisplayUpload, error) {
+	err := s.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(id)
+
+	upload, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return nil, &uploadOffsetError{upload: upload, reason: fmt.Sprintf("Chunk starts at [%d]", offset)}
+	}
+
+	path := filepath.Join(s.root(), id, upload.Filename)
+	remaining := upload.Size - upload.Offset
+	written, err := writeUploadedFile(path, io.LimitReader(chunk, remaining), os.O_WRONLY|os.O_APPEND, s.logger)
+	upload.Offset += written
+	if err != nil {
+		return upload, err
+	}
+
+	n, _ := io.ReadFull(chunk, make([]byte, 1))
+	if n > 0 {
+		os.Truncate(path, offset)
+		return nil, &badUploadError{reason: fmt.Sprintf("Chunk extends past the upload's size of [%d] bytes", upload.Size)}
+	}
+	return upload, nil
+}
+
+// complete hands over a fully received upload to be saved, removing it from the sessions
+func (s *uploadSessions) complete(id string, provenance string) (*upload, error) {
+	err := s.acquire(id)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(id)
+
+	session, err := s.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Offset != session.Size {
+		return nil, &uploadOffsetError{upload: session, reason: "Upload is incomplete"}
+	}
+
+	// moving the upload out of the sessions directory keeps it from being swept while it's saved
+	saving, err := ioutil.TempDir(s.config.dir(), "chart-")
+	if err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(saving, id)
+	err = os.Rename(filepath.Join(s.root(), id), dir)
+	if err != nil {
+		os.RemoveAll(saving)
+		return nil, err
+	}
+
+	u := &upload{
+		dir:        saving,
+		charts:     []string{filepath.Join(dir, session.Filename)},
+		provenance: map[string]string{},
+	}
+	if provenance != "" {
+		name := session.Filename + ProvenanceExtension
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(provenance), 0600)
+		if err != nil {
+			u.cleanup()
+			return nil, err
+		}
+		u.provenance[name] = filepath.Join(dir, name)
+	}
+	return u, nil
+}
+
+func (s *uploadSessions) remove(id string) error {
+	err := s.acquire(id)
+	if err != nil {
+		return err
+	}
+	defer s.release(id)
+
+	_, err = s.get(id)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.root(), id))
+}
+
+// sweep removes uploads that haven't received a chunk within the expiry
+func (s *uploadSessions) sweep() {
+	if s.config.Expiry <= 0 {
+		return
+	}
+	entries, err := ioutil.ReadDir(s.root())
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		id := entry.Name()
+		upload, err := s.get(id)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(filepath.Join(s.root(), id, upload.Filename))
+		if err != nil || time.Since(info.ModTime()) < s.config.Expiry {
+			continue
+		}
+		if s.acquire(id) != nil {
+			continue
+		}
+		s.logger.Info(fmt.Sprintf("Upload: removing expired upload [%s] of [%s]", id, upload.Filename))
+		os.RemoveAll(filepath.Join(s.root(), id))
+		s.release(id)
+	}
+}
+
+func newUploadID() (string, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
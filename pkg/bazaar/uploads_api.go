@@ -0,0 +1,148 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/pkg/errors"
+)
+
+// Uploads serves chunked uploads, for charts too large to save in a single request:
+// POST /uploads starts an upload, PUT /uploads/<id> appends a chunk starting at the Upload-Offset
+// header, GET /uploads/<id> shows how much was received, POST /uploads/<id>/save saves the chart
+// and DELETE /uploads/<id> abandons the upload.
+func (api *api) Uploads() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !api.authorized(w, r, httphelpers.RolePublisher) {
+			return
+		}
+
+		var err error
+		id, _ := getUrlPart(1, r)
+		action, _ := getUrlPart(2, r)
+		parts := countUrlParts(r)
+		switch {
+		case r.Method == "POST" && parts == 1:
+			err = api.CreateUpload(w, r)
+		case r.Method == "GET" && parts == 2:
+			err = api.ShowUpload(w, id)
+		case r.Method == "PUT" && parts == 2:
+			err = api.AppendUpload(w, r, id)
+		case r.Method == "POST" && parts == 3 && action == "save":
+			err = api.SaveUpload(w, r, id)
+		case r.Method == "DELETE" && parts == 2:
+			err = api.DeleteUpload(w, id)
+		case r.Method == "GET" || r.Method == "POST" || r.Method == "PUT" || r.Method == "DELETE":
+			w.WriteHeader(404)
+		default:
+			w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+			w.WriteHeader(405)
+		}
+
+		if err != nil {
+			api.logger.WithError(err).Error("Error writing response")
+		}
+	})
+}
+
+func (api *api) CreateUpload(w http.ResponseWriter, r *http.Request) error {
+	request := UploadRequest{}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		api.ServerError(400, errors.Wrap(err, "Unable to parse upload").Error(), w)
+		return nil
+	}
+
+	upload, err := api.sessions.create(request)
+	if err != nil {
+		api.uploadSessionFailed(errors.Wrap(err, "Unable to start upload"), w)
+		return nil
+	}
+	return api.WriteJSONResponse(w, upload)
+}
+
+func (api *api) ShowUpload(w http.ResponseWriter, id string) error {
+	upload, err := api.sessions.get(id)
+	if err != nil {
+		api.uploadSessionFailed(err, w)
+		return nil
+	}
+	return api.WriteJSONResponse(w, upload)
+}
+
+func (api *api) AppendUpload(w http.ResponseWriter, r *http.Request, id string) error {
+	offset, err := strconv.ParseInt(r.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		api.ServerError(400, "A chunk requires its offset in the Upload-Offset header", w)
+		return nil
+	}
+
+	upload, err := api.sessions.append(id, offset, r.Body)
+	if err != nil {
+		api.uploadSessionFailed(err, w)
+		return nil
+	}
+	return api.WriteJSONResponse(w, upload)
+}
+
+func (api *api) SaveUpload(w http.ResponseWriter, r *http.Request, id string) error {
+	request := SaveUploadRequest{}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		api.ServerError(400, errors.Wrap(err, "Unable to parse upload").Error(), w)
+		return nil
+	}
+
+	upload, err := api.sessions.complete(id, request.Provenance)
+	if err != nil {
+		api.uploadSessionFailed(err, w)
+		return nil
+	}
+	defer upload.cleanup()
+
+	return api.saveUpload(upload, w)
+}
+
+func (api *api) DeleteUpload(w http.ResponseWriter, id string) error {
+	err := api.sessions.remove(id)
+	if err != nil {
+		api.uploadSessionFailed(err, w)
+		return nil
+	}
+	return api.WriteJSONResponse(w, DisplayResponse{Message: "Upload deleted"})
+}
+
+// uploadSessionFailed writes the current state of the upload along with conflicts, so clients can
+// resume from the offset the server has
+func (api *api) uploadSessionFailed(err error, w http.ResponseWriter) {
+	if err == errUploadNotFound {
+		api.ServerError(404, err.Error(), w)
+		return
+	}
+	switch cause := errors.Cause(err).(type) {
+	case *uploadOffsetError:
+		w.Header().Set(UploadOffsetHeader, strconv.FormatInt(cause.upload.Offset, 10))
+		api.ServerError(409, err.Error(), w)
+	case *uploadBusyError:
+		api.ServerError(409, err.Error(), w)
+	default:
+		api.uploadFailed(err, w)
+	}
+}
@@ -0,0 +1,238 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bazaar_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/cf-platform-eng/kibosh/pkg/bazaar"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Uploads", func() {
+	var repo repositoryfakes.FakeRepository
+	var stagedChange *repositoryfakes.FakeStagedChange
+	var api bazaar.API
+	var uploadDir string
+	var savedChart string
+	var savedProvenance string
+
+	var kiboshAPIRequest *http.Request
+	var kiboshAPITestServer *httptest.Server
+
+	serve := func(method string, path string, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		Expect(err).To(BeNil())
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		api.Uploads().ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	start := func(size int) bazaar.DisplayUpload {
+		recorder := serve("POST", "/uploads", `{"filename": "spacebears-0.0.1.tgz", "size": `+strconv.Itoa(size)+`}`, nil)
+		Expect(recorder.Code).To(Equal(200))
+		upload := bazaar.DisplayUpload{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &upload)).To(Succeed())
+		return upload
+	}
+
+	appendChunk := func(id string, offset int, chunk string) *httptest.ResponseRecorder {
+		return serve("PUT", "/uploads/"+id, chunk, map[string]string{bazaar.UploadOffsetHeader: strconv.Itoa(offset)})
+	}
+
+	BeforeEach(func() {
+		kiboshAPIRequest = nil
+		kiboshAPITestServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			kiboshAPIRequest = r
+		}))
+
+		var err error
+		uploadDir, err = ioutil.TempDir("", "uploads-")
+		Expect(err).To(BeNil())
+
+		savedChart = ""
+		savedProvenance = ""
+		repo = repositoryfakes.FakeRepository{}
+		stagedChange = &repositoryfakes.FakeStagedChange{}
+		repo.StageSaveChartStub = func(path string, provenancePath string) (repository.StagedChange, error) {
+			contents, _ := ioutil.ReadFile(path)
+			savedChart = string(contents)
+			provenance, _ := ioutil.ReadFile(path + bazaar.ProvenanceExtension)
+			savedProvenance = string(provenance)
+			return stagedChange, nil
+		}
+
+		kiboshConfig := &bazaar.KiboshConfig{
+			Server: kiboshAPITestServer.URL,
+			User:   "bob",
+			Pass:   "monkey123",
+		}
+		uploadConfig := &bazaar.UploadConfig{Dir: uploadDir, MaxSize: 100, Expiry: time.Hour}
+		api = bazaar.NewAPI(&repo, kiboshConfig, bazaar.APIOptions{Uploads: uploadConfig}, logrus.New())
+	})
+
+	AfterEach(func() {
+		kiboshAPITestServer.Close()
+		os.RemoveAll(uploadDir)
+	})
+
+	It("saves a chart uploaded in chunks", func() {
+		upload := start(12)
+		Expect(upload.ID).NotTo(BeEmpty())
+		Expect(upload.Offset).To(BeZero())
+
+		recorder := appendChunk(upload.ID, 0, "hello ")
+		Expect(recorder.Code).To(Equal(200))
+		recorder = appendChunk(upload.ID, 6, "upload")
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(MatchJSON(`{"id":"` + upload.ID + `","filename":"spacebears-0.0.1.tgz","size":12,"offset":12}`))
+
+		recorder = serve("POST", "/uploads/"+upload.ID+"/save", `{"provenance": "signature"}`, nil)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(savedChart).To(Equal("hello upload"))
+		Expect(savedProvenance).To(Equal("signature"))
+		Expect(kiboshAPIRequest.URL.Path).To(Equal("/reload_charts"))
+		Expect(stagedChange.CommitCallCount()).To(Equal(1))
+		Expect(ioutil.ReadDir(filepath.Join(uploadDir, "bazaar-uploads"))).To(BeEmpty())
+		Expect(serve("GET", "/uploads/"+upload.ID, "", nil).Code).To(Equal(404))
+	})
+
+	It("shows how much was received, to resume from", func() {
+		upload := start(12)
+		appendChunk(upload.ID, 0, "hello ")
+
+		recorder := serve("GET", "/uploads/"+upload.ID, "", nil)
+
+		Expect(recorder.Code).To(Equal(200))
+		shown := bazaar.DisplayUpload{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &shown)).To(Succeed())
+		Expect(shown.Offset).To(Equal(int64(6)))
+	})
+
+	It("409s chunks that don't continue the upload", func() {
+		upload := start(12)
+		appendChunk(upload.ID, 0, "hello ")
+
+		recorder := appendChunk(upload.ID, 0, "hello ")
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Header().Get(bazaar.UploadOffsetHeader)).To(Equal("6"))
+	})
+
+	It("400s chunks without an offset", func() {
+		upload := start(12)
+
+		recorder := serve("PUT", "/uploads/"+upload.ID, "hello ", nil)
+
+		Expect(recorder.Code).To(Equal(400))
+	})
+
+	It("discards chunks past the upload's size", func() {
+		upload := start(12)
+		appendChunk(upload.ID, 0, "hello ")
+
+		recorder := appendChunk(upload.ID, 6, "upload and more")
+
+		Expect(recorder.Code).To(Equal(400))
+		shown := bazaar.DisplayUpload{}
+		Expect(json.Unmarshal(serve("GET", "/uploads/"+upload.ID, "", nil).Body.Bytes(), &shown)).To(Succeed())
+		Expect(shown.Offset).To(Equal(int64(6)))
+	})
+
+	It("409s saving an incomplete upload", func() {
+		upload := start(12)
+		appendChunk(upload.ID, 0, "hello ")
+
+		recorder := serve("POST", "/uploads/"+upload.ID+"/save", `{}`, nil)
+
+		Expect(recorder.Code).To(Equal(409))
+		Expect(recorder.Body.String()).To(ContainSubstring("incomplete"))
+		Expect(repo.StageSaveChartCallCount()).To(BeZero())
+	})
+
+	It("413s uploads over the maximum size", func() {
+		recorder := serve("POST", "/uploads", `{"filename": "spacebears-0.0.1.tgz", "size": 101}`, nil)
+
+		Expect(recorder.Code).To(Equal(413))
+	})
+
+	It("400s file names with paths", func() {
+		recorder := serve("POST", "/uploads", `{"filename": "../spacebears-0.0.1.tgz", "size": 10}`, nil)
+
+		Expect(recorder.Code).To(Equal(400))
+	})
+
+	It("400s the file name reserved for upload metadata", func() {
+		recorder := serve("POST", "/uploads", `{"filename": "upload.json", "size": 10}`, nil)
+
+		Expect(recorder.Code).To(Equal(400))
+		Expect(recorder.Body.String()).To(ContainSubstring("reserved"))
+	})
+
+	It("404s unknown uploads", func() {
+		Expect(serve("GET", "/uploads/0123456789abcdef0123456789abcdef", "", nil).Code).To(Equal(404))
+		Expect(serve("GET", "/uploads/..", "", nil).Code).To(Equal(404))
+		Expect(appendChunk("0123456789abcdef0123456789abcdef", 0, "hello").Code).To(Equal(404))
+	})
+
+	It("deletes uploads", func() {
+		upload := start(12)
+
+		recorder := serve("DELETE", "/uploads/"+upload.ID, "", nil)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(serve("GET", "/uploads/"+upload.ID, "", nil).Code).To(Equal(404))
+	})
+
+	It("removes expired uploads when starting another", func() {
+		upload := start(12)
+		expired := time.Now().Add(-2 * time.Hour)
+		os.Chtimes(filepath.Join(uploadDir, "bazaar-uploads", upload.ID, "spacebears-0.0.1.tgz"), expired, expired)
+
+		start(12)
+
+		Expect(serve("GET", "/uploads/"+upload.ID, "", nil).Code).To(Equal(404))
+	})
+
+	It("requires the publisher role", func() {
+		req, err := http.NewRequest("POST", "/uploads", strings.NewReader(`{"filename": "spacebears-0.0.1.tgz", "size": 10}`))
+		Expect(err).To(BeNil())
+		req = httphelpers.WithRole(req, httphelpers.RoleReader)
+		recorder := httptest.NewRecorder()
+
+		api.Uploads().ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(403))
+	})
+})
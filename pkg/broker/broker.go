@@ -21,10 +21,12 @@ import (
 	"encoding/base32"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/credstore"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
 	my_helm "github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/k8s"
 	"github.com/cf-platform-eng/kibosh/pkg/repository"
@@ -52,25 +54,40 @@ type PksServiceBroker struct {
 	helmClientFactory              my_helm.HelmClientFactory
 	serviceAccountInstallerFactory k8s.ServiceAccountInstallerFactory
 	helmInstallerFactory           my_helm.InstallerFactory
+	imageChecker                   docker.ImageChecker
 
 	logger *logrus.Logger
 }
 
-func NewPksServiceBroker(
-	config *config.Config, clusterFactory k8s.ClusterFactory, helmClientFactory my_helm.HelmClientFactory,
-	serviceAccountInstallerFactory k8s.ServiceAccountInstallerFactory, helmInstallerFactory my_helm.InstallerFactory,
-	repo repository.Repository, cs credstore.CredStore, operators []*my_helm.MyChart, logger *logrus.Logger,
-) *PksServiceBroker {
+// Options are what a broker needs beyond its config and charts. The factories are only needed by
+// the operations that use them.
+type Options struct {
+	ClusterFactory                 k8s.ClusterFactory
+	HelmClientFactory              my_helm.HelmClientFactory
+	ServiceAccountInstallerFactory k8s.ServiceAccountInstallerFactory
+	HelmInstallerFactory           my_helm.InstallerFactory
+	// CredStore keeps bind credentials, which are returned to the platform directly when nil
+	CredStore credstore.CredStore
+	// Operators are installed to the clusters of plans that deploy to their own cluster
+	Operators []*my_helm.MyChart
+	// ImageChecker checks that the plan's images are in the private registry before Provision
+	// installs the chart. The check is disabled when nil.
+	ImageChecker docker.ImageChecker
+}
+
+// NewPksServiceBroker brokers the charts in repo
+func NewPksServiceBroker(config *config.Config, repo repository.Repository, options Options, logger *logrus.Logger) *PksServiceBroker {
 	broker := &PksServiceBroker{
 		config:    config,
 		repo:      repo,
-		credstore: cs,
-		operators: operators,
+		credstore: options.CredStore,
+		operators: options.Operators,
 
-		clusterFactory:                 clusterFactory,
-		helmClientFactory:              helmClientFactory,
-		serviceAccountInstallerFactory: serviceAccountInstallerFactory,
-		helmInstallerFactory:           helmInstallerFactory,
+		clusterFactory:                 options.ClusterFactory,
+		helmClientFactory:              options.HelmClientFactory,
+		serviceAccountInstallerFactory: options.ServiceAccountInstallerFactory,
+		helmInstallerFactory:           options.HelmInstallerFactory,
+		imageChecker:                   options.ImageChecker,
 
 		logger: logger,
 	}
@@ -166,6 +183,11 @@ func (broker *PksServiceBroker) Provision(ctx context.Context, instanceID string
 		return brokerapi.ProvisionedServiceSpec{}, errors.New(fmt.Sprintf("Chart not found for [%s]", details.ServiceID))
 	}
 
+	err = broker.checkImages(chart, planName)
+	if err != nil {
+		return brokerapi.ProvisionedServiceSpec{}, err
+	}
+
 	var installValues []byte
 	if details.GetRawParameters() != nil {
 		installValues, err = yaml.JSONToYAML(details.GetRawParameters())
@@ -223,6 +245,21 @@ func (broker *PksServiceBroker) Provision(ctx context.Context, instanceID string
 	}, nil
 }
 
+func (broker *PksServiceBroker) checkImages(chart *my_helm.MyChart, planName string) error {
+	if broker.imageChecker == nil {
+		return nil
+	}
+	images, err := chart.ImageReferences(planName)
+	if err != nil {
+		return err
+	}
+	err = broker.imageChecker.CheckImages(images)
+	if missing, ok := err.(*docker.MissingImagesError); ok {
+		return brokerapi.NewFailureResponse(missing, http.StatusUnprocessableEntity, "missing-images")
+	}
+	return err
+}
+
 func (broker *PksServiceBroker) GetInstance(context context.Context, instanceID string) (brokerapi.GetInstanceDetailsSpec, error) {
 	return brokerapi.GetInstanceDetailsSpec{}, errors.New("this optional operation isn't supported yet")
 }
@@ -285,8 +322,9 @@ func (broker *PksServiceBroker) Bind(ctx context.Context, instanceID, bindingID
 
 	if broker.credstore != nil {
 		credentialName := broker.getCredentialName(broker.getServiceName(chart), bindingID)
+		bindingCredStore := broker.getBindingCredStore(instanceID, bindingID, details.AppGUID)
 
-		_, err := broker.credstore.Put(credentialName, credentials)
+		_, err := bindingCredStore.Put(credentialName, credentials)
 		if err != nil {
 			return brokerapi.Binding{}, err
 		}
@@ -294,7 +332,7 @@ func (broker *PksServiceBroker) Bind(ctx context.Context, instanceID, bindingID
 			"credhub-ref": credentialName,
 		}
 
-		_, err = broker.credstore.AddPermission(credentialName, "mtls-app:"+details.AppGUID, []string{"read"})
+		_, err = bindingCredStore.AddPermission(credentialName, "mtls-app:"+details.AppGUID, []string{"read"})
 		if err != nil {
 			return brokerapi.Binding{}, err
 		}
@@ -377,13 +415,14 @@ func (broker *PksServiceBroker) Unbind(ctx context.Context, instanceID, bindingI
 
 	if broker.credstore != nil {
 		credentialName := broker.getCredentialName(broker.getServiceName(chart), bindingID)
+		bindingCredStore := broker.getBindingCredStore(instanceID, bindingID, "")
 
-		err = broker.credstore.DeletePermission(credentialName)
+		err = bindingCredStore.DeletePermission(credentialName)
 		if err != nil {
 			broker.logger.Error(fmt.Sprintf("fail to delete permissions on the key %s", credentialName), err)
 		}
 
-		err := broker.credstore.Delete(credentialName)
+		err := bindingCredStore.Delete(credentialName)
 		if err != nil {
 			return brokerapi.UnbindSpec{}, err
 		}
@@ -559,6 +598,14 @@ func (broker *PksServiceBroker) getCredentialName(serviceName, bindingID string)
 	return fmt.Sprintf("/c/%s/%s/%s/secrets-and-services", credhubClientIdentifier, serviceName, bindingID)
 }
 
+func (broker *PksServiceBroker) getBindingCredStore(instanceID, bindingID, appGUID string) credstore.CredStore {
+	scoper, ok := broker.credstore.(credstore.BindingScoper)
+	if ok {
+		return scoper.ForBinding(instanceID, bindingID, appGUID)
+	}
+	return broker.credstore
+}
+
 func (broker *PksServiceBroker) getRenderedTemplate(bindTemplate string, servicesAndSecrets map[string]interface{}) (string, error) {
 	ssTemplateBytes, err := json.Marshal(servicesAndSecrets)
 	if err != nil {
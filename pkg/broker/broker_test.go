@@ -18,11 +18,15 @@ package broker_test
 import (
 	"encoding/json"
 	"errors"
+	"net/http"
 	"strings"
 
 	. "github.com/cf-platform-eng/kibosh/pkg/broker"
 	my_config "github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/credstore"
 	"github.com/cf-platform-eng/kibosh/pkg/credstore/credstorefakes"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/cf-platform-eng/kibosh/pkg/docker/dockerfakes"
 	my_helm "github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/helm/helmfakes"
 	"github.com/cf-platform-eng/kibosh/pkg/k8s"
@@ -151,7 +155,7 @@ var _ = Describe("Broker", func() {
 
 	Context("catalog", func() {
 		It("Provides a catalog with correct service", func() {
-			serviceBroker := NewPksServiceBroker(config, nil, nil, nil, nil, fakeRepo, nil, nil, logger)
+			serviceBroker := NewPksServiceBroker(config, fakeRepo, Options{}, logger)
 			serviceCatalog, err := serviceBroker.Services(nil)
 			Expect(err).To(BeNil())
 
@@ -179,7 +183,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("Provides a catalog with correct plans", func() {
-			serviceBroker := NewPksServiceBroker(config, nil, nil, nil, nil, fakeRepo, nil, nil, logger)
+			serviceBroker := NewPksServiceBroker(config, fakeRepo, Options{}, logger)
 			serviceCatalog, err := serviceBroker.Services(nil)
 			Expect(err).To(BeNil())
 
@@ -225,7 +229,7 @@ var _ = Describe("Broker", func() {
 		It("Returns error when problem with catalog", func() {
 			fakeRepo.GetChartsReturns(nil, errors.New("issue with catalog"))
 
-			serviceBroker := NewPksServiceBroker(config, nil, nil, nil, nil, fakeRepo, nil, nil, logger)
+			serviceBroker := NewPksServiceBroker(config, fakeRepo, Options{}, logger)
 			_, err := serviceBroker.Services(nil)
 			Expect(err).NotTo(BeNil())
 			Expect(err.Error()).To(ContainSubstring("issue with catalog"))
@@ -241,7 +245,7 @@ var _ = Describe("Broker", func() {
 				ServiceID: spacebearsServiceGUID,
 			}
 
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 			Expect(fakeClusterFactory.DefaultClusterCallCount()).To(Equal(0))
 			Expect(fakeClusterFactory.GetClusterCallCount()).To(Equal(0))
 
@@ -304,7 +308,7 @@ var _ = Describe("Broker", func() {
 					PlanID:    spacebearsServiceGUID + "-small",
 				}
 
-				broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+				broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 			})
 
 			It("uses cluster configured in plan to build helm client", func() {
@@ -334,7 +338,7 @@ var _ = Describe("Broker", func() {
 		Context("registry secrets", func() {
 			It("doesn't mess with secrets when not configured", func() {
 				config = &my_config.Config{RegistryConfig: &my_config.RegistryConfig{}, HelmTLSConfig: &my_config.HelmTLSConfig{}}
-				broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+				broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 				_, err := broker.Provision(nil, "my-instance-guid", details, true)
 
@@ -408,13 +412,64 @@ var _ = Describe("Broker", func() {
 				Expect(strings.TrimSpace(string(opts))).To(Equal("foo: bar"))
 			})
 		})
+		Context("image check", func() {
+			var fakeImageChecker *dockerfakes.FakeImageChecker
+
+			BeforeEach(func() {
+				spacebearsChart.TransformedValues = []byte("image: 127.0.0.1/spacebears\nimageTag: latest\n")
+				plan := spacebearsChart.Plans["small"]
+				plan.Values = []byte("imageTag: 1.1.0\n")
+				spacebearsChart.Plans["small"] = plan
+
+				details = brokerapi.ProvisionDetails{
+					ServiceID: spacebearsServiceGUID,
+					PlanID:    spacebearsServiceGUID + "-small",
+				}
+
+				fakeImageChecker = &dockerfakes.FakeImageChecker{}
+				broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory, ImageChecker: fakeImageChecker}, logger)
+			})
+
+			It("checks the plan's images", func() {
+				_, err := broker.Provision(nil, "my-instance-guid", details, true)
+
+				Expect(err).To(BeNil())
+				Expect(fakeImageChecker.CheckImagesCallCount()).To(Equal(1))
+				Expect(fakeImageChecker.CheckImagesArgsForCall(0)).To(Equal([]string{"127.0.0.1/spacebears:1.1.0"}))
+				Expect(fakeHelmClient.InstallChartCallCount()).To(Equal(1))
+			})
+
+			It("doesn't install when images are missing", func() {
+				fakeImageChecker.CheckImagesReturns(&docker.MissingImagesError{Images: []string{"127.0.0.1/spacebears:1.1.0"}})
+
+				_, err := broker.Provision(nil, "my-instance-guid", details, true)
+
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("127.0.0.1/spacebears:1.1.0"))
+				failure, ok := err.(*brokerapi.FailureResponse)
+				Expect(ok).To(BeTrue())
+				Expect(failure.ValidatedStatusCode(nil)).To(Equal(http.StatusUnprocessableEntity))
+				Expect(fakeHelmClient.InstallChartCallCount()).To(Equal(0))
+				Expect(fakeClusterFactory.GetClusterFromK8sConfigCallCount() + fakeClusterFactory.DefaultClusterCallCount()).To(Equal(0))
+			})
+
+			It("returns error when the registry can't be checked", func() {
+				fakeImageChecker.CheckImagesReturns(errors.New("registry down"))
+
+				_, err := broker.Provision(nil, "my-instance-guid", details, true)
+
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("registry down"))
+				Expect(fakeHelmClient.InstallChartCallCount()).To(Equal(0))
+			})
+		})
 	})
 
 	Context("last operation", func() {
 		var broker *PksServiceBroker
 
 		BeforeEach(func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 			serviceList := api_v1.ServiceList{
 				Items: []api_v1.Service{
@@ -623,7 +678,7 @@ var _ = Describe("Broker", func() {
 
 			fakeClusterFactory.GetClusterFromK8sConfigReturns(&fakeCluster, nil)
 
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 			details := brokerapi.PollDetails{
 				OperationData: "provision",
@@ -646,7 +701,7 @@ var _ = Describe("Broker", func() {
 		var broker *PksServiceBroker
 
 		BeforeEach(func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 		})
 
 		It("bind returns cluster secrets", func() {
@@ -680,7 +735,7 @@ var _ = Describe("Broker", func() {
 
 			fakeClusterFactory.GetClusterFromK8sConfigReturns(&fakeCluster, nil)
 
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 			binding, err := broker.Bind(nil, "my-instance-id", "my-binding-id", brokerapi.BindDetails{
 				ServiceID: spacebearsServiceGUID,
@@ -776,7 +831,7 @@ var _ = Describe("Broker", func() {
 
 		Context("credstore", func() {
 			BeforeEach(func() {
-				broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, fakeCredStore, nil, logger)
+				broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory, CredStore: fakeCredStore}, logger)
 			})
 
 			It("bind returns reference to k8s secrets and services", func() {
@@ -837,6 +892,33 @@ var _ = Describe("Broker", func() {
 				Expect(err).NotTo(BeNil())
 				Expect(err.Error()).To(ContainSubstring("fail"))
 			})
+
+			It("attributes audited operations to the binding", func() {
+				fakeCluster.GetSecretsAndServicesReturns(map[string][]map[string]interface{}{
+					"secrets":  {{"password": "foo"}},
+					"services": {{"myservice": "service-stuff"}},
+				}, nil)
+				fakeAuditSink := &credstorefakes.FakeAuditSink{}
+				auditingCredStore := credstore.NewAuditingCredStore(fakeCredStore, fakeAuditSink, logger)
+				broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory, CredStore: auditingCredStore}, logger)
+
+				_, err := broker.Bind(nil, "my-instance-id", "my-binding-id", brokerapi.BindDetails{
+					ServiceID: mysqlServiceID,
+					AppGUID:   "my-app-id",
+				}, false)
+				Expect(err).To(BeNil())
+
+				Expect(fakeAuditSink.RecordCallCount()).To(Equal(2))
+				put := fakeAuditSink.RecordArgsForCall(0)
+				Expect(put.Operation).To(Equal("put"))
+				Expect(put.InstanceID).To(Equal("my-instance-id"))
+				Expect(put.BindingID).To(Equal("my-binding-id"))
+				Expect(put.AppGUID).To(Equal("my-app-id"))
+
+				permission := fakeAuditSink.RecordArgsForCall(1)
+				Expect(permission.Operation).To(Equal("add-permission"))
+				Expect(permission.Actor).To(Equal("mtls-app:my-app-id"))
+			})
 		})
 
 		Describe("uses proper cluster", func() {
@@ -888,7 +970,7 @@ var _ = Describe("Broker", func() {
 		var broker *PksServiceBroker
 
 		BeforeEach(func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 		})
 
 		It("correctly calls deletion", func() {
@@ -945,7 +1027,7 @@ var _ = Describe("Broker", func() {
 
 			fakeClusterFactory.GetClusterFromK8sConfigReturns(&fakeCluster, nil)
 
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 			_, err := broker.Deprovision(nil, "my-instance-guid", details, true)
 
@@ -975,7 +1057,7 @@ var _ = Describe("Broker", func() {
 		var broker *PksServiceBroker
 
 		BeforeEach(func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 		})
 
 		It("requires async", func() {
@@ -1032,7 +1114,7 @@ var _ = Describe("Broker", func() {
 
 			fakeClusterFactory.GetClusterFromK8sConfigReturns(&fakeCluster, nil)
 
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory}, logger)
 
 			_, err := broker.Update(nil, "my-instance-guid", details, true)
 
@@ -1049,7 +1131,7 @@ var _ = Describe("Broker", func() {
 		var broker *PksServiceBroker
 
 		It("happy path without credhub", func() {
-			broker = NewPksServiceBroker(config, nil, nil, nil, fakeInstallerFactory, fakeRepo, nil, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{HelmInstallerFactory: fakeInstallerFactory}, logger)
 			response, err := broker.Unbind(nil, "my-instance-id", "my-binding-id", brokerapi.UnbindDetails{
 				ServiceID: mysqlServiceID,
 			}, false)
@@ -1059,7 +1141,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("cleanups credhub", func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, fakeCredStore, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory, CredStore: fakeCredStore}, logger)
 
 			_, err := broker.Unbind(nil, "my-instance-id", "my-binding-id", brokerapi.UnbindDetails{
 				ServiceID: mysqlServiceID,
@@ -1077,7 +1159,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("surfaces error failing to cleanup", func() {
-			broker = NewPksServiceBroker(config, &fakeClusterFactory, &fakeHelmClientFactory, &fakeServiceAccountInstallerFactory, fakeInstallerFactory, fakeRepo, fakeCredStore, nil, logger)
+			broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory, ServiceAccountInstallerFactory: &fakeServiceAccountInstallerFactory, HelmInstallerFactory: fakeInstallerFactory, CredStore: fakeCredStore}, logger)
 
 			fakeCredStore.DeleteReturns(errors.New("the tubes are down"))
 
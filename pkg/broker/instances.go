@@ -0,0 +1,203 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"sort"
+	"strings"
+
+	my_helm "github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/k8s"
+	"github.com/pkg/errors"
+	api_v1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+)
+
+var ErrInstanceNotFound = errors.New("Service instance not found")
+
+// Instance is a provisioned service instance, described by the labels Provision puts on its
+// namespace and the status of its release
+type Instance struct {
+	InstanceID       string `json:"instanceID"`
+	Chart            string `json:"chart"`
+	Plan             string `json:"plan"`
+	ServiceID        string `json:"serviceID"`
+	PlanID           string `json:"planID"`
+	OrganizationGUID string `json:"organizationGUID"`
+	SpaceGUID        string `json:"spaceGUID"`
+	Namespace        string `json:"namespace"`
+	Release          string `json:"release"`
+	ReleaseStatus    string `json:"releaseStatus"`
+	Ready            bool   `json:"ready"`
+	ReadinessMessage string `json:"readinessMessage,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
+// ListInstances finds the instances in the default cluster and every plan specific cluster,
+// only those of chartName when it's set. Instances whose status can't be read are listed with
+// the error.
+func (broker *PksServiceBroker) ListInstances(chartName string) ([]*Instance, error) {
+	charts, err := broker.GetChartsMap()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := broker.instanceClusters(charts)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := []*Instance{}
+	for _, cluster := range clusters {
+		namespaces, err := cluster.GetNamespaces()
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to list namespaces")
+		}
+		for _, namespace := range namespaces.Items {
+			instance := broker.namespaceInstance(namespace, charts)
+			if instance == nil || (chartName != "" && instance.Chart != chartName) {
+				continue
+			}
+			broker.addInstanceStatus(instance, cluster)
+			instances = append(instances, instance)
+		}
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		if instances[i].Chart != instances[j].Chart {
+			return instances[i].Chart < instances[j].Chart
+		}
+		return instances[i].InstanceID < instances[j].InstanceID
+	})
+	return instances, nil
+}
+
+func (broker *PksServiceBroker) GetInstanceStatus(instanceID string) (*Instance, error) {
+	charts, err := broker.GetChartsMap()
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := broker.instanceClusters(charts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cluster := range clusters {
+		namespace, err := cluster.GetNamespace(broker.getNamespace(instanceID), &meta_v1.GetOptions{})
+		if k8s_errors.IsNotFound(err) || (err == nil && namespace == nil) {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to get namespace")
+		}
+		instance := broker.namespaceInstance(*namespace, charts)
+		if instance == nil {
+			continue
+		}
+		broker.addInstanceStatus(instance, cluster)
+		return instance, nil
+	}
+	return nil, ErrInstanceNotFound
+}
+
+// instanceClusters are the default cluster and the clusters of plans that have their own
+func (broker *PksServiceBroker) instanceClusters(charts map[string]*my_helm.MyChart) ([]k8s.Cluster, error) {
+	defaultCluster, err := broker.clusterFactory.DefaultCluster()
+	if err != nil {
+		return nil, err
+	}
+	clusters := []k8s.Cluster{defaultCluster}
+
+	for _, chart := range charts {
+		for _, plan := range chart.Plans {
+			if plan.ClusterConfig == nil {
+				continue
+			}
+			cluster, err := broker.clusterFactory.GetClusterFromK8sConfig(plan.ClusterConfig)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Unable to connect to the cluster of plan [%s/%s]", chart.Metadata.Name, plan.Name)
+			}
+			if !containsCluster(clusters, cluster) {
+				clusters = append(clusters, cluster)
+			}
+		}
+	}
+	return clusters, nil
+}
+
+func containsCluster(clusters []k8s.Cluster, cluster k8s.Cluster) bool {
+	for _, existing := range clusters {
+		if existing == cluster {
+			return true
+		}
+		existingConfig, config := existing.GetClientConfig(), cluster.GetClientConfig()
+		if existingConfig != nil && config != nil && existingConfig.Host == config.Host {
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceInstance is nil for namespaces Kibosh didn't provision
+func (broker *PksServiceBroker) namespaceInstance(namespace api_v1.Namespace, charts map[string]*my_helm.MyChart) *Instance {
+	labels := namespace.Labels
+	if !strings.HasPrefix(namespace.Name, "kibosh-") || labels["serviceID"] == "" {
+		return nil
+	}
+
+	instanceID := labels["instanceID"]
+	if instanceID == "" {
+		instanceID = strings.TrimPrefix(namespace.Name, "kibosh-")
+	}
+	instance := &Instance{
+		InstanceID:       instanceID,
+		ServiceID:        labels["serviceID"],
+		PlanID:           labels["planID"],
+		Plan:             strings.TrimPrefix(labels["planID"], labels["serviceID"]+"-"),
+		OrganizationGUID: labels["organizationGUID"],
+		SpaceGUID:        labels["spaceGUID"],
+		Namespace:        namespace.Name,
+		Release:          broker.getReleaseName(instanceID),
+	}
+	if chart, ok := charts[instance.ServiceID]; ok {
+		instance.Chart = broker.getServiceName(chart)
+	}
+	return instance
+}
+
+func (broker *PksServiceBroker) addInstanceStatus(instance *Instance, cluster k8s.Cluster) {
+	helmClient := broker.helmClientFactory.HelmClient(cluster)
+
+	status, err := helmClient.ReleaseStatus(instance.Release)
+	if err != nil {
+		instance.Error = err.Error()
+		return
+	}
+	if status.Info != nil && status.Info.Status != nil {
+		instance.ReleaseStatus = status.Info.Status.Code.String()
+	}
+
+	message, code, err := helmClient.ResourceReadiness(instance.Namespace, cluster)
+	if err != nil {
+		instance.Error = err.Error()
+		return
+	}
+	instance.Ready = code == hapi_release.Status_DEPLOYED
+	if message != nil {
+		instance.ReadinessMessage = *message
+	}
+}
@@ -0,0 +1,83 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+type InstancesAPI interface {
+	Instances() http.Handler
+}
+
+type instancesAPI struct {
+	broker *PksServiceBroker
+	logger *logrus.Logger
+}
+
+func NewInstancesAPI(broker *PksServiceBroker, logger *logrus.Logger) InstancesAPI {
+	return &instancesAPI{
+		broker: broker,
+		logger: logger,
+	}
+}
+
+// Instances lists instances on /instances, filtered by ?chart=, and shows one on /instances/<id>
+func (api *instancesAPI) Instances() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			w.Header().Set("Allow", "GET")
+			w.WriteHeader(405)
+			return
+		}
+
+		var result interface{}
+		var err error
+		instanceID := strings.Trim(strings.TrimPrefix(r.URL.Path, "/instances"), "/")
+		if strings.Contains(instanceID, "/") {
+			err = ErrInstanceNotFound
+		} else if instanceID == "" {
+			result, err = api.broker.ListInstances(r.URL.Query().Get("chart"))
+		} else {
+			result, err = api.broker.GetInstanceStatus(instanceID)
+		}
+		if err == ErrInstanceNotFound {
+			w.WriteHeader(404)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if err != nil {
+			api.logger.WithError(err).Error("Unable to load instances")
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		serialized, err := json.Marshal(result)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(serialized)
+	})
+}
@@ -0,0 +1,275 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/cf-platform-eng/kibosh/pkg/broker"
+	my_config "github.com/cf-platform-eng/kibosh/pkg/config"
+	my_helm "github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/helm/helmfakes"
+	"github.com/cf-platform-eng/kibosh/pkg/k8s/k8sfakes"
+	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pborman/uuid"
+	"github.com/pivotal-cf/brokerapi"
+	"github.com/sirupsen/logrus"
+	api_v1 "k8s.io/api/core/v1"
+	k8s_errors "k8s.io/apimachinery/pkg/api/errors"
+	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8sAPI "k8s.io/client-go/tools/clientcmd/api"
+	hapi_chart "k8s.io/helm/pkg/proto/hapi/chart"
+	hapi_release "k8s.io/helm/pkg/proto/hapi/release"
+	hapi_services "k8s.io/helm/pkg/proto/hapi/services"
+)
+
+var _ = Describe("Instances", func() {
+	mysqlServiceID := uuid.NewSHA1(uuid.NameSpace_OID, []byte("mysql")).String()
+	spacebearsServiceID := uuid.NewSHA1(uuid.NameSpace_OID, []byte("spacebears")).String()
+
+	var fakeHelmClient helmfakes.FakeMyHelmClient
+	var fakeHelmClientFactory helmfakes.FakeHelmClientFactory
+	var fakeCluster k8sfakes.FakeCluster
+	var fakeClusterFactory k8sfakes.FakeClusterFactory
+	var fakeRepo *repositoryfakes.FakeRepository
+	var broker *PksServiceBroker
+
+	instanceNamespace := func(instanceID string, serviceID string, plan string) api_v1.Namespace {
+		return api_v1.Namespace{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: "kibosh-" + instanceID,
+				Labels: map[string]string{
+					"serviceID":                    serviceID,
+					"planID":                       serviceID + "-" + plan,
+					"organizationGUID":             "my-org",
+					"spaceGUID":                    "my-space",
+					"instanceID":                   instanceID,
+					"app.kubernetes.io/managed-by": "kibosh",
+				},
+			},
+		}
+	}
+
+	BeforeEach(func() {
+		charts := []*my_helm.MyChart{
+			{
+				Chart: hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "mysql"}},
+				Plans: map[string]my_helm.Plan{
+					"small": {Name: "small", Free: brokerapi.FreeValue(true), Bindable: brokerapi.BindableValue(true)},
+				},
+			},
+			{
+				Chart: hapi_chart.Chart{Metadata: &hapi_chart.Metadata{Name: "spacebears"}},
+				Plans: map[string]my_helm.Plan{
+					"small": {Name: "small", Free: brokerapi.FreeValue(true), Bindable: brokerapi.BindableValue(true)},
+				},
+			},
+		}
+
+		fakeHelmClient = helmfakes.FakeMyHelmClient{}
+		fakeHelmClientFactory = helmfakes.FakeHelmClientFactory{}
+		fakeHelmClientFactory.HelmClientReturns(&fakeHelmClient)
+		fakeCluster = k8sfakes.FakeCluster{}
+		fakeClusterFactory = k8sfakes.FakeClusterFactory{}
+		fakeClusterFactory.DefaultClusterReturns(&fakeCluster, nil)
+		fakeRepo = &repositoryfakes.FakeRepository{}
+		fakeRepo.GetChartsReturns(charts, nil)
+
+		fakeCluster.GetNamespacesReturns(&api_v1.NamespaceList{
+			Items: []api_v1.Namespace{
+				{ObjectMeta: meta_v1.ObjectMeta{Name: "kube-system"}},
+				instanceNamespace("spacebears-1", spacebearsServiceID, "small"),
+				instanceNamespace("mysql-1", mysqlServiceID, "small"),
+				{ObjectMeta: meta_v1.ObjectMeta{Name: "kibosh-operators"}},
+			},
+		}, nil)
+		fakeHelmClient.ReleaseStatusReturns(&hapi_services.GetReleaseStatusResponse{
+			Info: &hapi_release.Info{
+				Status: &hapi_release.Status{Code: hapi_release.Status_DEPLOYED},
+			},
+		}, nil)
+		fakeHelmClient.ResourceReadinessReturns(nil, hapi_release.Status_DEPLOYED, nil)
+
+		config := &my_config.Config{RegistryConfig: &my_config.RegistryConfig{}, HelmTLSConfig: &my_config.HelmTLSConfig{}}
+		broker = NewPksServiceBroker(config, fakeRepo, Options{ClusterFactory: &fakeClusterFactory, HelmClientFactory: &fakeHelmClientFactory}, logrus.New())
+	})
+
+	Context("list", func() {
+		It("lists provisioned namespaces with their status", func() {
+			instances, err := broker.ListInstances("")
+
+			Expect(err).To(BeNil())
+			Expect(instances).To(HaveLen(2))
+			Expect(*instances[0]).To(Equal(Instance{
+				InstanceID:       "mysql-1",
+				Chart:            "mysql",
+				Plan:             "small",
+				ServiceID:        mysqlServiceID,
+				PlanID:           mysqlServiceID + "-small",
+				OrganizationGUID: "my-org",
+				SpaceGUID:        "my-space",
+				Namespace:        "kibosh-mysql-1",
+				Release:          instances[0].Release,
+				ReleaseStatus:    "DEPLOYED",
+				Ready:            true,
+			}))
+			Expect(instances[1].Chart).To(Equal("spacebears"))
+
+			Expect(fakeHelmClient.ReleaseStatusCallCount()).To(Equal(2))
+			namespace, _ := fakeHelmClient.ResourceReadinessArgsForCall(0)
+			Expect(namespace).To(Equal("kibosh-spacebears-1"))
+		})
+
+		It("filters by chart", func() {
+			instances, err := broker.ListInstances("spacebears")
+
+			Expect(err).To(BeNil())
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].InstanceID).To(Equal("spacebears-1"))
+		})
+
+		It("reports the readiness message", func() {
+			message := "Deployment is not ready: kibosh-mysql-1/mysql"
+			fakeHelmClient.ResourceReadinessReturns(&message, hapi_release.Status_PENDING_INSTALL, nil)
+
+			instances, err := broker.ListInstances("mysql")
+
+			Expect(err).To(BeNil())
+			Expect(instances[0].Ready).To(BeFalse())
+			Expect(instances[0].ReadinessMessage).To(Equal(message))
+		})
+
+		It("lists instances whose status fails with the error", func() {
+			fakeHelmClient.ReleaseStatusReturns(nil, errors.New("release not found"))
+
+			instances, err := broker.ListInstances("")
+
+			Expect(err).To(BeNil())
+			Expect(instances).To(HaveLen(2))
+			Expect(instances[0].Error).To(Equal("release not found"))
+		})
+
+		It("also lists instances in plan specific clusters", func() {
+			planCluster := &k8sfakes.FakeCluster{}
+			planCluster.GetNamespacesReturns(&api_v1.NamespaceList{
+				Items: []api_v1.Namespace{instanceNamespace("mysql-2", mysqlServiceID, "large")},
+			}, nil)
+			fakeClusterFactory.GetClusterFromK8sConfigReturns(planCluster, nil)
+			charts, _ := fakeRepo.GetCharts()
+			charts[0].Plans["large"] = my_helm.Plan{Name: "large", ClusterConfig: &k8sAPI.Config{}}
+
+			instances, err := broker.ListInstances("mysql")
+
+			Expect(err).To(BeNil())
+			Expect(instances).To(HaveLen(2))
+			Expect(instances[1].InstanceID).To(Equal("mysql-2"))
+			Expect(instances[1].Plan).To(Equal("large"))
+			Expect(fakeHelmClientFactory.HelmClientArgsForCall(1)).To(Equal(planCluster))
+		})
+
+		It("returns cluster errors", func() {
+			fakeCluster.GetNamespacesReturns(nil, errors.New("no route to cluster"))
+
+			_, err := broker.ListInstances("")
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("no route to cluster"))
+		})
+	})
+
+	Context("show", func() {
+		It("shows an instance", func() {
+			namespace := instanceNamespace("mysql-1", mysqlServiceID, "small")
+			fakeCluster.GetNamespaceReturns(&namespace, nil)
+
+			instance, err := broker.GetInstanceStatus("mysql-1")
+
+			Expect(err).To(BeNil())
+			Expect(instance.Chart).To(Equal("mysql"))
+			Expect(instance.ReleaseStatus).To(Equal("DEPLOYED"))
+			name, _ := fakeCluster.GetNamespaceArgsForCall(0)
+			Expect(name).To(Equal("kibosh-mysql-1"))
+		})
+
+		It("errors on unknown instances", func() {
+			fakeCluster.GetNamespaceReturns(nil, k8s_errors.NewNotFound(schema.GroupResource{Resource: "namespaces"}, "kibosh-nope"))
+
+			_, err := broker.GetInstanceStatus("nope")
+
+			Expect(err).To(Equal(ErrInstanceNotFound))
+		})
+	})
+
+	Context("api", func() {
+		var api InstancesAPI
+
+		BeforeEach(func() {
+			api = NewInstancesAPI(broker, logrus.New())
+		})
+
+		It("lists instances of a chart", func() {
+			req := httptest.NewRequest("GET", "/instances?chart=mysql", nil)
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			var instances []Instance
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &instances)).To(Succeed())
+			Expect(instances).To(HaveLen(1))
+			Expect(instances[0].InstanceID).To(Equal("mysql-1"))
+		})
+
+		It("shows an instance", func() {
+			namespace := instanceNamespace("mysql-1", mysqlServiceID, "small")
+			fakeCluster.GetNamespaceReturns(&namespace, nil)
+			req := httptest.NewRequest("GET", "/instances/mysql-1", nil)
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(200))
+			var instance Instance
+			Expect(json.Unmarshal(recorder.Body.Bytes(), &instance)).To(Succeed())
+			Expect(instance.InstanceID).To(Equal("mysql-1"))
+		})
+
+		It("404s on unknown instances", func() {
+			req := httptest.NewRequest("GET", "/instances/nope", nil)
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(404))
+		})
+
+		It("only allows GET", func() {
+			req := httptest.NewRequest("DELETE", "/instances/mysql-1", nil)
+			recorder := httptest.NewRecorder()
+
+			api.Instances().ServeHTTP(recorder, req)
+
+			Expect(recorder.Code).To(Equal(405))
+			Expect(recorder.Header().Get("Allow")).To(Equal(http.MethodGet))
+		})
+	})
+})
@@ -21,10 +21,10 @@ import (
 	"github.com/cf-platform-eng/kibosh/pkg/moreio"
 
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 type ClusterCredentials struct {
@@ -41,6 +41,14 @@ type RegistryConfig struct {
 	User   string `envconfig:"REG_USER"`
 	Pass   string `envconfig:"REG_PASS"`
 	Email  string `envconfig:"REG_EMAIL"`
+
+	// PlainHTTP and CACertFile are for talking to the registry directly, to push or check images
+	PlainHTTP      bool   `envconfig:"REG_PLAIN_HTTP"`
+	CACertFile     string `envconfig:"REG_CA_CERT_FILE"`
+	SkipImageCheck bool   `envconfig:"REG_SKIP_IMAGE_CHECK"`
+
+	// Registries are private registries for the images matching their prefixes, all others go to Server
+	Registries Registries `envconfig:"REG_REGISTRIES"`
 }
 
 type CFClientConfig struct {
@@ -67,23 +75,62 @@ type CredStoreConfig struct {
 	UaaClientSecret   string `envconfig:"CH_UAA_CLIENT_SECRET"`
 	SkipSSLValidation bool   `envconfig:"CH_SKIP_SSL_VALIDATION"`
 	CaCertFile        string `envconfig:"CH_CA_CERT_FILE"`
+
+	AuditSink       string `envconfig:"CH_AUDIT_SINK"`
+	AuditFile       string `envconfig:"CH_AUDIT_FILE"`
+	AuditWebhookURL string `envconfig:"CH_AUDIT_WEBHOOK_URL"`
+}
+
+type HelmRepoConfig struct {
+	URL               string `envconfig:"HELM_REPO_URL"`
+	Username          string `envconfig:"HELM_REPO_USERNAME"`
+	Password          string `envconfig:"HELM_REPO_PASSWORD"`
+	CACertFile        string `envconfig:"HELM_REPO_CA_CERT_FILE"`
+	SkipSSLValidation bool   `envconfig:"HELM_REPO_SKIP_SSL_VALIDATION"`
+	PinFile           string `envconfig:"HELM_REPO_PIN_FILE"`
+}
+
+type OCIChartConfig struct {
+	Charts    []string `envconfig:"OCI_CHARTS"`
+	PlainHTTP bool     `envconfig:"OCI_CHARTS_PLAIN_HTTP"`
+}
+
+type GitRepoConfig struct {
+	URL          string        `envconfig:"GIT_REPO_URL"`
+	Branch       string        `envconfig:"GIT_REPO_BRANCH" default:"master"`
+	ChartPath    string        `envconfig:"GIT_REPO_CHART_PATH"`
+	SyncInterval time.Duration `envconfig:"GIT_REPO_SYNC_INTERVAL"`
+}
+
+// ServerTLSConfig serves https when a cert and key are set, and verifies client certificates
+// against ClientCAFile when it's set
+type ServerTLSConfig struct {
+	CertFile     string `envconfig:"TLS_CERT_FILE"`
+	KeyFile      string `envconfig:"TLS_KEY_FILE"`
+	ClientCAFile string `envconfig:"TLS_CLIENT_CA_FILE"`
 }
 
 type Config struct {
 	AdminUsername string `envconfig:"SECURITY_USER_NAME" required:"true"`
 	AdminPassword string `envconfig:"SECURITY_USER_PASSWORD" required:"true"`
 
-	Port            int    `envconfig:"PORT" default:"8080"`
-	HelmChartDir    string `envconfig:"HELM_CHART_DIR" default:"charts"`
-	OperatorDir     string `envconfig:"OPERATOR_DIR" default:"operators"`
-	TillerNamespace string `envconfig:"TILLER_NAMESPACE" default:"kube-system"`
-	TillerSHA       string `envconfig:"TILLER_IMAGE_SHA"`
+	Port            int           `envconfig:"PORT" default:"8080"`
+	HelmChartDir    string        `envconfig:"HELM_CHART_DIR" default:"charts"`
+	WatchChartDir   bool          `envconfig:"HELM_CHART_DIR_WATCH"`
+	WatchDebounce   time.Duration `envconfig:"HELM_CHART_DIR_WATCH_DEBOUNCE" default:"2s"`
+	OperatorDir     string        `envconfig:"OPERATOR_DIR" default:"operators"`
+	TillerNamespace string        `envconfig:"TILLER_NAMESPACE" default:"kube-system"`
+	TillerSHA       string        `envconfig:"TILLER_IMAGE_SHA"`
 
 	ClusterCredentials *ClusterCredentials
 	RegistryConfig     *RegistryConfig
 	CFClientConfig     *CFClientConfig
 	HelmTLSConfig      *HelmTLSConfig
 	CredStoreConfig    *CredStoreConfig
+	HelmRepoConfig     *HelmRepoConfig
+	OCIChartConfig     *OCIChartConfig
+	GitRepoConfig      *GitRepoConfig
+	ServerTLSConfig    *ServerTLSConfig
 }
 
 func (r RegistryConfig) HasRegistryConfig() bool {
@@ -102,26 +149,42 @@ func (c *CredStoreConfig) HasCredHubConfig() bool {
 	return c.CredHubURL != ""
 }
 
+func (c *CredStoreConfig) HasAuditConfig() bool {
+	return c.AuditSink != ""
+}
+
+func (h *HelmRepoConfig) HasHelmRepoConfig() bool {
+	return h.URL != ""
+}
+
+func (o *OCIChartConfig) HasOCIChartConfig() bool {
+	return len(o.Charts) > 0
+}
+
+func (g *GitRepoConfig) HasGitRepoConfig() bool {
+	return g.URL != ""
+}
+
+func (s *ServerTLSConfig) HasServerTLS() bool {
+	return s.CertFile != ""
+}
+
+func (s *ServerTLSConfig) Validate() error {
+	if (s.CertFile == "") != (s.KeyFile == "") {
+		return errors.New("serving tls requires both a cert and key (TLS_CERT_FILE, TLS_KEY_FILE)")
+	}
+	if s.ClientCAFile != "" && s.CertFile == "" {
+		return errors.New("verifying client certificates (TLS_CLIENT_CA_FILE) requires serving tls")
+	}
+	return nil
+}
+
 func (r RegistryConfig) GetDockerConfigJson() ([]byte, error) {
 	if r.Server == "" || r.Email == "" || r.Pass == "" || r.User == "" {
 		return nil, errors.New("environment didn't have a proper registry Config")
 	}
 
-	dockerConfig := map[string]interface{}{
-		"auths": map[string]interface{}{
-			r.Server: map[string]interface{}{
-				"username": r.User,
-				"password": r.Pass,
-				"email":    r.Email,
-			},
-		},
-	}
-	dockerConfigJson, err := json.Marshal(dockerConfig)
-	if err != nil {
-		return nil, err
-	}
-
-	return dockerConfigJson, nil
+	return Registry{Server: r.Server, User: r.User, Pass: r.Pass, Email: r.Email}.GetDockerConfigJson()
 }
 
 func EmptyConfig() *Config {
@@ -131,6 +194,10 @@ func EmptyConfig() *Config {
 		CFClientConfig:     &CFClientConfig{},
 		HelmTLSConfig:      &HelmTLSConfig{},
 		CredStoreConfig:    &CredStoreConfig{},
+		HelmRepoConfig:     &HelmRepoConfig{},
+		OCIChartConfig:     &OCIChartConfig{},
+		GitRepoConfig:      &GitRepoConfig{},
+		ServerTLSConfig:    &ServerTLSConfig{},
 	}
 }
 
@@ -153,6 +220,32 @@ func Parse() (*Config, error) {
 		}
 	}
 
+	if c.HelmRepoConfig.HasHelmRepoConfig() && c.HelmRepoConfig.PinFile == "" {
+		return nil, errors.New("a helm repository requires a pin file (HELM_REPO_PIN_FILE)")
+	}
+	if c.OCIChartConfig.HasOCIChartConfig() {
+		if !c.RegistryConfig.HasRegistryConfig() {
+			return nil, errors.New("oci charts require a registry (REG_SERVER)")
+		}
+		if c.HelmRepoConfig.HasHelmRepoConfig() {
+			return nil, errors.New("charts can be served from either a helm repository or oci charts, not both")
+		}
+	}
+	err = c.ServerTLSConfig.Validate()
+	if err != nil {
+		return nil, err
+	}
+	err = c.RegistryConfig.Validate()
+	if err != nil {
+		return nil, err
+	}
+	if c.GitRepoConfig.HasGitRepoConfig() && (c.HelmRepoConfig.HasHelmRepoConfig() || c.OCIChartConfig.HasOCIChartConfig()) {
+		return nil, errors.New("charts can be served from only one of a git repository, helm repository or oci charts")
+	}
+	if c.WatchChartDir && (c.GitRepoConfig.HasGitRepoConfig() || c.HelmRepoConfig.HasHelmRepoConfig() || c.OCIChartConfig.HasOCIChartConfig()) {
+		return nil, errors.New("watching the chart directory (HELM_CHART_DIR_WATCH) can't be combined with a git repository, helm repository or oci charts, which keep it in sync themselves")
+	}
+
 	c.cleanupConfig()
 
 	return c, nil
@@ -200,4 +293,7 @@ func (t *HelmTLSConfig) validateHelmConfig() error {
 func (c Config) cleanupConfig() {
 	c.RegistryConfig.Server = strings.TrimPrefix(c.RegistryConfig.Server, "https://")
 	c.RegistryConfig.Server = strings.TrimPrefix(c.RegistryConfig.Server, "http://")
+	for i, registry := range c.RegistryConfig.Registries {
+		c.RegistryConfig.Registries[i].Server = strings.TrimPrefix(strings.TrimPrefix(registry.Server, "https://"), "http://")
+	}
 }
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"path/filepath"
+	"time"
 
 	. "github.com/cf-platform-eng/kibosh/pkg/config"
 )
@@ -169,6 +170,45 @@ my cert data
 					},
 				}))
 			})
+
+			It("parses additional registries", func() {
+				os.Setenv("REG_REGISTRIES", `[{"server": "https://harbor.example.com/vendor", "user": "robot", "pass": "abc", "match": ["quay.io/vendor"]}]`)
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.RegistryConfig.Registries).To(Equal(Registries{{
+					Server: "harbor.example.com/vendor",
+					User:   "robot",
+					Pass:   "abc",
+					Match:  []string{"quay.io/vendor"},
+				}}))
+			})
+
+			It("errors on additional registries that aren't json", func() {
+				os.Setenv("REG_REGISTRIES", `harbor.example.com`)
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("REG_REGISTRIES"))
+			})
+
+			It("errors on an additional registry without match prefixes", func() {
+				os.Setenv("REG_REGISTRIES", `[{"server": "harbor.example.com"}]`)
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("harbor.example.com"))
+			})
+
+			It("errors on additional registries without a default registry", func() {
+				os.Setenv("REG_SERVER", "")
+				os.Setenv("REG_REGISTRIES", `[{"server": "harbor.example.com", "match": ["quay.io"]}]`)
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("REG_SERVER"))
+			})
 		})
 
 		It("err on missing env values", func() {
@@ -286,6 +326,132 @@ my cert data
 
 				Expect(c.CredStoreConfig.CredHubURL).To(Equal("https://credhub.example.com"))
 				Expect(c.CredStoreConfig.UaaURL).To(Equal("https://uaa.example.com"))
+				Expect(c.CredStoreConfig.HasAuditConfig()).To(BeFalse())
+			})
+
+			It("parses credstore audit config", func() {
+				os.Setenv("CH_AUDIT_SINK", "file")
+				os.Setenv("CH_AUDIT_FILE", "/var/vcap/sys/log/kibosh/audit.log")
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.CredStoreConfig.HasAuditConfig()).To(BeTrue())
+				Expect(c.CredStoreConfig.AuditSink).To(Equal("file"))
+				Expect(c.CredStoreConfig.AuditFile).To(Equal("/var/vcap/sys/log/kibosh/audit.log"))
+			})
+		})
+
+		Context("helm repository config", func() {
+			It("parses helm repository config", func() {
+				os.Setenv("HELM_REPO_URL", "https://charts.example.com")
+				os.Setenv("HELM_REPO_USERNAME", "repo-user")
+				os.Setenv("HELM_REPO_PIN_FILE", "/var/vcap/jobs/kibosh/config/pins.yml")
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.HelmRepoConfig.HasHelmRepoConfig()).To(BeTrue())
+				Expect(c.HelmRepoConfig.URL).To(Equal("https://charts.example.com"))
+				Expect(c.HelmRepoConfig.Username).To(Equal("repo-user"))
+				Expect(c.HelmRepoConfig.PinFile).To(Equal("/var/vcap/jobs/kibosh/config/pins.yml"))
+			})
+
+			It("requires a pin file", func() {
+				os.Setenv("HELM_REPO_URL", "https://charts.example.com")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Context("oci chart config", func() {
+			It("parses oci chart config", func() {
+				os.Setenv("REG_SERVER", "registry.example.com")
+				os.Setenv("OCI_CHARTS", "charts/mysql:0.10.2,charts/spacebears:1.0.0")
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.OCIChartConfig.HasOCIChartConfig()).To(BeTrue())
+				Expect(c.OCIChartConfig.Charts).To(Equal([]string{"charts/mysql:0.10.2", "charts/spacebears:1.0.0"}))
+			})
+
+			It("requires a registry", func() {
+				os.Setenv("OCI_CHARTS", "charts/mysql:0.10.2")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Context("git repository config", func() {
+			It("parses git repository config", func() {
+				os.Setenv("GIT_REPO_URL", "https://git.example.com/charts.git")
+				os.Setenv("GIT_REPO_CHART_PATH", "stable")
+				os.Setenv("GIT_REPO_SYNC_INTERVAL", "5m")
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.GitRepoConfig.HasGitRepoConfig()).To(BeTrue())
+				Expect(c.GitRepoConfig.Branch).To(Equal("master"))
+				Expect(c.GitRepoConfig.ChartPath).To(Equal("stable"))
+				Expect(c.GitRepoConfig.SyncInterval).To(Equal(5 * time.Minute))
+			})
+
+			It("can't be combined with a helm repository", func() {
+				os.Setenv("GIT_REPO_URL", "https://git.example.com/charts.git")
+				os.Setenv("HELM_REPO_URL", "https://charts.example.com")
+				os.Setenv("HELM_REPO_PIN_FILE", "/var/vcap/jobs/kibosh/config/pins.yml")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+			})
+
+			It("can't be combined with watching the chart directory", func() {
+				os.Setenv("GIT_REPO_URL", "https://git.example.com/charts.git")
+				os.Setenv("HELM_CHART_DIR_WATCH", "true")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("HELM_CHART_DIR_WATCH"))
+			})
+		})
+
+		Context("server tls config", func() {
+			It("serves plain http by default", func() {
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.ServerTLSConfig.HasServerTLS()).To(BeFalse())
+			})
+
+			It("parses server tls config", func() {
+				os.Setenv("TLS_CERT_FILE", "/var/vcap/jobs/kibosh/config/tls.crt")
+				os.Setenv("TLS_KEY_FILE", "/var/vcap/jobs/kibosh/config/tls.key")
+				os.Setenv("TLS_CLIENT_CA_FILE", "/var/vcap/jobs/kibosh/config/client_ca.crt")
+
+				c, err := Parse()
+				Expect(err).To(BeNil())
+
+				Expect(c.ServerTLSConfig.HasServerTLS()).To(BeTrue())
+				Expect(c.ServerTLSConfig.KeyFile).To(Equal("/var/vcap/jobs/kibosh/config/tls.key"))
+				Expect(c.ServerTLSConfig.ClientCAFile).To(Equal("/var/vcap/jobs/kibosh/config/client_ca.crt"))
+			})
+
+			It("requires a key with the cert", func() {
+				os.Setenv("TLS_CERT_FILE", "/var/vcap/jobs/kibosh/config/tls.crt")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
+			})
+
+			It("requires serving tls to verify client certificates", func() {
+				os.Setenv("TLS_CLIENT_CA_FILE", "/var/vcap/jobs/kibosh/config/client_ca.crt")
+
+				_, err := Parse()
+				Expect(err).NotTo(BeNil())
 			})
 		})
 	})
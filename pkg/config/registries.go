@@ -0,0 +1,168 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Registry is a private registry besides REG_SERVER. Images whose source name starts with one of
+// the Match prefixes (eg "quay.io/vendor") are pushed to and pulled from Server instead.
+type Registry struct {
+	Server string   `json:"server"`
+	User   string   `json:"user"`
+	Pass   string   `json:"pass"`
+	Email  string   `json:"email"`
+	Match  []string `json:"match"`
+}
+
+// Registries decodes REG_REGISTRIES, a json list of registries
+type Registries []Registry
+
+func (r *Registries) Decode(value string) error {
+	registries := []Registry{}
+	err := json.Unmarshal([]byte(value), &registries)
+	if err != nil {
+		return errors.New(fmt.Sprintf("REG_REGISTRIES isn't a json list of registries: %v", err))
+	}
+	*r = registries
+	return nil
+}
+
+// HasCredentials is false for registries that allow anonymous pulls, which don't need a pull secret
+func (r Registry) HasCredentials() bool {
+	return r.User != "" && r.Pass != ""
+}
+
+func (r Registry) GetDockerConfigJson() ([]byte, error) {
+	if r.Server == "" || !r.HasCredentials() {
+		return nil, errors.New(fmt.Sprintf("registry [%s] doesn't have credentials", r.Server))
+	}
+
+	auth := map[string]interface{}{
+		"username": r.User,
+		"password": r.Pass,
+	}
+	if r.Email != "" {
+		auth["email"] = r.Email
+	}
+	dockerConfig := map[string]interface{}{
+		"auths": map[string]interface{}{
+			r.Server: auth,
+		},
+	}
+	return json.Marshal(dockerConfig)
+}
+
+func (r *RegistryConfig) Validate() error {
+	if len(r.Registries) == 0 {
+		return nil
+	}
+	if !r.HasRegistryConfig() {
+		return errors.New("additional registries (REG_REGISTRIES) require a default registry (REG_SERVER)")
+	}
+	for i, registry := range r.Registries {
+		if registry.Server == "" {
+			return errors.New(fmt.Sprintf("registry [%d] in REG_REGISTRIES has no server", i))
+		}
+		if len(registry.Match) == 0 {
+			return errors.New(fmt.Sprintf("registry [%s] in REG_REGISTRIES has nothing to match", registry.Server))
+		}
+	}
+	return nil
+}
+
+// AllRegistries lists the default registry, when configured, followed by the additional ones
+func (r RegistryConfig) AllRegistries() []Registry {
+	registries := []Registry{}
+	if r.HasRegistryConfig() {
+		registries = append(registries, Registry{Server: r.Server, User: r.User, Pass: r.Pass, Email: r.Email})
+	}
+	return append(registries, r.Registries...)
+}
+
+// Registry finds the configured registry with server
+func (r RegistryConfig) Registry(server string) (Registry, bool) {
+	for _, registry := range r.AllRegistries() {
+		if registry.Server == server {
+			return registry, true
+		}
+	}
+	return Registry{}, false
+}
+
+// Target picks the server of the registry the image named source is rewritten to. overrides maps
+// source prefixes to registry servers for a single chart, and takes precedence over the Match
+// prefixes of the configured registries. The longest matching prefix wins, and images nothing
+// matches go to the default registry.
+func (r RegistryConfig) Target(source string, overrides map[string]string) string {
+	matched := ""
+	target := r.Server
+	for _, prefix := range sortedKeys(overrides) {
+		if matchesPrefix(source, prefix) && len(prefix) > len(matched) {
+			matched = prefix
+			target = overrides[prefix]
+		}
+	}
+	if matched != "" {
+		return target
+	}
+
+	for _, registry := range r.Registries {
+		for _, prefix := range registry.Match {
+			if matchesPrefix(source, prefix) && len(prefix) > len(matched) {
+				matched = prefix
+				target = registry.Server
+			}
+		}
+	}
+	return target
+}
+
+// ValidateTargets checks a chart's overrides only map images to configured registries, as Kibosh
+// has no credentials for any other
+func (r RegistryConfig) ValidateTargets(overrides map[string]string) error {
+	for _, prefix := range sortedKeys(overrides) {
+		if _, ok := r.Registry(overrides[prefix]); !ok {
+			return errors.New(fmt.Sprintf("images matching [%s] map to registry [%s], which isn't configured", prefix, overrides[prefix]))
+		}
+	}
+	return nil
+}
+
+// matchesPrefix is true when prefix is source or its leading path segments, so "quay.io/vendor"
+// matches "quay.io/vendor/app:1.0" but not "quay.io/vendor-tools/app:1.0"
+func matchesPrefix(source string, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if prefix == "" || !strings.HasPrefix(source, prefix) {
+		return false
+	}
+	rest := source[len(prefix):]
+	return rest == "" || strings.ContainsAny(rest[:1], "/:@")
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
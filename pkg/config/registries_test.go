@@ -0,0 +1,115 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/cf-platform-eng/kibosh/pkg/config"
+)
+
+var _ = Describe("Registries", func() {
+	var registryConfig *RegistryConfig
+
+	BeforeEach(func() {
+		registryConfig = &RegistryConfig{
+			Server: "registry.example.com",
+			User:   "k8s",
+			Pass:   "xyz789",
+			Email:  "k8s@example.com",
+			Registries: Registries{
+				{Server: "harbor.example.com/vendor", User: "robot", Pass: "abc", Match: []string{"quay.io/vendor", "vendor.example.com"}},
+				{Server: "harbor.example.com/monitoring", Match: []string{"quay.io/vendor/exporter"}},
+			},
+		}
+	})
+
+	Context("target", func() {
+		It("sends images nothing matches to the default registry", func() {
+			Expect(registryConfig.Target("docker.io/bitnami/mysql:8.0", nil)).To(Equal("registry.example.com"))
+		})
+
+		It("sends matching images to the registry matching them", func() {
+			Expect(registryConfig.Target("vendor.example.com/app:1.0", nil)).To(Equal("harbor.example.com/vendor"))
+			Expect(registryConfig.Target("quay.io/vendor/app", nil)).To(Equal("harbor.example.com/vendor"))
+		})
+
+		It("prefers the longest matching prefix", func() {
+			Expect(registryConfig.Target("quay.io/vendor/exporter:0.5.0", nil)).To(Equal("harbor.example.com/monitoring"))
+		})
+
+		It("only matches whole path segments", func() {
+			Expect(registryConfig.Target("quay.io/vendor-tools/app:1.0", nil)).To(Equal("registry.example.com"))
+		})
+
+		It("prefers the chart's overrides", func() {
+			overrides := map[string]string{"quay.io/vendor/exporter": "registry.example.com"}
+
+			Expect(registryConfig.Target("quay.io/vendor/exporter:0.5.0", overrides)).To(Equal("registry.example.com"))
+			Expect(registryConfig.Target("quay.io/vendor/app:1.0", overrides)).To(Equal("harbor.example.com/vendor"))
+		})
+	})
+
+	Context("validate targets", func() {
+		It("accepts configured registries", func() {
+			err := registryConfig.ValidateTargets(map[string]string{
+				"docker.io": "harbor.example.com/vendor", "quay.io": "registry.example.com",
+			})
+
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects registries that aren't configured", func() {
+			err := registryConfig.ValidateTargets(map[string]string{"docker.io": "other.example.com"})
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("other.example.com"))
+		})
+	})
+
+	It("lists the default registry first", func() {
+		registries := registryConfig.AllRegistries()
+
+		Expect(registries).To(HaveLen(3))
+		Expect(registries[0]).To(Equal(Registry{Server: "registry.example.com", User: "k8s", Pass: "xyz789", Email: "k8s@example.com"}))
+		Expect(registries[1].Server).To(Equal("harbor.example.com/vendor"))
+	})
+
+	It("serializes a registry without an email", func() {
+		j, err := registryConfig.Registries[0].GetDockerConfigJson()
+		Expect(err).To(BeNil())
+
+		unmarshalled := map[string]interface{}{}
+		Expect(json.Unmarshal(j, &unmarshalled)).To(Succeed())
+		Expect(unmarshalled).To(Equal(map[string]interface{}{
+			"auths": map[string]interface{}{
+				"harbor.example.com/vendor": map[string]interface{}{
+					"username": "robot",
+					"password": "abc",
+				},
+			},
+		}))
+	})
+
+	It("errors serializing a registry without credentials", func() {
+		_, err := registryConfig.Registries[1].GetDockerConfigJson()
+
+		Expect(err).NotTo(BeNil())
+	})
+})
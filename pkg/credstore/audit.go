@@ -0,0 +1,243 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package credstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/credhub-cli/credhub/permissions"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	AuditOutcomeSuccess = "success"
+	AuditOutcomeFailure = "failure"
+
+	AuditSinkLog     = "log"
+	AuditSinkFile    = "file"
+	AuditSinkWebhook = "webhook"
+)
+
+type AuditEvent struct {
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"`
+	Path       string    `json:"path"`
+	Actor      string    `json:"actor,omitempty"`
+	InstanceID string    `json:"instanceID,omitempty"`
+	BindingID  string    `json:"bindingID,omitempty"`
+	AppGUID    string    `json:"appGUID,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+}
+
+//go:generate counterfeiter ./ AuditSink
+type AuditSink interface {
+	Record(event AuditEvent) error
+}
+
+// BindingScoper is implemented by stores that can attribute operations to a binding.
+type BindingScoper interface {
+	ForBinding(instanceID string, bindingID string, appGUID string) CredStore
+}
+
+type auditingCredStore struct {
+	delegate   CredStore
+	sink       AuditSink
+	instanceID string
+	bindingID  string
+	appGUID    string
+	logger     *logrus.Logger
+}
+
+func NewAuditingCredStore(delegate CredStore, sink AuditSink, logger *logrus.Logger) CredStore {
+	return &auditingCredStore{
+		delegate: delegate,
+		sink:     sink,
+		logger:   logger,
+	}
+}
+
+func (a *auditingCredStore) ForBinding(instanceID string, bindingID string, appGUID string) CredStore {
+	return &auditingCredStore{
+		delegate:   a.delegate,
+		sink:       a.sink,
+		instanceID: instanceID,
+		bindingID:  bindingID,
+		appGUID:    appGUID,
+		logger:     a.logger,
+	}
+}
+
+func (a *auditingCredStore) Put(key string, credentials interface{}) (interface{}, error) {
+	result, err := a.delegate.Put(key, credentials)
+	a.record("put", key, "", err)
+	return result, err
+}
+
+func (a *auditingCredStore) Get(key string) (interface{}, error) {
+	return a.delegate.Get(key)
+}
+
+func (a *auditingCredStore) Delete(key string) error {
+	err := a.delegate.Delete(key)
+	a.record("delete", key, "", err)
+	return err
+}
+
+func (a *auditingCredStore) AddPermission(path string, actor string, ops []string) (*permissions.Permission, error) {
+	permission, err := a.delegate.AddPermission(path, actor, ops)
+	a.record("add-permission", path, actor, err)
+	return permission, err
+}
+
+func (a *auditingCredStore) DeletePermission(path string) error {
+	err := a.delegate.DeletePermission(path)
+	a.record("delete-permission", path, "", err)
+	return err
+}
+
+func (a *auditingCredStore) record(operation string, path string, actor string, opErr error) {
+	event := AuditEvent{
+		Time:       time.Now().UTC(),
+		Operation:  operation,
+		Path:       path,
+		Actor:      actor,
+		InstanceID: a.instanceID,
+		BindingID:  a.bindingID,
+		AppGUID:    a.appGUID,
+		Outcome:    AuditOutcomeSuccess,
+	}
+	if opErr != nil {
+		event.Outcome = AuditOutcomeFailure
+		event.Error = opErr.Error()
+	}
+
+	err := a.sink.Record(event)
+	if err != nil {
+		a.logger.WithError(err).Error(fmt.Sprintf("Unable to record credential audit event for [%s]", path))
+	}
+}
+
+func NewAuditSink(sinkType string, filePath string, webhookURL string, logger *logrus.Logger) (AuditSink, error) {
+	switch sinkType {
+	case "", AuditSinkLog:
+		return NewLogAuditSink(logger), nil
+	case AuditSinkFile:
+		if filePath == "" {
+			return nil, errors.New("file audit sink requires a file path")
+		}
+		return NewFileAuditSink(filePath), nil
+	case AuditSinkWebhook:
+		if webhookURL == "" {
+			return nil, errors.New("webhook audit sink requires a url")
+		}
+		return NewWebhookAuditSink(webhookURL, &http.Client{Timeout: 10 * time.Second}), nil
+	default:
+		return nil, errors.Errorf("Unknown audit sink [%s]", sinkType)
+	}
+}
+
+type logAuditSink struct {
+	logger *logrus.Logger
+}
+
+func NewLogAuditSink(logger *logrus.Logger) AuditSink {
+	return &logAuditSink{
+		logger: logger,
+	}
+}
+
+func (s *logAuditSink) Record(event AuditEvent) error {
+	s.logger.WithFields(logrus.Fields{
+		"audit":      "credstore",
+		"operation":  event.Operation,
+		"path":       event.Path,
+		"actor":      event.Actor,
+		"instanceID": event.InstanceID,
+		"bindingID":  event.BindingID,
+		"appGUID":    event.AppGUID,
+		"outcome":    event.Outcome,
+		"error":      event.Error,
+	}).Info("Credential audit event")
+	return nil
+}
+
+type fileAuditSink struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewFileAuditSink(path string) AuditSink {
+	return &fileAuditSink{
+		path: path,
+	}
+}
+
+func (s *fileAuditSink) Record(event AuditEvent) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+type webhookAuditSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookAuditSink(url string, client *http.Client) AuditSink {
+	return &webhookAuditSink{
+		url:    url,
+		client: client,
+	}
+}
+
+func (s *webhookAuditSink) Record(event AuditEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.Errorf("audit webhook returned non 2xx status code [%v]", res.StatusCode)
+	}
+	return nil
+}
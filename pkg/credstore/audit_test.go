@@ -0,0 +1,168 @@
+package credstore_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/credstore"
+	"github.com/cf-platform-eng/kibosh/pkg/credstore/credstorefakes"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Audit", func() {
+	var logger *logrus.Logger
+	var fakeCredStore *credstorefakes.FakeCredStore
+	var fakeSink *credstorefakes.FakeAuditSink
+	var auditingStore credstore.CredStore
+
+	BeforeEach(func() {
+		logger = logrus.New()
+		fakeCredStore = &credstorefakes.FakeCredStore{}
+		fakeSink = &credstorefakes.FakeAuditSink{}
+		auditingStore = credstore.NewAuditingCredStore(fakeCredStore, fakeSink, logger)
+	})
+
+	Context("auditing cred store", func() {
+		It("records successful put", func() {
+			_, err := auditingStore.Put("/c/kibosh/mysql/my-binding-id/secrets-and-services", map[string]string{"a": "b"})
+			Expect(err).To(BeNil())
+
+			Expect(fakeCredStore.PutCallCount()).To(Equal(1))
+			Expect(fakeSink.RecordCallCount()).To(Equal(1))
+			event := fakeSink.RecordArgsForCall(0)
+			Expect(event.Operation).To(Equal("put"))
+			Expect(event.Path).To(Equal("/c/kibosh/mysql/my-binding-id/secrets-and-services"))
+			Expect(event.Outcome).To(Equal(credstore.AuditOutcomeSuccess))
+			Expect(event.Error).To(BeEmpty())
+		})
+
+		It("records failed operations and still returns the error", func() {
+			fakeCredStore.DeleteReturns(errors.New("the tubes are down"))
+
+			err := auditingStore.Delete("/c/kibosh/mysql/my-binding-id/secrets-and-services")
+			Expect(err).NotTo(BeNil())
+
+			event := fakeSink.RecordArgsForCall(0)
+			Expect(event.Operation).To(Equal("delete"))
+			Expect(event.Outcome).To(Equal(credstore.AuditOutcomeFailure))
+			Expect(event.Error).To(ContainSubstring("tubes"))
+		})
+
+		It("records binding details when scoped", func() {
+			scoper, ok := auditingStore.(credstore.BindingScoper)
+			Expect(ok).To(BeTrue())
+
+			bindingStore := scoper.ForBinding("my-instance-id", "my-binding-id", "my-app-guid")
+			_, err := bindingStore.AddPermission("/c/kibosh/mysql/my-binding-id/secrets-and-services", "mtls-app:my-app-guid", []string{"read"})
+			Expect(err).To(BeNil())
+
+			Expect(fakeCredStore.AddPermissionCallCount()).To(Equal(1))
+			event := fakeSink.RecordArgsForCall(0)
+			Expect(event.Operation).To(Equal("add-permission"))
+			Expect(event.Actor).To(Equal("mtls-app:my-app-guid"))
+			Expect(event.InstanceID).To(Equal("my-instance-id"))
+			Expect(event.BindingID).To(Equal("my-binding-id"))
+			Expect(event.AppGUID).To(Equal("my-app-guid"))
+		})
+
+		It("does not audit reads", func() {
+			_, err := auditingStore.Get("/c/kibosh/mysql/my-binding-id/secrets-and-services")
+			Expect(err).To(BeNil())
+
+			Expect(fakeCredStore.GetCallCount()).To(Equal(1))
+			Expect(fakeSink.RecordCallCount()).To(Equal(0))
+		})
+
+		It("sink failure doesn't fail the operation", func() {
+			fakeSink.RecordReturns(errors.New("sink unavailable"))
+
+			err := auditingStore.DeletePermission("/c/kibosh/mysql/my-binding-id/secrets-and-services")
+			Expect(err).To(BeNil())
+			Expect(fakeCredStore.DeletePermissionCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("sinks", func() {
+		var event credstore.AuditEvent
+
+		BeforeEach(func() {
+			event = credstore.AuditEvent{
+				Operation: "put",
+				Path:      "/c/kibosh/mysql/my-binding-id/secrets-and-services",
+				BindingID: "my-binding-id",
+				Outcome:   credstore.AuditOutcomeSuccess,
+			}
+		})
+
+		It("appends json lines to file", func() {
+			dir, err := ioutil.TempDir("", "audit-")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+
+			auditFile := filepath.Join(dir, "audit.log")
+			sink, err := credstore.NewAuditSink("file", auditFile, "", logger)
+			Expect(err).To(BeNil())
+
+			Expect(sink.Record(event)).To(BeNil())
+			Expect(sink.Record(event)).To(BeNil())
+
+			contents, err := ioutil.ReadFile(auditFile)
+			Expect(err).To(BeNil())
+			lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+			Expect(lines).To(HaveLen(2))
+
+			recorded := credstore.AuditEvent{}
+			err = json.Unmarshal([]byte(lines[0]), &recorded)
+			Expect(err).To(BeNil())
+			Expect(recorded.BindingID).To(Equal("my-binding-id"))
+		})
+
+		It("posts to webhook", func() {
+			var webhookRequest *http.Request
+			var webhookBody []byte
+			webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				webhookRequest = r
+				webhookBody, _ = ioutil.ReadAll(r.Body)
+			}))
+			defer webhookServer.Close()
+
+			sink, err := credstore.NewAuditSink("webhook", "", webhookServer.URL, logger)
+			Expect(err).To(BeNil())
+
+			Expect(sink.Record(event)).To(BeNil())
+			Expect(webhookRequest.Method).To(Equal("POST"))
+			Expect(webhookRequest.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(string(webhookBody)).To(ContainSubstring(`"bindingID":"my-binding-id"`))
+		})
+
+		It("webhook returns error on non 2xx", func() {
+			webhookServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(500)
+			}))
+			defer webhookServer.Close()
+
+			sink, err := credstore.NewAuditSink("webhook", "", webhookServer.URL, logger)
+			Expect(err).To(BeNil())
+
+			Expect(sink.Record(event)).NotTo(BeNil())
+		})
+
+		It("errors on unknown sink", func() {
+			_, err := credstore.NewAuditSink("carrier-pigeon", "", "", logger)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("errors on file sink without path", func() {
+			_, err := credstore.NewAuditSink("file", "", "", logger)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
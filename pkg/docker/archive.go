@@ -0,0 +1,504 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DockerManifestMediaType     = "application/vnd.docker.distribution.manifest.v2+json"
+	DockerManifestListMediaType = "application/vnd.docker.distribution.manifest.list.v2+json"
+	DockerConfigMediaType       = "application/vnd.docker.container.image.v1+json"
+	DockerLayerMediaType        = "application/vnd.docker.image.rootfs.diff.tar.gzip"
+	OCIManifestMediaType        = "application/vnd.oci.image.manifest.v1+json"
+	OCIIndexMediaType           = "application/vnd.oci.image.index.v1+json"
+
+	ociRefNameAnnotation     = "org.opencontainers.image.ref.name"
+	containerdNameAnnotation = "io.containerd.image.name"
+	maxSymlinks              = 10
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifestContent struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        *descriptor  `json:"config,omitempty"`
+	Layers        []descriptor `json:"layers,omitempty"`
+	Manifests     []descriptor `json:"manifests,omitempty"`
+}
+
+type dockerArchiveManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// blob is a config or layer as pushed to the registry
+type blob struct {
+	descriptor
+	open func() (io.ReadCloser, error)
+}
+
+// manifestNode is an image manifest with the blobs it references, or an index with the manifests it
+// references
+type manifestNode struct {
+	mediaType string
+	digest    string
+	content   []byte
+	blobs     []*blob
+	children  []*manifestNode
+}
+
+type imageReference struct {
+	repository string
+	tag        string
+}
+
+func (i imageReference) String() string {
+	return fmt.Sprintf("%s:%s", i.repository, i.tag)
+}
+
+// archiveImage is an image found in an archive, under every reference it was saved with
+type archiveImage struct {
+	references []imageReference
+	manifest   *manifestNode
+}
+
+// imageSource reads files from an image archive, which is either a tarball or an extracted directory
+type imageSource interface {
+	open(name string) (io.ReadCloser, error)
+	exists(name string) bool
+}
+
+type dirSource struct {
+	dir string
+}
+
+func (d dirSource) open(name string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.dir, filepath.FromSlash(name)))
+}
+
+func (d dirSource) exists(name string) bool {
+	_, err := os.Stat(filepath.Join(d.dir, filepath.FromSlash(name)))
+	return err == nil
+}
+
+// tarSource reads entries of a tarball, optionally gzipped. Each open scans the tarball from the
+// start, which is cheap as the entries in between are skipped rather than read.
+type tarSource struct {
+	path string
+}
+
+type tarEntryReader struct {
+	io.Reader
+	file *os.File
+}
+
+func (t tarEntryReader) Close() error {
+	return t.file.Close()
+}
+
+func (t tarSource) open(name string) (io.ReadCloser, error) {
+	return t.openEntry(path.Clean(name), 0)
+}
+
+func (t tarSource) exists(name string) bool {
+	reader, err := t.open(name)
+	if err != nil {
+		return false
+	}
+	reader.Close()
+	return true
+}
+
+func (t tarSource) openEntry(name string, symlinks int) (io.ReadCloser, error) {
+	file, err := os.Open(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var archive io.Reader = file
+	buffered := bufio.NewReader(file)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		archive, err = gzip.NewReader(buffered)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	} else if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	reader := tar.NewReader(archive)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			file.Close()
+			return nil, errors.Errorf("[%s] not found in [%s]", name, t.path)
+		}
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrapf(err, "Unable to read [%s]", t.path)
+		}
+		if path.Clean(header.Name) != name {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeSymlink, tar.TypeLink:
+			file.Close()
+			if symlinks >= maxSymlinks {
+				return nil, errors.Errorf("Too many links resolving [%s] in [%s]", name, t.path)
+			}
+			target := header.Linkname
+			if header.Typeflag == tar.TypeSymlink && !path.IsAbs(target) {
+				target = path.Join(path.Dir(name), target)
+			}
+			return t.openEntry(path.Clean(strings.TrimPrefix(target, "/")), symlinks+1)
+		case tar.TypeReg, tar.TypeRegA:
+			return tarEntryReader{Reader: reader, file: file}, nil
+		default:
+			file.Close()
+			return nil, errors.Errorf("[%s] in [%s] is not a file", name, t.path)
+		}
+	}
+}
+
+func newImageSource(archivePath string) (imageSource, error) {
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return dirSource{dir: archivePath}, nil
+	}
+	return tarSource{path: archivePath}, nil
+}
+
+// readArchive reads the images in a docker save tarball, or an OCI image layout as a tarball or
+// directory. Layers of docker save tarballs are uncompressed, so they are gzipped into tmpDir to
+// push them as the registry expects.
+func readArchive(archivePath string, tmpDir string) ([]*archiveImage, error) {
+	source, err := newImageSource(archivePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if source.exists("manifest.json") {
+		return readDockerArchive(source, archivePath, tmpDir)
+	}
+	if source.exists("index.json") {
+		return readOCILayout(source, archivePath)
+	}
+	return nil, errors.Errorf("[%s] is neither a docker save archive nor an OCI image layout", archivePath)
+}
+
+func readDockerArchive(source imageSource, archivePath string, tmpDir string) ([]*archiveImage, error) {
+	entries := []dockerArchiveManifest{}
+	err := readJSON(source, "manifest.json", &entries)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read manifest.json of [%s]", archivePath)
+	}
+
+	layers := map[string]*blob{}
+	images := []*archiveImage{}
+	for _, entry := range entries {
+		if len(entry.RepoTags) == 0 {
+			return nil, errors.Errorf("Image [%s] in [%s] has no tags to push it with", entry.Config, archivePath)
+		}
+
+		configContent, err := readAll(source, entry.Config)
+		if err != nil {
+			return nil, err
+		}
+		config := contentBlob(DockerConfigMediaType, configContent)
+
+		node := &manifestNode{
+			mediaType: DockerManifestMediaType,
+			blobs:     []*blob{config},
+		}
+		manifest := manifestContent{
+			SchemaVersion: 2,
+			MediaType:     DockerManifestMediaType,
+			Config:        &config.descriptor,
+			Layers:        []descriptor{},
+		}
+		for _, layerPath := range entry.Layers {
+			layer, ok := layers[layerPath]
+			if !ok {
+				layer, err = compressLayer(source, layerPath, tmpDir)
+				if err != nil {
+					return nil, errors.Wrapf(err, "Unable to read layer [%s] of [%s]", layerPath, archivePath)
+				}
+				layers[layerPath] = layer
+			}
+			node.blobs = append(node.blobs, layer)
+			manifest.Layers = append(manifest.Layers, layer.descriptor)
+		}
+
+		node.content, err = json.Marshal(manifest)
+		if err != nil {
+			return nil, err
+		}
+		node.digest = digestOf(node.content)
+
+		image := &archiveImage{manifest: node}
+		for _, repoTag := range entry.RepoTags {
+			image.references = append(image.references, parseImageReference(repoTag))
+		}
+		images = append(images, image)
+	}
+	return images, nil
+}
+
+func readOCILayout(source imageSource, archivePath string) ([]*archiveImage, error) {
+	index := manifestContent{}
+	err := readJSON(source, "index.json", &index)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read index.json of [%s]", archivePath)
+	}
+
+	images := []*archiveImage{}
+	for _, desc := range index.Manifests {
+		reference, ok := ociReference(desc, archivePath)
+		if !ok {
+			continue
+		}
+		node, err := readOCIManifest(source, desc, 0)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to read [%s] from [%s]", reference, archivePath)
+		}
+		images = append(images, &archiveImage{
+			references: []imageReference{reference},
+			manifest:   node,
+		})
+	}
+	if len(images) == 0 {
+		return nil, errors.Errorf("No manifests in [%s] are named with a reference to push them with", archivePath)
+	}
+	return images, nil
+}
+
+// ociReference names a manifest in an OCI layout's index. The containerd name annotation holds a
+// full reference, while the ref name annotation is either a full reference or only a tag, in which
+// case the archive file name is the repository.
+func ociReference(desc descriptor, archivePath string) (imageReference, bool) {
+	if name := desc.Annotations[containerdNameAnnotation]; name != "" {
+		return parseImageReference(name), true
+	}
+	refName := desc.Annotations[ociRefNameAnnotation]
+	if refName == "" {
+		return imageReference{}, false
+	}
+	if strings.ContainsAny(refName, "/:") {
+		return parseImageReference(refName), true
+	}
+
+	repository := filepath.Base(archivePath)
+	for _, ext := range []string{".gz", ".tgz", ".tar"} {
+		repository = strings.TrimSuffix(repository, ext)
+	}
+	return imageReference{repository: repository, tag: refName}, true
+}
+
+func readOCIManifest(source imageSource, desc descriptor, depth int) (*manifestNode, error) {
+	if depth > 2 {
+		return nil, errors.Errorf("Manifest [%s] is nested too deeply", desc.Digest)
+	}
+	content, err := readAll(source, blobPath(desc.Digest))
+	if err != nil {
+		return nil, err
+	}
+	manifest := manifestContent{}
+	err = json.Unmarshal(content, &manifest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse manifest [%s]", desc.Digest)
+	}
+
+	node := &manifestNode{
+		mediaType: desc.MediaType,
+		digest:    desc.Digest,
+		content:   content,
+	}
+	if node.mediaType == "" {
+		node.mediaType = manifest.MediaType
+	}
+
+	switch node.mediaType {
+	case OCIIndexMediaType, DockerManifestListMediaType:
+		for _, child := range manifest.Manifests {
+			childNode, err := readOCIManifest(source, child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, childNode)
+		}
+	case OCIManifestMediaType, DockerManifestMediaType:
+		if manifest.Config == nil {
+			return nil, errors.Errorf("Manifest [%s] has no config", desc.Digest)
+		}
+		for _, blobDesc := range append([]descriptor{*manifest.Config}, manifest.Layers...) {
+			node.blobs = append(node.blobs, layoutBlob(source, blobDesc))
+		}
+	default:
+		return nil, errors.Errorf("Manifest [%s] has unsupported media type [%s]", desc.Digest, node.mediaType)
+	}
+	return node, nil
+}
+
+func layoutBlob(source imageSource, desc descriptor) *blob {
+	return &blob{
+		descriptor: descriptor{MediaType: desc.MediaType, Digest: desc.Digest, Size: desc.Size},
+		open: func() (io.ReadCloser, error) {
+			return source.open(blobPath(desc.Digest))
+		},
+	}
+}
+
+func contentBlob(mediaType string, content []byte) *blob {
+	return &blob{
+		descriptor: descriptor{MediaType: mediaType, Digest: digestOf(content), Size: int64(len(content))},
+		open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(content)), nil
+		},
+	}
+}
+
+// compressLayer gzips a layer into tmpDir, unless it's already gzipped, in which case it's pushed
+// straight from the archive
+func compressLayer(source imageSource, layerPath string, tmpDir string) (*blob, error) {
+	reader, err := source.open(layerPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	buffered := bufio.NewReader(reader)
+	hash := sha256.New()
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		size, err := io.Copy(hash, buffered)
+		if err != nil {
+			return nil, err
+		}
+		return &blob{
+			descriptor: descriptor{MediaType: DockerLayerMediaType, Digest: hashDigest(hash.Sum(nil)), Size: size},
+			open: func() (io.ReadCloser, error) {
+				return source.open(layerPath)
+			},
+		}, nil
+	}
+
+	compressed, err := ioutil.TempFile(tmpDir, "layer-")
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+
+	counter := &countingWriter{}
+	gzipWriter := gzip.NewWriter(io.MultiWriter(compressed, hash, counter))
+	_, err = io.Copy(gzipWriter, buffered)
+	if err != nil {
+		return nil, err
+	}
+	err = gzipWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	compressedPath := compressed.Name()
+	return &blob{
+		descriptor: descriptor{MediaType: DockerLayerMediaType, Digest: hashDigest(hash.Sum(nil)), Size: counter.count},
+		open: func() (io.ReadCloser, error) {
+			return os.Open(compressedPath)
+		},
+	}, nil
+}
+
+type countingWriter struct {
+	count int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.count += int64(len(p))
+	return len(p), nil
+}
+
+// parseImageReference splits "repository:tag", defaulting the tag to latest like docker does
+func parseImageReference(ref string) imageReference {
+	if i := strings.Index(ref, "@"); i > 0 {
+		ref = ref[:i]
+	}
+	i := strings.LastIndex(ref, ":")
+	if i < 0 || strings.Contains(ref[i:], "/") {
+		return imageReference{repository: ref, tag: "latest"}
+	}
+	return imageReference{repository: ref[:i], tag: ref[i+1:]}
+}
+
+func blobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+func readAll(source imageSource, name string) ([]byte, error) {
+	reader, err := source.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func readJSON(source imageSource, name string, target interface{}) error {
+	content, err := readAll(source, name)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, target)
+}
+
+func digestOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hashDigest(sum[:])
+}
+
+func hashDigest(sum []byte) string {
+	return "sha256:" + hex.EncodeToString(sum)
+}
@@ -1,11 +1,9 @@
 package docker
 
 import (
-	"fmt"
 	"io/ioutil"
-	"os/exec"
+	"os"
 	"path"
-	"strings"
 
 	"github.com/ghodss/yaml"
 )
@@ -16,72 +14,38 @@ type ImageValues struct {
 	Images   map[string]ImageValues `json:"images"`
 }
 
-type DockerImageOutput struct {
-	Repo string `json:"repo"`
-	Tag  string `json:"tag"`
-}
-
-func TagAndPush(privateRegistryServer string) error {
-	cmd := exec.Command("docker", "images", "--format", "- repo: {{ json .Repository }}\n  tag: {{ json .Tag }}")
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return err
-	}
-
-	images := []DockerImageOutput{}
-	err = yaml.Unmarshal([]byte(output), &images)
+// ParseValues validates the images in the chart's values.yaml, following its images.yaml when present.
+// Images at other paths than image and images.<name> aren't part of the returned ImageValues.
+func ParseValues(chartPath string) (*ImageValues, error) {
+	valuesPath := path.Join(chartPath, "values.yaml")
+	bytes, err := ioutil.ReadFile(valuesPath)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	for _, image := range images {
-		split := strings.Split(image.Repo, "/")
-		imageName := fmt.Sprintf("%s/%s", privateRegistryServer, split[len(split)-1])
-
-		origTag := fmt.Sprintf("%s:%s", image.Repo, image.Tag)
-		newTag := fmt.Sprintf("%s:%s", imageName, image.Tag)
-
-		cmd := exec.Command("docker", "tag", origTag, newTag)
-		output, err := cmd.CombinedOutput()
-		println(string(output))
-		if err != nil {
-			return err
-		}
-
-		cmd = exec.Command("docker", "push", newTag)
-		output, err = cmd.CombinedOutput()
-		println(string(output))
+	var imagePaths *ImagePaths
+	imagePathsBytes, err := ioutil.ReadFile(path.Join(chartPath, ImagePathsFile))
+	if err == nil {
+		imagePaths, err = ParseImagePaths(imagePathsBytes)
 		if err != nil {
-			return err
+			return nil, err
 		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
 	}
 
-	return nil
-}
-
-func LoadImage(imagePath string) error {
-	cmd := exec.Command("docker", "load", "-i", imagePath)
-	output, err := cmd.CombinedOutput()
-	println(string(output))
-	return err
-}
-
-func ParseValues(chartPath string) (*ImageValues, error) {
-	valuesPath := path.Join(chartPath, "values.yaml")
-	bytes, err := ioutil.ReadFile(valuesPath)
+	err = ValidateImageValues(bytes, imagePaths)
 	if err != nil {
 		return nil, err
 	}
 
+	// image maps at image or images.<name> listed in images.yaml don't fit ImageValues, and are
+	// left empty rather than failing
 	parsedImages := &ImageValues{}
 	err = yaml.Unmarshal(bytes, parsedImages)
-	if err != nil {
+	if err != nil && imagePaths == nil {
 		return nil, err
 	}
-	if !parsedImages.ValidateImages() {
-		return nil, err
-	}
-
 	return parsedImages, nil
 }
 
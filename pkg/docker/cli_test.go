@@ -114,5 +114,77 @@ images:
 			Expect(err).To(BeNil())
 			Expect(len(parsedImages.Images)).To(Equal(2))
 		})
+
+		It("returns error when no images are declared", func() {
+			err := ioutil.WriteFile(filepath.Join(chartPath, "values.yaml"), []byte(`
+controller:
+  image:
+    repository: bitnami/nginx
+    tag: 1.17.6
+`), 0666)
+			Expect(err).To(BeNil())
+
+			_, err = ParseValues(chartPath)
+			Expect(err).NotTo(BeNil())
+		})
+
+		Context("images.yaml", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(chartPath, "values.yaml"), []byte(`
+controller:
+  image:
+    repository: bitnami/nginx
+    tag: 1.17.6
+metrics:
+  image: bitnami/nginx-exporter:0.5.0
+  port: 9113
+`), 0666)
+				Expect(err).To(BeNil())
+			})
+
+			It("accepts images at listed paths", func() {
+				err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- controller.image
+- metrics.image
+`), 0666)
+				Expect(err).To(BeNil())
+
+				_, err = ParseValues(chartPath)
+				Expect(err).To(BeNil())
+			})
+
+			It("accepts discovered images", func() {
+				err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`discover: true`), 0666)
+				Expect(err).To(BeNil())
+
+				_, err = ParseValues(chartPath)
+				Expect(err).To(BeNil())
+			})
+
+			It("returns error for a path that isn't an image", func() {
+				err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.port
+`), 0666)
+				Expect(err).To(BeNil())
+
+				_, err = ParseValues(chartPath)
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("metrics.port"))
+			})
+
+			It("returns error for a missing path", func() {
+				err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- server.image
+`), 0666)
+				Expect(err).To(BeNil())
+
+				_, err = ParseValues(chartPath)
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("not found"))
+			})
+		})
 	})
 })
@@ -0,0 +1,181 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/pkg/errors"
+)
+
+//go:generate counterfeiter ./ ImageChecker
+
+// ImageChecker checks that images exist in the private registry, so a missing image is reported
+// when a chart is saved or provisioned rather than as an image pull failure minutes later
+type ImageChecker interface {
+	CheckImages(references []string) error
+}
+
+// MissingImagesError lists the images the private registry doesn't have
+type MissingImagesError struct {
+	Images []string
+}
+
+func (m *MissingImagesError) Error() string {
+	return fmt.Sprintf("Images not found in the private registry: [%s]", strings.Join(m.Images, ", "))
+}
+
+type registryImageChecker struct {
+	targets map[string]*registryTarget
+}
+
+// NewImageChecker checks images with a HEAD on their manifest, using the credentials of the
+// registry they're in. Only images in the private registries are checked, as those are the ones
+// Kibosh rewrote. Checks can run concurrently, as provisions do.
+func NewImageChecker(registryConf *config.RegistryConfig, httpClient *http.Client) (ImageChecker, error) {
+	if !registryConf.HasRegistryConfig() {
+		return nil, errors.New("Checking images requires a registry server")
+	}
+	return &registryImageChecker{
+		targets: newRegistryTargets(registryConf, httpClient),
+	}, nil
+}
+
+// NewConfiguredImageChecker checks images as registryConf sets, trusting its CA cert. It's nil when
+// there's no private registry, or when the check is skipped.
+func NewConfiguredImageChecker(registryConf *config.RegistryConfig) (ImageChecker, error) {
+	if !registryConf.HasRegistryConfig() || registryConf.SkipImageCheck {
+		return nil, nil
+	}
+	registryClient, err := httphelpers.NewHTTPClient(registryConf.CACertFile, "", "", false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Loading registry ca cert")
+	}
+	return NewImageChecker(registryConf, registryClient)
+}
+
+func (r *registryImageChecker) CheckImages(references []string) error {
+	missing := []string{}
+	checked := map[string]bool{}
+	for _, reference := range references {
+		target := r.targetOf(reference)
+		if checked[reference] || target == nil {
+			continue
+		}
+		checked[reference] = true
+
+		repository, tagOrDigest := splitImageReference(strings.TrimPrefix(reference, target.host+"/"))
+		exists, err := target.client.ManifestExists(repository, tagOrDigest, DockerManifestMediaType, DockerManifestListMediaType, OCIManifestMediaType, OCIIndexMediaType)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to check image [%s]", reference)
+		}
+		if !exists {
+			missing = append(missing, reference)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return &MissingImagesError{Images: missing}
+	}
+	return nil
+}
+
+// targetOf finds the registry with the longest server the reference is under, which is nil for
+// images outside the private registries
+func (r *registryImageChecker) targetOf(reference string) *registryTarget {
+	var found *registryTarget
+	for server, target := range r.targets {
+		if strings.HasPrefix(reference, server+"/") && (found == nil || len(server) > len(found.server)) {
+			found = target
+		}
+	}
+	return found
+}
+
+// splitImageReference splits "repository:tag" or "repository@digest", defaulting the tag to latest
+func splitImageReference(reference string) (string, string) {
+	if i := strings.Index(reference, "@"); i > 0 {
+		return reference[:i], reference[i+1:]
+	}
+	image := parseImageReference(reference)
+	return image.repository, image.tag
+}
+
+// ImageReferences lists the images in values: image (with imageTag or tag), and maps with
+// repository and tag (with registry when set) at any depth, plus the image names at the paths in
+// images.yaml when imagePaths isn't nil
+func ImageReferences(values map[string]interface{}, imagePaths *ImagePaths) ([]string, error) {
+	found := map[string]bool{}
+	collectImageReferences(values, found)
+
+	if imagePaths != nil {
+		images, err := imagePaths.Find(values)
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range images {
+			if name, ok := image.Value().(string); ok {
+				found[name] = true
+			}
+		}
+	}
+
+	references := []string{}
+	for reference := range found {
+		references = append(references, reference)
+	}
+	sort.Strings(references)
+	return references, nil
+}
+
+func collectImageReferences(value interface{}, found map[string]bool) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if image, ok := typed["image"].(string); ok && image != "" {
+			tag := typed["imageTag"]
+			if tag == nil {
+				tag = typed["tag"]
+			}
+			found[withTag(image, tag)] = true
+		}
+		if repository, ok := typed["repository"].(string); ok && repository != "" {
+			if registry, ok := typed["registry"].(string); ok && registry != "" {
+				repository = fmt.Sprintf("%s/%s", registry, repository)
+			}
+			found[withTag(repository, typed["tag"])] = true
+		}
+		for _, child := range typed {
+			collectImageReferences(child, found)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			collectImageReferences(child, found)
+		}
+	}
+}
+
+func withTag(image string, tag interface{}) string {
+	if tag == nil || fmt.Sprintf("%v", tag) == "" {
+		return image
+	}
+	return fmt.Sprintf("%s:%v", image, tag)
+}
@@ -0,0 +1,236 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	. "github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Image checks", func() {
+	Context("ImageChecker", func() {
+		var registry *testRegistry
+		var server *httptest.Server
+		var registryConf *config.RegistryConfig
+		var host string
+		var checker ImageChecker
+
+		BeforeEach(func() {
+			registry = newTestRegistry()
+			server = httptest.NewServer(registry)
+			host = strings.TrimPrefix(server.URL, "http://")
+			registryConf = &config.RegistryConfig{Server: host + "/library", PlainHTTP: true}
+
+			registry.manifests["library/mysql:8.0"] = storedManifest{mediaType: DockerManifestMediaType, content: []byte("{}")}
+			registry.manifests["library/spacebears@sha256:abc"] = storedManifest{mediaType: OCIIndexMediaType, content: []byte("{}")}
+
+			var err error
+			checker, err = NewImageChecker(registryConf, nil)
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("requires a registry server", func() {
+			_, err := NewImageChecker(&config.RegistryConfig{}, nil)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("isn't configured without a registry server or when skipped", func() {
+			checker, err := NewConfiguredImageChecker(&config.RegistryConfig{})
+			Expect(err).To(BeNil())
+			Expect(checker).To(BeNil())
+
+			registryConf.SkipImageCheck = true
+			checker, err = NewConfiguredImageChecker(registryConf)
+			Expect(err).To(BeNil())
+			Expect(checker).To(BeNil())
+		})
+
+		It("is configured with the registry", func() {
+			checker, err := NewConfiguredImageChecker(registryConf)
+			Expect(err).To(BeNil())
+
+			err = checker.CheckImages([]string{host + "/library/mysql:8.0"})
+
+			Expect(err).To(BeNil())
+		})
+
+		It("errors on a missing registry ca cert", func() {
+			registryConf.CACertFile = "/nonexistent/ca.crt"
+
+			_, err := NewConfiguredImageChecker(registryConf)
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("passes when the registry has every image", func() {
+			registry.manifests["library/spacebears:sha256:abc"] = registry.manifests["library/spacebears@sha256:abc"]
+
+			err := checker.CheckImages([]string{host + "/library/mysql:8.0", host + "/library/spacebears@sha256:abc"})
+
+			Expect(err).To(BeNil())
+		})
+
+		It("reports every missing image", func() {
+			err := checker.CheckImages([]string{
+				host + "/library/mysql:8.0", host + "/library/redis:5.0", host + "/library/mysql",
+			})
+
+			Expect(err).NotTo(BeNil())
+			missing, ok := err.(*MissingImagesError)
+			Expect(ok).To(BeTrue())
+			Expect(missing.Images).To(Equal([]string{host + "/library/mysql", host + "/library/redis:5.0"}))
+		})
+
+		It("only checks images in the private registry", func() {
+			err := checker.CheckImages([]string{"docker.io/bitnami/redis:5.0"})
+
+			Expect(err).To(BeNil())
+			Expect(registry.requests).To(BeEmpty())
+		})
+
+		It("uses the registry credentials", func() {
+			registry.username = "bob"
+			registry.password = "monkey123"
+			registry.token = "abc"
+			registryConf.User = "bob"
+			registryConf.Pass = "monkey123"
+			checker, err := NewImageChecker(registryConf, nil)
+			Expect(err).To(BeNil())
+
+			err = checker.CheckImages([]string{host + "/library/mysql:8.0"})
+
+			Expect(err).To(BeNil())
+		})
+
+		It("returns error when the registry fails", func() {
+			server.Close()
+
+			err := checker.CheckImages([]string{host + "/library/mysql:8.0"})
+
+			Expect(err).NotTo(BeNil())
+			_, ok := err.(*MissingImagesError)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("checks images in each registry with its credentials", func() {
+			vendorRegistry := newTestRegistry()
+			vendorRegistry.username = "robot"
+			vendorRegistry.password = "abc"
+			vendorServer := httptest.NewServer(vendorRegistry)
+			defer vendorServer.Close()
+			vendorHost := strings.TrimPrefix(vendorServer.URL, "http://")
+			vendorRegistry.manifests["vendor/app:1.0"] = storedManifest{mediaType: DockerManifestMediaType, content: []byte("{}")}
+			registryConf.Registries = config.Registries{{
+				Server: vendorHost + "/vendor", User: "robot", Pass: "abc", Match: []string{"quay.io/vendor"},
+			}}
+			checker, err := NewImageChecker(registryConf, nil)
+			Expect(err).To(BeNil())
+
+			err = checker.CheckImages([]string{
+				host + "/library/mysql:8.0", vendorHost + "/vendor/app:1.0", vendorHost + "/vendor/app:2.0",
+			})
+
+			Expect(err).NotTo(BeNil())
+			missing, ok := err.(*MissingImagesError)
+			Expect(ok).To(BeTrue())
+			Expect(missing.Images).To(Equal([]string{vendorHost + "/vendor/app:2.0"}))
+		})
+
+		It("checks images the loader pushed", func() {
+			chartPath, err := ioutil.TempDir("", "chart-")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(chartPath)
+			Expect(os.Mkdir(filepath.Join(chartPath, "images"), 0700)).To(Succeed())
+			writeTar(filepath.Join(chartPath, "images", "redis.tar"), false, []tarEntry{
+				{name: "config.json", content: []byte(`{}`)},
+				{name: "manifest.json", content: []byte(`[{"Config": "config.json", "RepoTags": ["bitnami/redis:5.0"], "Layers": []}]`)},
+			})
+			logger := logrus.New()
+			logger.Out = ioutil.Discard
+			loader, err := NewLoader(registryConf, nil, logger)
+			Expect(err).To(BeNil())
+			Expect(loader.LoadChart(chartPath)).To(Succeed())
+
+			err = checker.CheckImages([]string{host + "/library/redis:5.0"})
+
+			Expect(err).To(BeNil())
+		})
+	})
+
+	Context("ImageReferences", func() {
+		var values map[string]interface{}
+
+		BeforeEach(func() {
+			values = map[string]interface{}{}
+			Expect(yaml.Unmarshal([]byte(`
+image: registry.example.com/mysql
+imageTag: 5.7.14
+images:
+  thing1:
+    image: registry.example.com/first
+    tag: latest
+  thing2:
+    image: registry.example.com/second@sha256
+    imageTag: abcd
+metrics:
+  image:
+    registry: registry.example.com
+    repository: bitnami/exporter
+    tag: 0.5.0
+sidecars:
+- image:
+    repository: registry.example.com/busybox
+    tag: 1.31
+extra:
+  sidecar: registry.example.com/envoy:1.12
+`), &values)).To(Succeed())
+		})
+
+		It("finds images anywhere in the values", func() {
+			references, err := ImageReferences(values, nil)
+
+			Expect(err).To(BeNil())
+			Expect(references).To(Equal([]string{
+				"registry.example.com/bitnami/exporter:0.5.0",
+				"registry.example.com/busybox:1.31",
+				"registry.example.com/first:latest",
+				"registry.example.com/mysql:5.7.14",
+				"registry.example.com/second@sha256:abcd",
+			}))
+		})
+
+		It("adds images at the paths in images.yaml", func() {
+			references, err := ImageReferences(values, &ImagePaths{Paths: []string{"extra.sidecar"}})
+
+			Expect(err).To(BeNil())
+			Expect(references).To(ContainElement("registry.example.com/envoy:1.12"))
+		})
+	})
+})
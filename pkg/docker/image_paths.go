@@ -0,0 +1,179 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// ImagePathsFile lists values holding images, for charts that don't only use image and imageTag or
+// images.<name>.image and images.<name>.imageTag
+const ImagePathsFile = "images.yaml"
+
+// ImagePaths lists dotted value paths (eg "metrics.image") that hold an image, either as a string
+// or as a map with repository, and optionally registry and tag. With Discover set, every map in the
+// values with repository and tag is also taken as an image.
+//
+// Registries maps source prefixes (eg "quay.io/vendor") to the private registry server their images
+// go to, overriding the registries configured for Kibosh for this chart.
+type ImagePaths struct {
+	Paths      []string          `json:"paths"`
+	Discover   bool              `json:"discover"`
+	Registries map[string]string `json:"registries"`
+}
+
+// ImageValue is an image found in the values, at Parent[Key]
+type ImageValue struct {
+	Path   string
+	Parent map[string]interface{}
+	Key    string
+}
+
+func (i ImageValue) Value() interface{} {
+	return i.Parent[i.Key]
+}
+
+// ParseImagePaths parses the contents of an images.yaml file
+func ParseImagePaths(content []byte) (*ImagePaths, error) {
+	imagePaths := &ImagePaths{}
+	err := yaml.Unmarshal(content, imagePaths)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse %s", ImagePathsFile)
+	}
+	return imagePaths, nil
+}
+
+// Find returns the images at the listed paths, then the discovered ones, each only once. A listed
+// path that's missing or doesn't hold an image is an error.
+func (p *ImagePaths) Find(values map[string]interface{}) ([]ImageValue, error) {
+	found := []ImageValue{}
+	seen := map[string]bool{}
+	for _, imagePath := range p.Paths {
+		image, err := findImagePath(values, imagePath)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[image.Path] {
+			seen[image.Path] = true
+			found = append(found, *image)
+		}
+	}
+
+	if p.Discover {
+		for _, image := range discoverImages(values, "") {
+			if !seen[image.Path] {
+				seen[image.Path] = true
+				found = append(found, image)
+			}
+		}
+	}
+	return found, nil
+}
+
+func findImagePath(values map[string]interface{}, imagePath string) (*ImageValue, error) {
+	keys := strings.Split(imagePath, ".")
+	parent := values
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := parent[key].(map[string]interface{})
+		if !ok {
+			return nil, errors.Errorf("Image path [%s] not found in values", imagePath)
+		}
+		parent = child
+	}
+
+	key := keys[len(keys)-1]
+	value, ok := parent[key]
+	if !ok {
+		return nil, errors.Errorf("Image path [%s] not found in values", imagePath)
+	}
+	switch image := value.(type) {
+	case string:
+		if image == "" {
+			return nil, errors.Errorf("Image path [%s] is empty", imagePath)
+		}
+	case map[string]interface{}:
+		if repository, _ := image["repository"].(string); repository == "" {
+			return nil, errors.Errorf("Image path [%s] has no repository", imagePath)
+		}
+	default:
+		return nil, errors.Errorf("Image path [%s] is neither an image name nor a map with a repository", imagePath)
+	}
+	return &ImageValue{Path: imagePath, Parent: parent, Key: key}, nil
+}
+
+// discoverImages walks nested maps, in key order so results are stable. Lists aren't walked.
+func discoverImages(values map[string]interface{}, prefix string) []ImageValue {
+	keys := []string{}
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	found := []ImageValue{}
+	for _, key := range keys {
+		child, ok := values[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if isImageMap(child) {
+			found = append(found, ImageValue{Path: prefix + key, Parent: values, Key: key})
+		} else {
+			found = append(found, discoverImages(child, prefix+key+".")...)
+		}
+	}
+	return found
+}
+
+func isImageMap(value map[string]interface{}) bool {
+	repository, ok := value["repository"].(string)
+	if !ok || repository == "" {
+		return false
+	}
+	_, ok = value["tag"]
+	return ok
+}
+
+// ValidateImageValues checks that the values declare images the way Kibosh and the loader
+// understand, with images.yaml when the chart has one (imagePaths isn't nil)
+func ValidateImageValues(valuesYaml []byte, imagePaths *ImagePaths) error {
+	imageValues := &ImageValues{}
+	err := yaml.Unmarshal(valuesYaml, imageValues)
+	declared := err == nil && imageValues.ValidateImages()
+	if imagePaths == nil {
+		if !declared {
+			return errors.New("images should be declared as image and imageTag, or as images.<name>.image and images.<name>.imageTag, or listed in images.yaml")
+		}
+		return nil
+	}
+
+	values := map[string]interface{}{}
+	err = yaml.Unmarshal(valuesYaml, &values)
+	if err != nil {
+		return err
+	}
+	found, err := imagePaths.Find(values)
+	if err != nil {
+		return err
+	}
+	if !declared && len(found) == 0 {
+		return errors.Errorf("No images found in values at the paths in %s", ImagePathsFile)
+	}
+	return nil
+}
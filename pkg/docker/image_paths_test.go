@@ -0,0 +1,99 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker_test
+
+import (
+	. "github.com/cf-platform-eng/kibosh/pkg/docker"
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ImagePaths", func() {
+	var values map[string]interface{}
+
+	BeforeEach(func() {
+		values = map[string]interface{}{}
+		err := yaml.Unmarshal([]byte(`
+image:
+  registry: docker.io
+  repository: bitnami/kafka
+  tag: 2.3.0
+zookeeper:
+  image:
+    repository: bitnami/zookeeper
+    tag: 3.5.6
+metrics:
+  jmx:
+    image:
+      repository: bitnami/jmx-exporter
+      tag: 0.12.0
+  sidecar: busybox:1.31
+sidecars:
+- image:
+    repository: bitnami/minideb
+    tag: latest
+`), &values)
+		Expect(err).To(BeNil())
+	})
+
+	paths := func(images []ImageValue) []string {
+		found := []string{}
+		for _, image := range images {
+			found = append(found, image.Path)
+		}
+		return found
+	}
+
+	It("finds images at listed paths", func() {
+		imagePaths := &ImagePaths{Paths: []string{"metrics.sidecar", "zookeeper.image"}}
+
+		images, err := imagePaths.Find(values)
+
+		Expect(err).To(BeNil())
+		Expect(paths(images)).To(Equal([]string{"metrics.sidecar", "zookeeper.image"}))
+		Expect(images[0].Value()).To(Equal("busybox:1.31"))
+	})
+
+	It("discovers maps with repository and tag, outside lists", func() {
+		imagePaths := &ImagePaths{Paths: []string{"metrics.sidecar", "zookeeper.image"}, Discover: true}
+
+		images, err := imagePaths.Find(values)
+
+		Expect(err).To(BeNil())
+		Expect(paths(images)).To(Equal([]string{"metrics.sidecar", "zookeeper.image", "image", "metrics.jmx.image"}))
+	})
+
+	It("returns error for maps without a repository", func() {
+		imagePaths := &ImagePaths{Paths: []string{"metrics.jmx"}}
+
+		_, err := imagePaths.Find(values)
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("no repository"))
+	})
+
+	It("parses images.yaml", func() {
+		imagePaths, err := ParseImagePaths([]byte(`
+paths:
+- metrics.sidecar
+discover: true
+`))
+
+		Expect(err).To(BeNil())
+		Expect(imagePaths).To(Equal(&ImagePaths{Paths: []string{"metrics.sidecar"}, Discover: true}))
+	})
+})
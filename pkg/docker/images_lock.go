@@ -0,0 +1,82 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+// ImagesLockFile is written by the loader next to a chart's values.yaml
+const ImagesLockFile = "images.lock"
+
+// ImagesLock records the manifest digest of every image the loader pushed, by the reference it was
+// pushed as (eg "registry.example.com/mysql:8.0"), so the chart can pin images to what was pushed
+// rather than to tags that can be overwritten
+type ImagesLock struct {
+	Images map[string]string `json:"images"`
+}
+
+func NewImagesLock() *ImagesLock {
+	return &ImagesLock{Images: map[string]string{}}
+}
+
+// ParseImagesLock parses the contents of an images.lock file
+func ParseImagesLock(content []byte) (*ImagesLock, error) {
+	lock := NewImagesLock()
+	err := yaml.Unmarshal(content, lock)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to parse %s", ImagesLockFile)
+	}
+	if lock.Images == nil {
+		lock.Images = map[string]string{}
+	}
+	return lock, nil
+}
+
+func (l *ImagesLock) Add(repository string, tag string, digest string) {
+	l.Images[fmt.Sprintf("%s:%s", repository, tag)] = digest
+}
+
+func (l *ImagesLock) Digest(repository string, tag string) (string, bool) {
+	digest, ok := l.Images[fmt.Sprintf("%s:%s", repository, tag)]
+	return digest, ok
+}
+
+// Write replaces the lock file in chartPath, leaving the previous one in place if it can't
+func (l *ImagesLock) Write(chartPath string) error {
+	content, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+
+	lockPath := filepath.Join(chartPath, ImagesLockFile)
+	tmpPath := lockPath + ".tmp"
+	err = ioutil.WriteFile(tmpPath, content, 0644)
+	if err == nil {
+		err = os.Rename(tmpPath, lockPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrapf(err, "Unable to write [%s]", lockPath)
+	}
+	return nil
+}
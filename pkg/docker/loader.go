@@ -0,0 +1,148 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/registry"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// Loader pushes the images saved in a chart's images directory to the private registries, without a
+// docker daemon. Images are renamed the same way MyChart.OverrideImageSources renames them in the
+// chart's values, so "docker.io/bitnami/mysql:8.0" is pushed as "<registry server>/mysql:8.0", to
+// the registry the image maps to.
+type Loader struct {
+	registryConf *config.RegistryConfig
+	targets      map[string]*registryTarget
+	overrides    map[string]string
+	lock         *ImagesLock
+	logger       *logrus.Logger
+}
+
+// NewLoader pushes to the registries in registryConf, whose servers can include a path (eg
+// "harbor.example.com/library")
+func NewLoader(registryConf *config.RegistryConfig, httpClient *http.Client, logger *logrus.Logger) (*Loader, error) {
+	if !registryConf.HasRegistryConfig() {
+		return nil, errors.New("Loading images requires a registry server")
+	}
+
+	return &Loader{
+		registryConf: registryConf,
+		targets:      newRegistryTargets(registryConf, httpClient),
+		lock:         NewImagesLock(),
+		logger:       logger,
+	}, nil
+}
+
+// LoadChart pushes every archive in <chartPath>/images, skipping hidden files, then records the
+// digests of the pushed images in <chartPath>/images.lock. The registries in the chart's images.yaml
+// override the configured ones.
+func (l *Loader) LoadChart(chartPath string) error {
+	l.lock = NewImagesLock()
+	imagePaths, err := readImagePaths(chartPath)
+	if err != nil {
+		return err
+	}
+	err = l.registryConf.ValidateTargets(imagePaths.Registries)
+	if err != nil {
+		return err
+	}
+	l.overrides = imagePaths.Registries
+
+	imagesPath := filepath.Join(chartPath, "images")
+	files, err := ioutil.ReadDir(imagesPath)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read images in [%s]", imagesPath)
+	}
+
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), ".") {
+			continue
+		}
+		err := l.LoadArchive(filepath.Join(imagesPath, file.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return l.lock.Write(chartPath)
+}
+
+// LoadArchive pushes the images in a docker save tarball, or an OCI image layout as a tarball or
+// directory
+func (l *Loader) LoadArchive(archivePath string) error {
+	tmpDir, err := ioutil.TempDir("", "loader-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	images, err := readArchive(archivePath, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	for _, image := range images {
+		for _, reference := range image.references {
+			target := l.targets[l.registryConf.Target(reference.String(), l.overrides)]
+			repository := target.repository(reference.repository)
+			l.logger.Info(fmt.Sprintf("Pushing [%s] as [%s/%s:%s]", reference, target.host, repository, reference.tag))
+			err := l.push(target.client, repository, reference.tag, image.manifest)
+			if err != nil {
+				return errors.Wrapf(err, "Unable to push [%s]", reference)
+			}
+			l.lock.Add(fmt.Sprintf("%s/%s", target.host, repository), reference.tag, image.manifest.digest)
+		}
+	}
+	return nil
+}
+
+// push uploads the blobs and manifests an index references before the index itself, as registries
+// reject manifests referencing content they don't have
+func (l *Loader) push(client *registry.Client, repository string, reference string, node *manifestNode) error {
+	for _, child := range node.children {
+		err := l.push(client, repository, child.digest, child)
+		if err != nil {
+			return err
+		}
+	}
+	for _, b := range node.blobs {
+		err := client.PushBlob(repository, b.Digest, b.Size, b.open)
+		if err != nil {
+			return err
+		}
+	}
+	return client.PutManifest(repository, reference, node.mediaType, node.content)
+}
+
+// readImagePaths reads <chartPath>/images.yaml, which is empty when the chart doesn't have one
+func readImagePaths(chartPath string) (*ImagePaths, error) {
+	content, err := ioutil.ReadFile(filepath.Join(chartPath, ImagePathsFile))
+	if os.IsNotExist(err) {
+		return &ImagePaths{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return ParseImagePaths(content)
+}
@@ -0,0 +1,466 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	. "github.com/cf-platform-eng/kibosh/pkg/docker"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+type tarEntry struct {
+	name     string
+	content  []byte
+	linkname string
+}
+
+func writeTar(path string, gzipped bool, entries []tarEntry) {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if entry.linkname != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.linkname
+			header.Size = 0
+		}
+		Expect(tarWriter.WriteHeader(header)).To(Succeed())
+		_, err := tarWriter.Write(entry.content)
+		Expect(err).To(BeNil())
+	}
+	Expect(tarWriter.Close()).To(Succeed())
+
+	content := buffer.Bytes()
+	if gzipped {
+		gzipped := &bytes.Buffer{}
+		gzipWriter := gzip.NewWriter(gzipped)
+		_, err := gzipWriter.Write(content)
+		Expect(err).To(BeNil())
+		Expect(gzipWriter.Close()).To(Succeed())
+		content = gzipped.Bytes()
+	}
+	Expect(ioutil.WriteFile(path, content, 0644)).To(Succeed())
+}
+
+func sha256Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func layerTar(fileName string, content string) []byte {
+	buffer := &bytes.Buffer{}
+	tarWriter := tar.NewWriter(buffer)
+	Expect(tarWriter.WriteHeader(&tar.Header{Name: fileName, Mode: 0644, Size: int64(len(content))})).To(Succeed())
+	_, err := tarWriter.Write([]byte(content))
+	Expect(err).To(BeNil())
+	Expect(tarWriter.Close()).To(Succeed())
+	return buffer.Bytes()
+}
+
+type pushedManifest struct {
+	MediaType string `json:"mediaType"`
+	Config    struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	} `json:"config"`
+	Layers []struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+		Size      int64  `json:"size"`
+	} `json:"layers"`
+}
+
+var _ = Describe("Loader", func() {
+	var registry *testRegistry
+	var server *httptest.Server
+	var registryConf *config.RegistryConfig
+	var chartPath string
+	var imagesPath string
+	var logger *logrus.Logger
+
+	configContent := []byte(`{"architecture":"amd64","rootfs":{"type":"layers"}}`)
+	var baseLayer []byte
+	var appLayer []byte
+
+	writeDockerSave := func(path string, gzipped bool, repoTags ...string) {
+		manifest, err := json.Marshal([]map[string]interface{}{{
+			"Config":   "abc123.json",
+			"RepoTags": repoTags,
+			"Layers":   []string{"base/layer.tar", "app/layer.tar", "again/layer.tar"},
+		}})
+		Expect(err).To(BeNil())
+		writeTar(path, gzipped, []tarEntry{
+			{name: "abc123.json", content: configContent},
+			{name: "base/layer.tar", content: baseLayer},
+			{name: "app/layer.tar", content: appLayer},
+			{name: "again/layer.tar", linkname: "../base/layer.tar"},
+			{name: "manifest.json", content: manifest},
+		})
+	}
+
+	BeforeEach(func() {
+		baseLayer = layerTar("etc/os-release", "alpine")
+		appLayer = layerTar("app/run.sh", "echo hello")
+
+		registry = newTestRegistry()
+		server = httptest.NewServer(registry)
+		registryConf = &config.RegistryConfig{Server: strings.TrimPrefix(server.URL, "http://"), PlainHTTP: true}
+
+		var err error
+		chartPath, err = ioutil.TempDir("", "chart-")
+		Expect(err).To(BeNil())
+		imagesPath = filepath.Join(chartPath, "images")
+		Expect(os.Mkdir(imagesPath, 0700)).To(Succeed())
+
+		logger = logrus.New()
+		logger.Out = ioutil.Discard
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(chartPath)
+	})
+
+	loadChart := func() error {
+		loader, err := NewLoader(registryConf, nil, logger)
+		Expect(err).To(BeNil())
+		return loader.LoadChart(chartPath)
+	}
+
+	It("requires a registry server", func() {
+		_, err := NewLoader(&config.RegistryConfig{}, nil, logger)
+		Expect(err).NotTo(BeNil())
+	})
+
+	Context("docker save archives", func() {
+		It("pushes images renamed to the private registry", func() {
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tgz"), true, "docker.io/bitnami/mysql:8.0", "mysql:latest")
+
+			Expect(loadChart()).To(Succeed())
+
+			stored, ok := registry.manifest("mysql", "8.0")
+			Expect(ok).To(BeTrue())
+			Expect(stored.mediaType).To(Equal(DockerManifestMediaType))
+			_, ok = registry.manifest("mysql", "latest")
+			Expect(ok).To(BeTrue())
+
+			manifest := pushedManifest{}
+			Expect(json.Unmarshal(stored.content, &manifest)).To(Succeed())
+			Expect(manifest.MediaType).To(Equal(DockerManifestMediaType))
+			Expect(manifest.Config.MediaType).To(Equal(DockerConfigMediaType))
+			Expect(manifest.Config.Digest).To(Equal(sha256Digest(configContent)))
+			Expect(registry.blobs[manifest.Config.Digest]).To(Equal(configContent))
+
+			Expect(manifest.Layers).To(HaveLen(3))
+			Expect(manifest.Layers[2].Digest).To(Equal(manifest.Layers[0].Digest))
+			for i, expected := range [][]byte{baseLayer, appLayer} {
+				Expect(manifest.Layers[i].MediaType).To(Equal(DockerLayerMediaType))
+				compressed := registry.blobs[manifest.Layers[i].Digest]
+				Expect(int64(len(compressed))).To(Equal(manifest.Layers[i].Size))
+
+				gzipReader, err := gzip.NewReader(bytes.NewReader(compressed))
+				Expect(err).To(BeNil())
+				uncompressed, err := ioutil.ReadAll(gzipReader)
+				Expect(err).To(BeNil())
+				Expect(uncompressed).To(Equal(expected))
+			}
+		})
+
+		It("records the digests of pushed images in images.lock", func() {
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+
+			Expect(loadChart()).To(Succeed())
+
+			content, err := ioutil.ReadFile(filepath.Join(chartPath, ImagesLockFile))
+			Expect(err).To(BeNil())
+			lock, err := ParseImagesLock(content)
+			Expect(err).To(BeNil())
+
+			stored, _ := registry.manifest("mysql", "8.0")
+			digest, ok := lock.Digest(registryConf.Server+"/mysql", "8.0")
+			Expect(ok).To(BeTrue())
+			Expect(digest).To(Equal(sha256Digest(stored.content)))
+			Expect(lock.Images).To(HaveLen(1))
+		})
+
+		It("doesn't leave a partial images.lock when it can't be written", func() {
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+			err := os.MkdirAll(filepath.Join(chartPath, ImagesLockFile, "in-the-way"), 0700)
+			Expect(err).To(BeNil())
+
+			Expect(loadChart()).NotTo(Succeed())
+
+			_, err = os.Stat(filepath.Join(chartPath, ImagesLockFile+".tmp"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("pushes under the path of the registry server", func() {
+			registryConf.Server = registryConf.Server + "/library"
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := registry.manifest("library/mysql", "8.0")
+			Expect(ok).To(BeTrue())
+
+			content, err := ioutil.ReadFile(filepath.Join(chartPath, ImagesLockFile))
+			Expect(err).To(BeNil())
+			Expect(string(content)).To(ContainSubstring(registryConf.Server + "/mysql:8.0"))
+		})
+
+		It("skips blobs the registry already has", func() {
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+
+			Expect(loadChart()).To(Succeed())
+			Expect(registry.blobUploads()).To(Equal(3))
+
+			Expect(loadChart()).To(Succeed())
+			Expect(registry.blobUploads()).To(Equal(3))
+		})
+
+		It("skips hidden files", func() {
+			Expect(ioutil.WriteFile(filepath.Join(imagesPath, ".DS_Store"), []byte("junk"), 0644)).To(Succeed())
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+
+			Expect(loadChart()).To(Succeed())
+		})
+
+		It("returns error for images without tags", func() {
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false)
+
+			err := loadChart()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("no tags"))
+		})
+	})
+
+	Context("OCI image layouts", func() {
+		var layoutPath string
+		var layerDigest string
+		var imageManifestDigest string
+
+		writeBlob := func(content []byte) string {
+			digest := sha256Digest(content)
+			Expect(ioutil.WriteFile(filepath.Join(layoutPath, "blobs", "sha256", strings.TrimPrefix(digest, "sha256:")), content, 0644)).To(Succeed())
+			return digest
+		}
+
+		BeforeEach(func() {
+			layoutPath = filepath.Join(imagesPath, "spacebears")
+			Expect(os.MkdirAll(filepath.Join(layoutPath, "blobs", "sha256"), 0700)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(layoutPath, "oci-layout"), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0644)).To(Succeed())
+
+			configDigest := writeBlob(configContent)
+			compressedLayer := &bytes.Buffer{}
+			gzipWriter := gzip.NewWriter(compressedLayer)
+			_, err := gzipWriter.Write(appLayer)
+			Expect(err).To(BeNil())
+			Expect(gzipWriter.Close()).To(Succeed())
+			layerDigest = writeBlob(compressedLayer.Bytes())
+
+			imageManifest, err := json.Marshal(map[string]interface{}{
+				"schemaVersion": 2,
+				"config":        map[string]interface{}{"mediaType": "application/vnd.oci.image.config.v1+json", "digest": configDigest, "size": len(configContent)},
+				"layers":        []map[string]interface{}{{"mediaType": "application/vnd.oci.image.layer.v1.tar+gzip", "digest": layerDigest, "size": compressedLayer.Len()}},
+			})
+			Expect(err).To(BeNil())
+			imageManifestDigest = writeBlob(imageManifest)
+
+			imageIndex, err := json.Marshal(map[string]interface{}{
+				"schemaVersion": 2,
+				"manifests": []map[string]interface{}{{
+					"mediaType": OCIManifestMediaType, "digest": imageManifestDigest, "size": len(imageManifest),
+					"platform": map[string]string{"architecture": "amd64", "os": "linux"},
+				}},
+			})
+			Expect(err).To(BeNil())
+			imageIndexDigest := writeBlob(imageIndex)
+
+			index, err := json.Marshal(map[string]interface{}{
+				"schemaVersion": 2,
+				"manifests": []map[string]interface{}{{
+					"mediaType": OCIIndexMediaType, "digest": imageIndexDigest, "size": len(imageIndex),
+					"annotations": map[string]string{"org.opencontainers.image.ref.name": "1.2.3"},
+				}},
+			})
+			Expect(err).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(layoutPath, "index.json"), index, 0644)).To(Succeed())
+		})
+
+		It("pushes an index and the manifests it references, named after the layout", func() {
+			Expect(loadChart()).To(Succeed())
+
+			stored, ok := registry.manifest("spacebears", "1.2.3")
+			Expect(ok).To(BeTrue())
+			Expect(stored.mediaType).To(Equal(OCIIndexMediaType))
+
+			child, ok := registry.manifest("spacebears", imageManifestDigest)
+			Expect(ok).To(BeTrue())
+			Expect(child.mediaType).To(Equal(OCIManifestMediaType))
+			Expect(registry.hasBlob("spacebears", layerDigest)).To(BeTrue())
+		})
+
+		It("uses the full reference from annotations", func() {
+			index, err := ioutil.ReadFile(filepath.Join(layoutPath, "index.json"))
+			Expect(err).To(BeNil())
+			index = bytes.Replace(index, []byte(`"1.2.3"`), []byte(`"docker.io/cfplatformeng/spacebears:2.0"`), 1)
+			Expect(ioutil.WriteFile(filepath.Join(layoutPath, "index.json"), index, 0644)).To(Succeed())
+
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := registry.manifest("spacebears", "2.0")
+			Expect(ok).To(BeTrue())
+
+			content, err := ioutil.ReadFile(filepath.Join(chartPath, ImagesLockFile))
+			Expect(err).To(BeNil())
+			lock, err := ParseImagesLock(content)
+			Expect(err).To(BeNil())
+			digest, _ := lock.Digest(registryConf.Server+"/spacebears", "2.0")
+			Expect(digest).To(HavePrefix("sha256:"))
+		})
+
+		It("returns error when a referenced blob is missing", func() {
+			Expect(os.Remove(filepath.Join(layoutPath, "blobs", "sha256", strings.TrimPrefix(layerDigest, "sha256:")))).To(Succeed())
+
+			Expect(loadChart()).NotTo(Succeed())
+		})
+	})
+
+	Context("authentication", func() {
+		BeforeEach(func() {
+			registry.username = "bob"
+			registry.password = "monkey123"
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+		})
+
+		It("uses basic auth", func() {
+			registryConf.User = "bob"
+			registryConf.Pass = "monkey123"
+
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := registry.manifest("mysql", "8.0")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("fetches a bearer token when challenged", func() {
+			registry.token = "abc"
+			registryConf.User = "bob"
+			registryConf.Pass = "monkey123"
+
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := registry.manifest("mysql", "8.0")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("returns error with wrong credentials", func() {
+			registryConf.User = "bob"
+			registryConf.Pass = "wrong"
+
+			err := loadChart()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("401"))
+		})
+	})
+
+	Context("multiple registries", func() {
+		var vendorRegistry *testRegistry
+		var vendorServer *httptest.Server
+		var vendorHost string
+
+		BeforeEach(func() {
+			vendorRegistry = newTestRegistry()
+			vendorRegistry.username = "robot"
+			vendorRegistry.password = "abc"
+			vendorServer = httptest.NewServer(vendorRegistry)
+			vendorHost = strings.TrimPrefix(vendorServer.URL, "http://")
+			registryConf.Registries = config.Registries{{
+				Server: vendorHost + "/vendor", User: "robot", Pass: "abc", Match: []string{"quay.io/vendor"},
+			}}
+
+			writeDockerSave(filepath.Join(imagesPath, "mysql.tar"), false, "bitnami/mysql:8.0")
+			writeDockerSave(filepath.Join(imagesPath, "app.tar"), false, "quay.io/vendor/app:1.0")
+		})
+
+		AfterEach(func() {
+			vendorServer.Close()
+		})
+
+		It("pushes each image to the registry it matches", func() {
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := registry.manifest("mysql", "8.0")
+			Expect(ok).To(BeTrue())
+			_, ok = registry.manifest("app", "1.0")
+			Expect(ok).To(BeFalse())
+			_, ok = vendorRegistry.manifest("vendor/app", "1.0")
+			Expect(ok).To(BeTrue())
+
+			content, err := ioutil.ReadFile(filepath.Join(chartPath, ImagesLockFile))
+			Expect(err).To(BeNil())
+			lock, err := ParseImagesLock(content)
+			Expect(err).To(BeNil())
+			_, ok = lock.Digest(vendorHost+"/vendor/app", "1.0")
+			Expect(ok).To(BeTrue())
+		})
+
+		It("follows the registries in the chart's images.yaml", func() {
+			Expect(ioutil.WriteFile(filepath.Join(chartPath, ImagePathsFile), []byte(`
+registries:
+  bitnami: `+vendorHost+`/vendor
+`), 0644)).To(Succeed())
+
+			Expect(loadChart()).To(Succeed())
+
+			_, ok := vendorRegistry.manifest("vendor/mysql", "8.0")
+			Expect(ok).To(BeTrue())
+			_, ok = registry.manifest("mysql", "8.0")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("returns error when images.yaml maps to a registry that isn't configured", func() {
+			Expect(ioutil.WriteFile(filepath.Join(chartPath, ImagePathsFile), []byte(`
+registries:
+  bitnami: other.example.com
+`), 0644)).To(Succeed())
+
+			err := loadChart()
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("other.example.com"))
+		})
+	})
+
+	It("returns error for files that aren't image archives", func() {
+		Expect(ioutil.WriteFile(filepath.Join(imagesPath, "notes.txt"), []byte("not an image"), 0644)).To(Succeed())
+
+		Expect(loadChart()).NotTo(Succeed())
+	})
+})
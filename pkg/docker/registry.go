@@ -0,0 +1,70 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/registry"
+)
+
+// splitRegistryServer splits a registry server setting like "harbor.example.com/library" into the
+// registry host and the path images are kept under
+func splitRegistryServer(server string) (string, string) {
+	host := strings.TrimSuffix(server, "/")
+	if i := strings.Index(host, "/"); i > 0 {
+		return host[:i], host[i+1:]
+	}
+	return host, ""
+}
+
+// registryTarget is a private registry images are pushed to or checked in. Its server can include a
+// path (eg "harbor.example.com/library"), which is split into host and pathPrefix.
+type registryTarget struct {
+	server     string
+	host       string
+	pathPrefix string
+	client     *registry.Client
+}
+
+// newRegistryTargets connects to every registry in registryConf, keyed by server
+func newRegistryTargets(registryConf *config.RegistryConfig, httpClient *http.Client) map[string]*registryTarget {
+	targets := map[string]*registryTarget{}
+	for _, reg := range registryConf.AllRegistries() {
+		host, pathPrefix := splitRegistryServer(reg.Server)
+		targets[reg.Server] = &registryTarget{
+			server:     reg.Server,
+			host:       host,
+			pathPrefix: pathPrefix,
+			client:     registry.NewClient(registry.URL(host, registryConf.PlainHTTP), reg.User, reg.Pass, httpClient),
+		}
+	}
+	return targets
+}
+
+// repository keeps the last path segment of the source repository under the path prefix, like
+// MyChart.OverrideImageSources
+func (t *registryTarget) repository(sourceRepository string) string {
+	split := strings.Split(sourceRepository, "/")
+	name := split[len(split)-1]
+	if t.pathPrefix == "" {
+		return name
+	}
+	return fmt.Sprintf("%s/%s", t.pathPrefix, name)
+}
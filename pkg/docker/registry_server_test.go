@@ -0,0 +1,214 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package docker_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	blobPathRegex     = regexp.MustCompile(`^/v2/(.+)/blobs/(sha256:[0-9a-f]{64})$`)
+	uploadStartRegex  = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/$`)
+	uploadPathRegex   = regexp.MustCompile(`^/v2/(.+)/blobs/uploads/([0-9]+)$`)
+	manifestPathRegex = regexp.MustCompile(`^/v2/(.+)/manifests/([^/]+)$`)
+)
+
+type storedManifest struct {
+	mediaType string
+	content   []byte
+}
+
+// testRegistry is an in-process registry implementing the part of the v2 API the loader uses.
+// Blobs are shared across repositories, mounted into a repository when uploaded to it.
+type testRegistry struct {
+	sync.Mutex
+	blobs     map[string][]byte
+	repoBlobs map[string]map[string]bool
+	manifests map[string]storedManifest
+	uploads   map[string]string
+	nextID    int
+	requests  []string
+
+	username string
+	password string
+	token    string
+}
+
+func newTestRegistry() *testRegistry {
+	return &testRegistry{
+		blobs:     map[string][]byte{},
+		repoBlobs: map[string]map[string]bool{},
+		manifests: map[string]storedManifest{},
+		uploads:   map[string]string{},
+	}
+}
+
+func (t *testRegistry) hasBlob(repository string, digest string) bool {
+	t.Lock()
+	defer t.Unlock()
+	return t.repoBlobs[repository][digest]
+}
+
+func (t *testRegistry) manifest(repository string, reference string) (storedManifest, bool) {
+	t.Lock()
+	defer t.Unlock()
+	manifest, ok := t.manifests[repository+":"+reference]
+	return manifest, ok
+}
+
+func (t *testRegistry) blobUploads() int {
+	t.Lock()
+	defer t.Unlock()
+	count := 0
+	for _, request := range t.requests {
+		if strings.HasPrefix(request, "PUT ") && strings.Contains(request, "/blobs/uploads/") {
+			count++
+		}
+	}
+	return count
+}
+
+func (t *testRegistry) authorized(r *http.Request) bool {
+	if t.token != "" {
+		return r.Header.Get("Authorization") == "Bearer "+t.token
+	}
+	if t.username != "" {
+		username, password, ok := r.BasicAuth()
+		return ok && username == t.username && password == t.password
+	}
+	return true
+}
+
+func (t *testRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.Lock()
+	defer t.Unlock()
+	t.requests = append(t.requests, r.Method+" "+r.URL.Path)
+
+	if r.URL.Path == "/token" {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != t.username || password != t.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(fmt.Sprintf(`{"token": "%s"}`, t.token)))
+		return
+	}
+	if !t.authorized(r) {
+		if t.token != "" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test",scope="repository:pushed:pull,push"`, r.Host))
+		} else {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if match := blobPathRegex.FindStringSubmatch(r.URL.Path); match != nil && r.Method == "HEAD" {
+		if !t.repoBlobs[match[1]][match[2]] {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", len(t.blobs[match[2]])))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if match := uploadStartRegex.FindStringSubmatch(r.URL.Path); match != nil && r.Method == "POST" {
+		t.nextID++
+		id := fmt.Sprintf("%d", t.nextID)
+		t.uploads[id] = match[1]
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s?_state=abc", match[1], id))
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+
+	if match := uploadPathRegex.FindStringSubmatch(r.URL.Path); match != nil && r.Method == "PUT" {
+		if t.uploads[match[2]] != match[1] || r.URL.Query().Get("_state") != "abc" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		sum := sha256.Sum256(content)
+		digest := "sha256:" + hex.EncodeToString(sum[:])
+		if digest != r.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("DIGEST_INVALID"))
+			return
+		}
+		t.storeBlob(match[1], digest, content)
+		delete(t.uploads, match[2])
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if match := manifestPathRegex.FindStringSubmatch(r.URL.Path); match != nil && r.Method == "HEAD" {
+		manifest, ok := t.manifests[match[1]+":"+match[2]]
+		if !ok || !strings.Contains(strings.Join(r.Header["Accept"], ","), manifest.mediaType) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifest.mediaType)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if match := manifestPathRegex.FindStringSubmatch(r.URL.Path); match != nil && r.Method == "PUT" {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		for _, digest := range regexp.MustCompile(`sha256:[0-9a-f]{64}`).FindAllString(string(content), -1) {
+			if !t.repoBlobs[match[1]][digest] && !t.hasManifestDigest(match[1], digest) {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("MANIFEST_BLOB_UNKNOWN " + digest))
+				return
+			}
+		}
+		manifest := storedManifest{mediaType: r.Header.Get("Content-Type"), content: content}
+		sum := sha256.Sum256(content)
+		t.manifests[match[1]+":"+match[2]] = manifest
+		t.manifests[match[1]+":sha256:"+hex.EncodeToString(sum[:])] = manifest
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (t *testRegistry) storeBlob(repository string, digest string, content []byte) {
+	t.blobs[digest] = content
+	if t.repoBlobs[repository] == nil {
+		t.repoBlobs[repository] = map[string]bool{}
+	}
+	t.repoBlobs[repository][digest] = true
+}
+
+func (t *testRegistry) hasManifestDigest(repository string, digest string) bool {
+	_, ok := t.manifests[repository+":"+digest]
+	return ok
+}
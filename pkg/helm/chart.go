@@ -28,6 +28,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -37,6 +39,8 @@ import (
 	"k8s.io/helm/pkg/proto/hapi/chart"
 )
 
+var planNameRegex = regexp.MustCompile(`^[0-9a-z.\-]+$`)
+
 type MyChart struct {
 	chart.Chart
 
@@ -45,6 +49,10 @@ type MyChart struct {
 	BindTemplate          string          `json:"bindTemplate"`
 	Plans                 map[string]Plan `json:"plans"`
 	ChartPath             string          `json:"chartPath"`
+
+	registryConfig *config.RegistryConfig
+	imagePaths     *docker.ImagePaths
+	imagesLock     *docker.ImagesLock
 }
 
 type Bind struct {
@@ -90,7 +98,7 @@ func LoadFromDir(dir string, log *logrus.Logger) ([]*MyChart, error) {
 	charts := []*MyChart{}
 	for _, source := range sources {
 		chartPath := path.Join(dir, source.Name())
-		c, err := NewChart(chartPath, "", log)
+		c, err := NewChart(chartPath, nil, log)
 		if err != nil {
 			log.Debug(fmt.Sprintf("The file [%s] not failed to load as a chart", chartPath), err)
 		} else {
@@ -101,9 +109,15 @@ func LoadFromDir(dir string, log *logrus.Logger) ([]*MyChart, error) {
 	return charts, nil
 }
 
-func NewChart(chartPath string, privateRegistryServer string, log *logrus.Logger) (*MyChart, error) {
+// NewChart rewrites each image to the registry registryConfig maps it to, with the chart's own
+// mapping in images.yaml taking precedence. A nil registryConfig leaves images as they are.
+func NewChart(chartPath string, registryConfig *config.RegistryConfig, log *logrus.Logger) (*MyChart, error) {
+	if registryConfig == nil {
+		registryConfig = &config.RegistryConfig{}
+	}
 	myChart := &MyChart{
-		PrivateRegistryServer: privateRegistryServer,
+		PrivateRegistryServer: registryConfig.Server,
+		registryConfig:        registryConfig,
 	}
 
 	chartPathStat, err := os.Stat(chartPath)
@@ -154,22 +168,13 @@ func NewChart(chartPath string, privateRegistryServer string, log *logrus.Logger
 }
 
 func (c *MyChart) LoadChartValues() error {
-	baseVals := map[string]interface{}{}
-	if c.Chart.Values == nil {
-		return errors.New("values.yaml is requires")
-	}
-	err := yaml.Unmarshal([]byte(c.Chart.Values.Raw), &baseVals)
+	baseVals, err := c.parseValues()
 	if err != nil {
 		return err
 	}
-
-	var transformed = baseVals
-	if c.PrivateRegistryServer != "" {
-		transformed, err = c.OverrideImageSources(transformed)
-		if err != nil {
-			return err
-		}
-		transformed = c.EnsureGlobalImageRegistry(transformed)
+	transformed, err := c.transformValues(baseVals)
+	if err != nil {
+		return err
 	}
 
 	finalVals, err := yaml.Marshal(transformed)
@@ -182,22 +187,79 @@ func (c *MyChart) LoadChartValues() error {
 	return nil
 }
 
+// parseValues reads the chart's values.yaml, which every chart needs
+func (c *MyChart) parseValues() (map[string]interface{}, error) {
+	baseVals := map[string]interface{}{}
+	if c.Chart.Values == nil {
+		return nil, errors.New("values.yaml is requires")
+	}
+	err := yaml.Unmarshal([]byte(c.Chart.Values.Raw), &baseVals)
+	if err != nil {
+		return nil, err
+	}
+	return baseVals, nil
+}
+
+// transformValues rewrites the images in vals to the private registries and pins them to the
+// digests the loader recorded. vals are left as they are when there's no private registry.
+func (c *MyChart) transformValues(vals map[string]interface{}) (map[string]interface{}, error) {
+	if c.PrivateRegistryServer == "" {
+		return vals, nil
+	}
+
+	var err error
+	c.imagesLock, err = c.loadImagesLock()
+	if err != nil {
+		return nil, err
+	}
+	c.imagePaths, err = c.loadImagePaths()
+	if err != nil {
+		return nil, err
+	}
+	err = c.registries().ValidateTargets(c.imagePaths.Registries)
+	if err != nil {
+		return nil, err
+	}
+	rewritten, err := c.overrideImagePaths(vals)
+	if err != nil {
+		return nil, err
+	}
+	transformed, err := c.overrideImageSources(vals, "", rewritten)
+	if err != nil {
+		return nil, err
+	}
+	transformed = c.EnsureGlobalImageRegistry(transformed)
+	err = c.pinImageDigests(transformed)
+	if err != nil {
+		return nil, err
+	}
+	return transformed, nil
+}
+
 func (c *MyChart) OverrideImageSources(rawVals map[string]interface{}) (map[string]interface{}, error) {
+	return c.overrideImageSources(rawVals, "", map[string]bool{})
+}
+
+// overrideImageSources rewrites image, images.<name> and global.imageRegistry, at prefix in the
+// values, leaving the value paths in rewritten alone: those were already rewritten from images.yaml
+// or discovery, and rewriting them again would resolve their registry from the private name
+func (c *MyChart) overrideImageSources(rawVals map[string]interface{}, prefix string, rewritten map[string]bool) (map[string]interface{}, error) {
 	transformedVals := map[string]interface{}{}
 	for key, val := range rawVals {
-		if key == "image" {
+		if rewritten[prefix+key] {
+			transformedVals[key] = val
+		} else if key == "image" {
 			stringVal, ok := val.(string)
 			if ok {
-				split := strings.Split(stringVal, "/")
-				transformedVals[key] = fmt.Sprintf("%s/%s", c.PrivateRegistryServer, split[len(split)-1])
+				transformedVals[key] = c.privateImageName(stringVal)
 			} else {
 				imageMap, castOk := rawVals["image"].(map[string]interface{})
 				if !castOk {
 					return nil, errors.New("image key didn't match expected structure")
 				}
-				_, foundInMap := imageMap["registry"]
+				registry, foundInMap := imageMap["registry"]
 				if foundInMap {
-					imageMap["registry"] = c.PrivateRegistryServer
+					imageMap["registry"] = c.targetRegistry(fmt.Sprintf("%v/%v", registry, imageMap["repository"]))
 				}
 
 				transformedVals["image"] = rawVals["image"]
@@ -215,7 +277,10 @@ func (c *MyChart) OverrideImageSources(rawVals map[string]interface{}) (map[stri
 			}
 
 			for imageName, imageDefMap := range imageMap {
-				transformedImage, err := c.OverrideImageSources(imageDefMap)
+				if rewritten[prefix+"images."+imageName] {
+					continue
+				}
+				transformedImage, err := c.overrideImageSources(imageDefMap, prefix+"images."+imageName+".", rewritten)
 				if err != nil {
 					return nil, err
 				}
@@ -228,13 +293,12 @@ func (c *MyChart) OverrideImageSources(rawVals map[string]interface{}) (map[stri
 				return nil, errors.New("image key didn't match expected structure")
 			}
 
-			if globalVal, ok := globalMap["imageRegistry"]; ok {
+			if globalVal, ok := globalMap["imageRegistry"]; ok && !rewritten[prefix+"global.imageRegistry"] {
 				stringVal, ok := globalVal.(string)
 				if !ok {
 					return nil, errors.New("'imageRegistry' key value is not a string, vals structure is incorrect")
 				}
-				split := strings.Split(stringVal, "/")
-				globalMap["imageRegistry"] = fmt.Sprintf("%s/%s", c.PrivateRegistryServer, split[len(split)-1])
+				globalMap["imageRegistry"] = c.privateImageName(stringVal)
 				transformedVals["global"] = globalMap
 			} else {
 				transformedVals[key] = val
@@ -246,6 +310,199 @@ func (c *MyChart) OverrideImageSources(rawVals map[string]interface{}) (map[stri
 	return transformedVals, nil
 }
 
+// privateImageName keeps the last path segment of image, under the private registry it maps to
+func (c *MyChart) privateImageName(image string) string {
+	split := strings.Split(image, "/")
+	return fmt.Sprintf("%s/%s", c.targetRegistry(image), split[len(split)-1])
+}
+
+// targetRegistry is the server of the private registry the image named source is rewritten to
+func (c *MyChart) targetRegistry(source string) string {
+	var overrides map[string]string
+	if c.imagePaths != nil {
+		overrides = c.imagePaths.Registries
+	}
+	return c.registries().Target(source, overrides)
+}
+
+// registries is the registry config the chart was loaded with, or only the private registry server
+// for charts that weren't
+func (c *MyChart) registries() *config.RegistryConfig {
+	if c.registryConfig == nil {
+		return &config.RegistryConfig{Server: c.PrivateRegistryServer}
+	}
+	return c.registryConfig
+}
+
+// overrideImagePaths rewrites the images at the value paths listed in the chart's images.yaml, and
+// the ones found walking the values when it enables discovery. An image map with a registry gets the
+// private registry, like a top level image map, otherwise its repository is renamed like an image string.
+// It returns the value paths it rewrote.
+func (c *MyChart) overrideImagePaths(vals map[string]interface{}) (map[string]bool, error) {
+	images, err := c.imagePaths.Find(vals)
+	if err != nil {
+		return nil, err
+	}
+
+	rewritten := map[string]bool{}
+	for _, image := range images {
+		rewritten[image.Path] = true
+		switch value := image.Value().(type) {
+		case string:
+			image.Parent[image.Key] = c.privateImageName(value)
+		case map[string]interface{}:
+			if registry, ok := value["registry"]; ok {
+				value["registry"] = c.targetRegistry(fmt.Sprintf("%v/%v", registry, value["repository"]))
+			} else {
+				value["repository"] = c.privateImageName(value["repository"].(string))
+			}
+		}
+	}
+	return rewritten, nil
+}
+
+// ImageReferences lists the images a plan deploys, from the transformed values merged with the
+// plan's values. An unknown plan lists the images in the transformed values only.
+func (c *MyChart) ImageReferences(planName string) ([]string, error) {
+	valueBytes := c.TransformedValues
+	if plan, ok := c.Plans[planName]; ok {
+		var err error
+		valueBytes, err = MergeValueBytes(c.TransformedValues, plan.Values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	values := map[string]interface{}{}
+	err := yaml.Unmarshal(valueBytes, &values)
+	if err != nil {
+		return nil, err
+	}
+
+	var imagePaths *docker.ImagePaths
+	if content, ok := c.chartFile(docker.ImagePathsFile); ok {
+		imagePaths, err = docker.ParseImagePaths(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return docker.ImageReferences(values, imagePaths)
+}
+
+// loadImagePaths reads the chart's images.yaml, which is empty when the chart doesn't have one
+func (c *MyChart) loadImagePaths() (*docker.ImagePaths, error) {
+	content, ok := c.chartFile(docker.ImagePathsFile)
+	if !ok {
+		return &docker.ImagePaths{}, nil
+	}
+	return docker.ParseImagePaths(content)
+}
+
+// loadImagesLock reads the digests the loader recorded when it pushed the chart's images, which is
+// nil when the chart has no lock file
+func (c *MyChart) loadImagesLock() (*docker.ImagesLock, error) {
+	content, ok := c.chartFile(docker.ImagesLockFile)
+	if !ok {
+		return nil, nil
+	}
+	return docker.ParseImagesLock(content)
+}
+
+func (c *MyChart) chartFile(name string) ([]byte, bool) {
+	for _, file := range c.Chart.Files {
+		if file.TypeUrl == name {
+			return file.Value, true
+		}
+	}
+	return nil, false
+}
+
+// pinImageDigests pins every image the loader pushed to the digest it recorded, where the values
+// can take one: image names with their tag, at any depth and at the paths in images.yaml, become
+// "<repo>@<digest>", and images kept apart from their tag get the digest in their digest key when
+// the chart has one. A tag is never replaced by a digest, as charts also use tags in labels and names.
+func (c *MyChart) pinImageDigests(vals map[string]interface{}) error {
+	if c.imagesLock == nil {
+		return nil
+	}
+	c.pinImageMaps(vals)
+
+	images, err := c.imagePaths.Find(vals)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if name, ok := image.Value().(string); ok {
+			image.Parent[image.Key] = c.pinnedImageName(name)
+		}
+	}
+	return nil
+}
+
+func (c *MyChart) pinImageMaps(value interface{}) {
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		if image, ok := typed["image"].(string); ok {
+			tag := typed["imageTag"]
+			if tag == nil {
+				tag = typed["tag"]
+			}
+			if tag == nil {
+				typed["image"] = c.pinnedImageName(image)
+			} else {
+				c.pinDigestKey(typed, image, tag)
+			}
+		}
+		if repository, ok := typed["repository"].(string); ok && typed["tag"] != nil {
+			if registry, ok := typed["registry"].(string); ok && registry != "" {
+				repository = fmt.Sprintf("%s/%s", registry, lastPathSegment(repository))
+			}
+			c.pinDigestKey(typed, repository, typed["tag"])
+		}
+		for _, child := range typed {
+			c.pinImageMaps(child)
+		}
+	case map[string]map[string]interface{}:
+		// images, as rewritten by OverrideImageSources
+		for _, child := range typed {
+			c.pinImageMaps(child)
+		}
+	case []interface{}:
+		for _, child := range typed {
+			c.pinImageMaps(child)
+		}
+	}
+}
+
+// pinDigestKey sets the digest key of an image kept apart from its tag, for charts that declare
+// one (eg bitnami's image.digest). image is the name the loader pushed the image as.
+func (c *MyChart) pinDigestKey(vals map[string]interface{}, image string, tag interface{}) {
+	if _, ok := vals["digest"]; !ok || strings.Contains(image, "@") {
+		return
+	}
+	digest, ok := c.imagesLock.Digest(image, fmt.Sprintf("%v", tag))
+	if ok {
+		vals["digest"] = digest
+	}
+}
+
+// pinnedImageName pins an image named with its tag, "<repo>:<tag>" becoming "<repo>@<digest>"
+func (c *MyChart) pinnedImageName(name string) string {
+	i := strings.LastIndex(name, ":")
+	if strings.Contains(name, "@") || i < 0 || strings.Contains(name[i:], "/") {
+		return name
+	}
+	digest, ok := c.imagesLock.Digest(name[:i], name[i+1:])
+	if !ok {
+		return name
+	}
+	return fmt.Sprintf("%s@%s", name[:i], digest)
+}
+
+func lastPathSegment(name string) string {
+	split := strings.Split(name, "/")
+	return split[len(split)-1]
+}
+
 func (c *MyChart) EnsureGlobalImageRegistry(rawVals map[string]interface{}) map[string]interface{} {
 	_, foundInMap := rawVals["global"]
 	if !foundInMap {
@@ -332,96 +589,144 @@ func (c *MyChart) loadOSBAPIMetadataFromArchive(chartPath string, log *logrus.Lo
 }
 
 func (c *MyChart) loadOSBAPIMetadataFromDirectory(chartPath string, log *logrus.Logger) error {
+	_, bind, err := loadBindFile(chartPath)
+	if err != nil {
+		return err
+	}
+	if bind != nil {
+		c.BindTemplate = bind.Template
+	}
+
+	plansPath, plans, err := loadPlansFile(chartPath)
+	if err != nil {
+		return err
+	}
+	if plansPath == "" {
+		log.Info(fmt.Sprintf("No plan file found in path %s, creating default plan", chartPath))
+		c.Plans = map[string]Plan{}
+		return nil
+	}
+
+	return c.loadPlans(filepath.Join(chartPath, "plans"), plans)
+}
+
+// loadBindFile reads bind.yaml, or bind.yml, in chartPath, returning the path it was read from.
+// The path is "" and the bind nil when the chart has neither.
+func loadBindFile(chartPath string) (string, *Bind, error) {
 	bindTemplatePath := path.Join(chartPath, "bind.yaml")
 	_, err := os.Stat(bindTemplatePath)
 	if err != nil {
 		bindTemplatePath = path.Join(chartPath, "bind.yml")
 		_, err := os.Stat(bindTemplatePath)
 		if err != nil {
-			bindTemplatePath = ""
+			return "", nil, nil
 		}
 	}
 
-	if bindTemplatePath != "" {
-		bindTemplateBytes, err := ioutil.ReadFile(bindTemplatePath)
-		if err != nil {
-			return err
-		}
-
-		bind := &Bind{}
-		err = yaml.Unmarshal(bindTemplateBytes, bind)
-		if err != nil {
-			return err
-		}
+	bindTemplateBytes, err := ioutil.ReadFile(bindTemplatePath)
+	if err != nil {
+		return bindTemplatePath, nil, err
+	}
 
-		c.BindTemplate = bind.Template
+	bind := &Bind{}
+	err = yaml.Unmarshal(bindTemplateBytes, bind)
+	if err != nil {
+		return bindTemplatePath, nil, err
 	}
+	return bindTemplatePath, bind, nil
+}
 
+// loadPlansFile reads the plan definitions in plans.yaml, or plans.yml, in chartPath, returning
+// the path they were read from. The path is "" when the chart has neither.
+func loadPlansFile(chartPath string) (string, []Plan, error) {
 	plansPath := path.Join(chartPath, "plans.yaml")
-	_, err = os.Stat(plansPath)
+	_, err := os.Stat(plansPath)
 	if err != nil {
 		plansPath = path.Join(chartPath, "plans.yml")
 		_, err := os.Stat(plansPath)
 		if err != nil {
 			_, ok := err.(*os.PathError)
 			if ok {
-				log.Info(fmt.Sprintf("No plan file found in path %s, creating default plan", chartPath))
-				c.Plans = map[string]Plan{}
-				return nil
+				return "", nil, nil
 			} else {
-				return err
+				return "", nil, err
 			}
 		}
 	}
 
 	plansBytes, err := ioutil.ReadFile(plansPath)
 	if err != nil {
-		return err
+		return plansPath, nil, err
 	}
 
 	plans := []Plan{}
 	err = yaml.Unmarshal(plansBytes, &plans)
 	if err != nil {
-		return err
+		return plansPath, nil, err
 	}
+	return plansPath, plans, nil
+}
 
-	return c.loadPlans(filepath.Join(chartPath, "plans"), plans)
+// LoadPlans reads and validates plan definitions against the plan files in plansPath, the same way
+// plans are loaded with a chart
+func LoadPlans(plansPath string, plans []Plan) (map[string]Plan, error) {
+	c := &MyChart{}
+	err := c.loadPlans(plansPath, plans)
+	if err != nil {
+		return nil, err
+	}
+	return c.Plans, nil
 }
 
 func (c *MyChart) loadPlans(plansPath string, plans []Plan) error {
 	c.Plans = map[string]Plan{}
 
 	for _, p := range plans {
-		planValues, err := ioutil.ReadFile(filepath.Join(plansPath, p.File))
+		err := c.loadPlan(plansPath, &p)
 		if err != nil {
 			return err
 		}
-		p.Values = planValues
-
-		c.SetPlanDefaultValues(&p)
-		match, err := regexp.MatchString(`^[0-9a-z.\-]+$`, p.Name)
+		err = loadPlanCredentials(plansPath, &p)
 		if err != nil {
 			return err
 		}
-		if !match {
-			return errors.New(fmt.Sprintf("Name [%s] contains invalid characters", p.Name))
-		}
 
-		if p.CredentialsPath != "" {
-			loader := &clientcmd.ClientConfigLoadingRules{
-				ExplicitPath: filepath.Join(plansPath, p.CredentialsPath),
-			}
-			loadedConfig, err := loader.Load()
-			if err != nil {
-				return err
-			}
+		c.Plans[p.Name] = p
+	}
 
-			p.ClusterConfig = loadedConfig
-		}
+	return nil
+}
 
-		c.Plans[p.Name] = p
+// loadPlan reads the values of a plan from its file in plansPath and checks its name
+func (c *MyChart) loadPlan(plansPath string, p *Plan) error {
+	planValues, err := ioutil.ReadFile(filepath.Join(plansPath, p.File))
+	if err != nil {
+		return err
+	}
+	p.Values = planValues
+
+	c.SetPlanDefaultValues(p)
+	if !planNameRegex.MatchString(p.Name) {
+		return errors.New(fmt.Sprintf("Name [%s] contains invalid characters", p.Name))
+	}
+	return nil
+}
+
+// loadPlanCredentials loads the cluster a plan deploys to from its credentials file in plansPath,
+// when it has one
+func loadPlanCredentials(plansPath string, p *Plan) error {
+	if p.CredentialsPath == "" {
+		return nil
+	}
+	loader := &clientcmd.ClientConfigLoadingRules{
+		ExplicitPath: filepath.Join(plansPath, p.CredentialsPath),
+	}
+	loadedConfig, err := loader.Load()
+	if err != nil {
+		return err
 	}
 
+	p.ClusterConfig = loadedConfig
 	return nil
 }
 
@@ -23,6 +23,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/test"
 	"github.com/ghodss/yaml"
@@ -53,7 +54,7 @@ var _ = Describe("Broker", func() {
 	})
 
 	It("should load chart", func() {
-		chart, err := helm.NewChart(chartPath, "", nil)
+		chart, err := helm.NewChart(chartPath, nil, nil)
 
 		Expect(err).To(BeNil())
 		Expect(chart).NotTo(BeNil())
@@ -68,7 +69,7 @@ var _ = Describe("Broker", func() {
 		testChart = test.DefaultChart()
 		err = testChart.WriteChartYML(chartPath)
 		Expect(err).To(BeNil())
-		chart, err := helm.NewChart(chartPath, "", nil)
+		chart, err := helm.NewChart(chartPath, nil, nil)
 
 		Expect(err).To(BeNil())
 		Expect(chart).NotTo(BeNil())
@@ -78,7 +79,7 @@ var _ = Describe("Broker", func() {
 	})
 
 	It("should load chart default values.yaml", func() {
-		chart, err := helm.NewChart(chartPath, "", nil)
+		chart, err := helm.NewChart(chartPath, nil, nil)
 		Expect(err).To(BeNil())
 
 		values := map[string]interface{}{}
@@ -93,7 +94,7 @@ var _ = Describe("Broker", func() {
 		err := os.Remove(filepath.Join(chartPath, "plans.yaml"))
 		Expect(err).To(BeNil())
 
-		chart, err := helm.NewChart(chartPath, "", logger)
+		chart, err := helm.NewChart(chartPath, nil, logger)
 
 		Expect(err).To(BeNil())
 
@@ -107,7 +108,7 @@ var _ = Describe("Broker", func() {
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			myChart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			myChart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			serialized, err := json.Marshal(myChart)
 			Expect(err).To(BeNil())
@@ -136,7 +137,7 @@ var _ = Describe("Broker", func() {
 			err := ioutil.WriteFile(path.Join(chartPath, "bind.yaml"), []byte(bindTemplate), 0666)
 			Expect(err).To(BeNil())
 
-			chartToSave, err := helm.NewChart(chartPath, "", logger)
+			chartToSave, err := helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			chartArchiveDirPath, err := ioutil.TempDir("", "chartarcive-")
@@ -145,7 +146,7 @@ var _ = Describe("Broker", func() {
 			chartArchivePath, err := chartutil.Save(&chartToSave.Chart, chartArchiveDirPath)
 			Expect(err).To(BeNil())
 
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 			Expect(err).To(BeNil())
 
 			Expect(loadedChart.BindTemplate).To(Equal("{hostname: $.services[0].status.loadBalancer.ingress[0].ip}"))
@@ -157,7 +158,7 @@ var _ = Describe("Broker", func() {
 			err := ioutil.WriteFile(path.Join(chartPath, "bind.yaml"), []byte(bindTemplate), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 			Expect(err.Error()).To(ContainSubstring("yaml"))
 		})
 
@@ -167,7 +168,7 @@ var _ = Describe("Broker", func() {
 			err := ioutil.WriteFile(path.Join(chartPath, "bind.yaml"), []byte(bindTemplate), 0666)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "", nil)
+			chart, err := helm.NewChart(chartPath, nil, nil)
 
 			Expect(chart.BindTemplate).To(Equal("{hostname: $.services[0].status.loadBalancer.ingress[0].ip}"))
 		})
@@ -178,7 +179,7 @@ var _ = Describe("Broker", func() {
 			err := ioutil.WriteFile(path.Join(chartPath, "bind.yaml"), []byte(bindTemplate), 0666)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "", nil)
+			chart, err := helm.NewChart(chartPath, nil, nil)
 
 			Expect(chart.BindTemplate).To(Equal("{hostname: $.services[0].status.loadBalancer.ingress[0].ip}"))
 		})
@@ -187,7 +188,7 @@ var _ = Describe("Broker", func() {
 	Context("archived chart (tgz)", func() {
 		var chartArchivePath string
 		BeforeEach(func() {
-			chartToSave, err := helm.NewChart(chartPath, "", logger)
+			chartToSave, err := helm.NewChart(chartPath, nil, logger)
 
 			chartArchiveDirPath, err := ioutil.TempDir("", "chartarcive-")
 			Expect(err).To(BeNil())
@@ -197,7 +198,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("should load chart tgz", func() {
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 
 			Expect(err).To(BeNil())
 			Expect(loadedChart).NotTo(BeNil())
@@ -205,7 +206,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("should load values in chart tgz", func() {
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 
 			values := map[string]interface{}{}
 			err = yaml.Unmarshal(loadedChart.TransformedValues, &values)
@@ -216,7 +217,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("loads plans", func() {
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 
 			Expect(err).To(BeNil())
 
@@ -245,7 +246,7 @@ var _ = Describe("Broker", func() {
 			err := os.Remove(filepath.Join(chartPath, "plans.yaml"))
 			err = os.RemoveAll(filepath.Join(chartPath, "plans"))
 
-			chartToSave, err := helm.NewChart(chartPath, "", logger)
+			chartToSave, err := helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			chartArchiveDirPath, err := ioutil.TempDir("", "chartarcive-")
@@ -254,7 +255,7 @@ var _ = Describe("Broker", func() {
 			chartArchivePath, err = chartutil.Save(&chartToSave.Chart, chartArchiveDirPath)
 			Expect(err).To(BeNil())
 
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 
 			Expect(err).To(BeNil())
 			Expect(loadedChart).NotTo(BeNil())
@@ -273,7 +274,7 @@ var _ = Describe("Broker", func() {
 			testChart = test.DefaultChart()
 			err = testChart.WriteChartYML(chartPath)
 
-			chartToSave, err := helm.NewChart(chartPath, "", logger)
+			chartToSave, err := helm.NewChart(chartPath, nil, logger)
 
 			chartArchiveDirPath, err := ioutil.TempDir("", "chartarcive-")
 			Expect(err).To(BeNil())
@@ -281,7 +282,7 @@ var _ = Describe("Broker", func() {
 			chartArchivePath, err = chartutil.Save(&chartToSave.Chart, chartArchiveDirPath)
 			Expect(err).To(BeNil())
 
-			loadedChart, err := helm.NewChart(chartArchivePath, "", logger)
+			loadedChart, err := helm.NewChart(chartArchivePath, nil, logger)
 			Expect(err).To(BeNil())
 			Expect(loadedChart).NotTo(BeNil())
 			Expect(len(loadedChart.Plans)).To(Equal(2))
@@ -294,7 +295,7 @@ var _ = Describe("Broker", func() {
 		var chartArchiveDirPath string
 
 		BeforeEach(func() {
-			chartToSave, err := helm.NewChart(chartPath, "", logger)
+			chartToSave, err := helm.NewChart(chartPath, nil, logger)
 
 			chartArchiveDirPath, err = ioutil.TempDir("", "chartarcive-")
 			Expect(err).To(BeNil())
@@ -334,7 +335,7 @@ var _ = Describe("Broker", func() {
 		})
 
 		It("multiple charts", func() {
-			chartToSave2, err := helm.NewChart(chartPath, "", logger)
+			chartToSave2, err := helm.NewChart(chartPath, nil, logger)
 			chartToSave2.Metadata.Name = "spacebears2"
 			_, err = chartutil.Save(&chartToSave2.Chart, chartArchiveDirPath)
 			Expect(err).To(BeNil())
@@ -353,7 +354,7 @@ var _ = Describe("Broker", func() {
 		err := os.Remove(filepath.Join(chartPath, "values.yaml"))
 		Expect(err).To(BeNil())
 
-		_, err = helm.NewChart(chartPath, "", logger)
+		_, err = helm.NewChart(chartPath, nil, logger)
 
 		Expect(err).NotTo(BeNil())
 		Expect(err.Error()).To(ContainSubstring("values.yaml"))
@@ -363,14 +364,14 @@ var _ = Describe("Broker", func() {
 		err := ioutil.WriteFile(filepath.Join(chartPath, "values.yaml"), []byte(`:foo`), 0666)
 		Expect(err).To(BeNil())
 
-		_, err = helm.NewChart(chartPath, "", logger)
+		_, err = helm.NewChart(chartPath, nil, logger)
 
 		Expect(err).NotTo(BeNil())
 	})
 
 	Context("ensure .helmignore", func() {
 		It("adds ignore file with images when not present", func() {
-			_, err := helm.NewChart(chartPath, "", logger)
+			_, err := helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			ignoreContents, err := ioutil.ReadFile(filepath.Join(chartPath, ".helmignore"))
@@ -382,7 +383,7 @@ var _ = Describe("Broker", func() {
 			err := ioutil.WriteFile(filepath.Join(chartPath, ".helmignore"), []byte(`secrets`), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			ignoreContents, err := ioutil.ReadFile(filepath.Join(chartPath, ".helmignore"))
@@ -396,7 +397,7 @@ images
 foo`), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			ignoreContents, err := ioutil.ReadFile(filepath.Join(chartPath, ".helmignore"))
@@ -415,7 +416,7 @@ foo: bar
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "", logger)
+			chart, err := helm.NewChart(chartPath, nil, logger)
 			Expect(err).To(BeNil())
 
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -432,7 +433,7 @@ foo: bar
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com/some-scope", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com/some-scope"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -451,7 +452,7 @@ foo: bar
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com/some-scope", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com/some-scope"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -475,7 +476,7 @@ images:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -491,6 +492,386 @@ images:
 `)))
 		})
 
+		It("pins images to the digests in images.lock", func() {
+			testChart.ValuesYaml = []byte(`
+image: quay.io/my-image
+imageTag: 5.7.14
+digest: ""
+images:
+  thing1:
+    image: my-first-image:1.2.3
+  thing2:
+    image: my-second-image:1.0.0
+  thing3:
+    image: my-third-image
+    imageTag: 1.2.3
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`
+images:
+  docker.example.com/my-image:5.7.14: sha256:aaaa
+  docker.example.com/my-first-image:1.2.3: sha256:bbbb
+  docker.example.com/my-second-image:latest: sha256:cccc
+  docker.example.com/my-third-image:1.2.3: sha256:dddd
+`), 0666)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+digest: sha256:aaaa
+global:
+  imageRegistry: docker.example.com
+image: docker.example.com/my-image
+imageTag: 5.7.14
+images:
+  thing1:
+    image: docker.example.com/my-first-image@sha256:bbbb
+  thing2:
+    image: docker.example.com/my-second-image:1.0.0
+  thing3:
+    image: docker.example.com/my-third-image
+    imageTag: 1.2.3
+`)))
+		})
+
+		It("pins discovered images nested in values", func() {
+			testChart.ValuesYaml = []byte(`
+mysql:
+  image:
+    repository: bitnami/mysql
+    tag: 8.0.19
+    digest: ""
+  backup:
+    agent:
+      image:
+        repository: example/backup
+        tag: 1.0.0
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+discover: true
+`), 0666)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`
+images:
+  docker.example.com/mysql:8.0.19: sha256:aaaa
+  docker.example.com/backup:1.0.0: sha256:bbbb
+`), 0666)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+global:
+  imageRegistry: docker.example.com
+mysql:
+  backup:
+    agent:
+      image:
+        repository: docker.example.com/backup
+        tag: 1.0.0
+  image:
+    digest: sha256:aaaa
+    repository: docker.example.com/mysql
+    tag: 8.0.19
+`)))
+		})
+
+		It("pins map images with a registry by the name the loader pushed them as", func() {
+			testChart.ValuesYaml = []byte(`
+metrics:
+  image:
+    registry: quay.io
+    repository: vendor/exporter
+    tag: 0.5.0
+    digest: ""
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+discover: true
+`), 0666)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`
+images:
+  docker.example.com/library/exporter:0.5.0: sha256:aaaa
+`), 0666)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com/library"}, logger)
+
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+global:
+  imageRegistry: docker.example.com/library
+metrics:
+  image:
+    digest: sha256:aaaa
+    registry: docker.example.com/library
+    repository: vendor/exporter
+    tag: 0.5.0
+`)))
+		})
+
+		It("ignores images.lock without a private registry", func() {
+			testChart.ValuesYaml = []byte(`
+image: my-image
+imageTag: 5.7.14
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`
+images:
+  my-image:5.7.14: sha256:aaaa
+`), 0666)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, nil, logger)
+
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+image: my-image
+imageTag: 5.7.14
+`)))
+		})
+
+		It("returns error for an unparseable images.lock", func() {
+			err := ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`images: [`), 0666)
+			Expect(err).To(BeNil())
+
+			_, err = helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("rewrites images at the paths in images.yaml", func() {
+			testChart.ValuesYaml = []byte(`
+controller:
+  image:
+    repository: bitnami/nginx
+    tag: 1.17.6
+metrics:
+  image: quay.io/nginx/exporter:0.5.0
+defaultBackend:
+  image:
+    registry: docker.io
+    repository: bitnami/nginx-backend
+    tag: 0.0.1
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.image
+- defaultBackend.image
+discover: true
+`), 0666)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "images.lock"), []byte(`
+images:
+  docker.example.com/nginx:1.17.6: sha256:aaaa
+  docker.example.com/exporter:0.5.0: sha256:bbbb
+`), 0666)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+
+			Expect(err).To(BeNil())
+			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+controller:
+  image:
+    repository: docker.example.com/nginx
+    tag: 1.17.6
+defaultBackend:
+  image:
+    registry: docker.example.com
+    repository: bitnami/nginx-backend
+    tag: 0.0.1
+global:
+  imageRegistry: docker.example.com
+metrics:
+  image: docker.example.com/exporter@sha256:bbbb
+`)))
+		})
+
+		It("returns error for a path in images.yaml that isn't in values", func() {
+			err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.image
+`), 0666)
+			Expect(err).To(BeNil())
+
+			_, err = helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("lists the images for a plan", func() {
+			testChart.ValuesYaml = []byte(`
+image: mysql
+imageTag: 5.7.14
+metrics:
+  image:
+    registry: docker.io
+    repository: prom/mysqld-exporter
+    tag: v0.10.0
+`)
+			testChart.PlanContents["medium"] = []byte(`
+imageTag: 5.7.28
+`)
+			err := testChart.WriteChart(chartPath)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
+			Expect(err).To(BeNil())
+
+			images, err := chart.ImageReferences("medium")
+
+			Expect(err).To(BeNil())
+			Expect(images).To(Equal([]string{
+				"docker.example.com/mysql:5.7.28",
+				"docker.io/prom/mysqld-exporter:v0.10.0",
+			}))
+		})
+
+		Context("multiple registries", func() {
+			var registryConfig *config.RegistryConfig
+
+			BeforeEach(func() {
+				registryConfig = &config.RegistryConfig{
+					Server: "docker.example.com",
+					Registries: config.Registries{
+						{Server: "harbor.example.com/vendor", Match: []string{"quay.io/vendor"}},
+					},
+				}
+				testChart.ValuesYaml = []byte(`
+image: bitnami/mysql
+imageTag: 8.0.18
+images:
+  app:
+    image: quay.io/vendor/app
+    imageTag: 1.0.0
+metrics:
+  image:
+    registry: quay.io
+    repository: vendor/exporter
+    tag: 0.5.0
+`)
+				err := ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.image
+`), 0666)
+				Expect(err).To(BeNil())
+			})
+
+			It("rewrites each image to the registry it matches", func() {
+				err := testChart.WriteChart(chartPath)
+				Expect(err).To(BeNil())
+
+				chart, err := helm.NewChart(chartPath, registryConfig, logger)
+
+				Expect(err).To(BeNil())
+				Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+global:
+  imageRegistry: docker.example.com
+image: docker.example.com/mysql
+imageTag: 8.0.18
+images:
+  app:
+    image: harbor.example.com/vendor/app
+    imageTag: 1.0.0
+metrics:
+  image:
+    registry: harbor.example.com/vendor
+    repository: vendor/exporter
+    tag: 0.5.0
+`)))
+			})
+
+			It("rewrites images found from images.yaml only once", func() {
+				testChart.ValuesYaml = []byte(`
+image:
+  registry: quay.io
+  repository: vendor/app
+  tag: 1.0.0
+images:
+  worker:
+    image: quay.io/vendor/worker
+    imageTag: 1.0.0
+metrics:
+  image: quay.io/vendor/exporter:0.5.0
+`)
+				err := testChart.WriteChart(chartPath)
+				Expect(err).To(BeNil())
+				err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- images.worker.image
+- metrics.image
+discover: true
+`), 0666)
+				Expect(err).To(BeNil())
+
+				chart, err := helm.NewChart(chartPath, registryConfig, logger)
+
+				Expect(err).To(BeNil())
+				Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
+global:
+  imageRegistry: docker.example.com
+image:
+  registry: harbor.example.com/vendor
+  repository: vendor/app
+  tag: 1.0.0
+images:
+  worker:
+    image: harbor.example.com/vendor/worker
+    imageTag: 1.0.0
+metrics:
+  image: harbor.example.com/vendor/exporter:0.5.0
+`)))
+			})
+
+			It("follows the registries in the chart's images.yaml", func() {
+				err := testChart.WriteChart(chartPath)
+				Expect(err).To(BeNil())
+				err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.image
+registries:
+  quay.io/vendor/app: docker.example.com
+  bitnami: harbor.example.com/vendor
+`), 0666)
+				Expect(err).To(BeNil())
+
+				chart, err := helm.NewChart(chartPath, registryConfig, logger)
+
+				Expect(err).To(BeNil())
+				values := string(chart.TransformedValues)
+				Expect(values).To(ContainSubstring("image: harbor.example.com/vendor/mysql"))
+				Expect(values).To(ContainSubstring("image: docker.example.com/app"))
+				Expect(values).To(ContainSubstring("registry: harbor.example.com/vendor"))
+			})
+
+			It("returns error when images.yaml maps to a registry that isn't configured", func() {
+				err := testChart.WriteChart(chartPath)
+				Expect(err).To(BeNil())
+				err = ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+registries:
+  bitnami: other.example.com
+`), 0666)
+				Expect(err).To(BeNil())
+
+				_, err = helm.NewChart(chartPath, registryConfig, logger)
+
+				Expect(err).NotTo(BeNil())
+				Expect(err.Error()).To(ContainSubstring("other.example.com"))
+			})
+		})
+
 		It("adds prefix for global.imageRegistry case", func() {
 			testChart.ValuesYaml = []byte(`
 global:
@@ -499,7 +880,7 @@ global:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -516,7 +897,7 @@ global:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -534,7 +915,7 @@ global:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 
@@ -560,7 +941,7 @@ image:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -580,7 +961,7 @@ bob: foo
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(chartPath, "docker.example.com", logger)
+			chart, err := helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).To(BeNil())
 			Expect(strings.TrimSpace(string(chart.TransformedValues))).To(Equal(strings.TrimSpace(`
@@ -598,7 +979,7 @@ images:
 			err := testChart.WriteChart(chartPath)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "docker.example.com", logger)
+			_, err = helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 			Expect(err).NotTo(BeNil())
 		})
@@ -614,14 +995,14 @@ images:
 		err := testChart.WriteChart(chartPath)
 		Expect(err).To(BeNil())
 
-		_, err = helm.NewChart(chartPath, "docker.example.com", logger)
+		_, err = helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 
 		Expect(err).NotTo(BeNil())
 	})
 
 	Context("plans", func() {
 		It("loads plan correctly", func() {
-			myChart, err := helm.NewChart(chartPath, "", logger)
+			myChart, err := helm.NewChart(chartPath, nil, logger)
 
 			Expect(err).To(BeNil())
 			Expect(myChart.Plans["small"].Name).To(Equal("small"))
@@ -677,7 +1058,7 @@ users:
 			}
 			credsFile.Close()
 
-			myChart, err := helm.NewChart(chartPath, "", logger)
+			myChart, err := helm.NewChart(chartPath, nil, logger)
 
 			Expect(myChart.Plans["medium"].ClusterConfig).To(BeNil())
 
@@ -697,7 +1078,7 @@ users:
 			err := os.Remove(filepath.Join(chartPath, "plans", "small.yaml"))
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 			Expect(err).NotTo(BeNil())
 		})
 
@@ -705,7 +1086,7 @@ users:
 			err := ioutil.WriteFile(filepath.Join(chartPath, "plans.yaml"), []byte(`:foo`), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 
 			Expect(err).NotTo(BeNil())
 		})
@@ -719,7 +1100,7 @@ users:
 `), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 
 			Expect(err).NotTo(BeNil())
 			Expect(err.Error()).To(ContainSubstring("invalid characters"))
@@ -733,7 +1114,7 @@ users:
 `), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 
 			Expect(err).NotTo(BeNil())
 			Expect(err.Error()).To(ContainSubstring("invalid characters"))
@@ -747,7 +1128,7 @@ users:
 `), 0666)
 			Expect(err).To(BeNil())
 
-			_, err = helm.NewChart(chartPath, "", logger)
+			_, err = helm.NewChart(chartPath, nil, logger)
 
 			Expect(err).NotTo(BeNil())
 			Expect(err.Error()).To(ContainSubstring("invalid characters"))
@@ -0,0 +1,251 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/docker"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type ValidationProblem struct {
+	Check    string `json:"check"`
+	File     string `json:"file,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+type ValidationReport struct {
+	Chart    string              `json:"chart"`
+	Version  string              `json:"version"`
+	Valid    bool                `json:"valid"`
+	Problems []ValidationProblem `json:"problems"`
+}
+
+// ValidateChart runs the checks NewChart does, with images rewritten to the registries in
+// registryConfig, plus rendering the bind template and checking the image structure the loader
+// expects, and reports every problem found instead of stopping at the first. Errors mean Kibosh
+// would reject the chart, warnings that it may fail later (at bind or image load time).
+func ValidateChart(chartPath string, registryConfig *config.RegistryConfig) *ValidationReport {
+	report := &ValidationReport{
+		Chart:    filepath.Base(chartPath),
+		Problems: []ValidationProblem{},
+	}
+	if registryConfig == nil {
+		registryConfig = &config.RegistryConfig{}
+	}
+
+	loadedChart, err := chartutil.Load(chartPath)
+	if err != nil {
+		report.addError("chart", "", err.Error())
+		report.Valid = false
+		return report
+	}
+	report.Chart = loadedChart.Metadata.Name
+	report.Version = loadedChart.Metadata.Version
+
+	myChart := &MyChart{
+		Chart:                 *loadedChart,
+		PrivateRegistryServer: registryConfig.Server,
+		registryConfig:        registryConfig,
+	}
+	report.validateValues(myChart)
+
+	chartDir, err := expandedChartDir(chartPath, loadedChart.Metadata.Name)
+	if err != nil {
+		report.addError("chart", "", err.Error())
+	} else {
+		if chartDir.tempDir != "" {
+			defer os.RemoveAll(chartDir.tempDir)
+		}
+		report.validatePlans(myChart, chartDir.path)
+		report.validateBind(chartDir.path)
+	}
+
+	report.Valid = true
+	for _, problem := range report.Problems {
+		if problem.Severity == SeverityError {
+			report.Valid = false
+		}
+	}
+	return report
+}
+
+type chartDir struct {
+	path    string
+	tempDir string
+}
+
+// expandedChartDir is the directory of the chart in chartPath. Archives are expanded to a temporary
+// directory so that plans and the bind template are loaded from files the same way for both.
+func expandedChartDir(chartPath string, name string) (*chartDir, error) {
+	stat, err := os.Stat(chartPath)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return &chartDir{path: chartPath}, nil
+	}
+
+	tempDir, err := ioutil.TempDir("", name+"-validate")
+	if err != nil {
+		return nil, err
+	}
+	err = chartutil.ExpandFile(tempDir, chartPath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return nil, err
+	}
+	return &chartDir{path: filepath.Join(tempDir, name), tempDir: tempDir}, nil
+}
+
+func (r *ValidationReport) addError(check string, file string, message string) {
+	r.Problems = append(r.Problems, ValidationProblem{Check: check, File: file, Severity: SeverityError, Message: message})
+}
+
+func (r *ValidationReport) addWarning(check string, file string, message string) {
+	r.Problems = append(r.Problems, ValidationProblem{Check: check, File: file, Severity: SeverityWarning, Message: message})
+}
+
+// validateValues runs the checks LoadChartValues does, and checks images.yaml against the values
+// even when there's no private registry to rewrite them to
+func (r *ValidationReport) validateValues(myChart *MyChart) {
+	values, err := myChart.parseValues()
+	if err != nil {
+		r.addError("values", "values.yaml", err.Error())
+		return
+	}
+
+	var imagePaths *docker.ImagePaths
+	if _, ok := myChart.chartFile(docker.ImagePathsFile); ok {
+		imagePaths, err = myChart.loadImagePaths()
+		if err == nil {
+			_, err = imagePaths.Find(values)
+		}
+		if err != nil {
+			r.addError("images", docker.ImagePathsFile, err.Error())
+			return
+		}
+	}
+	err = docker.ValidateImageValues([]byte(myChart.Chart.Values.Raw), imagePaths)
+	if err != nil {
+		r.addWarning("images", "values.yaml", err.Error())
+	}
+
+	_, err = myChart.transformValues(values)
+	if err != nil {
+		r.addError("images", docker.ImagePathsFile, err.Error())
+	}
+}
+
+func (r *ValidationReport) validatePlans(myChart *MyChart, chartPath string) {
+	plansPath, plans, err := loadPlansFile(chartPath)
+	plansFile := filepath.Base(plansPath)
+	if err != nil {
+		r.addError("plans", plansFile, err.Error())
+		return
+	}
+
+	for _, plan := range plans {
+		err := myChart.loadPlan(filepath.Join(chartPath, "plans"), &plan)
+		if err != nil {
+			r.addError("plans", path.Join("plans", plan.File), err.Error())
+		}
+		err = loadPlanCredentials(filepath.Join(chartPath, "plans"), &plan)
+		if err != nil {
+			r.addError("credentials", path.Join("plans", plan.CredentialsPath), err.Error())
+		}
+	}
+}
+
+func (r *ValidationReport) validateBind(chartPath string) {
+	bindPath, bind, err := loadBindFile(chartPath)
+	bindFile := filepath.Base(bindPath)
+	if err != nil {
+		r.addError("bind", bindFile, err.Error())
+		return
+	}
+	if bind == nil || bind.Template == "" {
+		return
+	}
+
+	rendered, err := RenderJsonnetTemplate(bind.Template, syntheticSecretsAndServices())
+	if err != nil {
+		if strings.Contains(err.Error(), "RUNTIME ERROR") {
+			r.addWarning("bind", bindFile, "Template failed against a sample services and secrets document: "+err.Error())
+		} else {
+			r.addError("bind", bindFile, err.Error())
+		}
+		return
+	}
+
+	credentials := map[string]interface{}{}
+	err = json.Unmarshal([]byte(rendered), &credentials)
+	if err != nil {
+		r.addError("bind", bindFile, "Template must render an object: "+err.Error())
+	}
+}
+
+// syntheticSecretsAndServices mirrors the document k8s.Cluster.GetSecretsAndServices hands to bind templates
+func syntheticSecretsAndServices() map[string][]map[string]interface{} {
+	return map[string][]map[string]interface{}{
+		"secrets": {
+			{
+				"name": "sample-secret",
+				"data": map[string]interface{}{
+					"username": "sample-user",
+					"password": "sample-password",
+				},
+			},
+		},
+		"services": {
+			{
+				"name": "sample-service",
+				"metadata": map[string]interface{}{
+					"name":      "sample-service",
+					"namespace": "kibosh-sample",
+				},
+				"spec": map[string]interface{}{
+					"type":        "LoadBalancer",
+					"clusterIP":   "10.0.0.1",
+					"externalIPs": []interface{}{"10.0.0.2"},
+					"ports": []interface{}{
+						map[string]interface{}{"name": "sample", "port": 8080, "protocol": "TCP", "nodePort": 30080},
+					},
+				},
+				"status": map[string]interface{}{
+					"loadBalancer": map[string]interface{}{
+						"ingress": []interface{}{
+							map[string]interface{}{"ip": "10.0.0.3"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
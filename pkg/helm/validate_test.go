@@ -0,0 +1,205 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+var _ = Describe("Validate", func() {
+	var chartPath string
+	var testChart *test.TestChart
+
+	checks := func(report *helm.ValidationReport, severity string) []string {
+		found := []string{}
+		for _, problem := range report.Problems {
+			if problem.Severity == severity {
+				found = append(found, problem.Check)
+			}
+		}
+		return found
+	}
+
+	BeforeEach(func() {
+		var err error
+		chartPath, err = ioutil.TempDir("", "chart-")
+		Expect(err).To(BeNil())
+
+		testChart = test.DefaultChart()
+		testChart.ValuesYaml = []byte(`
+image: my-image
+imageTag: 1.0.0
+`)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(chartPath)
+	})
+
+	It("reports valid chart", func() {
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "bind.yaml"), []byte(`
+template: |
+  {hostname: $.services[0].status.loadBalancer.ingress[0].ip}
+`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeTrue())
+		Expect(report.Chart).To(Equal("spacebears"))
+		Expect(report.Version).To(Equal("0.0.1"))
+		Expect(report.Problems).To(BeEmpty())
+	})
+
+	It("reports every problem at once", func() {
+		testChart.PlansYaml = []byte(`
+- name: "Small"
+  description: "bad name"
+  file: "small.yaml"
+- name: "missing"
+  description: "no values file"
+  file: "missing.yaml"
+- name: "medium"
+  description: "bad credentials"
+  file: "medium.yaml"
+  credentials: "bad-creds.yaml"
+`)
+		testChart.PlanContents["bad-creds"] = []byte(`clusters: not-a-list`)
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "bind.yaml"), []byte(`
+template: |
+  {{{$$$$
+`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(checks(report, helm.SeverityError)).To(ConsistOf("plans", "plans", "credentials", "bind"))
+	})
+
+	It("warns when bind template fails against sample document", func() {
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "bind.yaml"), []byte(`
+template: |
+  {password: $.secrets[3].data.password}
+`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeTrue())
+		Expect(checks(report, helm.SeverityWarning)).To(ConsistOf("bind"))
+	})
+
+	It("warns on image structure the loader doesn't understand", func() {
+		testChart.ValuesYaml = []byte(`
+image:
+  repository: my-image
+`)
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeTrue())
+		Expect(checks(report, helm.SeverityWarning)).To(ConsistOf("images"))
+	})
+
+	It("follows images.yaml", func() {
+		testChart.ValuesYaml = []byte(`
+image:
+  repository: my-image
+  tag: 1.0.0
+`)
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`discover: true`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeTrue())
+		Expect(report.Problems).To(BeEmpty())
+	})
+
+	It("reports paths in images.yaml that aren't images", func() {
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+paths:
+- metrics.image
+`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(checks(report, helm.SeverityError)).To(ConsistOf("images"))
+	})
+
+	It("reports images.yaml mapping images to registries that aren't configured", func() {
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(ioutil.WriteFile(filepath.Join(chartPath, "images.yaml"), []byte(`
+registries:
+  my-image: harbor.example.com/vendor
+`), 0666)).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"})
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(checks(report, helm.SeverityError)).To(ConsistOf("images"))
+		Expect(report.Problems[0].Message).To(ContainSubstring("isn't configured"))
+	})
+
+	It("reports every problem in an archive", func() {
+		testChart.PlansYaml = []byte(`
+- name: "Small"
+  description: "bad name"
+  file: "small.yaml"
+`)
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		loadedChart, err := chartutil.Load(chartPath)
+		Expect(err).To(BeNil())
+		archivePath, err := chartutil.Save(loadedChart, chartPath)
+		Expect(err).To(BeNil())
+
+		report := helm.ValidateChart(archivePath, nil)
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(report.Chart).To(Equal("spacebears"))
+		Expect(checks(report, helm.SeverityError)).To(ConsistOf("plans"))
+	})
+
+	It("reports missing values", func() {
+		Expect(testChart.WriteChart(chartPath)).To(BeNil())
+		Expect(os.Remove(filepath.Join(chartPath, "values.yaml"))).To(BeNil())
+
+		report := helm.ValidateChart(chartPath, nil)
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(checks(report, helm.SeverityError)).To(ContainElement("values"))
+	})
+
+	It("reports chart that can't be loaded", func() {
+		report := helm.ValidateChart(filepath.Join(chartPath, "nope.tgz"), nil)
+
+		Expect(report.Valid).To(BeFalse())
+		Expect(checks(report, helm.SeverityError)).To(ConsistOf("chart"))
+	})
+})
@@ -0,0 +1,72 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FetchClientCredentialsToken gets an access token for a client from an OAuth2 token endpoint,
+// such as UAA's /oauth/token
+func FetchClientCredentialsToken(client *http.Client, tokenURL string, clientID string, clientSecret string) (string, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("response_type", "token")
+
+	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to fetch token")
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Non-OK response code from token endpoint [%v]\nMessage from server: %v", res.Status, string(body))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+	}
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to decode token response")
+	}
+	if token.AccessToken == "" {
+		return "", errors.New("token endpoint returned no access token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", errors.Errorf("unsupported token type [%s]", token.TokenType)
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,80 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client credentials", func() {
+	var tokenRequest *http.Request
+	var server *httptest.Server
+	var status int
+	var response string
+
+	BeforeEach(func() {
+		status = 200
+		response = `{"access_token":"my-token","token_type":"bearer","expires_in":43199}`
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			tokenRequest = r
+			w.WriteHeader(status)
+			w.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("fetches a token with the client's credentials", func() {
+		token, err := httphelpers.FetchClientCredentialsToken(http.DefaultClient, server.URL+"/oauth/token", "bazaar-ci", "secret")
+
+		Expect(err).To(BeNil())
+		Expect(token).To(Equal("my-token"))
+
+		Expect(tokenRequest.Method).To(Equal("POST"))
+		Expect(tokenRequest.URL.Path).To(Equal("/oauth/token"))
+		Expect(tokenRequest.PostForm.Get("grant_type")).To(Equal("client_credentials"))
+		user, pass, ok := tokenRequest.BasicAuth()
+		Expect(ok).To(BeTrue())
+		Expect(user).To(Equal("bazaar-ci"))
+		Expect(pass).To(Equal("secret"))
+	})
+
+	It("returns the server's message on failure", func() {
+		status = 401
+		response = `{"error":"unauthorized","error_description":"Bad credentials"}`
+
+		_, err := httphelpers.FetchClientCredentialsToken(http.DefaultClient, server.URL+"/oauth/token", "bazaar-ci", "wrong")
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Bad credentials"))
+	})
+
+	It("errors when no token is returned", func() {
+		response = `{}`
+
+		_, err := httphelpers.FetchClientCredentialsToken(http.DefaultClient, server.URL+"/oauth/token", "bazaar-ci", "secret")
+
+		Expect(err).NotTo(BeNil())
+	})
+})
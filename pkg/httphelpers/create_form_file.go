@@ -28,13 +28,22 @@ type FlagValues struct {
 	Value string
 }
 
+type FormFiles struct {
+	FieldName string
+	Paths     []string
+}
+
 func CreateFormRequest(url string, fieldname string, filepaths []string) (*http.Request, error) {
 	return CreateFormRequestFlags(url, fieldname, filepaths, []FlagValues{})
 }
 
 func CreateFormRequestFlags(url string, fieldname string, filepaths []string, flags []FlagValues) (*http.Request, error) {
+	return CreateFormRequestFiles(url, []FormFiles{{FieldName: fieldname, Paths: filepaths}}, flags)
+}
 
-	body, boundary, err := CreateFormFile(fieldname, filepaths, flags)
+func CreateFormRequestFiles(url string, files []FormFiles, flags []FlagValues) (*http.Request, error) {
+
+	body, boundary, err := CreateFormFiles(files, flags)
 
 	if err != nil {
 		return nil, err
@@ -52,6 +61,10 @@ func CreateFormRequestFlags(url string, fieldname string, filepaths []string, fl
 }
 
 func CreateFormFile(fieldname string, paths []string, flags []FlagValues) (io.Reader, string, error) {
+	return CreateFormFiles([]FormFiles{{FieldName: fieldname, Paths: paths}}, flags)
+}
+
+func CreateFormFiles(files []FormFiles, flags []FlagValues) (io.Reader, string, error) {
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
@@ -64,28 +77,31 @@ func CreateFormFile(fieldname string, paths []string, flags []FlagValues) (io.Re
 		f.Write([]byte(flag.Value))
 	}
 
-	for _, path := range paths {
+	for _, formFiles := range files {
+		for _, path := range formFiles.Paths {
 
-		chartFileInfo, err := os.Stat(path)
-		if err != nil {
-			return nil, "", err
-		}
+			chartFileInfo, err := os.Stat(path)
+			if err != nil {
+				return nil, "", err
+			}
 
-		part, err := writer.CreateFormFile(fieldname, chartFileInfo.Name())
-		if err != nil {
-			return nil, "", err
-		}
+			part, err := writer.CreateFormFile(formFiles.FieldName, chartFileInfo.Name())
+			if err != nil {
+				return nil, "", err
+			}
 
-		file, err := os.Open(path)
-		if err != nil {
-			return nil, "", err
-		}
+			file, err := os.Open(path)
+			if err != nil {
+				return nil, "", err
+			}
 
-		_, err = io.CopyBuffer(part, file, make([]byte, 4096))
-		if err != nil {
-			return nil, "", err
-		}
+			_, err = io.CopyBuffer(part, file, make([]byte, 4096))
+			file.Close()
+			if err != nil {
+				return nil, "", err
+			}
 
+		}
 	}
 
 	//boundary := writer.Boundary()
@@ -0,0 +1,85 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers
+
+import (
+	"context"
+	"net/http"
+)
+
+// Role is what an authenticated caller may do, each role including the ones below it
+type Role int
+
+const (
+	RoleNone Role = iota
+	RoleReader
+	RolePublisher
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReader:
+		return "reader"
+	case RolePublisher:
+		return "publisher"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+func (r Role) Allows(required Role) bool {
+	return r >= required
+}
+
+// RoleScopes names the token scopes that grant each role
+type RoleScopes struct {
+	Reader    string
+	Publisher string
+	Admin     string
+}
+
+// RoleFor returns the highest role granted by any of the scopes
+func (s RoleScopes) RoleFor(scopes []string) Role {
+	role := RoleNone
+	for _, scope := range scopes {
+		switch {
+		case scope == "":
+			continue
+		case scope == s.Admin:
+			role = RoleAdmin
+		case scope == s.Publisher && role < RolePublisher:
+			role = RolePublisher
+		case scope == s.Reader && role < RoleReader:
+			role = RoleReader
+		}
+	}
+	return role
+}
+
+type roleKey struct{}
+
+func WithRole(r *http.Request, role Role) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), roleKey{}, role))
+}
+
+// RoleFromRequest returns the role an auth filter granted the request, if the filter assigns roles
+func RoleFromRequest(r *http.Request) (Role, bool) {
+	role, ok := r.Context().Value(roleKey{}).(Role)
+	return role, ok
+}
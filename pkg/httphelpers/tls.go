@@ -0,0 +1,119 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ServerTLSConfig serves certFile and keyFile. When clientCAFile is set, client certificates must be
+// signed by one of its CAs, and clients must present one when requireClientCert is set. Otherwise
+// handlers that need one are wrapped with RequireClientCert.
+func ServerTLSConfig(certFile string, keyFile string, clientCAFile string, requireClientCert bool) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to load server certificate")
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if clientCAFile != "" {
+		tlsConfig.ClientCAs, err = loadCertPool(clientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		if requireClientCert {
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		} else {
+			tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+	return tlsConfig, nil
+}
+
+// RequireClientCert rejects requests that didn't present a client certificate the server verified
+func RequireClientCert(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// ClientTLSConfig trusts the CAs in caCertFile instead of the system's, when set, and presents
+// certFile and keyFile to servers that verify client certificates, when set
+func ClientTLSConfig(caCertFile string, certFile string, keyFile string, insecureSkipVerify bool) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecureSkipVerify,
+	}
+	if caCertFile != "" {
+		pool, err := loadCertPool(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to load client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+func NewHTTPClient(caCertFile string, certFile string, keyFile string, insecureSkipVerify bool) (*http.Client, error) {
+	tlsConfig, err := ClientTLSConfig(caCertFile, certFile, keyFile, insecureSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}, nil
+}
+
+// ListenAndServe serves the default mux, over TLS when tlsConfig is set
+func ListenAndServe(addr string, tlsConfig *tls.Config) error {
+	server := &http.Server{
+		Addr:      addr,
+		TLSConfig: tlsConfig,
+	}
+	if tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	return server.ListenAndServeTLS("", "")
+}
+
+func loadCertPool(caCertFile string) (*x509.CertPool, error) {
+	caBytes, err := ioutil.ReadFile(caCertFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read ca cert [%s]", caCertFile)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBytes) {
+		return nil, errors.Errorf("No certificates found in [%s]", caCertFile)
+	}
+	return pool, nil
+}
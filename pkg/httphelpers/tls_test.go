@@ -0,0 +1,181 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("TLS", func() {
+	var certDir string
+	var certs *test.Certificates
+	var server *httptest.Server
+
+	BeforeEach(func() {
+		var err error
+		certDir, err = ioutil.TempDir("", "tls-test-")
+		Expect(err).To(BeNil())
+		certs, err = test.WriteCertificates(certDir)
+		Expect(err).To(BeNil())
+
+		server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("hello"))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(certDir)
+	})
+
+	startServer := func(clientCAFile string) {
+		tlsConfig, err := httphelpers.ServerTLSConfig(certs.ServerCertFile, certs.ServerKeyFile, clientCAFile, true)
+		Expect(err).To(BeNil())
+		server.TLS = tlsConfig
+		server.StartTLS()
+	}
+
+	It("trusts the configured ca", func() {
+		startServer("")
+		client, err := httphelpers.NewHTTPClient(certs.CACertFile, "", "", false)
+		Expect(err).To(BeNil())
+
+		res, err := client.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(200))
+	})
+
+	It("doesn't trust an unknown ca", func() {
+		startServer("")
+		client, err := httphelpers.NewHTTPClient("", "", "", false)
+		Expect(err).To(BeNil())
+
+		_, err = client.Get(server.URL)
+
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("skips verification when insecure", func() {
+		startServer("")
+		client, err := httphelpers.NewHTTPClient("", "", "", true)
+		Expect(err).To(BeNil())
+
+		res, err := client.Get(server.URL)
+
+		Expect(err).To(BeNil())
+		Expect(res.StatusCode).To(Equal(200))
+	})
+
+	Context("client certificates", func() {
+		BeforeEach(func() {
+			startServer(certs.CACertFile)
+		})
+
+		It("accepts a client certificate signed by the client ca", func() {
+			client, err := httphelpers.NewHTTPClient(certs.CACertFile, certs.ClientCertFile, certs.ClientKeyFile, false)
+			Expect(err).To(BeNil())
+
+			res, err := client.Get(server.URL)
+
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(200))
+		})
+
+		It("rejects clients without a certificate", func() {
+			client, err := httphelpers.NewHTTPClient(certs.CACertFile, "", "", false)
+			Expect(err).To(BeNil())
+
+			_, err = client.Get(server.URL)
+
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("optional client certificates", func() {
+		BeforeEach(func() {
+			tlsConfig, err := httphelpers.ServerTLSConfig(certs.ServerCertFile, certs.ServerKeyFile, certs.CACertFile, false)
+			Expect(err).To(BeNil())
+			mux := http.NewServeMux()
+			mux.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello"))
+			}))
+			mux.Handle("/admin", httphelpers.RequireClientCert(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("hello admin"))
+			})))
+			server.Config.Handler = mux
+			server.TLS = tlsConfig
+			server.StartTLS()
+		})
+
+		It("serves clients without a certificate", func() {
+			client, err := httphelpers.NewHTTPClient(certs.CACertFile, "", "", false)
+			Expect(err).To(BeNil())
+
+			res, err := client.Get(server.URL)
+
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(200))
+		})
+
+		It("rejects clients without a certificate where one is required", func() {
+			client, err := httphelpers.NewHTTPClient(certs.CACertFile, "", "", false)
+			Expect(err).To(BeNil())
+
+			res, err := client.Get(server.URL + "/admin")
+
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(403))
+		})
+
+		It("accepts a client certificate signed by the client ca where one is required", func() {
+			client, err := httphelpers.NewHTTPClient(certs.CACertFile, certs.ClientCertFile, certs.ClientKeyFile, false)
+			Expect(err).To(BeNil())
+
+			res, err := client.Get(server.URL + "/admin")
+
+			Expect(err).To(BeNil())
+			Expect(res.StatusCode).To(Equal(200))
+		})
+	})
+
+	It("errors on a missing ca file", func() {
+		_, err := httphelpers.NewHTTPClient(certDir+"/missing.crt", "", "", false)
+
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("errors on a ca file without certificates", func() {
+		_, err := httphelpers.NewHTTPClient(certs.ServerKeyFile, "", "", false)
+
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("No certificates found"))
+	})
+
+	It("errors on a server key that doesn't match", func() {
+		_, err := httphelpers.ServerTLSConfig(certs.ServerCertFile, certs.ClientKeyFile, "", true)
+
+		Expect(err).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,256 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// minKeyRefresh limits how often an unknown key id makes the verifier fetch the key set again
+const minKeyRefresh = 30 * time.Second
+
+// tokenLeeway allows for clock skew between bazaar and the token issuer
+const tokenLeeway = jwt.DefaultLeeway
+
+// signingAlgorithms are the asymmetric algorithms tokens may be signed with
+var signingAlgorithms = map[string]bool{
+	string(jose.RS256): true,
+	string(jose.RS384): true,
+	string(jose.RS512): true,
+	string(jose.PS256): true,
+	string(jose.PS384): true,
+	string(jose.PS512): true,
+	string(jose.ES256): true,
+	string(jose.ES384): true,
+	string(jose.ES512): true,
+}
+
+// Claims is the subset of JWT claims used to authorize a caller
+type Claims struct {
+	Subject  string       `json:"sub"`
+	ClientID string       `json:"client_id"`
+	Issuer   string       `json:"iss"`
+	Audience jwt.Audience `json:"aud"`
+	Scope    stringList   `json:"scope"`
+}
+
+// stringList accepts either a JSON array or a space separated string, as scope can be either
+type stringList []string
+
+func (l *stringList) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*l = list
+		return nil
+	}
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	*l = strings.Fields(value)
+	return nil
+}
+
+type TokenVerifier interface {
+	Verify(token string) (*Claims, error)
+}
+
+type keySource interface {
+	key(kid string) (interface{}, error)
+}
+
+type tokenVerifier struct {
+	keys     keySource
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+// NewKeyVerifier verifies tokens signed by a single PEM encoded public key, such as UAA's token verification key
+func NewKeyVerifier(pemKey string, issuer string, audience string) (TokenVerifier, error) {
+	block, _ := pem.Decode([]byte(pemKey))
+	if block == nil {
+		return nil, errors.New("token verification key is not PEM encoded")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		rsaKey, rsaErr := x509.ParsePKCS1PublicKey(block.Bytes)
+		if rsaErr != nil {
+			return nil, errors.Wrap(err, "unable to parse token verification key")
+		}
+		key = rsaKey
+	}
+	return newTokenVerifier(&staticKey{publicKey: key}, issuer, audience), nil
+}
+
+// NewJWKSVerifier verifies tokens signed by any key in the JSON Web Key Set at jwksURL, such as UAA's /token_keys
+func NewJWKSVerifier(client *http.Client, jwksURL string, issuer string, audience string) TokenVerifier {
+	return newTokenVerifier(&jwksKeys{url: jwksURL, client: client}, issuer, audience)
+}
+
+func newTokenVerifier(keys keySource, issuer string, audience string) *tokenVerifier {
+	return &tokenVerifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+	}
+}
+
+func (v *tokenVerifier) Verify(token string) (*Claims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "token is not a JWT")
+	}
+	if len(parsed.Headers) != 1 {
+		return nil, errors.New("token must have exactly one signature")
+	}
+	header := parsed.Headers[0]
+	if !signingAlgorithms[header.Algorithm] {
+		return nil, errors.Errorf("unsupported token signing algorithm [%s]", header.Algorithm)
+	}
+	key, err := v.keys.key(header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := jwt.Claims{}
+	claims := &Claims{}
+	if err := parsed.Claims(key, &registered, claims); err != nil {
+		return nil, errors.Wrap(err, "token signature is invalid")
+	}
+	return claims, v.checkClaims(&registered)
+}
+
+func (v *tokenVerifier) checkClaims(claims *jwt.Claims) error {
+	if claims.Expiry == nil {
+		return errors.New("token has no expiry")
+	}
+	expected := jwt.Expected{
+		Issuer: v.issuer,
+		Time:   v.now(),
+	}
+	if v.audience != "" {
+		expected.Audience = jwt.Audience{v.audience}
+	}
+	err := claims.ValidateWithLeeway(expected, tokenLeeway)
+	switch err {
+	case nil:
+		return nil
+	case jwt.ErrExpired:
+		return errors.New("token has expired")
+	case jwt.ErrNotValidYet:
+		return errors.New("token is not valid yet")
+	case jwt.ErrInvalidIssuer:
+		return errors.Errorf("token issuer [%s] is not trusted", claims.Issuer)
+	case jwt.ErrInvalidAudience:
+		return errors.Errorf("token is not intended for audience [%s]", v.audience)
+	default:
+		return errors.Wrap(err, "token claims are invalid")
+	}
+}
+
+type staticKey struct {
+	publicKey interface{}
+}
+
+func (s *staticKey) key(kid string) (interface{}, error) {
+	return s.publicKey, nil
+}
+
+type jwksKeys struct {
+	url    string
+	client *http.Client
+
+	lock      sync.Mutex
+	keys      map[string]interface{}
+	fetchedAt time.Time
+}
+
+func (j *jwksKeys) key(kid string) (interface{}, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	key, ok := j.lookup(kid)
+	if ok {
+		return key, nil
+	}
+	if time.Since(j.fetchedAt) < minKeyRefresh {
+		return nil, errors.Errorf("no token key with id [%s]", kid)
+	}
+
+	err := j.fetch()
+	if err != nil {
+		return nil, err
+	}
+	key, ok = j.lookup(kid)
+	if !ok {
+		return nil, errors.Errorf("no token key with id [%s]", kid)
+	}
+	return key, nil
+}
+
+func (j *jwksKeys) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true
+		}
+	}
+	key, ok := j.keys[kid]
+	return key, ok
+}
+
+func (j *jwksKeys) fetch() error {
+	j.fetchedAt = time.Now()
+
+	res, err := j.client.Get(j.url)
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch token keys")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("unable to fetch token keys, response code [%v]", res.StatusCode)
+	}
+
+	keySet := jose.JSONWebKeySet{}
+	err = json.NewDecoder(res.Body).Decode(&keySet)
+	if err != nil {
+		return errors.Wrap(err, "unable to decode token keys")
+	}
+
+	keys := map[string]interface{}{}
+	for _, webKey := range keySet.Keys {
+		if webKey.Use != "" && webKey.Use != "sig" {
+			continue
+		}
+		if !webKey.IsPublic() {
+			return errors.Errorf("token key [%s] is not a public key", webKey.KeyID)
+		}
+		keys[webKey.KeyID] = webKey.Key
+	}
+	j.keys = keys
+	return nil
+}
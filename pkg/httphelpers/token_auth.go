@@ -0,0 +1,84 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type tokenAuthFilter struct {
+	adminUsername string
+	adminPassword string
+	verifier      TokenVerifier
+	scopes        RoleScopes
+}
+
+// NewTokenAuthFilter accepts bearer tokens, granting the role of the token's scopes, as well as
+// the admin's basic auth credentials, which grant the admin role
+func NewTokenAuthFilter(adminUsername string, adminPassword string, verifier TokenVerifier, scopes RoleScopes) AuthFilter {
+	return &tokenAuthFilter{
+		adminUsername: adminUsername,
+		adminPassword: adminPassword,
+		verifier:      verifier,
+		scopes:        scopes,
+	}
+}
+
+func (a *tokenAuthFilter) Filter(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		role, err := a.authenticate(r)
+		if err != nil {
+			w.Header().Add("WWW-Authenticate", "Basic realm=kibosh")
+			w.Header().Add("WWW-Authenticate", `Bearer realm=kibosh, error="invalid_token"`)
+			w.WriteHeader(401)
+			w.Write([]byte(err.Error()))
+			return
+		}
+		if role == RoleNone {
+			w.WriteHeader(403)
+			w.Write([]byte("Token does not grant any bazaar role"))
+			return
+		}
+		handler.ServeHTTP(w, WithRole(r, role))
+	})
+}
+
+func (a *tokenAuthFilter) CheckAuth(req *http.Request) bool {
+	role, err := a.authenticate(req)
+	return err == nil && role != RoleNone
+}
+
+func (a *tokenAuthFilter) authenticate(req *http.Request) (Role, error) {
+	auth := req.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		claims, err := a.verifier.Verify(strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")))
+		if err != nil {
+			return RoleNone, err
+		}
+		return a.scopes.RoleFor(claims.Scope), nil
+	}
+	if auth != "" && auth == BasicAuthHeaderVal(a.adminUsername, a.adminPassword) {
+		return RoleAdmin, nil
+	}
+	return RoleNone, errors.New("Missing or invalid credentials")
+}
+
+func AddBearerAuthHeader(r *http.Request, token string) {
+	r.Header.Set("Authorization", "Bearer "+token)
+}
@@ -0,0 +1,150 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Token auth filter", func() {
+	var rsaKey *rsa.PrivateKey
+	var filter httphelpers.AuthFilter
+	var grantedRole httphelpers.Role
+	var hasRole bool
+	var handler http.Handler
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).To(BeNil())
+
+		verifier, err := httphelpers.NewKeyVerifier(publicKeyPEM(rsaKey), "", "")
+		Expect(err).To(BeNil())
+
+		filter = httphelpers.NewTokenAuthFilter("bobtheadmin", "monkey123", verifier, httphelpers.RoleScopes{
+			Reader:    "bazaar.read",
+			Publisher: "bazaar.write",
+			Admin:     "bazaar.admin",
+		})
+
+		grantedRole, hasRole = httphelpers.RoleNone, false
+		handler = filter.Filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			grantedRole, hasRole = httphelpers.RoleFromRequest(r)
+		}))
+	})
+
+	It("grants the role of the token's scopes", func() {
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		httphelpers.AddBearerAuthHeader(req, signToken(rsaKey, "", validClaims("openid", "bazaar.write")))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(hasRole).To(BeTrue())
+		Expect(grantedRole).To(Equal(httphelpers.RolePublisher))
+		Expect(filter.CheckAuth(req)).To(BeTrue())
+	})
+
+	It("grants the highest role when a token has several", func() {
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		httphelpers.AddBearerAuthHeader(req, signToken(rsaKey, "", validClaims("bazaar.admin", "bazaar.read")))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(grantedRole).To(Equal(httphelpers.RoleAdmin))
+	})
+
+	It("grants admin to the admin basic auth credentials", func() {
+		req := httptest.NewRequest("DELETE", "https://www.example.com/charts/mysql", nil)
+		httphelpers.AddBasicAuthHeader(req, "bobtheadmin", "monkey123")
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(grantedRole).To(Equal(httphelpers.RoleAdmin))
+	})
+
+	It("forbids valid tokens without a bazaar scope", func() {
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		httphelpers.AddBearerAuthHeader(req, signToken(rsaKey, "", validClaims("openid")))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(403))
+		Expect(hasRole).To(BeFalse())
+		Expect(filter.CheckAuth(req)).To(BeFalse())
+	})
+
+	It("rejects invalid tokens", func() {
+		otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).To(BeNil())
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		httphelpers.AddBearerAuthHeader(req, signToken(otherKey, "", validClaims("bazaar.admin")))
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(401))
+		Expect(recorder.Header()["Www-Authenticate"]).To(ContainElement(ContainSubstring("Bearer")))
+		Expect(hasRole).To(BeFalse())
+	})
+
+	It("rejects bad basic auth credentials", func() {
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		httphelpers.AddBasicAuthHeader(req, "bobtheadmin", "password")
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(401))
+		Expect(hasRole).To(BeFalse())
+	})
+
+	It("rejects requests without credentials", func() {
+		req := httptest.NewRequest("GET", "https://www.example.com/charts", nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(401))
+	})
+
+	Context("roles", func() {
+		It("includes the roles below it", func() {
+			Expect(httphelpers.RoleAdmin.Allows(httphelpers.RolePublisher)).To(BeTrue())
+			Expect(httphelpers.RolePublisher.Allows(httphelpers.RoleReader)).To(BeTrue())
+			Expect(httphelpers.RoleReader.Allows(httphelpers.RolePublisher)).To(BeFalse())
+			Expect(httphelpers.RolePublisher.Allows(httphelpers.RoleAdmin)).To(BeFalse())
+		})
+
+		It("is unset on requests that weren't filtered", func() {
+			_, ok := httphelpers.RoleFromRequest(httptest.NewRequest("GET", "https://www.example.com/charts", nil))
+
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
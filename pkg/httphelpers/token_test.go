@@ -0,0 +1,287 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httphelpers_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func signToken(key crypto.Signer, kid string, claims map[string]interface{}) string {
+	alg := "RS256"
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := crypto.SHA256.New()
+	digest.Write([]byte(signed))
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest.Sum(nil))
+	case *ecdsa.PrivateKey:
+		r, s, _ := ecdsa.Sign(rand.Reader, k, digest.Sum(nil))
+		signature = append(padded(r, 32), padded(s, 32)...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func padded(n *big.Int, size int) []byte {
+	b := n.Bytes()
+	return append(make([]byte, size-len(b)), b...)
+}
+
+func validClaims(scope ...string) map[string]interface{} {
+	return map[string]interface{}{
+		"sub":   "bazaar-client",
+		"iss":   "https://uaa.example.com/oauth/token",
+		"aud":   []string{"bazaar"},
+		"scope": scope,
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func publicKeyPEM(key crypto.Signer) string {
+	der, _ := x509.MarshalPKIXPublicKey(key.Public())
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+var _ = Describe("Token verification", func() {
+	var rsaKey *rsa.PrivateKey
+
+	BeforeEach(func() {
+		var err error
+		rsaKey, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).To(BeNil())
+	})
+
+	Context("verification key", func() {
+		var verifier httphelpers.TokenVerifier
+
+		BeforeEach(func() {
+			var err error
+			verifier, err = httphelpers.NewKeyVerifier(publicKeyPEM(rsaKey), "https://uaa.example.com/oauth/token", "bazaar")
+			Expect(err).To(BeNil())
+		})
+
+		It("accepts a token signed by the key", func() {
+			claims, err := verifier.Verify(signToken(rsaKey, "", validClaims("bazaar.read", "openid")))
+
+			Expect(err).To(BeNil())
+			Expect(claims.Subject).To(Equal("bazaar-client"))
+			Expect(claims.Scope).To(ConsistOf("bazaar.read", "openid"))
+		})
+
+		It("accepts space separated scope and a single audience", func() {
+			claims := validClaims()
+			claims["scope"] = "bazaar.read bazaar.write"
+			claims["aud"] = "bazaar"
+
+			verified, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).To(BeNil())
+			Expect(verified.Scope).To(ConsistOf("bazaar.read", "bazaar.write"))
+		})
+
+		It("rejects a token signed by another key", func() {
+			otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(signToken(otherKey, "", validClaims("bazaar.read")))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("signature is invalid"))
+		})
+
+		It("rejects a tampered token", func() {
+			token := signToken(rsaKey, "", validClaims("bazaar.read"))
+			parts := strings.Split(token, ".")
+			payload, _ := json.Marshal(validClaims("bazaar.admin"))
+			parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+			_, err := verifier.Verify(strings.Join(parts, "."))
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("rejects unsigned tokens", func() {
+			header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+			payload, _ := json.Marshal(validClaims("bazaar.admin"))
+
+			_, err := verifier.Verify(header + "." + base64.RawURLEncoding.EncodeToString(payload) + ".")
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unsupported token signing algorithm"))
+		})
+
+		It("rejects an expired token", func() {
+			claims := validClaims("bazaar.read")
+			claims["exp"] = time.Now().Add(-5 * time.Minute).Unix()
+
+			_, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("expired"))
+		})
+
+		It("allows for clock skew", func() {
+			claims := validClaims("bazaar.read")
+			claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+			claims["nbf"] = time.Now().Add(10 * time.Second).Unix()
+
+			_, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).To(BeNil())
+		})
+
+		It("rejects a token without expiry", func() {
+			claims := validClaims("bazaar.read")
+			delete(claims, "exp")
+
+			_, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("expiry"))
+		})
+
+		It("rejects a token from another issuer", func() {
+			claims := validClaims("bazaar.read")
+			claims["iss"] = "https://elsewhere.example.com"
+
+			_, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("issuer"))
+		})
+
+		It("rejects a token for another audience", func() {
+			claims := validClaims("bazaar.read")
+			claims["aud"] = []string{"cloud_controller"}
+
+			_, err := verifier.Verify(signToken(rsaKey, "", claims))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("audience"))
+		})
+
+		It("errors on a key that isn't PEM", func() {
+			_, err := httphelpers.NewKeyVerifier("not a key", "", "")
+
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("JWKS", func() {
+		var ecKey *ecdsa.PrivateKey
+		var jwks map[string]interface{}
+		var fetches int
+		var server *httptest.Server
+		var verifier httphelpers.TokenVerifier
+
+		BeforeEach(func() {
+			var err error
+			ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			Expect(err).To(BeNil())
+
+			jwks = map[string]interface{}{
+				"keys": []map[string]string{
+					{
+						"kty": "RSA",
+						"kid": "key-1",
+						"use": "sig",
+						"alg": "RS256",
+						"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+						"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+					},
+					{
+						"kty": "EC",
+						"kid": "key-2",
+						"crv": "P-256",
+						"x":   base64.RawURLEncoding.EncodeToString(padded(ecKey.X, 32)),
+						"y":   base64.RawURLEncoding.EncodeToString(padded(ecKey.Y, 32)),
+					},
+				},
+			}
+			fetches = 0
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches++
+				json.NewEncoder(w).Encode(jwks)
+			}))
+			verifier = httphelpers.NewJWKSVerifier(http.DefaultClient, server.URL+"/token_keys", "", "")
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("verifies tokens signed by any key in the set", func() {
+			_, err := verifier.Verify(signToken(rsaKey, "key-1", validClaims("bazaar.read")))
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(signToken(ecKey, "key-2", validClaims("bazaar.read")))
+			Expect(err).To(BeNil())
+
+			Expect(fetches).To(Equal(1))
+		})
+
+		It("rejects a key used with the wrong algorithm", func() {
+			token := signToken(rsaKey, "key-2", validClaims("bazaar.read"))
+
+			_, err := verifier.Verify(token)
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("rejects tokens from unknown keys without refetching every time", func() {
+			_, err := verifier.Verify(signToken(rsaKey, "key-1", validClaims("bazaar.read")))
+			Expect(err).To(BeNil())
+
+			_, err = verifier.Verify(signToken(rsaKey, "key-3", validClaims("bazaar.read")))
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("key-3"))
+
+			Expect(fetches).To(Equal(1))
+		})
+
+		It("errors when the key set can't be fetched", func() {
+			server.Close()
+
+			_, err := verifier.Verify(signToken(rsaKey, "key-1", validClaims("bazaar.read")))
+
+			Expect(err).NotTo(BeNil())
+			Expect(err.Error()).To(ContainSubstring("unable to fetch token keys"))
+		})
+	})
+})
@@ -19,6 +19,9 @@ import (
 	"github.com/cf-platform-eng/kibosh/pkg/config"
 
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
 
 	api_v1 "k8s.io/api/core/v1"
 	meta_v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +32,8 @@ import (
 
 const registrySecretName = "registry-secret"
 
+var invalidSecretNameChars = regexp.MustCompile(`[^a-z0-9.-]+`)
+
 type PrivateRegistrySetup interface {
 	Setup() error
 }
@@ -49,33 +54,55 @@ func NewPrivateRegistrySetup(namespace string, serviceAccount string, cluster Cl
 	}
 }
 
+// Setup writes a pull secret for the default registry and for each additional registry with
+// credentials, and sets them all as the service account's image pull secrets
 func (p *privateRegistrySetup) Setup() error {
-	dockerConfig, _ := p.registryConfig.GetDockerConfigJson()
-	secret := &api_v1.Secret{
-		ObjectMeta: meta_v1.ObjectMeta{
-			Name: registrySecretName,
-		},
-		Type: api_v1.SecretTypeDockerConfigJson,
-		Data: map[string][]byte{
-			api_v1.DockerConfigJsonKey: dockerConfig,
-		},
-	}
-	_, err := p.UpdateOrCreateSecret(p.namespace, secret)
+	pullSecrets := []map[string]interface{}{}
+	for i, registry := range p.registryConfig.AllRegistries() {
+		var dockerConfig []byte
+		if i == 0 {
+			dockerConfig, _ = p.registryConfig.GetDockerConfigJson()
+		} else if registry.HasCredentials() {
+			dockerConfig, _ = registry.GetDockerConfigJson()
+		} else {
+			continue
+		}
 
-	if err != nil {
-		return err
+		name := pullSecretName(i, registry.Server)
+		secret := &api_v1.Secret{
+			ObjectMeta: meta_v1.ObjectMeta{
+				Name: name,
+			},
+			Type: api_v1.SecretTypeDockerConfigJson,
+			Data: map[string][]byte{
+				api_v1.DockerConfigJsonKey: dockerConfig,
+			},
+		}
+		_, err := p.UpdateOrCreateSecret(p.namespace, secret)
+		if err != nil {
+			return err
+		}
+		pullSecrets = append(pullSecrets, map[string]interface{}{"name": name})
 	}
 
 	patch := map[string]interface{}{
-		"imagePullSecrets": []map[string]interface{}{
-			{"name": registrySecretName},
-		},
+		"imagePullSecrets": pullSecrets,
 	}
 	patchJson, _ := json.Marshal(patch)
-	_, err = p.cluster.Patch(p.namespace, p.serviceAccount, types.MergePatchType, patchJson)
+	_, err := p.cluster.Patch(p.namespace, p.serviceAccount, types.MergePatchType, patchJson)
 	return err
 }
 
+// pullSecretName keeps the name of the default registry's secret, and names the others after their
+// server, eg "registry-secret-harbor.example.com-vendor"
+func pullSecretName(index int, server string) string {
+	if index == 0 {
+		return registrySecretName
+	}
+	name := invalidSecretNameChars.ReplaceAllString(strings.ToLower(server), "-")
+	return fmt.Sprintf("%s-%s", registrySecretName, strings.Trim(name, ".-"))
+}
+
 func (p *privateRegistrySetup) UpdateOrCreateSecret(nameSpace string, secret *api_v1.Secret) (*api_v1.Secret, error) {
 	_, err := p.cluster.GetSecret(nameSpace, secret.Name, meta_v1.GetOptions{})
 	if err != nil {
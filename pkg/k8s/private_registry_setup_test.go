@@ -110,6 +110,46 @@ var _ = Describe("Private Registry Setup", func() {
 		Expect(err.Error()).To(Equal("no patch for you"))
 	})
 
+	Context("additional registries", func() {
+		BeforeEach(func() {
+			registryConfig.Registries = config.Registries{
+				{Server: "harbor.example.com:8443/Vendor", User: "robot", Pass: "abc", Match: []string{"quay.io/vendor"}},
+				{Server: "public.example.com", Match: []string{"quay.io/public"}},
+			}
+		})
+
+		It("creates a secret for each registry with credentials", func() {
+			err := setup.Setup()
+
+			Expect(err).To(BeNil())
+			Expect(fakeCluster.UpdateSecretCallCount()).To(Equal(2))
+
+			_, secret := fakeCluster.UpdateSecretArgsForCall(1)
+			Expect(secret.Name).To(Equal("registry-secret-harbor.example.com-8443-vendor"))
+			expectedConfig, _ := registryConfig.Registries[0].GetDockerConfigJson()
+			Expect(secret.Data).To(Equal(map[string][]byte{
+				".dockerconfigjson": expectedConfig,
+			}))
+		})
+
+		It("adds every pull secret to the service account", func() {
+			err := setup.Setup()
+
+			Expect(err).To(BeNil())
+			_, _, _, data, _ := fakeCluster.PatchArgsForCall(0)
+			Expect(string(data)).To(Equal(`{"imagePullSecrets":[{"name":"registry-secret"},{"name":"registry-secret-harbor.example.com-8443-vendor"}]}`))
+		})
+
+		It("returns error when a secret can't be written", func() {
+			fakeCluster.UpdateSecretReturnsOnCall(1, nil, errors.New("no secret for you"))
+
+			err := setup.Setup()
+
+			Expect(err).NotTo(BeNil())
+			Expect(fakeCluster.PatchCallCount()).To(Equal(0))
+		})
+	})
+
 	It("update a docker registry secret when configured", func() {
 		fakeCluster.GetSecretReturns(nil, nil)
 		err := setup.Setup()
@@ -20,6 +20,7 @@ import (
 	"compress/gzip"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 )
@@ -50,6 +51,18 @@ func FileExists(path string) (bool, error) {
 
 // "borrowed" from https://medium.com/@skdomino/taring-untaring-files-in-go-6b07cf56bc07
 func TarZip(src string, writers ...io.Writer) error {
+	return TarZipUnder(src, "", writers...)
+}
+
+// TarZipUnder is TarZip with every entry placed under the directory root, the layout helm expects
+// of a chart archive
+func TarZipUnder(src string, root string, writers ...io.Writer) error {
+	return TarZipUnderSkipping(src, root, nil, writers...)
+}
+
+// TarZipUnderSkipping is TarZipUnder leaving out the files in skip, given as slash separated
+// paths relative to src
+func TarZipUnderSkipping(src string, root string, skip map[string]bool, writers ...io.Writer) error {
 	_, err := os.Stat(src)
 	if err != nil {
 		return err
@@ -74,6 +87,15 @@ func TarZip(src string, writers ...io.Writer) error {
 		}
 
 		header.Name = strings.TrimPrefix(strings.Replace(file, src, "", -1), string(filepath.Separator))
+		if skip[filepath.ToSlash(header.Name)] {
+			return nil
+		}
+		if root != "" {
+			header.Name = path.Join(root, filepath.ToSlash(header.Name))
+			if fi.IsDir() {
+				header.Name += "/"
+			}
+		}
 
 		if err := tw.WriteHeader(header); err != nil {
 			return err
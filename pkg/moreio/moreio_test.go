@@ -19,6 +19,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -108,5 +109,70 @@ var _ = Describe("moreio", func() {
 			Expect(err).To(BeNil())
 			Expect(header.Name).To(Equal("second"))
 		})
+
+		It("skips files", func() {
+			buff := &bytes.Buffer{}
+
+			path, err := ioutil.TempDir("", "")
+			defer os.RemoveAll(path)
+			Expect(err).To(BeNil())
+
+			err = os.Mkdir(filepath.Join(path, "plans"), 0777)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(path, "plans", "small.yaml"), []byte("small"), 0666)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(path, "plans", "small-creds.yaml"), []byte("creds"), 0666)
+			Expect(err).To(BeNil())
+
+			err = TarZipUnderSkipping(path, "spacebears", map[string]bool{"plans/small-creds.yaml": true}, buff)
+			Expect(err).To(BeNil())
+
+			gz, err := gzip.NewReader(buff)
+			Expect(err).To(BeNil())
+			tr := tar.NewReader(gz)
+
+			names := []string{}
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).To(BeNil())
+				names = append(names, header.Name)
+			}
+			Expect(names).To(ContainElement("spacebears/plans/small.yaml"))
+			Expect(names).NotTo(ContainElement("spacebears/plans/small-creds.yaml"))
+		})
+
+		It("places entries under root", func() {
+			buff := &bytes.Buffer{}
+
+			path, err := ioutil.TempDir("", "")
+			defer os.RemoveAll(path)
+			Expect(err).To(BeNil())
+
+			err = os.Mkdir(filepath.Join(path, "plans"), 0777)
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(path, "plans", "small.yaml"), []byte("small"), 0666)
+			Expect(err).To(BeNil())
+
+			err = TarZipUnder(path, "spacebears", buff)
+			Expect(err).To(BeNil())
+
+			gz, err := gzip.NewReader(buff)
+			Expect(err).To(BeNil())
+			tr := tar.NewReader(gz)
+
+			names := []string{}
+			for {
+				header, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				Expect(err).To(BeNil())
+				names = append(names, header.Name)
+			}
+			Expect(names).To(Equal([]string{"spacebears/", "spacebears/plans/", "spacebears/plans/small.yaml"}))
+		})
 	})
 })
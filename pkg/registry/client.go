@@ -0,0 +1,328 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var challengeParamRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// Client talks to a registry over the v2 API. Bearer tokens are kept per repository, as each is
+// scoped to the repository it was issued for. A Client is safe to use concurrently.
+type Client struct {
+	registryURL string
+	username    string
+	password    string
+	httpClient  *http.Client
+
+	// tokenLock guards tokens only, requests aren't serialized
+	tokenLock sync.Mutex
+	tokens    map[string]string
+}
+
+// NewClient talks to the registry at registryURL (eg "https://harbor.example.com"), sending
+// username and password, when set, as basic auth or to get bearer tokens
+func NewClient(registryURL string, username string, password string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		registryURL: strings.TrimSuffix(registryURL, "/"),
+		username:    username,
+		password:    password,
+		httpClient:  httpClient,
+		tokens:      map[string]string{},
+	}
+}
+
+// URL is the base url of the registry at host
+func URL(host string, plainHTTP bool) string {
+	if plainHTTP {
+		return "http://" + host
+	}
+	return "https://" + host
+}
+
+// Get fetches path (eg "/v2/charts/mysql/manifests/0.10.2") from repository, accepting the given
+// media types. Anything other than a 200 is an error.
+func (c *Client) Get(repository string, path string, accept ...string) (*http.Response, error) {
+	res, err := c.Do(repository, func() (*http.Request, error) {
+		req, err := http.NewRequest("GET", c.registryURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.Errorf("[%s] returned status code [%v]", path, res.StatusCode)
+	}
+	return res, nil
+}
+
+// GetJSON fetches path from repository and decodes it into target
+func (c *Client) GetJSON(repository string, path string, target interface{}, accept ...string) error {
+	res, err := c.Get(repository, path, accept...)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(target)
+}
+
+// ManifestExists checks for the manifest with a HEAD, accepting the given media types
+func (c *Client) ManifestExists(repository string, reference string, accept ...string) (bool, error) {
+	res, err := c.Do(repository, func() (*http.Request, error) {
+		req, err := http.NewRequest("HEAD", fmt.Sprintf("%s/v2/%s/manifests/%s", c.registryURL, repository, reference), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, mediaType := range accept {
+			req.Header.Add("Accept", mediaType)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Errorf("Checking manifest [%s:%s] returned status code [%v]", repository, reference, res.StatusCode)
+	}
+}
+
+func (c *Client) BlobExists(repository string, digest string) (bool, error) {
+	res, err := c.Do(repository, func() (*http.Request, error) {
+		return http.NewRequest("HEAD", fmt.Sprintf("%s/v2/%s/blobs/%s", c.registryURL, repository, digest), nil)
+	})
+	if err != nil {
+		return false, err
+	}
+	res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	default:
+		return false, errors.Errorf("Checking blob [%s] in [%s] returned status code [%v]", digest, repository, res.StatusCode)
+	}
+}
+
+// PushBlob uploads a blob in a single request, unless the repository already has it. open is called
+// for each attempt, as a request body can only be read once.
+func (c *Client) PushBlob(repository string, digest string, size int64, open func() (io.ReadCloser, error)) error {
+	exists, err := c.BlobExists(repository, digest)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	res, err := c.Do(repository, func() (*http.Request, error) {
+		return http.NewRequest("POST", fmt.Sprintf("%s/v2/%s/blobs/uploads/", c.registryURL, repository), nil)
+	})
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusAccepted {
+		return errors.Errorf("Starting upload of blob [%s] to [%s] returned status code [%v]", digest, repository, res.StatusCode)
+	}
+
+	uploadURL, err := res.Request.URL.Parse(res.Header.Get("Location"))
+	if err != nil {
+		return errors.Wrapf(err, "Registry returned an invalid upload location for [%s]", repository)
+	}
+	query := uploadURL.Query()
+	query.Set("digest", digest)
+	uploadURL.RawQuery = query.Encode()
+
+	res, err = c.Do(repository, func() (*http.Request, error) {
+		content, err := open()
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("PUT", uploadURL.String(), content)
+		if err != nil {
+			content.Close()
+			return nil, err
+		}
+		req.ContentLength = size
+		req.Header.Set("Content-Type", "application/octet-stream")
+		return req, nil
+	})
+	if err != nil {
+		return errors.Wrapf(err, "Unable to upload blob [%s] to [%s]", digest, repository)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return errors.Errorf("Uploading blob [%s] to [%s] returned status code [%v]: %s", digest, repository, res.StatusCode, readMessage(res.Body))
+	}
+	return nil
+}
+
+func (c *Client) PutManifest(repository string, reference string, mediaType string, content []byte) error {
+	res, err := c.Do(repository, func() (*http.Request, error) {
+		req, err := http.NewRequest("PUT", fmt.Sprintf("%s/v2/%s/manifests/%s", c.registryURL, repository, reference), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", mediaType)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusCreated {
+		return errors.Errorf("Pushing manifest [%s:%s] returned status code [%v]: %s", repository, reference, res.StatusCode, readMessage(res.Body))
+	}
+	return nil
+}
+
+// Do sends the request built by newRequest. When the registry challenges for a bearer token, a
+// token for the challenged scope is requested with the registry credentials and a new request sent,
+// as a request body can only be read once.
+func (c *Client) Do(repository string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	res, err := c.send(repository, newRequest)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusUnauthorized {
+		return res, nil
+	}
+
+	challenge := res.Header.Get("WWW-Authenticate")
+	res.Body.Close()
+	if !strings.HasPrefix(strings.ToLower(challenge), "bearer ") {
+		return nil, errors.Errorf("Registry returned status code [%v] for [%s]", http.StatusUnauthorized, repository)
+	}
+	token, err := c.fetchToken(challenge)
+	if err != nil {
+		return nil, err
+	}
+	c.setToken(repository, token)
+
+	return c.send(repository, newRequest)
+}
+
+func (c *Client) send(repository string, newRequest func() (*http.Request, error)) (*http.Response, error) {
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	if token := c.token(repository); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	return c.httpClient.Do(req)
+}
+
+func (c *Client) token(repository string) string {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	return c.tokens[repository]
+}
+
+func (c *Client) setToken(repository string, token string) {
+	c.tokenLock.Lock()
+	defer c.tokenLock.Unlock()
+	c.tokens[repository] = token
+}
+
+func (c *Client) fetchToken(challenge string) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengeParamRegex.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return "", errors.Errorf("Registry auth challenge [%s] has no realm", challenge)
+	}
+
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", err
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.Errorf("Registry token request returned status code [%v]", res.StatusCode)
+	}
+
+	tokenResponse := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(&tokenResponse)
+	if err != nil {
+		return "", err
+	}
+	if tokenResponse.Token != "" {
+		return tokenResponse.Token, nil
+	}
+	return tokenResponse.AccessToken, nil
+}
+
+func readMessage(body io.Reader) string {
+	message, _ := ioutil.ReadAll(io.LimitReader(body, 1024))
+	return strings.TrimSpace(string(message))
+}
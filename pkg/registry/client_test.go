@@ -0,0 +1,147 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registry_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/cf-platform-eng/kibosh/pkg/registry"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var server *httptest.Server
+	var lock sync.Mutex
+	var requests []string
+	var tokenScopes []string
+	var client *registry.Client
+
+	BeforeEach(func() {
+		requests = []string{}
+		tokenScopes = []string{}
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			defer lock.Unlock()
+			if r.URL.Path == "/token" {
+				user, pass, _ := r.BasicAuth()
+				if user != "reg-user" || pass != "reg-pass" {
+					w.WriteHeader(http.StatusUnauthorized)
+					return
+				}
+				scope := r.URL.Query().Get("scope")
+				tokenScopes = append(tokenScopes, scope)
+				w.Write([]byte(fmt.Sprintf(`{"token": "token-for-%s"}`, scope)))
+				return
+			}
+			requests = append(requests, r.Method+" "+r.URL.Path)
+
+			repository := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/", 2)[0]
+			scope := fmt.Sprintf("repository:%s:pull", repository)
+			if r.Header.Get("Authorization") != "Bearer token-for-"+scope {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="http://%s/token",service="test",scope="%s"`, r.Host, scope))
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if strings.HasSuffix(r.URL.Path, "/manifests/missing") {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{"schemaVersion": 2}`))
+		}))
+
+		client = registry.NewClient(server.URL, "reg-user", "reg-pass", nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("gets a token for the challenged scope and retries", func() {
+		res, err := client.Get("charts/mysql", "/v2/charts/mysql/manifests/0.10.2")
+		Expect(err).To(BeNil())
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		Expect(err).To(BeNil())
+
+		Expect(string(body)).To(Equal(`{"schemaVersion": 2}`))
+		Expect(tokenScopes).To(Equal([]string{"repository:charts/mysql:pull"}))
+	})
+
+	It("keeps tokens per repository", func() {
+		exists, err := client.ManifestExists("charts/mysql", "0.10.2")
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		exists, err = client.ManifestExists("charts/redis", "5.0.0")
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+		exists, err = client.ManifestExists("charts/mysql", "0.10.3")
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeTrue())
+
+		Expect(tokenScopes).To(Equal([]string{"repository:charts/mysql:pull", "repository:charts/redis:pull"}))
+	})
+
+	It("can be used concurrently", func() {
+		var wg sync.WaitGroup
+		errs := make(chan error, 10)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := client.ManifestExists(fmt.Sprintf("charts/chart-%d", i%3), "1.0.0")
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			Expect(err).To(BeNil())
+		}
+	})
+
+	It("reports a missing manifest", func() {
+		exists, err := client.ManifestExists("charts/mysql", "missing")
+		Expect(err).To(BeNil())
+		Expect(exists).To(BeFalse())
+	})
+
+	It("errors when a get doesn't succeed", func() {
+		_, err := client.Get("charts/mysql", "/v2/charts/mysql/manifests/missing")
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("404"))
+	})
+
+	It("errors with bad credentials", func() {
+		client = registry.NewClient(server.URL, "reg-user", "wrong", nil)
+
+		var target interface{}
+		err := client.GetJSON("charts/mysql", "/v2/charts/mysql/manifests/0.10.2", &target)
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("token"))
+	})
+
+	It("builds registry urls", func() {
+		Expect(registry.URL("harbor.example.com", false)).To(Equal("https://harbor.example.com"))
+		Expect(registry.URL("localhost:5000", true)).To(Equal("http://localhost:5000"))
+	})
+})
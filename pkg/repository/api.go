@@ -16,6 +16,7 @@
 package repository
 
 import (
+	"encoding/json"
 	"net/http"
 	"strings"
 
@@ -28,6 +29,8 @@ import (
 
 type API interface {
 	ReloadCharts() http.Handler
+	QuarantinedCharts() http.Handler
+	ChartRevision() http.Handler
 }
 
 type api struct {
@@ -67,6 +70,55 @@ func (api *api) ReloadCharts() http.Handler {
 	})
 }
 
+func (api *api) QuarantinedCharts() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		quarantine, err := api.repository.GetQuarantinedCharts()
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		serialized, err := json.Marshal(quarantine)
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(serialized)
+	})
+}
+
+func (api *api) ChartRevision() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		revisioned, ok := api.repository.(RevisionedRepository)
+		if !ok {
+			w.WriteHeader(404)
+			w.Write([]byte("Charts are not served from a revisioned source"))
+			return
+		}
+
+		revision, err := revisioned.Revision()
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		serialized, err := json.Marshal(map[string]string{"revision": revision})
+		if err != nil {
+			w.WriteHeader(500)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(serialized)
+	})
+}
+
 func (api *api) refreshCloudFoundry() error {
 	bro, err := api.cfClient.GetServiceBrokerByName(api.conf.CFClientConfig.BrokerName)
 
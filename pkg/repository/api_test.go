@@ -69,6 +69,63 @@ var _ = Describe("Api", func() {
 		Expect(fakeRepo.ClearCacheCallCount()).To(Equal(1))
 	})
 
+	It("lists quarantined charts", func() {
+		fakeRepo.GetQuarantinedChartsReturns([]repository.QuarantinedChart{
+			{Name: "mysql", Path: "/charts/mysql", Reason: "values.yaml is requires"},
+		}, nil)
+		req, err := http.NewRequest("GET", "/quarantined_charts", nil)
+		Expect(err).To(BeNil())
+
+		recorder := httptest.NewRecorder()
+
+		apiHandler := api.QuarantinedCharts()
+		apiHandler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(Equal(
+			`[{"name":"mysql","path":"/charts/mysql","reason":"values.yaml is requires"}]`,
+		))
+	})
+
+	It("500s when quarantined charts can't be loaded", func() {
+		fakeRepo.GetQuarantinedChartsReturns(nil, errors.New("no such directory"))
+		req, err := http.NewRequest("GET", "/quarantined_charts", nil)
+		Expect(err).To(BeNil())
+
+		recorder := httptest.NewRecorder()
+
+		apiHandler := api.QuarantinedCharts()
+		apiHandler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(500))
+	})
+
+	It("404s chart revision when repository isn't revisioned", func() {
+		req, err := http.NewRequest("GET", "/chart_revision", nil)
+		Expect(err).To(BeNil())
+
+		recorder := httptest.NewRecorder()
+
+		apiHandler := api.ChartRevision()
+		apiHandler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(404))
+	})
+
+	It("returns chart revision", func() {
+		api = repository.NewAPI(&revisionedRepo{revision: "9fceb02d0ae598e95dc970b74767f19372d61af8"}, &cfClient, conf, logger)
+		req, err := http.NewRequest("GET", "/chart_revision", nil)
+		Expect(err).To(BeNil())
+
+		recorder := httptest.NewRecorder()
+
+		apiHandler := api.ChartRevision()
+		apiHandler.ServeHTTP(recorder, req)
+
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(Equal(`{"revision":"9fceb02d0ae598e95dc970b74767f19372d61af8"}`))
+	})
+
 	Context("reload self in cf", func() {
 		It("calls cf to create broker in reload charts", func() {
 			cfClient.GetServiceBrokerByNameReturns(
@@ -138,3 +195,12 @@ var _ = Describe("Api", func() {
 		})
 	})
 })
+
+type revisionedRepo struct {
+	repositoryfakes.FakeRepository
+	revision string
+}
+
+func (r *revisionedRepo) Revision() (string, error) {
+	return r.revision, nil
+}
@@ -0,0 +1,162 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/moreio"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// RevisionedRepository is implemented by repositories that know which source revision produced the catalog.
+type RevisionedRepository interface {
+	Revision() (string, error)
+}
+
+type gitRepository struct {
+	syncedCache
+	url            string
+	branch         string
+	chartPath      string
+	registryConfig *config.RegistryConfig
+
+	syncLock sync.Mutex
+}
+
+// NewGitRepository serves charts from a checkout of a git branch in checkoutDir. Syncing fetches the
+// branch and moves the checkout to the new commit, but only if every chart at that commit loads.
+func NewGitRepository(conf *config.GitRepoConfig, checkoutDir string, registryConfig *config.RegistryConfig, logger *logrus.Logger) (SyncedRepository, error) {
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errors.Wrap(err, "A git repository requires the git executable")
+	}
+
+	chartPath := filepath.Clean(conf.ChartPath)
+	if filepath.IsAbs(chartPath) || strings.HasPrefix(chartPath, "..") {
+		return nil, errors.Errorf("Chart path [%s] must be relative to the git repository", conf.ChartPath)
+	}
+
+	return &gitRepository{
+		syncedCache: syncedCache{
+			cache:    NewRepository(filepath.Join(checkoutDir, chartPath), Options{Registries: registryConfig}, logger),
+			cacheDir: checkoutDir,
+			source:   "git repository",
+			logger:   logger,
+		},
+		url:            conf.URL,
+		branch:         conf.Branch,
+		chartPath:      chartPath,
+		registryConfig: registryConfig,
+	}, nil
+}
+
+func (g *gitRepository) Sync() error {
+	g.syncLock.Lock()
+	defer g.syncLock.Unlock()
+
+	if !moreio.DirExistsAndIsReadable(filepath.Join(g.cacheDir, ".git")) {
+		_, err := g.git("init")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := g.git("fetch", "--quiet", g.url, g.branch)
+	if err != nil {
+		return err
+	}
+	fetched, err := g.git("rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return err
+	}
+
+	current, _ := g.Revision()
+	if fetched == current {
+		return nil
+	}
+
+	err = g.validate(fetched)
+	if err != nil {
+		return errors.Wrapf(err, "Not advancing to [%s]", fetched)
+	}
+
+	_, err = g.git("checkout", "--quiet", "--force", "--detach", fetched)
+	if err != nil {
+		return err
+	}
+	g.logger.Info(fmt.Sprintf("Advanced charts from [%s] to [%s] of [%s]", current, fetched, g.branch))
+	return nil
+}
+
+func (g *gitRepository) ClearCache() error {
+	return g.syncAndReload(g.Sync)
+}
+
+// Revision is the commit currently checked out, which is the one the catalog is loaded from
+func (g *gitRepository) Revision() (string, error) {
+	return g.git("rev-parse", "--verify", "--quiet", "HEAD")
+}
+
+// validate checks out commit into a temporary worktree and loads every chart in it
+func (g *gitRepository) validate(commit string) error {
+	worktree, err := ioutil.TempDir("", "git-chart-validate-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(worktree)
+
+	_, err = g.git("worktree", "add", "--detach", worktree, commit)
+	if err != nil {
+		return err
+	}
+	defer g.git("worktree", "remove", "--force", worktree)
+
+	candidate := NewRepository(filepath.Join(worktree, g.chartPath), Options{Registries: g.registryConfig}, g.logger)
+	_, err = candidate.GetCharts()
+	if err != nil {
+		return err
+	}
+	quarantine, err := candidate.GetQuarantinedCharts()
+	if err != nil {
+		return err
+	}
+	if len(quarantine) > 0 {
+		names := []string{}
+		for _, quarantined := range quarantine {
+			names = append(names, quarantined.Name)
+		}
+		return errors.Errorf("Charts [%s] failed to load: %s", strings.Join(names, ", "), quarantine[0].Reason)
+	}
+	return nil
+}
+
+func (g *gitRepository) git(args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", g.cacheDir}, args...)...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", errors.Wrapf(err, "git %s: %s", args[0], strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
@@ -0,0 +1,157 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Git repository", func() {
+	var logger *logrus.Logger
+	var sourceDir string
+	var checkoutDir string
+	var conf *config.GitRepoConfig
+
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{
+			"-C", sourceDir, "-c", "user.name=kibosh", "-c", "user.email=kibosh@example.com",
+		}, args...)...)
+		output, err := cmd.CombinedOutput()
+		Expect(err).To(BeNil(), string(output))
+		return strings.TrimSpace(string(output))
+	}
+
+	commitChart := func(name string, version string) string {
+		testChart := test.DefaultChart()
+		testChart.ChartYaml = []byte(`
+name: ` + name + `
+description: a chart from git
+version: ` + version + `
+`)
+		chartDir := filepath.Join(sourceDir, "charts", name)
+		err := os.MkdirAll(chartDir, 0700)
+		Expect(err).To(BeNil())
+		err = testChart.WriteChart(chartDir)
+		Expect(err).To(BeNil())
+
+		git("add", "-A")
+		git("commit", "--quiet", "-m", name+" "+version)
+		return git("rev-parse", "HEAD")
+	}
+
+	BeforeEach(func() {
+		logger = logrus.New()
+
+		var err error
+		sourceDir, err = ioutil.TempDir("", "git-source-")
+		Expect(err).To(BeNil())
+		checkoutDir, err = ioutil.TempDir("", "git-checkout-")
+		Expect(err).To(BeNil())
+
+		git("init", "--quiet")
+		git("checkout", "--quiet", "-b", "release")
+
+		conf = &config.GitRepoConfig{
+			URL:       sourceDir,
+			Branch:    "release",
+			ChartPath: "charts",
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(sourceDir)
+		os.RemoveAll(checkoutDir)
+	})
+
+	It("serves charts from the branch and records the revision", func() {
+		sha := commitChart("spacebears", "0.0.1")
+
+		gitRepository, err := repository.NewGitRepository(conf, checkoutDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(gitRepository.Sync()).To(BeNil())
+
+		charts, err := gitRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+		Expect(charts[0].Metadata.Name).To(Equal("spacebears"))
+
+		revision, err := gitRepository.(repository.RevisionedRepository).Revision()
+		Expect(err).To(BeNil())
+		Expect(revision).To(Equal(sha))
+	})
+
+	It("follows new commits on reload", func() {
+		commitChart("spacebears", "0.0.1")
+
+		gitRepository, err := repository.NewGitRepository(conf, checkoutDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(gitRepository.Sync()).To(BeNil())
+
+		sha := commitChart("mysql", "0.0.1")
+		Expect(gitRepository.ClearCache()).To(BeNil())
+
+		charts, err := gitRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(2))
+
+		revision, err := gitRepository.(repository.RevisionedRepository).Revision()
+		Expect(err).To(BeNil())
+		Expect(revision).To(Equal(sha))
+	})
+
+	It("refuses to advance to a commit with a broken chart", func() {
+		sha := commitChart("spacebears", "0.0.1")
+
+		gitRepository, err := repository.NewGitRepository(conf, checkoutDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(gitRepository.Sync()).To(BeNil())
+
+		err = ioutil.WriteFile(filepath.Join(sourceDir, "charts", "spacebears", "Chart.yaml"), []byte(`bad::::yaml`), 0666)
+		Expect(err).To(BeNil())
+		git("commit", "--quiet", "-am", "break spacebears")
+
+		err = gitRepository.Sync()
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("spacebears"))
+
+		revision, err := gitRepository.(repository.RevisionedRepository).Revision()
+		Expect(err).To(BeNil())
+		Expect(revision).To(Equal(sha))
+
+		Expect(gitRepository.ClearCache()).To(BeNil())
+		charts, err := gitRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+	})
+
+	It("rejects a chart path outside the repository", func() {
+		conf.ChartPath = "../charts"
+
+		_, err := repository.NewGitRepository(conf, checkoutDir, &config.RegistryConfig{}, logger)
+		Expect(err).NotTo(BeNil())
+	})
+})
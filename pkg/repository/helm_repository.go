@@ -0,0 +1,208 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/repo"
+)
+
+type ChartPin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type ChartPins struct {
+	Charts []ChartPin `json:"charts"`
+}
+
+type helmIndexRepository struct {
+	syncedCache
+	repoURL    *url.URL
+	username   string
+	password   string
+	pinFile    string
+	httpClient *http.Client
+}
+
+// NewHelmIndexRepository serves the charts listed in the pin file from a helm chart repository
+// (a server hosting index.yaml). Downloaded charts are kept in cacheDir, so the broker keeps serving
+// the last synced charts when the chart repository is unreachable.
+func NewHelmIndexRepository(conf *config.HelmRepoConfig, cacheDir string, registryConfig *config.RegistryConfig, logger *logrus.Logger) (SyncedRepository, error) {
+	repoURL, err := url.Parse(strings.TrimSuffix(conf.URL, "/") + "/")
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid helm repository url")
+	}
+
+	httpClient, err := httphelpers.NewHTTPClient(conf.CACertFile, "", "", conf.SkipSSLValidation)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to configure helm repository client")
+	}
+	httpClient.Timeout = 60 * time.Second
+
+	return &helmIndexRepository{
+		syncedCache: syncedCache{
+			cache:    NewRepository(cacheDir, Options{Registries: registryConfig}, logger),
+			cacheDir: cacheDir,
+			source:   "helm repository",
+			logger:   logger,
+		},
+		repoURL:    repoURL,
+		username:   conf.Username,
+		password:   conf.Password,
+		pinFile:    conf.PinFile,
+		httpClient: httpClient,
+	}, nil
+}
+
+// Sync downloads any pinned chart version missing from the cache, activates the pinned versions
+// and removes charts that are no longer pinned.
+func (h *helmIndexRepository) Sync() error {
+	pins, err := h.loadPins()
+	if err != nil {
+		return err
+	}
+
+	index, err := h.fetchIndex()
+	if err != nil {
+		return err
+	}
+
+	pinned := map[string]bool{}
+	for _, pin := range pins.Charts {
+		pinned[pin.Name] = true
+
+		chartVersion, err := index.Get(pin.Name, pin.Version)
+		if err != nil {
+			return errors.Wrapf(err, "Pinned chart [%s] version [%s] not in helm repository", pin.Name, pin.Version)
+		}
+
+		if !h.isCached(pin.Name, chartVersion.Version) {
+			err = h.downloadAndSave(chartVersion)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = h.cache.ActivateChartVersion(pin.Name, chartVersion.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return h.removeUnlisted(pinned)
+}
+
+func (h *helmIndexRepository) ClearCache() error {
+	return h.syncAndReload(h.Sync)
+}
+
+func (h *helmIndexRepository) loadPins() (*ChartPins, error) {
+	pinBytes, err := ioutil.ReadFile(h.pinFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read helm repository pin file")
+	}
+
+	pins := &ChartPins{}
+	err = yaml.Unmarshal(pinBytes, pins)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse helm repository pin file")
+	}
+	for _, pin := range pins.Charts {
+		if pin.Name == "" || pin.Version == "" {
+			return nil, errors.Errorf("Pin [%s] requires both name and version", pin.Name)
+		}
+	}
+	return pins, nil
+}
+
+func (h *helmIndexRepository) fetchIndex() (*repo.IndexFile, error) {
+	indexURL, err := h.repoURL.Parse("index.yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := h.get(indexURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to fetch helm repository index")
+	}
+	defer res.Body.Close()
+
+	indexBytes, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &repo.IndexFile{}
+	err = yaml.Unmarshal(indexBytes, index)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to parse helm repository index")
+	}
+	index.SortEntries()
+	return index, nil
+}
+
+func (h *helmIndexRepository) downloadAndSave(chartVersion *repo.ChartVersion) error {
+	if len(chartVersion.URLs) == 0 {
+		return errors.Errorf("Chart [%s] version [%s] has no download url", chartVersion.Name, chartVersion.Version)
+	}
+	chartURL, err := h.repoURL.Parse(chartVersion.URLs[0])
+	if err != nil {
+		return err
+	}
+
+	h.logger.Info(fmt.Sprintf("Downloading chart [%s] version [%s] from [%s]", chartVersion.Name, chartVersion.Version, chartURL.String()))
+	res, err := h.get(chartURL)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to download chart [%s]", chartVersion.Name)
+	}
+	defer res.Body.Close()
+
+	return h.saveVerified(res.Body, chartVersion.Digest, chartVersion.Name, chartVersion.Version)
+}
+
+// get fetches u, sending the repository credentials only to the repository's own host, since
+// index entries can point charts anywhere
+func (h *helmIndexRepository) get(u *url.URL) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if h.username != "" && u.Host == h.repoURL.Host {
+		req.SetBasicAuth(h.username, h.password)
+	}
+
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		res.Body.Close()
+		return nil, errors.Errorf("[%s] returned status code [%v]", u, res.StatusCode)
+	}
+	return res, nil
+}
@@ -0,0 +1,250 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/provenance"
+	"k8s.io/helm/pkg/repo"
+)
+
+var _ = Describe("Helm index repository", func() {
+	var logger *logrus.Logger
+	var serverDir string
+	var cacheDir string
+	var pinFile string
+	var server *httptest.Server
+	var serverUp bool
+	var requestUser string
+	var conf *config.HelmRepoConfig
+
+	packageChart := func(version string) string {
+		testChart := test.DefaultChart()
+		testChart.ChartYaml = []byte(`
+name: spacebears
+description: spacebears service and spacebears broker helm chart
+version: ` + version + `
+`)
+		chartDir := filepath.Join(serverDir, "src-"+version, "spacebears")
+		err := os.MkdirAll(chartDir, 0700)
+		Expect(err).To(BeNil())
+		err = testChart.WriteChart(chartDir)
+		Expect(err).To(BeNil())
+
+		chart, err := helm.NewChart(chartDir, nil, logger)
+		Expect(err).To(BeNil())
+		tarFile, err := chartutil.Save(&chart.Chart, serverDir)
+		Expect(err).To(BeNil())
+		return tarFile
+	}
+
+	writeIndex := func(versions ...string) {
+		index := repo.NewIndexFile()
+		for _, version := range versions {
+			tarFile := packageChart(version)
+			digest, err := provenance.DigestFile(tarFile)
+			Expect(err).To(BeNil())
+			chart, err := chartutil.Load(tarFile)
+			Expect(err).To(BeNil())
+			index.Add(chart.Metadata, filepath.Base(tarFile), "", digest)
+		}
+		err := index.WriteFile(filepath.Join(serverDir, "index.yaml"), 0600)
+		Expect(err).To(BeNil())
+	}
+
+	writePins := func(pins ...repository.ChartPin) {
+		pinBytes, err := yaml.Marshal(repository.ChartPins{Charts: pins})
+		Expect(err).To(BeNil())
+		err = ioutil.WriteFile(pinFile, pinBytes, 0600)
+		Expect(err).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		logger = logrus.New()
+
+		var err error
+		serverDir, err = ioutil.TempDir("", "helm-repo-")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "helm-repo-cache-")
+		Expect(err).To(BeNil())
+		pinFile = filepath.Join(serverDir, "pins.yml")
+
+		serverUp = true
+		requestUser = ""
+		fileServer := http.FileServer(http.Dir(serverDir))
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !serverUp {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			requestUser, _, _ = r.BasicAuth()
+			fileServer.ServeHTTP(w, r)
+		}))
+
+		conf = &config.HelmRepoConfig{
+			URL:      server.URL,
+			Username: "repo-user",
+			Password: "repo-pass",
+			PinFile:  pinFile,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(serverDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	It("serves pinned chart versions", func() {
+		writeIndex("0.0.1", "0.0.2")
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		charts, err := helmRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+		Expect(charts[0].Metadata.Version).To(Equal("0.0.1"))
+		Expect(requestUser).To(Equal("repo-user"))
+	})
+
+	It("moves to a new pin", func() {
+		writeIndex("0.0.1", "0.0.2")
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.2"})
+		Expect(helmRepository.ClearCache()).To(BeNil())
+
+		charts, err := helmRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+		Expect(charts[0].Metadata.Version).To(Equal("0.0.2"))
+	})
+
+	It("removes charts that are no longer pinned", func() {
+		writeIndex("0.0.1")
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		writePins()
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		charts, err := helmRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(BeEmpty())
+	})
+
+	It("keeps serving cached charts when the repository is down", func() {
+		writeIndex("0.0.1")
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		serverUp = false
+		Expect(helmRepository.Sync()).NotTo(BeNil())
+		Expect(helmRepository.ClearCache()).To(BeNil())
+
+		charts, err := helmRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+	})
+
+	It("errors when pinned version isn't in the index", func() {
+		writeIndex("0.0.1")
+		writePins(repository.ChartPin{Name: "spacebears", Version: "9.9.9"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+
+		err = helmRepository.Sync()
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("9.9.9"))
+	})
+
+	It("rejects a chart not matching the index digest", func() {
+		writeIndex("0.0.1")
+		index, err := repo.LoadIndexFile(filepath.Join(serverDir, "index.yaml"))
+		Expect(err).To(BeNil())
+		index.Entries["spacebears"][0].Digest = "0000"
+		err = index.WriteFile(filepath.Join(serverDir, "index.yaml"), 0600)
+		Expect(err).To(BeNil())
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+
+		err = helmRepository.Sync()
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("Digest"))
+	})
+
+	It("only sends credentials to the repository host", func() {
+		otherUser := "unset"
+		otherServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			otherUser, _, _ = r.BasicAuth()
+			http.FileServer(http.Dir(serverDir)).ServeHTTP(w, r)
+		}))
+		defer otherServer.Close()
+
+		writeIndex("0.0.1")
+		index, err := repo.LoadIndexFile(filepath.Join(serverDir, "index.yaml"))
+		Expect(err).To(BeNil())
+		index.Entries["spacebears"][0].URLs = []string{otherServer.URL + "/spacebears-0.0.1.tgz"}
+		err = index.WriteFile(filepath.Join(serverDir, "index.yaml"), 0600)
+		Expect(err).To(BeNil())
+		writePins(repository.ChartPin{Name: "spacebears", Version: "0.0.1"})
+
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+		Expect(helmRepository.Sync()).To(BeNil())
+
+		Expect(requestUser).To(Equal("repo-user"))
+		Expect(otherUser).To(Equal(""))
+	})
+
+	It("does not allow uploads", func() {
+		helmRepository, err := repository.NewHelmIndexRepository(conf, cacheDir, &config.RegistryConfig{}, logger)
+		Expect(err).To(BeNil())
+
+		Expect(helmRepository.SaveChart("spacebears-0.0.1.tgz")).NotTo(BeNil())
+		Expect(helmRepository.DeleteChart("spacebears")).NotTo(BeNil())
+	})
+})
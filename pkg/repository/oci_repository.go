@@ -0,0 +1,176 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/httphelpers"
+	"github.com/cf-platform-eng/kibosh/pkg/registry"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	OCIManifestMediaType        = "application/vnd.oci.image.manifest.v1+json"
+	HelmChartConfigMediaType    = "application/vnd.cncf.helm.config.v1+json"
+	HelmChartContentMediaType   = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	legacyChartContentMediaType = "application/tar+gzip"
+)
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociChartConfig struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type ociRepository struct {
+	syncedCache
+	client *registry.Client
+	charts []string
+
+	// syncLock serializes syncs
+	syncLock sync.Mutex
+}
+
+// NewOCIRepository serves the charts referenced by ociConf.Charts (eg "charts/mysql:0.10.2") from the
+// registry in registryConf, using the same credentials and CA as images. The last listed tag of a
+// chart is the active one. Pulled charts are kept in cacheDir.
+func NewOCIRepository(registryConf *config.RegistryConfig, ociConf *config.OCIChartConfig, cacheDir string, logger *logrus.Logger) (SyncedRepository, error) {
+	if !registryConf.HasRegistryConfig() {
+		return nil, errors.New("OCI charts require a registry server")
+	}
+	httpClient, err := httphelpers.NewHTTPClient(registryConf.CACertFile, "", "", false)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to configure registry client")
+	}
+	httpClient.Timeout = 60 * time.Second
+
+	return &ociRepository{
+		syncedCache: syncedCache{
+			cache:    NewRepository(cacheDir, Options{Registries: registryConf}, logger),
+			cacheDir: cacheDir,
+			source:   "OCI registry",
+			logger:   logger,
+		},
+		client: registry.NewClient(registry.URL(registryConf.Server, ociConf.PlainHTTP), registryConf.User, registryConf.Pass, httpClient),
+		charts: ociConf.Charts,
+	}, nil
+}
+
+func (o *ociRepository) Sync() error {
+	o.syncLock.Lock()
+	defer o.syncLock.Unlock()
+
+	listed := map[string]bool{}
+	for _, chartRef := range o.charts {
+		repository, reference, err := parseChartReference(chartRef)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := o.fetchManifest(repository, reference)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to fetch manifest for [%s]", chartRef)
+		}
+		if manifest.Config.MediaType != HelmChartConfigMediaType {
+			return errors.Errorf("[%s] is not a helm chart, config media type is [%s]", chartRef, manifest.Config.MediaType)
+		}
+		chartLayer, err := findChartLayer(manifest)
+		if err != nil {
+			return errors.Wrapf(err, "[%s]", chartRef)
+		}
+
+		chartConfig := &ociChartConfig{}
+		err = o.client.GetJSON(repository, fmt.Sprintf("/v2/%s/blobs/%s", repository, manifest.Config.Digest), chartConfig)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to fetch chart config for [%s]", chartRef)
+		}
+		if chartConfig.Name == "" || chartConfig.Version == "" {
+			return errors.Errorf("Chart config for [%s] is missing name or version", chartRef)
+		}
+		listed[chartConfig.Name] = true
+
+		if !o.isCached(chartConfig.Name, chartConfig.Version) {
+			o.logger.Info(fmt.Sprintf("Pulling chart [%s] version [%s] from [%s]", chartConfig.Name, chartConfig.Version, chartRef))
+			res, err := o.client.Get(repository, fmt.Sprintf("/v2/%s/blobs/%s", repository, chartLayer.Digest))
+			if err != nil {
+				return errors.Wrapf(err, "Unable to pull chart [%s]", chartRef)
+			}
+			err = o.saveVerified(res.Body, chartLayer.Digest, chartConfig.Name, chartConfig.Version)
+			res.Body.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		err = o.cache.ActivateChartVersion(chartConfig.Name, chartConfig.Version)
+		if err != nil {
+			return err
+		}
+	}
+
+	return o.removeUnlisted(listed)
+}
+
+func (o *ociRepository) ClearCache() error {
+	return o.syncAndReload(o.Sync)
+}
+
+// parseChartReference splits "repository:tag" or "repository@digest"
+func parseChartReference(chartRef string) (string, string, error) {
+	if i := strings.LastIndex(chartRef, "@"); i > 0 {
+		return chartRef[:i], chartRef[i+1:], nil
+	}
+	i := strings.LastIndex(chartRef, ":")
+	if i < 1 || strings.Contains(chartRef[i:], "/") {
+		return "", "", errors.Errorf("Chart reference [%s] requires a tag or digest", chartRef)
+	}
+	return chartRef[:i], chartRef[i+1:], nil
+}
+
+func findChartLayer(manifest *ociManifest) (*ociDescriptor, error) {
+	for i, layer := range manifest.Layers {
+		if layer.MediaType == HelmChartContentMediaType || layer.MediaType == legacyChartContentMediaType {
+			return &manifest.Layers[i], nil
+		}
+	}
+	return nil, errors.New("No chart content layer found in manifest")
+}
+
+func (o *ociRepository) fetchManifest(repository string, reference string) (*ociManifest, error) {
+	manifest := &ociManifest{}
+	err := o.client.GetJSON(repository, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), manifest, OCIManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,297 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cf-platform-eng/kibosh/pkg/config"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/sirupsen/logrus"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// fakeRegistry is a minimal stand in for an OCI registry using token auth
+type fakeRegistry struct {
+	manifests map[string][]byte
+	blobs     map[string][]byte
+	up        bool
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.up {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	if r.URL.Path == "/token" {
+		user, pass, _ := r.BasicAuth()
+		if user != "reg-user" || pass != "reg-pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"token": "registry-token"}`))
+		return
+	}
+
+	if r.Header.Get("Authorization") != "Bearer registry-token" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s://%s/token",service="fake-registry",scope="repository:charts:pull"`, scheme, r.Host))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/manifests/", 2)
+	if len(parts) == 2 {
+		manifest, ok := f.manifests[parts[0]+":"+parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", repository.OCIManifestMediaType)
+		w.Write(manifest)
+		return
+	}
+
+	parts = strings.SplitN(strings.TrimPrefix(r.URL.Path, "/v2/"), "/blobs/", 2)
+	if len(parts) == 2 {
+		blob, ok := f.blobs[parts[1]]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(blob)
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (f *fakeRegistry) addBlob(content []byte) string {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	f.blobs[digest] = content
+	return digest
+}
+
+func (f *fakeRegistry) pushChart(ref string, chartConfig []byte, chartContent []byte) {
+	manifest := map[string]interface{}{
+		"schemaVersion": 2,
+		"config": map[string]interface{}{
+			"mediaType": repository.HelmChartConfigMediaType,
+			"digest":    f.addBlob(chartConfig),
+			"size":      len(chartConfig),
+		},
+		"layers": []map[string]interface{}{{
+			"mediaType": repository.HelmChartContentMediaType,
+			"digest":    f.addBlob(chartContent),
+			"size":      len(chartContent),
+		}},
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	Expect(err).To(BeNil())
+	f.manifests[ref] = manifestBytes
+}
+
+var _ = Describe("OCI repository", func() {
+	var logger *logrus.Logger
+	var workDir string
+	var cacheDir string
+	var registry *fakeRegistry
+	var server *httptest.Server
+	var registryConf *config.RegistryConfig
+
+	packageChart := func(version string) []byte {
+		testChart := test.DefaultChart()
+		testChart.ChartYaml = []byte(`
+name: spacebears
+description: spacebears service and spacebears broker helm chart
+version: ` + version + `
+`)
+		chartDir := filepath.Join(workDir, "src-"+version, "spacebears")
+		err := os.MkdirAll(chartDir, 0700)
+		Expect(err).To(BeNil())
+		err = testChart.WriteChart(chartDir)
+		Expect(err).To(BeNil())
+
+		chart, err := helm.NewChart(chartDir, nil, logger)
+		Expect(err).To(BeNil())
+		tarFile, err := chartutil.Save(&chart.Chart, workDir)
+		Expect(err).To(BeNil())
+		content, err := ioutil.ReadFile(tarFile)
+		Expect(err).To(BeNil())
+		return content
+	}
+
+	pushSpacebears := func(version string) {
+		registry.pushChart(
+			"charts/spacebears:"+version,
+			[]byte(`{"name": "spacebears", "version": "`+version+`"}`),
+			packageChart(version),
+		)
+	}
+
+	BeforeEach(func() {
+		logger = logrus.New()
+
+		var err error
+		workDir, err = ioutil.TempDir("", "oci-work-")
+		Expect(err).To(BeNil())
+		cacheDir, err = ioutil.TempDir("", "oci-cache-")
+		Expect(err).To(BeNil())
+
+		registry = &fakeRegistry{
+			manifests: map[string][]byte{},
+			blobs:     map[string][]byte{},
+			up:        true,
+		}
+		server = httptest.NewServer(registry)
+
+		registryConf = &config.RegistryConfig{
+			Server: strings.TrimPrefix(server.URL, "http://"),
+			User:   "reg-user",
+			Pass:   "reg-pass",
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(workDir)
+		os.RemoveAll(cacheDir)
+	})
+
+	It("pulls configured charts", func() {
+		pushSpacebears("0.0.1")
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:0.0.1"}, PlainHTTP: true}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).To(BeNil())
+
+		charts, err := ociRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+		Expect(charts[0].Metadata.Name).To(Equal("spacebears"))
+		Expect(charts[0].Metadata.Version).To(Equal("0.0.1"))
+	})
+
+	It("activates the last listed tag of a chart", func() {
+		pushSpacebears("0.0.1")
+		pushSpacebears("0.0.2")
+		ociConf := &config.OCIChartConfig{
+			Charts:    []string{"charts/spacebears:0.0.2", "charts/spacebears:0.0.1"},
+			PlainHTTP: true,
+		}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).To(BeNil())
+
+		chartVersions, err := ociRepository.GetChartVersions()
+		Expect(err).To(BeNil())
+		Expect(chartVersions).To(HaveLen(1))
+		Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2", "0.0.1"}))
+		Expect(chartVersions[0].Active).To(Equal("0.0.1"))
+	})
+
+	It("fails with bad registry credentials", func() {
+		pushSpacebears("0.0.1")
+		registryConf.Pass = "wrong"
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:0.0.1"}, PlainHTTP: true}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).NotTo(BeNil())
+	})
+
+	It("errors on missing tag", func() {
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:9.9.9"}, PlainHTTP: true}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).NotTo(BeNil())
+	})
+
+	It("errors on reference without tag", func() {
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears"}, PlainHTTP: true}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		err = ociRepository.Sync()
+		Expect(err).NotTo(BeNil())
+		Expect(err.Error()).To(ContainSubstring("tag or digest"))
+	})
+
+	It("keeps serving cached charts when the registry is down", func() {
+		pushSpacebears("0.0.1")
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:0.0.1"}, PlainHTTP: true}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).To(BeNil())
+
+		registry.up = false
+		Expect(ociRepository.ClearCache()).To(BeNil())
+
+		charts, err := ociRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+	})
+
+	It("verifies the registry with the registry ca", func() {
+		pushSpacebears("0.0.1")
+		tlsServer := httptest.NewTLSServer(registry)
+		defer tlsServer.Close()
+
+		caFile := filepath.Join(workDir, "ca.crt")
+		caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw})
+		Expect(ioutil.WriteFile(caFile, caPEM, 0600)).To(BeNil())
+		registryConf.Server = strings.TrimPrefix(tlsServer.URL, "https://")
+		registryConf.CACertFile = caFile
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:0.0.1"}}
+
+		ociRepository, err := repository.NewOCIRepository(registryConf, ociConf, cacheDir, logger)
+		Expect(err).To(BeNil())
+		Expect(ociRepository.Sync()).To(BeNil())
+
+		charts, err := ociRepository.GetCharts()
+		Expect(err).To(BeNil())
+		Expect(charts).To(HaveLen(1))
+	})
+
+	It("requires a registry", func() {
+		ociConf := &config.OCIChartConfig{Charts: []string{"charts/spacebears:0.0.1"}}
+
+		_, err := repository.NewOCIRepository(&config.RegistryConfig{}, ociConf, cacheDir, logger)
+		Expect(err).NotTo(BeNil())
+	})
+})
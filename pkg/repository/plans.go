@@ -0,0 +1,336 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/moreio"
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// planDefinition is a plan as written to plans.yaml, without the contents of its files
+type planDefinition struct {
+	Name            string   `json:"name"`
+	Description     string   `json:"description"`
+	Bullets         []string `json:"bullets,omitempty"`
+	File            string   `json:"file"`
+	Free            *bool    `json:"free,omitempty"`
+	Bindable        *bool    `json:"bindable,omitempty"`
+	CredentialsPath string   `json:"credentials,omitempty"`
+}
+
+// planEdit changes the plan definitions of a chart, returning the plan files to write and to remove
+type planEdit func(plansDir string, definitions []planDefinition) ([]planDefinition, map[string][]byte, []string, error)
+
+// PackageChartWithPlan packages the active version of the chart, with plan added or replacing the
+// plan of the same name, as a chart archive in dir, to be saved like an uploaded chart. Stored
+// versions are never changed in place.
+// plan.Values and credentials are written to plans/<file>. When updating, a nil plan.Values or
+// empty credentials keep the plan's existing values or credentials, and clearCredentials removes
+// its credentials, moving it back to the default cluster.
+func (r *repository) PackageChartWithPlan(chartName string, plan helm.Plan, credentials []byte, clearCredentials bool, dir string) (string, error) {
+	if clearCredentials && len(credentials) > 0 {
+		return "", helm.NewChartValidationError(errors.New("Credentials can't be both set and cleared"))
+	}
+
+	return r.packageWithPlans(chartName, dir, func(plansDir string, definitions []planDefinition) ([]planDefinition, map[string][]byte, []string, error) {
+		existing := planDefinition{}
+		for _, definition := range definitions {
+			if definition.Name == plan.Name {
+				existing = definition
+			}
+		}
+
+		if plan.File == "" {
+			plan.File = existing.File
+		}
+		if plan.File == "" {
+			plan.File = plan.Name + ".yaml"
+		}
+		if plan.Values == nil && existing.File != "" {
+			values, err := ioutil.ReadFile(filepath.Join(plansDir, existing.File))
+			if err != nil {
+				return nil, nil, nil, errors.Wrapf(err, "Unable to read values of plan [%s]", plan.Name)
+			}
+			plan.Values = values
+		}
+		plan.CredentialsPath = existing.CredentialsPath
+		if clearCredentials {
+			plan.CredentialsPath = ""
+		} else if len(credentials) > 0 && plan.CredentialsPath == "" {
+			plan.CredentialsPath = plan.Name + "-creds.yaml"
+		}
+		newFiles := map[string][]byte{plan.File: plan.Values}
+		if len(credentials) > 0 {
+			newFiles[plan.CredentialsPath] = credentials
+		}
+
+		definition := planDefinition{
+			Name:            plan.Name,
+			Description:     plan.Description,
+			Bullets:         plan.Bullets,
+			File:            plan.File,
+			Free:            plan.Free,
+			Bindable:        plan.Bindable,
+			CredentialsPath: plan.CredentialsPath,
+		}
+		replaced := false
+		for i := range definitions {
+			if definitions[i].Name == plan.Name {
+				definitions[i] = definition
+				replaced = true
+			}
+		}
+		if !replaced {
+			definitions = append(definitions, definition)
+		}
+
+		return definitions, newFiles, unusedFiles(definitions, existing.File, existing.CredentialsPath), nil
+	})
+}
+
+// PackageChartWithoutPlan packages the active version of the chart, without the plan and any of
+// its files that no other plan uses, as a chart archive in dir, to be saved like an uploaded chart
+func (r *repository) PackageChartWithoutPlan(chartName string, planName string, dir string) (string, error) {
+	return r.packageWithPlans(chartName, dir, func(plansDir string, definitions []planDefinition) ([]planDefinition, map[string][]byte, []string, error) {
+		var deleted *planDefinition
+		remaining := []planDefinition{}
+		for i := range definitions {
+			if definitions[i].Name == planName {
+				deleted = &definitions[i]
+			} else {
+				remaining = append(remaining, definitions[i])
+			}
+		}
+		if deleted == nil {
+			return nil, nil, nil, errors.Errorf("Plan [%s] not found in chart [%s]", planName, chartName)
+		}
+
+		return remaining, nil, unusedFiles(remaining, deleted.File, deleted.CredentialsPath), nil
+	})
+}
+
+// unusedFiles returns the files that none of the plans in definitions use
+func unusedFiles(definitions []planDefinition, files ...string) []string {
+	inUse := map[string]bool{}
+	for _, definition := range definitions {
+		inUse[definition.File] = true
+		inUse[definition.CredentialsPath] = true
+	}
+	unused := []string{}
+	for _, file := range files {
+		if file != "" && !inUse[file] {
+			unused = append(unused, file)
+		}
+	}
+	return unused
+}
+
+// packageWithPlans copies the active version of the chart into dir, applies edit to the plans of
+// the copy and packages it as <name>-<version>.tgz in dir
+func (r *repository) packageWithPlans(chartName string, dir string, edit planEdit) (string, error) {
+	copyPath := filepath.Join(dir, chartName)
+	err := r.copyActiveVersion(chartName, copyPath)
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(copyPath)
+
+	plansFile, definitions, err := readPlanDefinitions(copyPath)
+	if err != nil {
+		return "", err
+	}
+	plansDir := filepath.Join(copyPath, "plans")
+	definitions, newFiles, removedFiles, err := edit(plansDir, definitions)
+	if err != nil {
+		return "", err
+	}
+	err = writePlans(plansDir, plansFile, definitions, newFiles, removedFiles)
+	if err != nil {
+		return "", err
+	}
+
+	metadata, err := chartutil.LoadChartfile(filepath.Join(copyPath, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+	archivePath := filepath.Join(dir, fmt.Sprintf("%s-%s.tgz", metadata.Name, metadata.Version))
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer archive.Close()
+
+	err = moreio.TarZipUnder(copyPath, metadata.Name, archive)
+	if err != nil {
+		return "", err
+	}
+	return archivePath, archive.Close()
+}
+
+func (r *repository) copyActiveVersion(chartName string, dst string) error {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	chartPath, err := r.chartPathFor(chartName)
+	if err != nil {
+		return err
+	}
+	return copyDir(chartPath, dst)
+}
+
+// chartPathFor returns the directory of the active version of the named chart
+func (r *repository) chartPathFor(chartName string) (string, error) {
+	rootChart, err := moreio.FileExists(filepath.Join(r.helmChartDir, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+	if rootChart {
+		return "", errors.Errorf("Chart [%s] is stored directly in [%s], which can't be saved to", chartName, r.helmChartDir)
+	}
+
+	chartDir := filepath.Join(r.helmChartDir, chartName)
+	if chartName != filepath.Base(chartName) || isReservedDir(chartName) || !moreio.DirExistsAndIsReadable(chartDir) {
+		return "", errors.Errorf("Chart [%s] not found", chartName)
+	}
+	chartPath, err := r.activeChartPath(chartDir)
+	if err != nil {
+		return "", err
+	}
+	if chartPath == "" {
+		return "", errors.Errorf("Chart [%s] not found", chartName)
+	}
+	return chartPath, nil
+}
+
+// readPlanDefinitions returns the plans file the chart uses (plans.yaml when it has none) and its contents
+func readPlanDefinitions(chartPath string) (string, []planDefinition, error) {
+	definitions := []planDefinition{}
+	for _, name := range []string{"plans.yaml", "plans.yml"} {
+		plansFile := filepath.Join(chartPath, name)
+		plansBytes, err := ioutil.ReadFile(plansFile)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return "", nil, err
+		}
+		err = yaml.Unmarshal(plansBytes, &definitions)
+		if err != nil {
+			return "", nil, err
+		}
+		return plansFile, definitions, nil
+	}
+	return filepath.Join(chartPath, "plans.yaml"), definitions, nil
+}
+
+// writePlans applies newFiles and removedFiles to plansDir, validates the plans against them and
+// writes the plans to plansFile
+func writePlans(plansDir string, plansFile string, definitions []planDefinition, newFiles map[string][]byte, removedFiles []string) error {
+	for file := range newFiles {
+		if file != filepath.Base(file) || file == "." || file == ".." {
+			return helm.NewChartValidationError(errors.Errorf("Plan file [%s] must be a file name, without a directory", file))
+		}
+	}
+
+	err := os.MkdirAll(plansDir, 0700)
+	if err != nil {
+		return err
+	}
+	for file, contents := range newFiles {
+		err = ioutil.WriteFile(filepath.Join(plansDir, file), contents, 0600)
+		if err != nil {
+			return err
+		}
+	}
+	for _, file := range removedFiles {
+		err = os.Remove(filepath.Join(plansDir, file))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	plans := []helm.Plan{}
+	for _, definition := range definitions {
+		plans = append(plans, helm.Plan{
+			Name:            definition.Name,
+			Description:     definition.Description,
+			Bullets:         definition.Bullets,
+			File:            definition.File,
+			Free:            definition.Free,
+			Bindable:        definition.Bindable,
+			CredentialsPath: definition.CredentialsPath,
+		})
+	}
+	_, err = helm.LoadPlans(plansDir, plans)
+	if err != nil {
+		return helm.NewChartValidationError(err)
+	}
+
+	plansBytes, err := yaml.Marshal(definitions)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(plansFile, plansBytes, 0600)
+}
+
+// copyDir copies the directory tree of src to dst, streaming the files, which can be large
+// (eg, embedded images)
+func copyDir(src string, dst string) error {
+	return filepath.Walk(src, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(src, file)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relative)
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return copyFile(file, target, info.Mode().Perm())
+	})
+}
+
+func copyFile(src string, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	closeErr := out.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
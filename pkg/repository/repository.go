@@ -20,7 +20,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/moreio"
 	"github.com/pkg/errors"
@@ -28,156 +30,375 @@ import (
 	"k8s.io/helm/pkg/chartutil"
 )
 
+const workspaceDir = "workspace_tmp"
+
+const provenanceExtension = ".prov"
+
+// isReservedDir reports whether name, in helmChartDir, is one of the repository's working directories
+// rather than a chart
+func isReservedDir(name string) bool {
+	return name == workspaceDir || name == stagingDir
+}
+
 //go:generate counterfeiter ./ Repository
 type Repository interface {
 	GetCharts() ([]*helm.MyChart, error)
+	GetQuarantinedCharts() ([]QuarantinedChart, error)
+	GetChartVersions() ([]ChartVersions, error)
+	ActivateChartVersion(name string, version string) error
 	SaveChart(path string) error
 	DeleteChart(name string) error
+	StageActivateChartVersion(name string, version string) (StagedChange, error)
+	StageSaveChart(path string, provenancePath string) (StagedChange, error)
+	StageDeleteChart(name string) (StagedChange, error)
+	SignedChartArchive(name string) (string, string, error)
+	PackageChartWithPlan(chartName string, plan helm.Plan, credentials []byte, clearCredentials bool, dir string) (string, error)
+	PackageChartWithoutPlan(chartName string, planName string, dir string) (string, error)
 	ClearCache() error
 }
 
+type QuarantinedChart struct {
+	Name   string                     `json:"name"`
+	Path   string                     `json:"path"`
+	Reason string                     `json:"reason"`
+	Error  *helm.ChartValidationError `json:"-"`
+}
+
 type repository struct {
-	helmChartDir          string
-	privateRegistryServer string
-	chartsCache           []*helm.MyChart
-	logger                *logrus.Logger
+	helmChartDir     string
+	registryConfig   *config.RegistryConfig
+	retainedVersions int
+	logger           *logrus.Logger
+
+	// cacheLock guards chartsCache, quarantine and stale, diskLock serializes loads and writes to helmChartDir
+	cacheLock   sync.RWMutex
+	chartsCache []*helm.MyChart
+	quarantine  []QuarantinedChart
+	stale       bool
+	diskLock    sync.Mutex
+
+	// stagedLock guards staged, the charts with a staged change that's not committed or rolled back yet
+	stagedLock sync.Mutex
+	staged     map[string]bool
+}
+
+// Options are the optional settings of a repository, the zero value of each is its default
+type Options struct {
+	// Registries the images of loaded charts are rewritten to, images are left as they are when nil
+	Registries *config.RegistryConfig
+	// RetainedVersions is how many versions of each chart are kept, DefaultRetainedVersions when 0
+	// and every version when negative
+	RetainedVersions int
 }
 
-func NewRepository(chartPath string, privateRegistryServer string, logger *logrus.Logger) Repository {
+// NewRepository loads charts from chartPath, as set by options
+func NewRepository(chartPath string, options Options, logger *logrus.Logger) Repository {
+	if options.Registries == nil {
+		options.Registries = &config.RegistryConfig{}
+	}
+	if options.RetainedVersions == 0 {
+		options.RetainedVersions = DefaultRetainedVersions
+	}
 	return &repository{
-		helmChartDir:          chartPath,
-		privateRegistryServer: privateRegistryServer,
-		logger:                logger,
+		helmChartDir:     chartPath,
+		registryConfig:   options.Registries,
+		retainedVersions: options.RetainedVersions,
+		logger:           logger,
+		staged:           map[string]bool{},
 	}
 }
 
 func (r *repository) ClearCache() error {
-	r.chartsCache = nil
+	r.invalidate()
+	_, err := r.reload(true)
+	if err != nil {
+		return err
+	}
 	r.logger.Info("Cleared Cache for Broker Repository")
 	return nil
 }
+
 func (r *repository) GetCharts() ([]*helm.MyChart, error) {
-	if r.chartsCache == nil {
-		charts := []*helm.MyChart{}
+	r.cacheLock.RLock()
+	charts, stale := r.chartsCache, r.stale
+	r.cacheLock.RUnlock()
 
-		chartExists, err := moreio.FileExists(filepath.Join(r.helmChartDir, "Chart.yaml"))
-		if err != nil {
+	if charts != nil && !stale {
+		return charts, nil
+	}
+	return r.reload(false)
+}
+
+func (r *repository) GetQuarantinedCharts() ([]QuarantinedChart, error) {
+	_, err := r.GetCharts()
+	if err != nil {
+		return nil, err
+	}
+
+	r.cacheLock.RLock()
+	defer r.cacheLock.RUnlock()
+	return r.quarantine, nil
+}
+
+func (r *repository) invalidate() {
+	r.cacheLock.Lock()
+	r.stale = true
+	r.cacheLock.Unlock()
+}
+
+// reload reads the charts from disk and swaps them in as a whole. When any chart fails to load,
+// a previously loaded catalog is kept and served instead.
+func (r *repository) reload(force bool) ([]*helm.MyChart, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	if !force {
+		r.cacheLock.RLock()
+		charts, stale := r.chartsCache, r.stale
+		r.cacheLock.RUnlock()
+		if charts != nil && !stale {
+			return charts, nil
+		}
+	}
+
+	charts, quarantine, err := r.loadCharts()
+
+	r.cacheLock.Lock()
+	defer r.cacheLock.Unlock()
+	if err != nil {
+		if r.chartsCache == nil {
+			return nil, err
+		}
+		// stale stays set, so that the next call tries again
+		r.logger.WithError(err).Error("Unable to reload charts, keeping previously loaded charts")
+		if force {
 			return nil, err
 		}
+		return r.chartsCache, nil
+	}
 
-		if chartExists {
-			myChart, err := helm.NewChart(r.helmChartDir, r.privateRegistryServer, r.logger)
-			if err != nil {
-				return nil, err
-			}
-			charts = append(charts, myChart)
-		} else {
-			helmDirFiles, err := ioutil.ReadDir(r.helmChartDir)
-			if err != nil {
-				return nil, err
+	r.chartsCache = charts
+	r.quarantine = quarantine
+	r.stale = false
+	return charts, nil
+}
+
+// loadCharts skips over subdirectories that fail chart validation and returns them as quarantined,
+// so that a single bad chart doesn't take down the whole catalog.
+func (r *repository) loadCharts() ([]*helm.MyChart, []QuarantinedChart, error) {
+	charts := []*helm.MyChart{}
+	quarantine := []QuarantinedChart{}
+
+	chartExists, err := moreio.FileExists(filepath.Join(r.helmChartDir, "Chart.yaml"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if chartExists {
+		myChart, err := helm.NewChart(r.helmChartDir, r.registryConfig, r.logger)
+		if err != nil {
+			return nil, nil, err
+		}
+		charts = append(charts, myChart)
+	} else {
+		helmDirFiles, err := ioutil.ReadDir(r.helmChartDir)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, fileInfo := range helmDirFiles {
+			if isReservedDir(fileInfo.Name()) {
+				//rename doesn't support moving things across disks, so we're expanding to a working dir
+				continue
 			}
-			for _, fileInfo := range helmDirFiles {
-				if fileInfo.Name() == "workspace_tmp" {
-					//rename doesn't support moving things across disks, so we're expanding to a working dir
-					continue
+			if fileInfo.IsDir() {
+				subChartPath, err := r.activeChartPath(filepath.Join(r.helmChartDir, fileInfo.Name()))
+				if err != nil {
+					return nil, nil, err
 				}
-				if fileInfo.IsDir() {
-					subChartPath := filepath.Join(r.helmChartDir, fileInfo.Name())
-					subdirChartExists, err := moreio.FileExists(filepath.Join(subChartPath, "Chart.yaml"))
+				if subChartPath != "" {
+					myChart, err := helm.NewChart(subChartPath, r.registryConfig, r.logger)
 					if err != nil {
-						return nil, err
-					}
-					if subdirChartExists {
-						myChart, err := helm.NewChart(filepath.Join(subChartPath), r.privateRegistryServer, r.logger)
-						if err != nil {
-							return nil, err
+						validationErr, ok := errors.Cause(err).(*helm.ChartValidationError)
+						if !ok {
+							return nil, nil, err
 						}
-						charts = append(charts, myChart)
-					} else {
-						r.logger.Info(fmt.Sprintf("[%s] does not contain Chart.yml, skipping", subChartPath))
+						r.logger.WithError(err).Error(fmt.Sprintf("[%s] failed to load, quarantining", subChartPath))
+						quarantine = append(quarantine, QuarantinedChart{
+							Name:   fileInfo.Name(),
+							Path:   subChartPath,
+							Reason: validationErr.Error(),
+							Error:  validationErr,
+						})
+						continue
 					}
+					charts = append(charts, myChart)
+				} else {
+					r.logger.Info(fmt.Sprintf("[%s] does not contain Chart.yml, skipping", filepath.Join(r.helmChartDir, fileInfo.Name())))
 				}
 			}
 		}
-
-		r.chartsCache = charts
 	}
 
-	return r.chartsCache, nil
+	return charts, quarantine, nil
 }
 
 func (r *repository) SaveChart(path string) error {
-	expandedTarPath := filepath.Join(r.helmChartDir, "workspace_tmp")
+	change, err := r.StageSaveChart(path, "")
+	if err != nil {
+		return err
+	}
+	return change.Commit()
+}
+
+// saveChart saves the chart archive in path as a new version, keeping the archive when it comes with
+// the provenance file in provenancePath
+func (r *repository) saveChart(path string, provenancePath string) (*stagedChange, error) {
+	expandedTarPath := filepath.Join(r.helmChartDir, workspaceDir)
 	err := os.RemoveAll(expandedTarPath)
 
 	if err != nil && !os.IsNotExist(err) {
-		return err
+		return nil, err
 	}
 
 	err = os.Mkdir(expandedTarPath, 0700)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	err = chartutil.ExpandFile(expandedTarPath, path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	files, err := ioutil.ReadDir(expandedTarPath)
 	var chartPathInfo os.FileInfo
 	if err != nil {
-		return err
+		return nil, err
 	}
 	for _, file := range files {
 		if file.IsDir() {
 			if chartPathInfo != nil {
-				return errors.New("Multiple directories found in uploaded archive")
+				return nil, errors.New("Multiple directories found in uploaded archive")
 			} else {
 				chartPathInfo = file
 			}
 		}
 	}
 
+	if chartPathInfo == nil {
+		return nil, errors.New("No chart directory found in uploaded archive")
+	}
+
 	chartPath := filepath.Join(expandedTarPath, chartPathInfo.Name())
-	chart, err := helm.NewChart(chartPath, r.privateRegistryServer, r.logger)
+	chart, err := helm.NewChart(chartPath, r.registryConfig, r.logger)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	destinationPath := filepath.Join(r.helmChartDir, chartPathInfo.Name())
-	info, _ := os.Stat(destinationPath)
-	if info != nil {
-		os.RemoveAll(destinationPath)
+	if chartPathInfo.Name() != chart.Metadata.Name {
+		return nil, errors.New("Chart metadata name and top level directory in archive for chart does not match")
 	}
 
-	if chartPathInfo.Name() != chart.Metadata.Name {
-		return errors.New("Chart metadata name and top level directory in archive for chart does not match")
+	release, err := r.lockChart(chart.Metadata.Name)
+	if err != nil {
+		return nil, err
+	}
+	chartDir := filepath.Join(r.helmChartDir, chartPathInfo.Name())
+	migrated, err := r.migrateUnversioned(chartDir, expandedTarPath)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	destinationPath := filepath.Join(chartDir, chart.Metadata.Version)
+	signedPath := filepath.Join(chartDir, signedDir, chart.Metadata.Version)
+	change, err := r.stage(chartDir, destinationPath, signedPath)
+	if err != nil {
+		if migrated != "" {
+			r.restoreUnversioned(chartDir, migrated)
+		}
+		release()
+		return nil, err
+	}
+	change.migrated = migrated
+	change.release = release
+	err = r.applySave(chartDir, chartPath, chart.Metadata.Version)
+	if err == nil && provenancePath != "" {
+		err = r.keepSignedArchive(chartDir, chart.Metadata.Version, path, provenancePath)
+	}
+	if err != nil {
+		change.restore()
+		change.unlock()
+		return nil, err
 	}
 
-	err = os.Rename(chartPath, filepath.Join(r.helmChartDir, chartPathInfo.Name()))
+	change.prune = true
+	r.invalidate()
+	return change, nil
+}
+
+// applySave moves the chart in chartPath into chartDir as version, which was staged beforehand,
+// and activates it
+func (r *repository) applySave(chartDir string, chartPath string, version string) error {
+	err := os.MkdirAll(chartDir, 0700)
 	if err != nil {
 		return err
 	}
 
-	r.chartsCache = nil
-	return nil
+	err = os.Rename(chartPath, filepath.Join(chartDir, version))
+	if err != nil {
+		return err
+	}
+
+	return replaceFile(filepath.Join(chartDir, activeVersionFile), []byte(version), 0600)
+}
+
+// keepSignedArchive copies the archive the version was uploaded as, and its provenance file, into the
+// chart's signed archives
+func (r *repository) keepSignedArchive(chartDir string, version string, archivePath string, provenancePath string) error {
+	signedPath := signedArchivePath(chartDir, version)
+	err := os.MkdirAll(filepath.Dir(signedPath), 0700)
+	if err != nil {
+		return err
+	}
+	err = copyFile(archivePath, signedPath, 0600)
+	if err != nil {
+		return err
+	}
+	return copyFile(provenancePath, signedPath+provenanceExtension, 0600)
 }
 
 func (r *repository) DeleteChart(name string) error {
+	change, err := r.StageDeleteChart(name)
+	if err != nil {
+		return err
+	}
+	return change.Commit()
+}
+
+func (r *repository) deleteChart(name string) (*stagedChange, error) {
 	deletePath := filepath.Join(r.helmChartDir, name)
 
 	_, err := os.Stat(deletePath)
 
 	if os.IsNotExist(err) {
 		r.logger.Info(fmt.Sprintf("[%s] does not exist, skipping", deletePath))
-		return nil
+		return &stagedChange{repo: r}, nil
 	} else if err != nil {
 		r.logger.Info(fmt.Sprintf("[%s] error reading at path, skipping", deletePath))
-		return err
+		return nil, err
 	}
-	os.RemoveAll(deletePath)
-	r.chartsCache = nil
 
-	return nil
+	release, err := r.lockChart(name)
+	if err != nil {
+		return nil, err
+	}
+	change, err := r.stage(deletePath, deletePath, "")
+	if err != nil {
+		release()
+		return nil, err
+	}
+	change.release = release
+	r.invalidate()
+
+	return change, nil
 }
@@ -19,7 +19,9 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sync"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 	"github.com/cf-platform-eng/kibosh/pkg/repository"
 	"github.com/cf-platform-eng/kibosh/pkg/test"
@@ -59,20 +61,20 @@ var _ = Describe("Repository", func() {
 		})
 
 		It("returns error on empty path", func() {
-			myRepository := repository.NewRepository("", "", logger)
+			myRepository := repository.NewRepository("", repository.Options{}, logger)
 			_, err := myRepository.GetCharts()
 			Expect(err).NotTo(BeNil())
 		})
 
 		It("returns empty slice on directory with no charts", func() {
-			myRepository := repository.NewRepository(emptyDir, "", logger)
+			myRepository := repository.NewRepository(emptyDir, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(charts).To(BeEmpty())
 			Expect(err).To(BeNil())
 		})
 
 		It("returns empty slice on directory with empty directories", func() {
-			myRepository := repository.NewRepository(nestedEmptyDir, "", logger)
+			myRepository := repository.NewRepository(nestedEmptyDir, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(charts).To(BeEmpty())
 			Expect(err).To(BeNil())
@@ -97,13 +99,29 @@ var _ = Describe("Repository", func() {
 		})
 
 		It("returns single chart", func() {
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
 
 			Expect(charts).To(HaveLen(1))
 			Expect(charts[0].Metadata.Name).To(Equal("spacebears"))
 		})
+
+		It("rewrites images to the configured registries", func() {
+			testChart.ValuesYaml = []byte("image: quay.io/vendor/spacebears\nimageTag: 1.0.0\n")
+			Expect(testChart.WriteChart(chartPath)).To(Succeed())
+			registryConfig := &config.RegistryConfig{
+				Server:     "docker.example.com",
+				Registries: config.Registries{{Server: "harbor.example.com", Match: []string{"quay.io/vendor"}}},
+			}
+
+			myRepository := repository.NewRepository(chartPath, repository.Options{Registries: registryConfig}, logger)
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+
+			Expect(charts).To(HaveLen(1))
+			Expect(string(charts[0].TransformedValues)).To(ContainSubstring("image: harbor.example.com/spacebears"))
+		})
 	})
 
 	Context("single plain charts", func() {
@@ -124,7 +142,7 @@ var _ = Describe("Repository", func() {
 		})
 
 		It("returns a single plain chart", func() {
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
 
@@ -177,7 +195,7 @@ version: 0.0.1
 		})
 
 		It("caches charts between calls", func() {
-			myRepository := repository.NewRepository(repoPath, "", logger)
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
 			Expect(charts).To(HaveLen(2))
@@ -192,7 +210,7 @@ version: 0.0.1
 
 		It("adding a chart invalidates the cache", func() {
 			// pre-conditions
-			myRepository := repository.NewRepository(repoPath, "", logger)
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
 			Expect(charts).To(HaveLen(2))
@@ -214,7 +232,7 @@ version: 0.0.2
 			err = testChart.WriteChart(thirdChartDir)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(thirdChartDir, "", logger)
+			chart, err := helm.NewChart(thirdChartDir, nil, logger)
 			tarFile, err := chartutil.Save(&chart.Chart, tarDir)
 
 			err = myRepository.SaveChart(tarFile)
@@ -226,7 +244,7 @@ version: 0.0.2
 		})
 
 		It("deleting a chart invalidates the cache", func() {
-			myRepository := repository.NewRepository(repoPath, "", logger)
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
 			Expect(charts).To(HaveLen(2))
@@ -238,6 +256,81 @@ version: 0.0.2
 			Expect(err).To(BeNil())
 			Expect(charts).To(HaveLen(1))
 		})
+
+		It("clearing the cache reloads charts", func() {
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(2))
+
+			err = os.RemoveAll(filepath.Join(repoPath, "mysql"))
+			Expect(err).To(BeNil())
+
+			err = myRepository.ClearCache()
+			Expect(err).To(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+		})
+
+		It("keeps existing charts when a reload fails", func() {
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(2))
+
+			err = os.RemoveAll(repoPath)
+			Expect(err).To(BeNil())
+
+			err = myRepository.ClearCache()
+			Expect(err).NotTo(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(2))
+		})
+
+		It("tries again after a reload fails", func() {
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(2))
+
+			movedPath := repoPath + "-moved"
+			Expect(os.Rename(repoPath, movedPath)).To(BeNil())
+			err = myRepository.ClearCache()
+			Expect(err).NotTo(BeNil())
+
+			Expect(os.RemoveAll(filepath.Join(movedPath, "mysql"))).To(BeNil())
+			Expect(os.Rename(movedPath, repoPath)).To(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+		})
+
+		It("is safe for concurrent use", func() {
+			myRepository := repository.NewRepository(repoPath, repository.Options{}, logger)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(2)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					charts, err := myRepository.GetCharts()
+					Expect(err).To(BeNil())
+					Expect(charts).To(HaveLen(2))
+				}()
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					Expect(myRepository.ClearCache()).To(BeNil())
+				}()
+			}
+			wg.Wait()
+		})
 	})
 
 	Context("multiple charts", func() {
@@ -282,7 +375,7 @@ version: 0.0.1
 
 		It("loads multiple charts", func() {
 			logger = logrus.New()
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 
 			charts, err := myRepository.GetCharts()
 			Expect(err).To(BeNil())
@@ -292,15 +385,51 @@ version: 0.0.1
 			Expect(charts[1].Metadata.Name).To(Equal("mysql"))
 		})
 
-		It("bubbles up chart load errors", func() {
+		It("quarantines charts that fail to load", func() {
 			err := ioutil.WriteFile(filepath.Join(chartPath, "c2", "Chart.yaml"), []byte(`bad::::yaml`), 0666)
 			Expect(err).To(BeNil())
 
 			logger = logrus.New()
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 
-			_, err = myRepository.GetCharts()
-			Expect(err).NotTo(BeNil())
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Metadata.Name).To(Equal("postgres"))
+
+			quarantine, err := myRepository.GetQuarantinedCharts()
+			Expect(err).To(BeNil())
+			Expect(quarantine).To(HaveLen(1))
+			Expect(quarantine[0].Name).To(Equal("c2"))
+			Expect(quarantine[0].Path).To(Equal(filepath.Join(chartPath, "c2")))
+			Expect(quarantine[0].Error).NotTo(BeNil())
+			Expect(quarantine[0].Reason).NotTo(BeEmpty())
+		})
+
+		It("releases charts from quarantine once fixed", func() {
+			chartYaml, err := ioutil.ReadFile(filepath.Join(chartPath, "c2", "Chart.yaml"))
+			Expect(err).To(BeNil())
+			err = ioutil.WriteFile(filepath.Join(chartPath, "c2", "Chart.yaml"), []byte(`bad::::yaml`), 0666)
+			Expect(err).To(BeNil())
+
+			logger = logrus.New()
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
+
+			quarantine, err := myRepository.GetQuarantinedCharts()
+			Expect(err).To(BeNil())
+			Expect(quarantine).To(HaveLen(1))
+
+			err = ioutil.WriteFile(filepath.Join(chartPath, "c2", "Chart.yaml"), chartYaml, 0666)
+			Expect(err).To(BeNil())
+			err = myRepository.ClearCache()
+			Expect(err).To(BeNil())
+
+			quarantine, err = myRepository.GetQuarantinedCharts()
+			Expect(err).To(BeNil())
+			Expect(quarantine).To(BeEmpty())
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(2))
 		})
 	})
 
@@ -328,10 +457,10 @@ version: 0.0.1
 			err := testChart.WriteChart(tarDir)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(tarDir, "docker.example.com", logger)
+			chart, err := helm.NewChart(tarDir, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 			tarFile, err := chartutil.Save(&chart.Chart, tarDir)
 
-			myRepository := repository.NewRepository(repoDir, "", logger)
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
 			files, err := ioutil.ReadDir(repoDir)
 			Expect(err).To(BeNil())
 			Expect(files).To(HaveLen(0))
@@ -339,7 +468,7 @@ version: 0.0.1
 			err = myRepository.SaveChart(tarFile)
 			Expect(err).To(BeNil())
 
-			contents, err := ioutil.ReadFile(filepath.Join(repoDir, "spacebears", "Chart.yaml"))
+			contents, err := ioutil.ReadFile(filepath.Join(repoDir, "spacebears", "0.0.1", "Chart.yaml"))
 			Expect(err).To(BeNil())
 
 			testChartParsed := map[string]interface{}{}
@@ -349,7 +478,7 @@ version: 0.0.1
 
 			Expect(testChartParsed).To(Equal(savedChartParsed))
 
-			mediumFileInfo, err := os.Stat(filepath.Join(repoDir, "spacebears", "plans", "medium.yaml"))
+			mediumFileInfo, err := os.Stat(filepath.Join(repoDir, "spacebears", "0.0.1", "plans", "medium.yaml"))
 			Expect(err).To(BeNil())
 			Expect(mediumFileInfo.Size()).NotTo(BeZero())
 		})
@@ -359,7 +488,7 @@ version: 0.0.1
 			err := ioutil.WriteFile(notChartFilePath, []byte("foo"), 0666)
 			Expect(err).To(BeNil())
 
-			myRepository := repository.NewRepository(repoDir, "", logger)
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
 
 			err = myRepository.SaveChart(notChartFilePath)
 			Expect(err).NotTo(BeNil())
@@ -377,10 +506,10 @@ version: 0.0.1
 			err = testChart.WriteChart(tarDir)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(tarDir, "docker.example.com", logger)
+			chart, err := helm.NewChart(tarDir, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 			tarFile, err := chartutil.Save(&chart.Chart, tarDir)
 
-			myRepository := repository.NewRepository(repoDir, "", logger)
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
 			_, err = ioutil.ReadDir(repoDir)
 			Expect(err).To(BeNil())
 
@@ -395,10 +524,10 @@ version: 0.0.1
 			err := testChart.WriteChart(tarDir)
 			Expect(err).To(BeNil())
 
-			chart, err := helm.NewChart(tarDir, "docker.example.com", logger)
+			chart, err := helm.NewChart(tarDir, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 			tarFile, err := chartutil.Save(&chart.Chart, tarDir)
 
-			myRepository := repository.NewRepository(repoDir, "", logger)
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
 			files, err := ioutil.ReadDir(repoDir)
 			Expect(err).To(BeNil())
 			Expect(files).To(HaveLen(0))
@@ -418,7 +547,7 @@ version: 0.0.2
 
 			err = testChart2.WriteChart(tarDir2)
 			Expect(err).To(BeNil())
-			chart2, err := helm.NewChart(tarDir2, "docker.example.com", logger)
+			chart2, err := helm.NewChart(tarDir2, &config.RegistryConfig{Server: "docker.example.com"}, logger)
 			Expect(err).To(BeNil())
 
 			tarFile2, err := chartutil.Save(&chart2.Chart, tarDir2)
@@ -427,13 +556,632 @@ version: 0.0.2
 			err = myRepository.SaveChart(tarFile2)
 			Expect(err).To(BeNil())
 
-			contents, err := ioutil.ReadFile(filepath.Join(repoDir, "spacebears", "Chart.yaml"))
+			contents, err := ioutil.ReadFile(filepath.Join(repoDir, "spacebears", "0.0.2", "Chart.yaml"))
 			Expect(err).To(BeNil())
 
 			savedChartParsed := map[string]interface{}{}
 			yaml.Unmarshal(contents, &savedChartParsed)
 
 			Expect(savedChartParsed["version"]).To(Equal("0.0.2"))
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.2"))
+		})
+	})
+
+	Context("chart versions", func() {
+		var repoDir string
+		var tarDir string
+
+		saveVersion := func(myRepository repository.Repository, version string) {
+			versionChart := test.DefaultChart()
+			versionChart.ChartYaml = []byte(`
+name: spacebears
+description: spacebears service and spacebears broker helm chart
+version: ` + version + `
+`)
+			versionDir, err := ioutil.TempDir(tarDir, "")
+			Expect(err).To(BeNil())
+			err = versionChart.WriteChart(versionDir)
+			Expect(err).To(BeNil())
+
+			chart, err := helm.NewChart(versionDir, nil, logger)
+			Expect(err).To(BeNil())
+			tarFile, err := chartutil.Save(&chart.Chart, versionDir)
+			Expect(err).To(BeNil())
+
+			err = myRepository.SaveChart(tarFile)
+			Expect(err).To(BeNil())
+		}
+
+		BeforeEach(func() {
+			var err error
+			repoDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+
+			tarDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+
+			logger = logrus.New()
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(repoDir)
+			os.RemoveAll(tarDir)
+		})
+
+		It("lists all versions with the latest save active", func() {
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
+			saveVersion(myRepository, "0.0.1")
+			saveVersion(myRepository, "0.0.10")
+			saveVersion(myRepository, "0.0.2")
+
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions).To(HaveLen(1))
+			Expect(chartVersions[0].Name).To(Equal("spacebears"))
+			Expect(chartVersions[0].Active).To(Equal("0.0.2"))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.10", "0.0.2", "0.0.1"}))
+		})
+
+		It("activates an older version", func() {
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
+			saveVersion(myRepository, "0.0.1")
+			saveVersion(myRepository, "0.0.2")
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.2"))
+
+			err = myRepository.ActivateChartVersion("spacebears", "0.0.1")
+			Expect(err).To(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.1"))
+		})
+
+		It("errors activating an unknown version", func() {
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
+			saveVersion(myRepository, "0.0.1")
+
+			err := myRepository.ActivateChartVersion("spacebears", "9.9.9")
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("keeps only the retained versions", func() {
+			myRepository := repository.NewRepository(repoDir, repository.Options{RetainedVersions: 2}, logger)
+			saveVersion(myRepository, "0.0.1")
+			saveVersion(myRepository, "0.0.2")
+			saveVersion(myRepository, "0.0.3")
+
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.3", "0.0.2"}))
+		})
+
+		It("never prunes the active version", func() {
+			myRepository := repository.NewRepository(repoDir, repository.Options{RetainedVersions: 2}, logger)
+			saveVersion(myRepository, "0.0.2")
+			saveVersion(myRepository, "0.0.3")
+			saveVersion(myRepository, "0.0.1")
+
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Active).To(Equal("0.0.1"))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.3", "0.0.2", "0.0.1"}))
+		})
+
+		It("migrates an unversioned chart when a new version is saved", func() {
+			unversionedDir := filepath.Join(repoDir, "spacebears")
+			err := os.Mkdir(unversionedDir, 0700)
+			Expect(err).To(BeNil())
+			err = test.DefaultChart().WriteChart(unversionedDir)
+			Expect(err).To(BeNil())
+
+			myRepository := repository.NewRepository(repoDir, repository.Options{}, logger)
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.1"}))
+
+			saveVersion(myRepository, "0.0.2")
+
+			_, err = os.Stat(filepath.Join(repoDir, "spacebears", "0.0.1", "Chart.yaml"))
+			Expect(err).To(BeNil())
+
+			chartVersions, err = myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Active).To(Equal("0.0.2"))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2", "0.0.1"}))
+		})
+	})
+
+	Context("staged changes", func() {
+		var repoDir string
+		var tarDir string
+		var myRepository repository.Repository
+
+		chartTar := func(version string) string {
+			versionChart := test.DefaultChart()
+			versionChart.ChartYaml = []byte(`
+name: spacebears
+description: spacebears service and spacebears broker helm chart
+version: ` + version + `
+`)
+			versionDir, err := ioutil.TempDir(tarDir, "")
+			Expect(err).To(BeNil())
+			Expect(versionChart.WriteChart(versionDir)).To(BeNil())
+
+			chart, err := helm.NewChart(versionDir, nil, logger)
+			Expect(err).To(BeNil())
+			tarFile, err := chartutil.Save(&chart.Chart, versionDir)
+			Expect(err).To(BeNil())
+			return tarFile
+		}
+
+		BeforeEach(func() {
+			var err error
+			repoDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+			tarDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+
+			logger = logrus.New()
+			myRepository = repository.NewRepository(repoDir, repository.Options{RetainedVersions: 1}, logger)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(repoDir)
+			os.RemoveAll(tarDir)
+		})
+
+		It("restores the previous versions when a save is rolled back", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.2"), "")
+			Expect(err).To(BeNil())
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.2"))
+
+			Expect(change.Rollback()).To(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.1"))
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.1"}))
+		})
+
+		It("removes a new chart when its save is rolled back", func() {
+			change, err := myRepository.StageSaveChart(chartTar("0.0.1"), "")
+			Expect(err).To(BeNil())
+
+			Expect(change.Rollback()).To(BeNil())
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(BeEmpty())
+		})
+
+		It("restores a deleted chart when the delete is rolled back", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+
+			change, err := myRepository.StageDeleteChart("spacebears")
+			Expect(err).To(BeNil())
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(BeEmpty())
+
+			Expect(change.Rollback()).To(BeNil())
+
+			charts, err = myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+		})
+
+		It("stages only the version being replaced", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.1"), "")
+			Expect(err).To(BeNil())
+			staged, err := ioutil.ReadDir(filepath.Join(repoDir, "staging_tmp"))
+			Expect(err).To(BeNil())
+			Expect(staged).To(HaveLen(1))
+			_, err = os.Stat(filepath.Join(repoDir, "staging_tmp", staged[0].Name(), "0.0.1", "Chart.yaml"))
+			Expect(err).To(BeNil())
+
+			Expect(change.Rollback()).To(BeNil())
+
+			staged, err = ioutil.ReadDir(filepath.Join(repoDir, "staging_tmp"))
+			Expect(err).To(BeNil())
+			Expect(staged).To(BeEmpty())
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+		})
+
+		It("restores the active version when a save is rolled back", func() {
+			myRepository = repository.NewRepository(repoDir, repository.Options{}, logger)
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+			Expect(myRepository.SaveChart(chartTar("0.0.2"))).To(BeNil())
+			Expect(myRepository.ActivateChartVersion("spacebears", "0.0.1")).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.3"), "")
+			Expect(err).To(BeNil())
+			Expect(change.Rollback()).To(BeNil())
+
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Active).To(Equal("0.0.1"))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2", "0.0.1"}))
+		})
+
+		It("discards the staged chart and prunes versions on commit", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+			Expect(myRepository.SaveChart(chartTar("0.0.2"))).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.2"), "")
+			Expect(err).To(BeNil())
+			staged, err := ioutil.ReadDir(filepath.Join(repoDir, "staging_tmp"))
+			Expect(err).To(BeNil())
+			Expect(staged).To(HaveLen(1))
+
+			Expect(change.Commit()).To(BeNil())
+
+			staged, err = ioutil.ReadDir(filepath.Join(repoDir, "staging_tmp"))
+			Expect(err).To(BeNil())
+			Expect(staged).To(BeEmpty())
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions).To(HaveLen(1))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2"}))
+		})
+
+		It("keeps versions over the retained number until the save is committed", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.2"), "")
+			Expect(err).To(BeNil())
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2", "0.0.1"}))
+
+			Expect(change.Commit()).To(BeNil())
+
+			chartVersions, err = myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.2"}))
+		})
+
+		It("doesn't stage a chart again until its staged change is rolled back", func() {
+			Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+			tarFiles := []string{}
+			for i := 0; i < 5; i++ {
+				tarFiles = append(tarFiles, chartTar("0.0.2"))
+			}
+
+			var wg sync.WaitGroup
+			var lock sync.Mutex
+			changes := []repository.StagedChange{}
+			busy := 0
+			for _, tarFile := range tarFiles {
+				wg.Add(1)
+				go func(tarFile string) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					change, err := myRepository.StageSaveChart(tarFile, "")
+
+					lock.Lock()
+					defer lock.Unlock()
+					if err != nil {
+						Expect(err).To(BeAssignableToTypeOf(&repository.ChartBusyError{}))
+						busy++
+						return
+					}
+					changes = append(changes, change)
+				}(tarFile)
+			}
+			wg.Wait()
+			Expect(changes).To(HaveLen(1))
+			Expect(busy).To(Equal(4))
+
+			_, err := myRepository.StageDeleteChart("spacebears")
+			Expect(err).To(BeAssignableToTypeOf(&repository.ChartBusyError{}))
+			_, err = myRepository.StageActivateChartVersion("spacebears", "0.0.1")
+			Expect(err).To(BeAssignableToTypeOf(&repository.ChartBusyError{}))
+
+			Expect(changes[0].Rollback()).To(BeNil())
+
+			chartVersions, err := myRepository.GetChartVersions()
+			Expect(err).To(BeNil())
+			Expect(chartVersions[0].Active).To(Equal("0.0.1"))
+			Expect(chartVersions[0].Versions).To(Equal([]string{"0.0.1"}))
+
+			change, err := myRepository.StageSaveChart(tarFiles[0], "")
+			Expect(err).To(BeNil())
+			Expect(change.Commit()).To(BeNil())
+
+			change, err = myRepository.StageDeleteChart("spacebears")
+			Expect(err).To(BeNil())
+			Expect(change.Rollback()).To(BeNil())
+		})
+
+		It("restores an unversioned chart when the save that migrated it is rolled back", func() {
+			unversionedDir := filepath.Join(repoDir, "spacebears")
+			Expect(os.Mkdir(unversionedDir, 0700)).To(BeNil())
+			Expect(test.DefaultChart().WriteChart(unversionedDir)).To(BeNil())
+
+			change, err := myRepository.StageSaveChart(chartTar("0.0.2"), "")
+			Expect(err).To(BeNil())
+			_, err = os.Stat(filepath.Join(unversionedDir, "0.0.1", "Chart.yaml"))
+			Expect(err).To(BeNil())
+
+			Expect(change.Rollback()).To(BeNil())
+
+			_, err = os.Stat(filepath.Join(unversionedDir, "Chart.yaml"))
+			Expect(err).To(BeNil())
+			files, err := ioutil.ReadDir(unversionedDir)
+			Expect(err).To(BeNil())
+			for _, file := range files {
+				Expect(file.Name()).NotTo(Equal("0.0.1"))
+				Expect(file.Name()).NotTo(Equal("0.0.2"))
+			}
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts).To(HaveLen(1))
+			Expect(charts[0].Metadata.Version).To(Equal("0.0.1"))
+		})
+
+		Context("signed archives", func() {
+			var provenancePath string
+
+			BeforeEach(func() {
+				provenancePath = filepath.Join(tarDir, "spacebears.tgz.prov")
+				Expect(ioutil.WriteFile(provenancePath, []byte("signature"), 0600)).To(BeNil())
+			})
+
+			It("keeps the archive a version was uploaded as with its provenance file", func() {
+				tarFile := chartTar("0.0.1")
+				change, err := myRepository.StageSaveChart(tarFile, provenancePath)
+				Expect(err).To(BeNil())
+				Expect(change.Commit()).To(BeNil())
+
+				archivePath, signedProvenancePath, err := myRepository.SignedChartArchive("spacebears")
+				Expect(err).To(BeNil())
+				Expect(filepath.Base(archivePath)).To(Equal("spacebears-0.0.1.tgz"))
+				archive, err := ioutil.ReadFile(archivePath)
+				Expect(err).To(BeNil())
+				uploaded, err := ioutil.ReadFile(tarFile)
+				Expect(err).To(BeNil())
+				Expect(archive).To(Equal(uploaded))
+				signature, err := ioutil.ReadFile(signedProvenancePath)
+				Expect(err).To(BeNil())
+				Expect(string(signature)).To(Equal("signature"))
+			})
+
+			It("has no signed archive for a version uploaded without a provenance file", func() {
+				Expect(myRepository.SaveChart(chartTar("0.0.1"))).To(BeNil())
+
+				archivePath, signedProvenancePath, err := myRepository.SignedChartArchive("spacebears")
+				Expect(err).To(BeNil())
+				Expect(archivePath).To(BeEmpty())
+				Expect(signedProvenancePath).To(BeEmpty())
+			})
+
+			It("drops the signed archive when the version is replaced unsigned, until that's rolled back", func() {
+				change, err := myRepository.StageSaveChart(chartTar("0.0.1"), provenancePath)
+				Expect(err).To(BeNil())
+				Expect(change.Commit()).To(BeNil())
+
+				change, err = myRepository.StageSaveChart(chartTar("0.0.1"), "")
+				Expect(err).To(BeNil())
+				archivePath, _, err := myRepository.SignedChartArchive("spacebears")
+				Expect(err).To(BeNil())
+				Expect(archivePath).To(BeEmpty())
+
+				Expect(change.Rollback()).To(BeNil())
+
+				archivePath, _, err = myRepository.SignedChartArchive("spacebears")
+				Expect(err).To(BeNil())
+				Expect(archivePath).NotTo(BeEmpty())
+			})
+
+			It("prunes the signed archives of pruned versions", func() {
+				change, err := myRepository.StageSaveChart(chartTar("0.0.1"), provenancePath)
+				Expect(err).To(BeNil())
+				Expect(change.Commit()).To(BeNil())
+				Expect(myRepository.SaveChart(chartTar("0.0.2"))).To(BeNil())
+
+				_, err = os.Stat(filepath.Join(repoDir, "spacebears", ".signed", "0.0.1"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Context("plans", func() {
+		var repoDir string
+		var packageDir string
+		var chartDir string
+		var myRepository repository.Repository
+
+		savePlan := func(plan helm.Plan, credentials []byte, clearCredentials bool) error {
+			chartFile, err := myRepository.PackageChartWithPlan("spacebears", plan, credentials, clearCredentials, packageDir)
+			if err != nil {
+				return err
+			}
+			return myRepository.SaveChart(chartFile)
+		}
+
+		deletePlan := func(chartName string, planName string) error {
+			chartFile, err := myRepository.PackageChartWithoutPlan(chartName, planName, packageDir)
+			if err != nil {
+				return err
+			}
+			return myRepository.SaveChart(chartFile)
+		}
+
+		BeforeEach(func() {
+			var err error
+			repoDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+			packageDir, err = ioutil.TempDir("", "")
+			Expect(err).To(BeNil())
+			chartDir = filepath.Join(repoDir, "spacebears", "0.0.1")
+			Expect(os.MkdirAll(chartDir, 0700)).To(BeNil())
+			Expect(test.DefaultChart().WriteChart(chartDir)).To(BeNil())
+
+			logger = logrus.New()
+			myRepository = repository.NewRepository(repoDir, repository.Options{}, logger)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(repoDir)
+			os.RemoveAll(packageDir)
+		})
+
+		It("adds a plan", func() {
+			_, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+
+			free := false
+			err = savePlan(helm.Plan{
+				Name:        "large",
+				Description: "large plan",
+				Free:        &free,
+				Values:      []byte("persistence:\n  size: 64Gi\n"),
+			}, nil, false)
+			Expect(err).To(BeNil())
+
+			values, err := ioutil.ReadFile(filepath.Join(chartDir, "plans", "large.yaml"))
+			Expect(err).To(BeNil())
+			Expect(string(values)).To(ContainSubstring("64Gi"))
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Plans).To(HaveKey("small"))
+			Expect(charts[0].Plans).To(HaveKey("medium"))
+			Expect(charts[0].Plans["large"].Description).To(Equal("large plan"))
+			Expect(*charts[0].Plans["large"].Free).To(BeFalse())
+		})
+
+		It("packages plan changes without changing the stored version", func() {
+			before, err := ioutil.ReadFile(filepath.Join(chartDir, "plans.yaml"))
+			Expect(err).To(BeNil())
+
+			chartFile, err := myRepository.PackageChartWithPlan("spacebears", helm.Plan{Name: "large"}, nil, false, packageDir)
+			Expect(err).To(BeNil())
+			Expect(chartFile).To(Equal(filepath.Join(packageDir, "spacebears-0.0.1.tgz")))
+
+			after, err := ioutil.ReadFile(filepath.Join(chartDir, "plans.yaml"))
+			Expect(err).To(BeNil())
+			Expect(after).To(Equal(before))
+			packaged, err := chartutil.Load(chartFile)
+			Expect(err).To(BeNil())
+			Expect(packaged.Metadata.Name).To(Equal("spacebears"))
+			files := []string{}
+			for _, file := range packaged.Files {
+				files = append(files, file.TypeUrl)
+			}
+			Expect(files).To(ContainElement("plans/large.yaml"))
+		})
+
+		It("replaces a plan, keeping its credentials", func() {
+			err := savePlan(helm.Plan{
+				Name:   "medium",
+				Values: []byte("persistence:\n  size: 32Gi\n"),
+			}, nil, false)
+			Expect(err).To(BeNil())
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Plans).To(HaveLen(2))
+			Expect(string(charts[0].Plans["medium"].Values)).To(ContainSubstring("32Gi"))
+			Expect(charts[0].Plans["medium"].CredentialsPath).To(Equal("medium-creds.yaml"))
+			Expect(charts[0].Plans["medium"].ClusterConfig).NotTo(BeNil())
+		})
+
+		It("keeps the values of a plan updated without them", func() {
+			err := savePlan(helm.Plan{
+				Name:        "medium",
+				Description: "bigger medium plan",
+			}, nil, false)
+			Expect(err).To(BeNil())
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Plans["medium"].Description).To(Equal("bigger medium plan"))
+			Expect(string(charts[0].Plans["medium"].Values)).To(ContainSubstring("16Gi"))
+		})
+
+		It("clears the credentials of a plan", func() {
+			err := savePlan(helm.Plan{
+				Name: "medium",
+			}, nil, true)
+			Expect(err).To(BeNil())
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Plans["medium"].CredentialsPath).To(BeEmpty())
+			Expect(charts[0].Plans["medium"].ClusterConfig).To(BeNil())
+			_, err = os.Stat(filepath.Join(chartDir, "plans", "medium-creds.yaml"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("rejects credentials that are both set and cleared", func() {
+			err := savePlan(helm.Plan{
+				Name: "medium",
+			}, []byte("apiVersion: v1"), true)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("rejects an invalid plan without changing the chart", func() {
+			before, err := ioutil.ReadFile(filepath.Join(chartDir, "plans.yaml"))
+			Expect(err).To(BeNil())
+
+			err = savePlan(helm.Plan{
+				Name: "large",
+			}, []byte("clusters: not-a-list"), false)
+			Expect(err).NotTo(BeNil())
+
+			after, err := ioutil.ReadFile(filepath.Join(chartDir, "plans.yaml"))
+			Expect(err).To(BeNil())
+			Expect(after).To(Equal(before))
+			_, err = os.Stat(filepath.Join(chartDir, "plans", "large.yaml"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("rejects plan files outside of plans", func() {
+			err := savePlan(helm.Plan{
+				Name: "large",
+				File: "../values.yaml",
+			}, nil, false)
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("deletes a plan and its files", func() {
+			err := deletePlan("spacebears", "medium")
+			Expect(err).To(BeNil())
+
+			charts, err := myRepository.GetCharts()
+			Expect(err).To(BeNil())
+			Expect(charts[0].Plans).To(HaveLen(1))
+			Expect(charts[0].Plans).To(HaveKey("small"))
+			_, err = os.Stat(filepath.Join(chartDir, "plans", "medium-creds.yaml"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		It("errors on unknown chart or plan", func() {
+			err := deletePlan("mysql", "small")
+			Expect(err).NotTo(BeNil())
+
+			err = deletePlan("spacebears", "large")
+			Expect(err).NotTo(BeNil())
 		})
 	})
 
@@ -452,7 +1200,7 @@ version: 0.0.2
 			err = testChart.WriteChart(deletePath)
 			Expect(err).To(BeNil())
 			logger = logrus.New()
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 
 			err = myRepository.DeleteChart("spacebears")
 			Expect(err).To(BeNil())
@@ -477,7 +1225,7 @@ version: 0.0.2
 			Expect(err).To(BeNil())
 
 			logger = logrus.New()
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 
 			err = myRepository.DeleteChart("spacebears")
 			Expect(err).To(BeNil())
@@ -494,7 +1242,7 @@ version: 0.0.2
 			Expect(err).To(BeNil())
 
 			logger = logrus.New()
-			myRepository := repository.NewRepository(chartPath, "", logger)
+			myRepository := repository.NewRepository(chartPath, repository.Options{}, logger)
 
 			err = myRepository.DeleteChart("spacebears")
 			Expect(err).To(BeNil())
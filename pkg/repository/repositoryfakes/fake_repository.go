@@ -9,6 +9,18 @@ import (
 )
 
 type FakeRepository struct {
+	ActivateChartVersionStub        func(string, string) error
+	activateChartVersionMutex       sync.RWMutex
+	activateChartVersionArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	activateChartVersionReturns struct {
+		result1 error
+	}
+	activateChartVersionReturnsOnCall map[int]struct {
+		result1 error
+	}
 	ClearCacheStub        func() error
 	clearCacheMutex       sync.RWMutex
 	clearCacheArgsForCall []struct {
@@ -30,6 +42,18 @@ type FakeRepository struct {
 	deleteChartReturnsOnCall map[int]struct {
 		result1 error
 	}
+	GetChartVersionsStub        func() ([]repository.ChartVersions, error)
+	getChartVersionsMutex       sync.RWMutex
+	getChartVersionsArgsForCall []struct {
+	}
+	getChartVersionsReturns struct {
+		result1 []repository.ChartVersions
+		result2 error
+	}
+	getChartVersionsReturnsOnCall map[int]struct {
+		result1 []repository.ChartVersions
+		result2 error
+	}
 	GetChartsStub        func() ([]*helm.MyChart, error)
 	getChartsMutex       sync.RWMutex
 	getChartsArgsForCall []struct {
@@ -42,6 +66,50 @@ type FakeRepository struct {
 		result1 []*helm.MyChart
 		result2 error
 	}
+	GetQuarantinedChartsStub        func() ([]repository.QuarantinedChart, error)
+	getQuarantinedChartsMutex       sync.RWMutex
+	getQuarantinedChartsArgsForCall []struct {
+	}
+	getQuarantinedChartsReturns struct {
+		result1 []repository.QuarantinedChart
+		result2 error
+	}
+	getQuarantinedChartsReturnsOnCall map[int]struct {
+		result1 []repository.QuarantinedChart
+		result2 error
+	}
+	PackageChartWithPlanStub        func(string, helm.Plan, []byte, bool, string) (string, error)
+	packageChartWithPlanMutex       sync.RWMutex
+	packageChartWithPlanArgsForCall []struct {
+		arg1 string
+		arg2 helm.Plan
+		arg3 []byte
+		arg4 bool
+		arg5 string
+	}
+	packageChartWithPlanReturns struct {
+		result1 string
+		result2 error
+	}
+	packageChartWithPlanReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
+	PackageChartWithoutPlanStub        func(string, string, string) (string, error)
+	packageChartWithoutPlanMutex       sync.RWMutex
+	packageChartWithoutPlanArgsForCall []struct {
+		arg1 string
+		arg2 string
+		arg3 string
+	}
+	packageChartWithoutPlanReturns struct {
+		result1 string
+		result2 error
+	}
+	packageChartWithoutPlanReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	SaveChartStub        func(string) error
 	saveChartMutex       sync.RWMutex
 	saveChartArgsForCall []struct {
@@ -53,10 +121,127 @@ type FakeRepository struct {
 	saveChartReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SignedChartArchiveStub        func(string) (string, string, error)
+	signedChartArchiveMutex       sync.RWMutex
+	signedChartArchiveArgsForCall []struct {
+		arg1 string
+	}
+	signedChartArchiveReturns struct {
+		result1 string
+		result2 string
+		result3 error
+	}
+	signedChartArchiveReturnsOnCall map[int]struct {
+		result1 string
+		result2 string
+		result3 error
+	}
+	StageActivateChartVersionStub        func(string, string) (repository.StagedChange, error)
+	stageActivateChartVersionMutex       sync.RWMutex
+	stageActivateChartVersionArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	stageActivateChartVersionReturns struct {
+		result1 repository.StagedChange
+		result2 error
+	}
+	stageActivateChartVersionReturnsOnCall map[int]struct {
+		result1 repository.StagedChange
+		result2 error
+	}
+	StageDeleteChartStub        func(string) (repository.StagedChange, error)
+	stageDeleteChartMutex       sync.RWMutex
+	stageDeleteChartArgsForCall []struct {
+		arg1 string
+	}
+	stageDeleteChartReturns struct {
+		result1 repository.StagedChange
+		result2 error
+	}
+	stageDeleteChartReturnsOnCall map[int]struct {
+		result1 repository.StagedChange
+		result2 error
+	}
+	StageSaveChartStub        func(string, string) (repository.StagedChange, error)
+	stageSaveChartMutex       sync.RWMutex
+	stageSaveChartArgsForCall []struct {
+		arg1 string
+		arg2 string
+	}
+	stageSaveChartReturns struct {
+		result1 repository.StagedChange
+		result2 error
+	}
+	stageSaveChartReturnsOnCall map[int]struct {
+		result1 repository.StagedChange
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
 
+func (fake *FakeRepository) ActivateChartVersion(arg1 string, arg2 string) error {
+	fake.activateChartVersionMutex.Lock()
+	ret, specificReturn := fake.activateChartVersionReturnsOnCall[len(fake.activateChartVersionArgsForCall)]
+	fake.activateChartVersionArgsForCall = append(fake.activateChartVersionArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("ActivateChartVersion", []interface{}{arg1, arg2})
+	fake.activateChartVersionMutex.Unlock()
+	if fake.ActivateChartVersionStub != nil {
+		return fake.ActivateChartVersionStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.activateChartVersionReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeRepository) ActivateChartVersionCallCount() int {
+	fake.activateChartVersionMutex.RLock()
+	defer fake.activateChartVersionMutex.RUnlock()
+	return len(fake.activateChartVersionArgsForCall)
+}
+
+func (fake *FakeRepository) ActivateChartVersionCalls(stub func(string, string) error) {
+	fake.activateChartVersionMutex.Lock()
+	defer fake.activateChartVersionMutex.Unlock()
+	fake.ActivateChartVersionStub = stub
+}
+
+func (fake *FakeRepository) ActivateChartVersionArgsForCall(i int) (string, string) {
+	fake.activateChartVersionMutex.RLock()
+	defer fake.activateChartVersionMutex.RUnlock()
+	argsForCall := fake.activateChartVersionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRepository) ActivateChartVersionReturns(result1 error) {
+	fake.activateChartVersionMutex.Lock()
+	defer fake.activateChartVersionMutex.Unlock()
+	fake.ActivateChartVersionStub = nil
+	fake.activateChartVersionReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeRepository) ActivateChartVersionReturnsOnCall(i int, result1 error) {
+	fake.activateChartVersionMutex.Lock()
+	defer fake.activateChartVersionMutex.Unlock()
+	fake.ActivateChartVersionStub = nil
+	if fake.activateChartVersionReturnsOnCall == nil {
+		fake.activateChartVersionReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.activateChartVersionReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeRepository) ClearCache() error {
 	fake.clearCacheMutex.Lock()
 	ret, specificReturn := fake.clearCacheReturnsOnCall[len(fake.clearCacheArgsForCall)]
@@ -169,6 +354,61 @@ func (fake *FakeRepository) DeleteChartReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeRepository) GetChartVersions() ([]repository.ChartVersions, error) {
+	fake.getChartVersionsMutex.Lock()
+	ret, specificReturn := fake.getChartVersionsReturnsOnCall[len(fake.getChartVersionsArgsForCall)]
+	fake.getChartVersionsArgsForCall = append(fake.getChartVersionsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("GetChartVersions", []interface{}{})
+	fake.getChartVersionsMutex.Unlock()
+	if fake.GetChartVersionsStub != nil {
+		return fake.GetChartVersionsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getChartVersionsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) GetChartVersionsCallCount() int {
+	fake.getChartVersionsMutex.RLock()
+	defer fake.getChartVersionsMutex.RUnlock()
+	return len(fake.getChartVersionsArgsForCall)
+}
+
+func (fake *FakeRepository) GetChartVersionsCalls(stub func() ([]repository.ChartVersions, error)) {
+	fake.getChartVersionsMutex.Lock()
+	defer fake.getChartVersionsMutex.Unlock()
+	fake.GetChartVersionsStub = stub
+}
+
+func (fake *FakeRepository) GetChartVersionsReturns(result1 []repository.ChartVersions, result2 error) {
+	fake.getChartVersionsMutex.Lock()
+	defer fake.getChartVersionsMutex.Unlock()
+	fake.GetChartVersionsStub = nil
+	fake.getChartVersionsReturns = struct {
+		result1 []repository.ChartVersions
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) GetChartVersionsReturnsOnCall(i int, result1 []repository.ChartVersions, result2 error) {
+	fake.getChartVersionsMutex.Lock()
+	defer fake.getChartVersionsMutex.Unlock()
+	fake.GetChartVersionsStub = nil
+	if fake.getChartVersionsReturnsOnCall == nil {
+		fake.getChartVersionsReturnsOnCall = make(map[int]struct {
+			result1 []repository.ChartVersions
+			result2 error
+		})
+	}
+	fake.getChartVersionsReturnsOnCall[i] = struct {
+		result1 []repository.ChartVersions
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeRepository) GetCharts() ([]*helm.MyChart, error) {
 	fake.getChartsMutex.Lock()
 	ret, specificReturn := fake.getChartsReturnsOnCall[len(fake.getChartsArgsForCall)]
@@ -224,6 +464,198 @@ func (fake *FakeRepository) GetChartsReturnsOnCall(i int, result1 []*helm.MyChar
 	}{result1, result2}
 }
 
+func (fake *FakeRepository) GetQuarantinedCharts() ([]repository.QuarantinedChart, error) {
+	fake.getQuarantinedChartsMutex.Lock()
+	ret, specificReturn := fake.getQuarantinedChartsReturnsOnCall[len(fake.getQuarantinedChartsArgsForCall)]
+	fake.getQuarantinedChartsArgsForCall = append(fake.getQuarantinedChartsArgsForCall, struct {
+	}{})
+	fake.recordInvocation("GetQuarantinedCharts", []interface{}{})
+	fake.getQuarantinedChartsMutex.Unlock()
+	if fake.GetQuarantinedChartsStub != nil {
+		return fake.GetQuarantinedChartsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getQuarantinedChartsReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) GetQuarantinedChartsCallCount() int {
+	fake.getQuarantinedChartsMutex.RLock()
+	defer fake.getQuarantinedChartsMutex.RUnlock()
+	return len(fake.getQuarantinedChartsArgsForCall)
+}
+
+func (fake *FakeRepository) GetQuarantinedChartsCalls(stub func() ([]repository.QuarantinedChart, error)) {
+	fake.getQuarantinedChartsMutex.Lock()
+	defer fake.getQuarantinedChartsMutex.Unlock()
+	fake.GetQuarantinedChartsStub = stub
+}
+
+func (fake *FakeRepository) GetQuarantinedChartsReturns(result1 []repository.QuarantinedChart, result2 error) {
+	fake.getQuarantinedChartsMutex.Lock()
+	defer fake.getQuarantinedChartsMutex.Unlock()
+	fake.GetQuarantinedChartsStub = nil
+	fake.getQuarantinedChartsReturns = struct {
+		result1 []repository.QuarantinedChart
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) GetQuarantinedChartsReturnsOnCall(i int, result1 []repository.QuarantinedChart, result2 error) {
+	fake.getQuarantinedChartsMutex.Lock()
+	defer fake.getQuarantinedChartsMutex.Unlock()
+	fake.GetQuarantinedChartsStub = nil
+	if fake.getQuarantinedChartsReturnsOnCall == nil {
+		fake.getQuarantinedChartsReturnsOnCall = make(map[int]struct {
+			result1 []repository.QuarantinedChart
+			result2 error
+		})
+	}
+	fake.getQuarantinedChartsReturnsOnCall[i] = struct {
+		result1 []repository.QuarantinedChart
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) PackageChartWithPlan(arg1 string, arg2 helm.Plan, arg3 []byte, arg4 bool, arg5 string) (string, error) {
+	var arg3Copy []byte
+	if arg3 != nil {
+		arg3Copy = make([]byte, len(arg3))
+		copy(arg3Copy, arg3)
+	}
+	fake.packageChartWithPlanMutex.Lock()
+	ret, specificReturn := fake.packageChartWithPlanReturnsOnCall[len(fake.packageChartWithPlanArgsForCall)]
+	fake.packageChartWithPlanArgsForCall = append(fake.packageChartWithPlanArgsForCall, struct {
+		arg1 string
+		arg2 helm.Plan
+		arg3 []byte
+		arg4 bool
+		arg5 string
+	}{arg1, arg2, arg3Copy, arg4, arg5})
+	fake.recordInvocation("PackageChartWithPlan", []interface{}{arg1, arg2, arg3Copy, arg4, arg5})
+	fake.packageChartWithPlanMutex.Unlock()
+	if fake.PackageChartWithPlanStub != nil {
+		return fake.PackageChartWithPlanStub(arg1, arg2, arg3, arg4, arg5)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.packageChartWithPlanReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) PackageChartWithPlanCallCount() int {
+	fake.packageChartWithPlanMutex.RLock()
+	defer fake.packageChartWithPlanMutex.RUnlock()
+	return len(fake.packageChartWithPlanArgsForCall)
+}
+
+func (fake *FakeRepository) PackageChartWithPlanCalls(stub func(string, helm.Plan, []byte, bool, string) (string, error)) {
+	fake.packageChartWithPlanMutex.Lock()
+	defer fake.packageChartWithPlanMutex.Unlock()
+	fake.PackageChartWithPlanStub = stub
+}
+
+func (fake *FakeRepository) PackageChartWithPlanArgsForCall(i int) (string, helm.Plan, []byte, bool, string) {
+	fake.packageChartWithPlanMutex.RLock()
+	defer fake.packageChartWithPlanMutex.RUnlock()
+	argsForCall := fake.packageChartWithPlanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4, argsForCall.arg5
+}
+
+func (fake *FakeRepository) PackageChartWithPlanReturns(result1 string, result2 error) {
+	fake.packageChartWithPlanMutex.Lock()
+	defer fake.packageChartWithPlanMutex.Unlock()
+	fake.PackageChartWithPlanStub = nil
+	fake.packageChartWithPlanReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) PackageChartWithPlanReturnsOnCall(i int, result1 string, result2 error) {
+	fake.packageChartWithPlanMutex.Lock()
+	defer fake.packageChartWithPlanMutex.Unlock()
+	fake.PackageChartWithPlanStub = nil
+	if fake.packageChartWithPlanReturnsOnCall == nil {
+		fake.packageChartWithPlanReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.packageChartWithPlanReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlan(arg1 string, arg2 string, arg3 string) (string, error) {
+	fake.packageChartWithoutPlanMutex.Lock()
+	ret, specificReturn := fake.packageChartWithoutPlanReturnsOnCall[len(fake.packageChartWithoutPlanArgsForCall)]
+	fake.packageChartWithoutPlanArgsForCall = append(fake.packageChartWithoutPlanArgsForCall, struct {
+		arg1 string
+		arg2 string
+		arg3 string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("PackageChartWithoutPlan", []interface{}{arg1, arg2, arg3})
+	fake.packageChartWithoutPlanMutex.Unlock()
+	if fake.PackageChartWithoutPlanStub != nil {
+		return fake.PackageChartWithoutPlanStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.packageChartWithoutPlanReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlanCallCount() int {
+	fake.packageChartWithoutPlanMutex.RLock()
+	defer fake.packageChartWithoutPlanMutex.RUnlock()
+	return len(fake.packageChartWithoutPlanArgsForCall)
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlanCalls(stub func(string, string, string) (string, error)) {
+	fake.packageChartWithoutPlanMutex.Lock()
+	defer fake.packageChartWithoutPlanMutex.Unlock()
+	fake.PackageChartWithoutPlanStub = stub
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlanArgsForCall(i int) (string, string, string) {
+	fake.packageChartWithoutPlanMutex.RLock()
+	defer fake.packageChartWithoutPlanMutex.RUnlock()
+	argsForCall := fake.packageChartWithoutPlanArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlanReturns(result1 string, result2 error) {
+	fake.packageChartWithoutPlanMutex.Lock()
+	defer fake.packageChartWithoutPlanMutex.Unlock()
+	fake.PackageChartWithoutPlanStub = nil
+	fake.packageChartWithoutPlanReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) PackageChartWithoutPlanReturnsOnCall(i int, result1 string, result2 error) {
+	fake.packageChartWithoutPlanMutex.Lock()
+	defer fake.packageChartWithoutPlanMutex.Unlock()
+	fake.PackageChartWithoutPlanStub = nil
+	if fake.packageChartWithoutPlanReturnsOnCall == nil {
+		fake.packageChartWithoutPlanReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.packageChartWithoutPlanReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeRepository) SaveChart(arg1 string) error {
 	fake.saveChartMutex.Lock()
 	ret, specificReturn := fake.saveChartReturnsOnCall[len(fake.saveChartArgsForCall)]
@@ -284,17 +716,292 @@ func (fake *FakeRepository) SaveChartReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeRepository) SignedChartArchive(arg1 string) (string, string, error) {
+	fake.signedChartArchiveMutex.Lock()
+	ret, specificReturn := fake.signedChartArchiveReturnsOnCall[len(fake.signedChartArchiveArgsForCall)]
+	fake.signedChartArchiveArgsForCall = append(fake.signedChartArchiveArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("SignedChartArchive", []interface{}{arg1})
+	fake.signedChartArchiveMutex.Unlock()
+	if fake.SignedChartArchiveStub != nil {
+		return fake.SignedChartArchiveStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	fakeReturns := fake.signedChartArchiveReturns
+	return fakeReturns.result1, fakeReturns.result2, fakeReturns.result3
+}
+
+func (fake *FakeRepository) SignedChartArchiveCallCount() int {
+	fake.signedChartArchiveMutex.RLock()
+	defer fake.signedChartArchiveMutex.RUnlock()
+	return len(fake.signedChartArchiveArgsForCall)
+}
+
+func (fake *FakeRepository) SignedChartArchiveCalls(stub func(string) (string, string, error)) {
+	fake.signedChartArchiveMutex.Lock()
+	defer fake.signedChartArchiveMutex.Unlock()
+	fake.SignedChartArchiveStub = stub
+}
+
+func (fake *FakeRepository) SignedChartArchiveArgsForCall(i int) string {
+	fake.signedChartArchiveMutex.RLock()
+	defer fake.signedChartArchiveMutex.RUnlock()
+	argsForCall := fake.signedChartArchiveArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRepository) SignedChartArchiveReturns(result1 string, result2 string, result3 error) {
+	fake.signedChartArchiveMutex.Lock()
+	defer fake.signedChartArchiveMutex.Unlock()
+	fake.SignedChartArchiveStub = nil
+	fake.signedChartArchiveReturns = struct {
+		result1 string
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeRepository) SignedChartArchiveReturnsOnCall(i int, result1 string, result2 string, result3 error) {
+	fake.signedChartArchiveMutex.Lock()
+	defer fake.signedChartArchiveMutex.Unlock()
+	fake.SignedChartArchiveStub = nil
+	if fake.signedChartArchiveReturnsOnCall == nil {
+		fake.signedChartArchiveReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 string
+			result3 error
+		})
+	}
+	fake.signedChartArchiveReturnsOnCall[i] = struct {
+		result1 string
+		result2 string
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeRepository) StageActivateChartVersion(arg1 string, arg2 string) (repository.StagedChange, error) {
+	fake.stageActivateChartVersionMutex.Lock()
+	ret, specificReturn := fake.stageActivateChartVersionReturnsOnCall[len(fake.stageActivateChartVersionArgsForCall)]
+	fake.stageActivateChartVersionArgsForCall = append(fake.stageActivateChartVersionArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("StageActivateChartVersion", []interface{}{arg1, arg2})
+	fake.stageActivateChartVersionMutex.Unlock()
+	if fake.StageActivateChartVersionStub != nil {
+		return fake.StageActivateChartVersionStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.stageActivateChartVersionReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) StageActivateChartVersionCallCount() int {
+	fake.stageActivateChartVersionMutex.RLock()
+	defer fake.stageActivateChartVersionMutex.RUnlock()
+	return len(fake.stageActivateChartVersionArgsForCall)
+}
+
+func (fake *FakeRepository) StageActivateChartVersionCalls(stub func(string, string) (repository.StagedChange, error)) {
+	fake.stageActivateChartVersionMutex.Lock()
+	defer fake.stageActivateChartVersionMutex.Unlock()
+	fake.StageActivateChartVersionStub = stub
+}
+
+func (fake *FakeRepository) StageActivateChartVersionArgsForCall(i int) (string, string) {
+	fake.stageActivateChartVersionMutex.RLock()
+	defer fake.stageActivateChartVersionMutex.RUnlock()
+	argsForCall := fake.stageActivateChartVersionArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRepository) StageActivateChartVersionReturns(result1 repository.StagedChange, result2 error) {
+	fake.stageActivateChartVersionMutex.Lock()
+	defer fake.stageActivateChartVersionMutex.Unlock()
+	fake.StageActivateChartVersionStub = nil
+	fake.stageActivateChartVersionReturns = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) StageActivateChartVersionReturnsOnCall(i int, result1 repository.StagedChange, result2 error) {
+	fake.stageActivateChartVersionMutex.Lock()
+	defer fake.stageActivateChartVersionMutex.Unlock()
+	fake.StageActivateChartVersionStub = nil
+	if fake.stageActivateChartVersionReturnsOnCall == nil {
+		fake.stageActivateChartVersionReturnsOnCall = make(map[int]struct {
+			result1 repository.StagedChange
+			result2 error
+		})
+	}
+	fake.stageActivateChartVersionReturnsOnCall[i] = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) StageDeleteChart(arg1 string) (repository.StagedChange, error) {
+	fake.stageDeleteChartMutex.Lock()
+	ret, specificReturn := fake.stageDeleteChartReturnsOnCall[len(fake.stageDeleteChartArgsForCall)]
+	fake.stageDeleteChartArgsForCall = append(fake.stageDeleteChartArgsForCall, struct {
+		arg1 string
+	}{arg1})
+	fake.recordInvocation("StageDeleteChart", []interface{}{arg1})
+	fake.stageDeleteChartMutex.Unlock()
+	if fake.StageDeleteChartStub != nil {
+		return fake.StageDeleteChartStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.stageDeleteChartReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) StageDeleteChartCallCount() int {
+	fake.stageDeleteChartMutex.RLock()
+	defer fake.stageDeleteChartMutex.RUnlock()
+	return len(fake.stageDeleteChartArgsForCall)
+}
+
+func (fake *FakeRepository) StageDeleteChartCalls(stub func(string) (repository.StagedChange, error)) {
+	fake.stageDeleteChartMutex.Lock()
+	defer fake.stageDeleteChartMutex.Unlock()
+	fake.StageDeleteChartStub = stub
+}
+
+func (fake *FakeRepository) StageDeleteChartArgsForCall(i int) string {
+	fake.stageDeleteChartMutex.RLock()
+	defer fake.stageDeleteChartMutex.RUnlock()
+	argsForCall := fake.stageDeleteChartArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeRepository) StageDeleteChartReturns(result1 repository.StagedChange, result2 error) {
+	fake.stageDeleteChartMutex.Lock()
+	defer fake.stageDeleteChartMutex.Unlock()
+	fake.StageDeleteChartStub = nil
+	fake.stageDeleteChartReturns = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) StageDeleteChartReturnsOnCall(i int, result1 repository.StagedChange, result2 error) {
+	fake.stageDeleteChartMutex.Lock()
+	defer fake.stageDeleteChartMutex.Unlock()
+	fake.StageDeleteChartStub = nil
+	if fake.stageDeleteChartReturnsOnCall == nil {
+		fake.stageDeleteChartReturnsOnCall = make(map[int]struct {
+			result1 repository.StagedChange
+			result2 error
+		})
+	}
+	fake.stageDeleteChartReturnsOnCall[i] = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) StageSaveChart(arg1 string, arg2 string) (repository.StagedChange, error) {
+	fake.stageSaveChartMutex.Lock()
+	ret, specificReturn := fake.stageSaveChartReturnsOnCall[len(fake.stageSaveChartArgsForCall)]
+	fake.stageSaveChartArgsForCall = append(fake.stageSaveChartArgsForCall, struct {
+		arg1 string
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("StageSaveChart", []interface{}{arg1, arg2})
+	fake.stageSaveChartMutex.Unlock()
+	if fake.StageSaveChartStub != nil {
+		return fake.StageSaveChartStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.stageSaveChartReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeRepository) StageSaveChartCallCount() int {
+	fake.stageSaveChartMutex.RLock()
+	defer fake.stageSaveChartMutex.RUnlock()
+	return len(fake.stageSaveChartArgsForCall)
+}
+
+func (fake *FakeRepository) StageSaveChartCalls(stub func(string, string) (repository.StagedChange, error)) {
+	fake.stageSaveChartMutex.Lock()
+	defer fake.stageSaveChartMutex.Unlock()
+	fake.StageSaveChartStub = stub
+}
+
+func (fake *FakeRepository) StageSaveChartArgsForCall(i int) (string, string) {
+	fake.stageSaveChartMutex.RLock()
+	defer fake.stageSaveChartMutex.RUnlock()
+	argsForCall := fake.stageSaveChartArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeRepository) StageSaveChartReturns(result1 repository.StagedChange, result2 error) {
+	fake.stageSaveChartMutex.Lock()
+	defer fake.stageSaveChartMutex.Unlock()
+	fake.StageSaveChartStub = nil
+	fake.stageSaveChartReturns = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeRepository) StageSaveChartReturnsOnCall(i int, result1 repository.StagedChange, result2 error) {
+	fake.stageSaveChartMutex.Lock()
+	defer fake.stageSaveChartMutex.Unlock()
+	fake.StageSaveChartStub = nil
+	if fake.stageSaveChartReturnsOnCall == nil {
+		fake.stageSaveChartReturnsOnCall = make(map[int]struct {
+			result1 repository.StagedChange
+			result2 error
+		})
+	}
+	fake.stageSaveChartReturnsOnCall[i] = struct {
+		result1 repository.StagedChange
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeRepository) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
+	fake.activateChartVersionMutex.RLock()
+	defer fake.activateChartVersionMutex.RUnlock()
 	fake.clearCacheMutex.RLock()
 	defer fake.clearCacheMutex.RUnlock()
 	fake.deleteChartMutex.RLock()
 	defer fake.deleteChartMutex.RUnlock()
+	fake.getChartVersionsMutex.RLock()
+	defer fake.getChartVersionsMutex.RUnlock()
 	fake.getChartsMutex.RLock()
 	defer fake.getChartsMutex.RUnlock()
+	fake.getQuarantinedChartsMutex.RLock()
+	defer fake.getQuarantinedChartsMutex.RUnlock()
+	fake.packageChartWithPlanMutex.RLock()
+	defer fake.packageChartWithPlanMutex.RUnlock()
+	fake.packageChartWithoutPlanMutex.RLock()
+	defer fake.packageChartWithoutPlanMutex.RUnlock()
 	fake.saveChartMutex.RLock()
 	defer fake.saveChartMutex.RUnlock()
+	fake.signedChartArchiveMutex.RLock()
+	defer fake.signedChartArchiveMutex.RUnlock()
+	fake.stageActivateChartVersionMutex.RLock()
+	defer fake.stageActivateChartVersionMutex.RUnlock()
+	fake.stageDeleteChartMutex.RLock()
+	defer fake.stageDeleteChartMutex.RUnlock()
+	fake.stageSaveChartMutex.RLock()
+	defer fake.stageSaveChartMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,275 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Charts are staged into stagingDir, inside helmChartDir so that staging and restoring them is a rename
+const stagingDir = "staging_tmp"
+
+// StagedChange is a change to the charts on disk that keeps what it replaced until it's committed,
+// so that it can be rolled back
+type StagedChange interface {
+	Commit() error
+	Rollback() error
+}
+
+// stagedChange keeps the directory a change replaced, replacedDir (a single version of a chart, or
+// the whole chart when it's deleted), in stagedDir, along with the version that was active before.
+// A replaced version's signed archive, replacedSigned, is kept with it.
+type stagedChange struct {
+	repo           *repository
+	chartDir       string
+	replacedDir    string
+	replacedSigned string
+	stagedDir      string
+	active         string
+	created        bool
+	prune          bool
+	// migrated is the version a chart stored directly in chartDir was moved to before the change
+	migrated string
+	// release unlocks the chart once the change is committed or rolled back
+	release func()
+}
+
+// ChartBusyError is returned when a chart already has a staged change that's not committed or
+// rolled back yet
+type ChartBusyError struct {
+	Name string
+}
+
+func (e *ChartBusyError) Error() string {
+	return fmt.Sprintf("Chart [%s] is being changed by another request", e.Name)
+}
+
+// lockChart keeps a chart from being staged again until its staged change is committed or rolled
+// back, so that a rollback never restores over another request's change
+func (r *repository) lockChart(name string) (func(), error) {
+	r.stagedLock.Lock()
+	defer r.stagedLock.Unlock()
+
+	if r.staged[name] {
+		return nil, &ChartBusyError{Name: name}
+	}
+	r.staged[name] = true
+	return func() {
+		r.stagedLock.Lock()
+		defer r.stagedLock.Unlock()
+
+		delete(r.staged, name)
+	}, nil
+}
+
+func (r *repository) StageSaveChart(path string, provenancePath string) (StagedChange, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	change, err := r.saveChart(path, provenancePath)
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (r *repository) StageDeleteChart(name string) (StagedChange, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	change, err := r.deleteChart(name)
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// stage sets replacedDir and replacedSigned, in the chart in chartDir, aside before they're changed,
+// and records the active version of the chart so that it can be restored too. replacedDir is empty
+// when only the active version changes.
+func (r *repository) stage(chartDir string, replacedDir string, replacedSigned string) (*stagedChange, error) {
+	change := &stagedChange{
+		repo:           r,
+		chartDir:       chartDir,
+		replacedDir:    replacedDir,
+		replacedSigned: replacedSigned,
+	}
+	if _, err := os.Stat(chartDir); os.IsNotExist(err) {
+		change.created = true
+		return change, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if replacedDir != chartDir {
+		active, err := r.activeVersion(chartDir)
+		if err != nil {
+			return nil, err
+		}
+		change.active = active
+	}
+	replaced := []string{}
+	for _, path := range []string{replacedDir, replacedSigned} {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		replaced = append(replaced, path)
+	}
+	if len(replaced) == 0 {
+		return change, nil
+	}
+
+	err := os.MkdirAll(filepath.Join(r.helmChartDir, stagingDir), 0700)
+	if err != nil {
+		return nil, err
+	}
+	stagedDir, err := ioutil.TempDir(filepath.Join(r.helmChartDir, stagingDir), filepath.Base(chartDir)+"-")
+	if err != nil {
+		return nil, err
+	}
+
+	for i, path := range replaced {
+		err = os.Rename(path, filepath.Join(stagedDir, change.stagedPath(path)))
+		if err != nil {
+			for _, staged := range replaced[:i] {
+				os.Rename(filepath.Join(stagedDir, change.stagedPath(staged)), staged)
+			}
+			os.RemoveAll(stagedDir)
+			return nil, errors.Wrapf(err, "Unable to stage [%s]", path)
+		}
+	}
+	change.stagedDir = stagedDir
+	return change, nil
+}
+
+// Commit discards what the change replaced, and the versions of the chart over the retained
+// number. Versions are only pruned here so that a rollback never has to bring them back.
+func (c *stagedChange) Commit() error {
+	defer c.unlock()
+
+	if c.stagedDir != "" {
+		err := os.RemoveAll(c.stagedDir)
+		if err != nil {
+			return err
+		}
+	}
+	if !c.prune {
+		return nil
+	}
+
+	c.repo.diskLock.Lock()
+	defer c.repo.diskLock.Unlock()
+	return c.repo.pruneVersions(c.chartDir)
+}
+
+// Rollback puts the staged directory and active version back, or removes the chart when there
+// was none before
+func (c *stagedChange) Rollback() error {
+	defer c.unlock()
+
+	c.repo.diskLock.Lock()
+	defer c.repo.diskLock.Unlock()
+
+	return c.restore()
+}
+
+func (c *stagedChange) unlock() {
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
+}
+
+func (c *stagedChange) restore() error {
+	defer c.repo.invalidate()
+
+	if c.created {
+		return os.RemoveAll(c.chartDir)
+	}
+	for _, path := range []string{c.replacedDir, c.replacedSigned} {
+		if path == "" {
+			continue
+		}
+		err := os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+		if c.stagedDir == "" {
+			continue
+		}
+		err = os.Rename(filepath.Join(c.stagedDir, c.stagedPath(path)), path)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if c.stagedDir != "" {
+		err := os.RemoveAll(c.stagedDir)
+		if err != nil {
+			return err
+		}
+	}
+	if c.active != "" {
+		err := replaceFile(filepath.Join(c.chartDir, activeVersionFile), []byte(c.active), 0600)
+		if err != nil {
+			return err
+		}
+	}
+	if c.migrated != "" {
+		return c.repo.restoreUnversioned(c.chartDir, c.migrated)
+	}
+	return nil
+}
+
+// stagedPath is the name path is kept under in stagedDir. A version's signed archives are named
+// after the version too, so they're kept under signedDir.
+func (c *stagedChange) stagedPath(path string) string {
+	if path == c.replacedSigned {
+		return signedDir
+	}
+	return filepath.Base(path)
+}
+
+// replaceFile writes path by renaming a new file over it, so that readers never see it partly written
+func replaceFile(path string, contents []byte, perm os.FileMode) error {
+	file, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(contents)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), perm)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,204 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/moreio"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// SyncedRepository is a Repository whose charts are pulled from a remote source into a local cache.
+type SyncedRepository interface {
+	Repository
+	Sync() error
+}
+
+// syncedCache serves charts out of the local cache for a remote source. The remote source owns
+// which charts and versions are served, so the cache can't be changed directly.
+type syncedCache struct {
+	cache    Repository
+	cacheDir string
+	source   string
+	logger   *logrus.Logger
+}
+
+func (s *syncedCache) GetCharts() ([]*helm.MyChart, error) {
+	return s.cache.GetCharts()
+}
+
+func (s *syncedCache) GetQuarantinedCharts() ([]QuarantinedChart, error) {
+	return s.cache.GetQuarantinedCharts()
+}
+
+func (s *syncedCache) GetChartVersions() ([]ChartVersions, error) {
+	return s.cache.GetChartVersions()
+}
+
+func (s *syncedCache) ActivateChartVersion(name string, version string) error {
+	return errors.Errorf("Chart versions are selected by the %s configuration", s.source)
+}
+
+func (s *syncedCache) SaveChart(path string) error {
+	return errors.Errorf("Charts are served from a %s, publish the chart there instead", s.source)
+}
+
+func (s *syncedCache) DeleteChart(name string) error {
+	return errors.Errorf("Charts are served from a %s, remove the chart from its configuration instead", s.source)
+}
+
+func (s *syncedCache) StageSaveChart(path string, provenancePath string) (StagedChange, error) {
+	return nil, s.SaveChart(path)
+}
+
+func (s *syncedCache) SignedChartArchive(name string) (string, string, error) {
+	return s.cache.SignedChartArchive(name)
+}
+
+func (s *syncedCache) StageDeleteChart(name string) (StagedChange, error) {
+	return nil, s.DeleteChart(name)
+}
+
+func (s *syncedCache) StageActivateChartVersion(name string, version string) (StagedChange, error) {
+	return nil, s.ActivateChartVersion(name, version)
+}
+
+func (s *syncedCache) PackageChartWithPlan(chartName string, plan helm.Plan, credentials []byte, clearCredentials bool, dir string) (string, error) {
+	return "", errors.Errorf("Charts are served from a %s, change the chart's plans there instead", s.source)
+}
+
+func (s *syncedCache) PackageChartWithoutPlan(chartName string, planName string, dir string) (string, error) {
+	return "", errors.Errorf("Charts are served from a %s, change the chart's plans there instead", s.source)
+}
+
+// syncAndReload reloads the cache after syncing. A failed sync (eg, the source being unreachable)
+// leaves the previously synced charts in place.
+func (s *syncedCache) syncAndReload(sync func() error) error {
+	err := sync()
+	if err != nil {
+		s.logger.WithError(err).Error(fmt.Sprintf("Unable to sync with %s, serving cached charts", s.source))
+	}
+	return s.cache.ClearCache()
+}
+
+func (s *syncedCache) isCached(name string, version string) bool {
+	return moreio.DirExistsAndIsReadable(filepath.Join(s.cacheDir, name, version))
+}
+
+// saveVerified saves the chart archive read from archive into the cache. When digest is set, the archive's
+// sha256 has to match it.
+func (s *syncedCache) saveVerified(archive io.Reader, digest string, name string, version string) error {
+	chartFile, err := ioutil.TempFile("", "synced-chart-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(chartFile.Name())
+	defer chartFile.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(chartFile, hash), archive)
+	if err != nil {
+		return err
+	}
+
+	digest = strings.TrimPrefix(digest, "sha256:")
+	if digest != "" && hex.EncodeToString(hash.Sum(nil)) != digest {
+		return errors.Errorf("Digest of chart [%s] version [%s] doesn't match the %s", name, version, s.source)
+	}
+
+	return s.cache.SaveChart(chartFile.Name())
+}
+
+// removeUnlisted removes cached charts that the source no longer lists
+func (s *syncedCache) removeUnlisted(listed map[string]bool) error {
+	chartVersions, err := s.cache.GetChartVersions()
+	if err != nil {
+		return err
+	}
+	for _, chartVersion := range chartVersions {
+		if listed[chartVersion.Name] {
+			continue
+		}
+		s.logger.Info(fmt.Sprintf("Removing chart [%s], it is no longer listed by the %s", chartVersion.Name, s.source))
+		err = s.cache.DeleteChart(chartVersion.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type syncPoller struct {
+	repository Repository
+	interval   time.Duration
+	logger     *logrus.Logger
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSyncPoller reloads the repository, syncing it with its source, every interval
+func NewSyncPoller(repository Repository, interval time.Duration, logger *logrus.Logger) Watcher {
+	return &syncPoller{
+		repository: repository,
+		interval:   interval,
+		logger:     logger,
+		done:       make(chan struct{}),
+	}
+}
+
+func (p *syncPoller) Start() error {
+	if p.interval <= 0 {
+		return errors.New("Sync interval must be positive")
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+				err := p.repository.ClearCache()
+				if err != nil {
+					p.logger.WithError(err).Error("Reloading charts after sync failed")
+				}
+			}
+		}
+	}()
+
+	p.logger.Info(fmt.Sprintf("Syncing charts every [%s]", p.interval))
+	return nil
+}
+
+func (p *syncPoller) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
@@ -0,0 +1,344 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/cf-platform-eng/kibosh/pkg/helm"
+	"github.com/cf-platform-eng/kibosh/pkg/moreio"
+	"github.com/pkg/errors"
+	"k8s.io/helm/pkg/chartutil"
+)
+
+// Charts are stored as <name>/<version>/, with the version used by the catalog recorded in <name>/.active.
+// A chart stored directly in <name>/ (the layout before versioning) is treated as its only version.
+const activeVersionFile = ".active"
+
+// A version uploaded with a provenance file also keeps the archive it was uploaded as, which is what
+// the provenance file signs, as <name>/.signed/<version>/<name>-<version>.tgz(.prov)
+const signedDir = ".signed"
+
+const DefaultRetainedVersions = 5
+
+type ChartVersions struct {
+	Name     string   `json:"name"`
+	Active   string   `json:"active"`
+	Versions []string `json:"versions"`
+}
+
+func (r *repository) GetChartVersions() ([]ChartVersions, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	chartVersions := []ChartVersions{}
+
+	helmDirFiles, err := ioutil.ReadDir(r.helmChartDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fileInfo := range helmDirFiles {
+		if !fileInfo.IsDir() || isReservedDir(fileInfo.Name()) {
+			continue
+		}
+		chartDir := filepath.Join(r.helmChartDir, fileInfo.Name())
+
+		unversioned, err := moreio.FileExists(filepath.Join(chartDir, "Chart.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		if unversioned {
+			metadata, err := chartutil.LoadChartfile(filepath.Join(chartDir, "Chart.yaml"))
+			if err != nil {
+				return nil, err
+			}
+			chartVersions = append(chartVersions, ChartVersions{
+				Name:     fileInfo.Name(),
+				Active:   metadata.Version,
+				Versions: []string{metadata.Version},
+			})
+			continue
+		}
+
+		versions, err := r.listVersions(chartDir)
+		if err != nil {
+			return nil, err
+		}
+		if len(versions) == 0 {
+			continue
+		}
+		active, err := r.activeVersion(chartDir)
+		if err != nil {
+			return nil, err
+		}
+		chartVersions = append(chartVersions, ChartVersions{
+			Name:     fileInfo.Name(),
+			Active:   active,
+			Versions: versions,
+		})
+	}
+
+	return chartVersions, nil
+}
+
+func (r *repository) ActivateChartVersion(name string, version string) error {
+	change, err := r.StageActivateChartVersion(name, version)
+	if err != nil {
+		return err
+	}
+	return change.Commit()
+}
+
+func (r *repository) StageActivateChartVersion(name string, version string) (StagedChange, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	chartDir := filepath.Join(r.helmChartDir, name)
+	versionPath := filepath.Join(chartDir, version)
+	if name != filepath.Base(name) || isReservedDir(name) || version != filepath.Base(version) || !moreio.DirExistsAndIsReadable(versionPath) {
+		return nil, errors.Errorf("Version [%s] of chart [%s] not found", version, name)
+	}
+
+	_, err := helm.NewChart(versionPath, r.registryConfig, r.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := r.lockChart(name)
+	if err != nil {
+		return nil, err
+	}
+	change, err := r.stage(chartDir, "", "")
+	if err != nil {
+		release()
+		return nil, err
+	}
+	change.release = release
+	err = replaceFile(filepath.Join(chartDir, activeVersionFile), []byte(version), 0600)
+	if err != nil {
+		change.unlock()
+		return nil, err
+	}
+
+	r.invalidate()
+	return change, nil
+}
+
+// SignedChartArchive returns the archive the active version of the chart was uploaded as and its
+// provenance file, or "" when the version wasn't uploaded with a provenance file
+func (r *repository) SignedChartArchive(name string) (string, string, error) {
+	r.diskLock.Lock()
+	defer r.diskLock.Unlock()
+
+	chartDir := filepath.Join(r.helmChartDir, name)
+	if name != filepath.Base(name) || isReservedDir(name) {
+		return "", "", errors.Errorf("Chart [%s] not found", name)
+	}
+	if !moreio.DirExistsAndIsReadable(chartDir) {
+		return "", "", nil
+	}
+
+	chartPath, err := r.activeChartPath(chartDir)
+	if err != nil || chartPath == "" || chartPath == chartDir {
+		return "", "", err
+	}
+
+	archivePath := signedArchivePath(chartDir, filepath.Base(chartPath))
+	exists, err := moreio.FileExists(archivePath + provenanceExtension)
+	if err != nil || !exists {
+		return "", "", err
+	}
+	return archivePath, archivePath + provenanceExtension, nil
+}
+
+// signedArchivePath is where the archive version of the chart in chartDir was uploaded as is kept
+func signedArchivePath(chartDir string, version string) string {
+	name := filepath.Base(chartDir)
+	return filepath.Join(chartDir, signedDir, version, fmt.Sprintf("%s-%s.tgz", name, version))
+}
+
+// activeChartPath returns the directory the catalog should load for the chart in chartDir,
+// or "" when chartDir doesn't contain a chart.
+func (r *repository) activeChartPath(chartDir string) (string, error) {
+	unversioned, err := moreio.FileExists(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+	if unversioned {
+		return chartDir, nil
+	}
+
+	active, err := r.activeVersion(chartDir)
+	if err != nil {
+		return "", err
+	}
+	if active == "" {
+		return "", nil
+	}
+	return filepath.Join(chartDir, active), nil
+}
+
+func (r *repository) activeVersion(chartDir string) (string, error) {
+	activeBytes, err := ioutil.ReadFile(filepath.Join(chartDir, activeVersionFile))
+	if err == nil {
+		return strings.TrimSpace(string(activeBytes)), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	versions, err := r.listVersions(chartDir)
+	if err != nil {
+		return "", err
+	}
+	if len(versions) == 0 {
+		return "", nil
+	}
+	return versions[0], nil
+}
+
+// listVersions returns the stored versions of the chart in chartDir, newest first
+func (r *repository) listVersions(chartDir string) ([]string, error) {
+	files, err := ioutil.ReadDir(chartDir)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := []string{}
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+		exists, err := moreio.FileExists(filepath.Join(chartDir, file.Name(), "Chart.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			versions = append(versions, file.Name())
+		}
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versionLess(versions[j], versions[i])
+	})
+	return versions, nil
+}
+
+func versionLess(a string, b string) bool {
+	aVersion, aErr := semver.NewVersion(a)
+	bVersion, bErr := semver.NewVersion(b)
+	if aErr != nil || bErr != nil {
+		return a < b
+	}
+	return aVersion.LessThan(bVersion)
+}
+
+// migrateUnversioned moves a chart stored directly in chartDir into chartDir/<version>, returning
+// the version, or "" when the chart is already versioned
+func (r *repository) migrateUnversioned(chartDir string, workspace string) (string, error) {
+	unversioned, err := moreio.FileExists(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil || !unversioned {
+		return "", err
+	}
+
+	metadata, err := chartutil.LoadChartfile(filepath.Join(chartDir, "Chart.yaml"))
+	if err != nil {
+		return "", err
+	}
+
+	stagedPath := filepath.Join(workspace, "unversioned-"+filepath.Base(chartDir))
+	err = os.Rename(chartDir, stagedPath)
+	if err != nil {
+		return "", err
+	}
+	err = os.Mkdir(chartDir, 0700)
+	if err != nil {
+		os.Rename(stagedPath, chartDir)
+		return "", err
+	}
+
+	r.logger.Info(fmt.Sprintf("Migrating [%s] to versioned layout as version [%s]", chartDir, metadata.Version))
+	err = os.Rename(stagedPath, filepath.Join(chartDir, metadata.Version))
+	if err != nil {
+		os.Remove(chartDir)
+		os.Rename(stagedPath, chartDir)
+		return "", err
+	}
+	return metadata.Version, nil
+}
+
+// restoreUnversioned undoes migrateUnversioned, moving chartDir/<version> back to chartDir and
+// dropping whatever else the versioned layout added
+func (r *repository) restoreUnversioned(chartDir string, version string) error {
+	err := os.MkdirAll(filepath.Join(r.helmChartDir, stagingDir), 0700)
+	if err != nil {
+		return err
+	}
+	stagedDir, err := ioutil.TempDir(filepath.Join(r.helmChartDir, stagingDir), filepath.Base(chartDir)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stagedDir)
+
+	stagedPath := filepath.Join(stagedDir, version)
+	err = os.Rename(filepath.Join(chartDir, version), stagedPath)
+	if err != nil {
+		return err
+	}
+	err = os.RemoveAll(chartDir)
+	if err != nil {
+		return err
+	}
+	return os.Rename(stagedPath, chartDir)
+}
+
+// pruneVersions removes all but the newest retainedVersions versions, never removing the active one
+func (r *repository) pruneVersions(chartDir string) error {
+	if r.retainedVersions < 1 {
+		return nil
+	}
+
+	versions, err := r.listVersions(chartDir)
+	if err != nil {
+		return err
+	}
+	active, err := r.activeVersion(chartDir)
+	if err != nil {
+		return err
+	}
+
+	for i, version := range versions {
+		if i < r.retainedVersions || version == active {
+			continue
+		}
+		r.logger.Info(fmt.Sprintf("Removing version [%s] of [%s]", version, chartDir))
+		err = os.RemoveAll(filepath.Join(chartDir, version))
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(filepath.Join(chartDir, signedDir, version))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sirupsen/logrus"
+)
+
+type Watcher interface {
+	Start() error
+	Stop()
+}
+
+type watcher struct {
+	repository   Repository
+	helmChartDir string
+	debounce     time.Duration
+	logger       *logrus.Logger
+
+	fsWatcher *fsnotify.Watcher
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewWatcher reloads the repository whenever something changes in helmChartDir. Bursts of
+// changes (like a chart being copied into place) are collapsed into a single reload.
+func NewWatcher(repository Repository, helmChartDir string, debounce time.Duration, logger *logrus.Logger) Watcher {
+	return &watcher{
+		repository:   repository,
+		helmChartDir: helmChartDir,
+		debounce:     debounce,
+		logger:       logger,
+		done:         make(chan struct{}),
+	}
+}
+
+func (w *watcher) Start() error {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	w.fsWatcher = fsWatcher
+
+	err = w.addDirs(w.helmChartDir)
+	if err != nil {
+		fsWatcher.Close()
+		return err
+	}
+
+	go w.run()
+
+	w.logger.Info(fmt.Sprintf("Watching [%s] for chart changes", w.helmChartDir))
+	return nil
+}
+
+func (w *watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+		if w.fsWatcher != nil {
+			w.fsWatcher.Close()
+		}
+	})
+}
+
+func (w *watcher) run() {
+	var timer *time.Timer
+	var fire <-chan time.Time
+
+	for {
+		select {
+		case <-w.done:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case event, ok := <-w.fsWatcher.Events:
+			if !ok {
+				return
+			}
+			if w.ignored(event.Name) {
+				continue
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				info, err := os.Stat(event.Name)
+				if err == nil && info.IsDir() {
+					err = w.addDirs(event.Name)
+					if err != nil {
+						w.logger.WithError(err).Error(fmt.Sprintf("Unable to watch [%s]", event.Name))
+					}
+				}
+			}
+
+			if timer == nil {
+				timer = time.NewTimer(w.debounce)
+			} else {
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+				timer.Reset(w.debounce)
+			}
+			fire = timer.C
+		case <-fire:
+			fire = nil
+			w.logger.Info("Chart directory changed, reloading charts")
+			err := w.repository.ClearCache()
+			if err != nil {
+				w.logger.WithError(err).Error("Reloading charts after chart directory change failed, retrying")
+				timer.Reset(w.debounce)
+				fire = timer.C
+			}
+		case err, ok := <-w.fsWatcher.Errors:
+			if !ok {
+				return
+			}
+			w.logger.WithError(err).Error("Error watching chart directory")
+		}
+	}
+}
+
+func (w *watcher) addDirs(root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if w.ignored(path) || (path != root && info.Name() == "images") {
+			return filepath.SkipDir
+		}
+		return w.fsWatcher.Add(path)
+	})
+}
+
+// ignored reports whether path is in one of the repository's working directories, or in a git
+// checkout's metadata, neither of which hold chart changes
+func (w *watcher) ignored(path string) bool {
+	rel, err := filepath.Rel(w.helmChartDir, path)
+	if err != nil {
+		return false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if isReservedDir(parts[0]) {
+		return true
+	}
+	for _, part := range parts {
+		if part == ".git" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// kibosh
+//
+// Copyright (c) 2017-Present Pivotal Software, Inc. All Rights Reserved.
+//
+// This program and the accompanying materials are made available under the terms of the under the Apache License,
+// Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may
+// obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software distributed under the
+// License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either
+// express or implied. See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cf-platform-eng/kibosh/pkg/repository"
+	"github.com/cf-platform-eng/kibosh/pkg/repository/repositoryfakes"
+	"github.com/cf-platform-eng/kibosh/pkg/test"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+var _ = Describe("Watcher", func() {
+	var repoPath string
+	var fakeRepo *repositoryfakes.FakeRepository
+	var watcher repository.Watcher
+	var logger *logrus.Logger
+
+	BeforeEach(func() {
+		var err error
+		repoPath, err = ioutil.TempDir("", "chart-")
+		Expect(err).To(BeNil())
+
+		logger = logrus.New()
+		fakeRepo = &repositoryfakes.FakeRepository{}
+		watcher = repository.NewWatcher(fakeRepo, repoPath, 50*time.Millisecond, logger)
+		Expect(watcher.Start()).To(BeNil())
+	})
+
+	AfterEach(func() {
+		watcher.Stop()
+		os.RemoveAll(repoPath)
+	})
+
+	It("reloads once after a burst of changes", func() {
+		chartDir := filepath.Join(repoPath, "spacebears")
+		err := os.Mkdir(chartDir, 0700)
+		Expect(err).To(BeNil())
+		err = test.DefaultChart().WriteChart(chartDir)
+		Expect(err).To(BeNil())
+
+		Eventually(fakeRepo.ClearCacheCallCount).Should(Equal(1))
+		Consistently(fakeRepo.ClearCacheCallCount, 200*time.Millisecond).Should(Equal(1))
+	})
+
+	It("reloads again when a reload fails", func() {
+		fakeRepo.ClearCacheReturnsOnCall(0, errors.New("chart directory unreadable"))
+
+		err := os.Mkdir(filepath.Join(repoPath, "spacebears"), 0700)
+		Expect(err).To(BeNil())
+
+		Eventually(fakeRepo.ClearCacheCallCount).Should(Equal(2))
+		Consistently(fakeRepo.ClearCacheCallCount, 200*time.Millisecond).Should(Equal(2))
+	})
+
+	It("watches newly created chart directories", func() {
+		chartDir := filepath.Join(repoPath, "spacebears")
+		err := os.Mkdir(chartDir, 0700)
+		Expect(err).To(BeNil())
+		Eventually(fakeRepo.ClearCacheCallCount).Should(Equal(1))
+
+		err = ioutil.WriteFile(filepath.Join(chartDir, "values.yaml"), []byte("count: 2"), 0666)
+		Expect(err).To(BeNil())
+		Eventually(fakeRepo.ClearCacheCallCount).Should(Equal(2))
+	})
+
+	It("ignores the upload workspace", func() {
+		err := os.Mkdir(filepath.Join(repoPath, "workspace_tmp"), 0700)
+		Expect(err).To(BeNil())
+
+		Consistently(fakeRepo.ClearCacheCallCount, 200*time.Millisecond).Should(Equal(0))
+	})
+
+	It("ignores git metadata", func() {
+		err := os.MkdirAll(filepath.Join(repoPath, ".git", "objects"), 0700)
+		Expect(err).To(BeNil())
+		err = ioutil.WriteFile(filepath.Join(repoPath, ".git", "FETCH_HEAD"), []byte("abc123"), 0666)
+		Expect(err).To(BeNil())
+
+		Consistently(fakeRepo.ClearCacheCallCount, 200*time.Millisecond).Should(Equal(0))
+	})
+})
+
+var _ = Describe("Sync poller", func() {
+	It("reloads every interval until stopped", func() {
+		fakeRepo := &repositoryfakes.FakeRepository{}
+		poller := repository.NewSyncPoller(fakeRepo, 20*time.Millisecond, logrus.New())
+		Expect(poller.Start()).To(BeNil())
+
+		Eventually(fakeRepo.ClearCacheCallCount).Should(BeNumerically(">=", 2))
+
+		poller.Stop()
+		calls := fakeRepo.ClearCacheCallCount()
+		Consistently(fakeRepo.ClearCacheCallCount, 100*time.Millisecond).Should(BeNumerically("<=", calls+1))
+	})
+
+	It("requires a positive interval", func() {
+		poller := repository.NewSyncPoller(&repositoryfakes.FakeRepository{}, 0, logrus.New())
+		Expect(poller.Start()).NotTo(BeNil())
+	})
+})
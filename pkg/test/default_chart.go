@@ -20,6 +20,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/cf-platform-eng/kibosh/pkg/config"
 	"github.com/cf-platform-eng/kibosh/pkg/helm"
 )
 
@@ -204,5 +205,5 @@ func DefaultMyChart() (*helm.MyChart, error) {
 		return nil, err
 	}
 
-	return helm.NewChart(chartPath, "docker.example.com", nil)
+	return helm.NewChart(chartPath, &config.RegistryConfig{Server: "docker.example.com"}, nil)
 }